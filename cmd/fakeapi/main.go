@@ -0,0 +1,86 @@
+// Package main serves in-memory fakes of provider APIs for local development without
+// network access, e.g. run 'fakeapi todoist' and start todoapi with
+// TODOIST_BASE_URL=http://localhost:8081/ and any TODOIST_API_TOKEN.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/jo-hoe/todoapi/todoclient/microsoft/graphfake"
+	"github.com/jo-hoe/todoapi/todoclient/todoist/todoistfake"
+)
+
+const usage = `Usage: fakeapi <provider> [flags]
+
+Providers:
+  todoist     Todoist REST API v2
+  microsoft   Microsoft Graph To Do API and v2 token endpoint
+
+Run 'fakeapi <provider> -h' for the flags of a provider.`
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	flags := flag.NewFlagSet(os.Args[1], flag.ExitOnError)
+	addr := flags.String("addr", "localhost:8081", "address to listen on")
+	latency := flags.Duration("latency", 0, "delay of every response")
+	rateLimit := flags.Int("rate-limit", 0, "maximum requests per rate window, 0 disables rate limiting")
+	rateWindow := flags.Duration("rate-window", time.Minute, "window of the rate limit")
+
+	var handler http.Handler
+	switch os.Args[1] {
+	case "todoist":
+		token := flags.String("token", "", "required bearer token, empty accepts all requests")
+		_ = flags.Parse(os.Args[2:])
+		fake := todoistfake.New(*token)
+		fake.SetLatency(*latency)
+		fake.SetRateLimit(*rateLimit, *rateWindow)
+		handler = fake
+	case "microsoft":
+		clientID := flags.String("client-id", "", "client ID required by the token endpoint, empty disables authentication")
+		clientSecret := flags.String("client-secret", "", "client secret required by the token endpoint")
+		pageSize := flags.Int("page-size", 10, "number of items per page")
+		_ = flags.Parse(os.Args[2:])
+		fake := graphfake.New()
+		fake.SetLatency(*latency)
+		fake.SetThrottle(*rateLimit, *rateWindow)
+		fake.SetPageSize(*pageSize)
+		if *clientID != "" {
+			log.Printf("Refresh token: %s", fake.EnableAuth(*clientID, *clientSecret))
+		}
+		log.Printf("To Do API at http://%s%s, token endpoint at http://%s%s", *addr, graphfake.TodoPath, *addr, graphfake.TokenPath)
+		handler = fake
+	default:
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	server := &http.Server{Addr: *addr, Handler: handler}
+	go func() {
+		log.Printf("Serving fake %s API on http://%s/", os.Args[1], *addr)
+		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			log.Fatalf("Server failed to start: %v", err)
+		}
+	}()
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	<-ctx.Done()
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		log.Printf("Server forced to shutdown: %v", err)
+	}
+}
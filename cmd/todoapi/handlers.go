@@ -0,0 +1,132 @@
+package main
+
+import (
+	"encoding/json"
+	stderrors "errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/jo-hoe/todoapi/pkg/errors"
+	"github.com/jo-hoe/todoapi/todoclient"
+	"github.com/jo-hoe/todoapi/todoclient/ical"
+	"github.com/jo-hoe/todoapi/todoclient/search"
+)
+
+// searchHandler finds tasks across all providers, e.g. GET /search?q=name:report&limit=10
+func searchHandler(searcher *search.Searcher) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		limit := 0
+		if value := r.URL.Query().Get("limit"); value != "" {
+			parsed, err := strconv.Atoi(value)
+			if err != nil || parsed < 0 {
+				writeError(w, errors.NewValidationError("limit", "limit must be a non-negative number"))
+				return
+			}
+			limit = parsed
+		}
+
+		results, err := searcher.Search(r.Context(), r.URL.Query().Get("q"), limit)
+		var providerErrors search.ProviderErrors
+		if stderrors.As(err, &providerErrors) {
+			// results of the remaining providers are still served
+			log.Printf("search failed for providers: %v", providerErrors)
+			w.Header().Set("X-Search-Failed-Providers", strings.Join(providerErrors.Providers(), ","))
+		} else if err != nil {
+			writeError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, results)
+	}
+}
+
+// calendarHandler serves the tasks of a parent as subscribable iCalendar feed,
+// e.g. GET /calendars/todoist/2203306141.ics or GET /calendars/github/owner/repo.ics
+func calendarHandler(clients map[string]todoclient.ToDoClient) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		client, ok := clients[r.PathValue("provider")]
+		if !ok {
+			writeError(w, errors.NewAPIError("PROVIDER_NOT_FOUND", "provider is not configured", errors.ErrNotFound))
+			return
+		}
+		parentID, ok := strings.CutSuffix(r.PathValue("file"), ".ics")
+		if !ok || parentID == "" {
+			writeError(w, errors.NewAPIError("CALENDAR_NOT_FOUND", "calendar feeds end with .ics", errors.ErrNotFound))
+			return
+		}
+
+		parents, err := client.GetAllParents(r.Context())
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		collection := ical.Collection{Provider: r.PathValue("provider")}
+		for _, parent := range parents {
+			if parent.ID == parentID {
+				collection.Parent = parent
+			}
+		}
+		if collection.Parent.ID == "" {
+			writeError(w, errors.NewAPIError("PARENT_NOT_FOUND", "parent not found", errors.ErrNotFound))
+			return
+		}
+		if collection.Tasks, err = client.GetChildrenTasks(r.Context(), parentID, nil); err != nil {
+			writeError(w, err)
+			return
+		}
+
+		b, err := ical.Marshal(collection)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+		w.WriteHeader(http.StatusOK)
+		if _, err := w.Write(b); err != nil {
+			log.Printf("failed to write response: %v", err)
+		}
+	}
+}
+
+// capabilitiesHandler reports the features supported by a provider,
+// e.g. GET /providers/github/capabilities
+func capabilitiesHandler(clients map[string]todoclient.ToDoClient) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		client, ok := clients[r.PathValue("provider")]
+		if !ok {
+			writeError(w, errors.NewAPIError("PROVIDER_NOT_FOUND", "provider is not configured", errors.ErrNotFound))
+			return
+		}
+		writeJSON(w, http.StatusOK, todoclient.CapabilitiesOf(client))
+	}
+}
+
+// writeJSON writes the value as JSON response with the given status
+func writeJSON(w http.ResponseWriter, status int, value interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(value); err != nil {
+		log.Printf("failed to write response: %v", err)
+	}
+}
+
+// writeError maps an error to an HTTP status and writes it as JSON response
+func writeError(w http.ResponseWriter, err error) {
+	status := http.StatusBadGateway
+	var validationErr *errors.ValidationError
+	var taskValidationErr *todoclient.ValidationError
+	switch {
+	case stderrors.As(err, &validationErr), stderrors.As(err, &taskValidationErr):
+		status = http.StatusBadRequest
+	case stderrors.Is(err, errors.ErrNotFound):
+		status = http.StatusNotFound
+	case stderrors.Is(err, errors.ErrConflict):
+		status = http.StatusConflict
+	case stderrors.Is(err, errors.ErrNotSupported):
+		status = http.StatusNotImplemented
+	default:
+		log.Printf("request failed: %v", err)
+	}
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"context"
+	stderrors "errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/jo-hoe/todoapi/config"
+	"github.com/jo-hoe/todoapi/internal/providers"
+	"github.com/jo-hoe/todoapi/internal/testutil"
+	"github.com/jo-hoe/todoapi/pkg/errors"
+	"github.com/jo-hoe/todoapi/todoclient"
+	"github.com/jo-hoe/todoapi/todoclient/search"
+)
+
+// pathClient has parents with IDs containing slashes like GitHub repositories and CalDAV collections
+type pathClient struct {
+	*testutil.MockToDoClient
+}
+
+func (c pathClient) GetAllParents(ctx context.Context) ([]todoclient.ToDoParent, error) {
+	return []todoclient.ToDoParent{
+		{ID: "owner/repo", Name: "repo"},
+		{ID: "/dav/calendars/user/tasks/", Name: "tasks"},
+	}, nil
+}
+
+func (c pathClient) GetChildrenTasks(ctx context.Context, parentID string, query *todoclient.TaskQuery) ([]todoclient.ToDoTask, error) {
+	return []todoclient.ToDoTask{{ID: "1", Name: "task of " + parentID}}, nil
+}
+
+func TestCalendarHandler_ParentIDWithSlashes(t *testing.T) {
+	clients := map[string]todoclient.ToDoClient{"github": pathClient{testutil.NewMockToDoClient()}}
+	router := newRouter(clients, search.NewSearcher(clients))
+
+	requests := map[string]string{
+		"/calendars/github/owner/repo.ics":                           "task of owner/repo",
+		"/calendars/github/owner%2Frepo.ics":                         "task of owner/repo",
+		"/calendars/github/%2Fdav%2Fcalendars%2Fuser%2Ftasks%2F.ics": "task of /dav/calendars/user/tasks/",
+	}
+	for url, expected := range requests {
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, url, nil))
+		if recorder.Code != http.StatusOK {
+			t.Errorf("%s: expected status %d but found %d", url, http.StatusOK, recorder.Code)
+		}
+		if !strings.Contains(recorder.Body.String(), expected) {
+			t.Errorf("%s: expected feed with '%s' but found '%s'", url, expected, recorder.Body.String())
+		}
+	}
+
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/calendars/github/owner/missing.ics", nil))
+	if recorder.Code != http.StatusNotFound {
+		t.Errorf("expected status %d for unknown parent but found %d", http.StatusNotFound, recorder.Code)
+	}
+}
+
+func TestSearchHandler_ProviderFailure(t *testing.T) {
+	healthy, failing := testutil.NewMockToDoClient(), testutil.NewMockToDoClient()
+	parent, _ := healthy.CreateParent(context.Background(), "errands")
+	_, _ = healthy.CreateTask(context.Background(), parent.ID, todoclient.ToDoTask{Name: "Buy bike lights"})
+	failing.FailNext(todoclient.OpGetAllParents, stderrors.New("service unavailable"), 1)
+	clients := map[string]todoclient.ToDoClient{"local": healthy, "todoist": failing}
+	router := newRouter(clients, search.NewSearcher(clients))
+
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/search?q=bike", nil))
+
+	if recorder.Code != http.StatusOK {
+		t.Errorf("expected status %d but found %d", http.StatusOK, recorder.Code)
+	}
+	if header := recorder.Header().Get("X-Search-Failed-Providers"); header != "todoist" {
+		t.Errorf("expected failed provider 'todoist' but found '%s'", header)
+	}
+	if !strings.Contains(recorder.Body.String(), "Buy bike lights") {
+		t.Errorf("expected result of the healthy provider but found '%s'", recorder.Body.String())
+	}
+}
+
+func TestWriteError_Validation(t *testing.T) {
+	clients, err := providers.FromConfig(&config.Config{Local: config.LocalConfig{Dir: t.TempDir()}})
+	if err != nil {
+		t.Fatalf("error was not nil but '%v'", err)
+	}
+	// rejected by the validation interceptor of the configured client
+	_, interceptorErr := clients[providers.Local].CreateParent(context.Background(), " ")
+
+	errs := map[string]error{
+		"interceptor": interceptorErr,
+		"handler":     errors.NewValidationError("limit", "limit must be a non-negative number"),
+	}
+	for name, err := range errs {
+		recorder := httptest.NewRecorder()
+		writeError(recorder, err)
+		if recorder.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected status %d but found %d", name, http.StatusBadRequest, recorder.Code)
+		}
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"log/slog"
 	"net/http"
 	"os"
 	"os/signal"
@@ -12,6 +13,9 @@ import (
 	"time"
 
 	"github.com/jo-hoe/todoapi/config"
+	"github.com/jo-hoe/todoapi/internal/providers"
+	"github.com/jo-hoe/todoapi/todoclient"
+	"github.com/jo-hoe/todoapi/todoclient/search"
 )
 
 func main() {
@@ -61,6 +65,27 @@ func main() {
 }
 
 func createHandler(cfg *config.Config) http.Handler {
+	clients, err := providers.FromConfig(cfg)
+	if err != nil {
+		log.Fatalf("Failed to create providers: %v", err)
+	}
+	// logging sits below the cache, so only calls reaching the providers are logged
+	clients = providers.WithMiddleware(clients, todoclient.Intercept(todoclient.LoggingInterceptor(slog.Default())))
+	clients, err = providers.WithCache(cfg.Cache, clients)
+	if err != nil {
+		log.Fatalf("Failed to create cache: %v", err)
+	}
+	log.Printf("Configured %d todo provider(s)", len(clients))
+
+	searcher := search.NewSearcher(clients)
+	if cfg.Search.UseIndex {
+		searcher = search.NewIndexedSearcher(clients, cfg.Search.RefreshInterval)
+	}
+	return newRouter(clients, searcher)
+}
+
+// newRouter registers the endpoints of the API
+func newRouter(clients map[string]todoclient.ToDoClient, searcher *search.Searcher) *http.ServeMux {
 	mux := http.NewServeMux()
 
 	// Health check endpoint
@@ -70,6 +95,15 @@ func createHandler(cfg *config.Config) http.Handler {
 		_, _ = fmt.Fprint(w, `{"status":"ok"}`)
 	})
 
+	// Search endpoint
+	mux.HandleFunc("GET /search", searchHandler(searcher))
+
+	// Calendar feed per parent, parent IDs may contain slashes like GitHub repositories
+	mux.HandleFunc("GET /calendars/{provider}/{file...}", calendarHandler(clients))
+
+	// Features supported per provider
+	mux.HandleFunc("GET /providers/{provider}/capabilities", capabilitiesHandler(clients))
+
 	// Root endpoint
 	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Type", "application/json")
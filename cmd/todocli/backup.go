@@ -0,0 +1,118 @@
+package main
+
+import (
+	"context"
+	stderrors "errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/jo-hoe/todoapi/internal/providers"
+	"github.com/jo-hoe/todoapi/todoclient/mirror"
+)
+
+func runBackup(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("backup", flag.ExitOnError)
+	provider := flags.String("provider", providers.Todoist, "provider to back up")
+	dir := flags.String("dir", "backups", "archive directory")
+	interval := flags.Duration("interval", 0, "take a snapshot every interval instead of once")
+	parse(flags, args)
+
+	source, err := client(*provider)
+	if err != nil {
+		return err
+	}
+	archive, err := mirror.OpenArchive(*dir)
+	if err != nil {
+		return err
+	}
+	m := mirror.New(source, archive)
+
+	if *interval <= 0 {
+		entry, err := m.RunOnce(ctx)
+		if err != nil {
+			return err
+		}
+		printEntry(entry)
+		return nil
+	}
+
+	err = m.Run(ctx, *interval, func(entry mirror.Entry, err error) {
+		if err != nil {
+			log.Printf("Snapshot failed: %v", err)
+			return
+		}
+		printEntry(entry)
+	})
+	if stderrors.Is(err, context.Canceled) {
+		return nil
+	}
+	return err
+}
+
+func runRestore(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("restore", flag.ExitOnError)
+	provider := flags.String("provider", providers.Todoist, "provider to restore into")
+	dir := flags.String("dir", "backups", "archive directory")
+	version := flags.Int("version", 0, "snapshot version to restore, the latest if 0")
+	parse(flags, args)
+
+	target, err := client(*provider)
+	if err != nil {
+		return err
+	}
+	archive, err := mirror.OpenArchive(*dir)
+	if err != nil {
+		return err
+	}
+
+	var snapshot *mirror.Snapshot
+	if *version > 0 {
+		snapshot, err = archive.Load(*version)
+	} else {
+		snapshot, err = archive.Latest()
+	}
+	if err != nil {
+		return err
+	}
+
+	report, err := mirror.Restore(ctx, snapshot, target)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Restored version %d: %d parent(s) created, %d task(s) created, %d updated, %d unchanged\n",
+		snapshot.Version, report.ParentsCreated, report.TasksCreated, report.TasksUpdated, report.TasksUnchanged)
+	return nil
+}
+
+func runVersions(args []string) error {
+	flags := flag.NewFlagSet("versions", flag.ExitOnError)
+	dir := flags.String("dir", "backups", "archive directory")
+	parse(flags, args)
+
+	archive, err := mirror.OpenArchive(*dir)
+	if err != nil {
+		return err
+	}
+	if err := archive.Verify(); err != nil {
+		return err
+	}
+	entries, err := archive.Entries()
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "VERSION\tCREATED\tPARENTS\tTASKS\tCHANGES")
+	for _, entry := range entries {
+		_, _ = fmt.Fprintf(w, "%d\t%s\t%d\t%d\t%d\n", entry.Version, entry.CreatedAt.Format(time.RFC3339), entry.Parents, entry.Tasks, entry.Changes)
+	}
+	return w.Flush()
+}
+
+func printEntry(entry mirror.Entry) {
+	fmt.Printf("Archived version %d: %d parent(s), %d task(s), %d change(s)\n", entry.Version, entry.Parents, entry.Tasks, entry.Changes)
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/jo-hoe/todoapi/internal/providers"
+	"github.com/jo-hoe/todoapi/todoclient/bulk"
+)
+
+func runBulk(ctx context.Context, args []string) error {
+	if len(args) == 0 || (args[0] != "export" && args[0] != "import") {
+		return fmt.Errorf("usage: todocli bulk export|import [flags]")
+	}
+	mode := args[0]
+
+	flags := flag.NewFlagSet("bulk "+mode, flag.ExitOnError)
+	provider := flags.String("provider", providers.Todoist, "provider to read from or write to")
+	format := flags.String("format", string(bulk.CSV), "csv or ndjson")
+	file := flags.String("file", "-", "file to write or read, - for stdout/stdin")
+	columns := flags.String("columns", "", "column mapping like Title=name,Due=due_date, all fields if empty")
+	parentID := flags.String("parent", "", "import: ID of the parent for rows without parent")
+	dryRun := flags.Bool("dry-run", false, "import: only validate and count the changes")
+	parse(flags, args[1:])
+
+	mapping, err := parseMapping(*columns)
+	if err != nil {
+		return err
+	}
+	codec, err := bulk.NewCodec(bulk.Format(*format), mapping)
+	if err != nil {
+		return err
+	}
+	target, err := client(*provider)
+	if err != nil {
+		return err
+	}
+
+	if mode == "export" {
+		var w io.Writer = os.Stdout
+		if *file != "-" {
+			f, err := os.Create(*file)
+			if err != nil {
+				return err
+			}
+			defer func() {
+				_ = f.Close()
+			}()
+			w = f
+		}
+		return bulk.Export(ctx, target, codec, w)
+	}
+
+	var r io.Reader = os.Stdin
+	if *file != "-" {
+		f, err := os.Open(*file)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			_ = f.Close()
+		}()
+		r = f
+	}
+	report, err := bulk.Import(ctx, target, codec, r, bulk.ImportOptions{DefaultParentID: *parentID, DryRun: *dryRun})
+	if err != nil {
+		return err
+	}
+	for _, rowErr := range report.Errors {
+		fmt.Fprintf(os.Stderr, "line %d: %s\n", rowErr.Line, rowErr.Error)
+	}
+	fmt.Printf("%d parent(s) created, %d task(s) created, %d updated, %d failed\n",
+		report.ParentsCreated, report.Created, report.Updated, report.Failed)
+	return nil
+}
+
+// parseMapping reads a mapping like Title=name,Due=due_date
+func parseMapping(value string) (bulk.Mapping, error) {
+	if value == "" {
+		return bulk.DefaultMapping(), nil
+	}
+	mapping := make(bulk.Mapping, 0)
+	for _, pair := range strings.Split(value, ",") {
+		header, field, ok := strings.Cut(pair, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid column '%s', expected header=field", pair)
+		}
+		mapping = append(mapping, bulk.Column{Header: strings.TrimSpace(header), Field: strings.TrimSpace(field)})
+	}
+	return mapping, nil
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/jo-hoe/todoapi/internal/providers"
+	"github.com/jo-hoe/todoapi/todoclient/ical"
+)
+
+func runImportICS(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("import-ics", flag.ExitOnError)
+	provider := flags.String("provider", providers.Todoist, "provider to import into")
+	parentID := flags.String("parent", "", "ID of the parent receiving the tasks")
+	file := flags.String("file", "", "iCalendar file to import")
+	dryRun := flags.Bool("dry-run", false, "only report what would be imported")
+	registryPath := flags.String("registry", "", "file remembering imported UIDs to skip them on later imports")
+	parse(flags, args)
+
+	if *parentID == "" || *file == "" {
+		return fmt.Errorf("-parent and -file are required")
+	}
+	target, err := client(*provider)
+	if err != nil {
+		return err
+	}
+	options := ical.ImportOptions{DryRun: *dryRun}
+	if *registryPath != "" {
+		if options.Registry, err = ical.NewFileRegistry(*registryPath); err != nil {
+			return err
+		}
+	}
+
+	f, err := os.Open(*file)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+
+	report, err := ical.Import(ctx, target, *parentID, f, options)
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "STATUS\tUID\tNAME\tERROR")
+	for _, item := range report.Items {
+		_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", item.Status, item.UID, item.Name, item.Error)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	fmt.Printf("%d created, %d duplicate(s), %d failed\n", report.Created, report.Duplicates, report.Failed)
+	return nil
+}
@@ -0,0 +1,87 @@
+// Package main provides a command line tool for maintenance tasks on the configured todo providers
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/jo-hoe/todoapi/config"
+	"github.com/jo-hoe/todoapi/internal/providers"
+	"github.com/jo-hoe/todoapi/todoclient"
+)
+
+const usage = `Usage: todocli <command> [flags]
+
+Commands:
+  backup      snapshot a provider into a versioned archive, once or on a schedule
+  restore     recreate an archived snapshot in a provider
+  versions    list the snapshots of an archive
+  import-ics  create the VTODO components of an iCalendar file as tasks
+  todotxt     export to or import from a todo.txt file
+  bulk        export to or import from CSV or newline-delimited JSON
+  markdown    export as Markdown checklists or apply an edited checklist
+
+Run 'todocli <command> -h' for the flags of a command.`
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	var err error
+	args := os.Args[2:]
+	switch os.Args[1] {
+	case "backup":
+		err = runBackup(ctx, args)
+	case "restore":
+		err = runRestore(ctx, args)
+	case "versions":
+		err = runVersions(args)
+	case "import-ics":
+		err = runImportICS(ctx, args)
+	case "todotxt":
+		err = runTodoTxt(ctx, args)
+	case "bulk":
+		err = runBulk(ctx, args)
+	case "markdown":
+		err = runMarkdown(ctx, args)
+	default:
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(2)
+	}
+	if err != nil {
+		log.Fatalf("%s failed: %v", os.Args[1], err)
+	}
+}
+
+// client returns the configured client of the named provider
+func client(name string) (todoclient.ToDoClient, error) {
+	cfg, err := config.Load()
+	if err != nil {
+		return nil, err
+	}
+	clients, err := providers.FromConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+	client, ok := clients[name]
+	if !ok {
+		return nil, fmt.Errorf("provider '%s' is not configured", name)
+	}
+	return client, nil
+}
+
+// parse parses the flags of a subcommand and exits on errors
+func parse(flags *flag.FlagSet, args []string) {
+	// ExitOnError never returns an error
+	_ = flags.Parse(args)
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/jo-hoe/todoapi/internal/providers"
+	"github.com/jo-hoe/todoapi/todoclient/markdown"
+)
+
+func runMarkdown(ctx context.Context, args []string) error {
+	if len(args) == 0 || (args[0] != "export" && args[0] != "apply") {
+		return fmt.Errorf("usage: todocli markdown export|apply [flags]")
+	}
+	mode := args[0]
+
+	flags := flag.NewFlagSet("markdown "+mode, flag.ExitOnError)
+	provider := flags.String("provider", providers.Todoist, "provider to read from or write to")
+	file := flags.String("file", "-", "markdown file, - for stdout/stdin")
+	dryRun := flags.Bool("dry-run", false, "apply: only print the changes")
+	parse(flags, args[1:])
+
+	target, err := client(*provider)
+	if err != nil {
+		return err
+	}
+	current, err := markdown.Load(ctx, target)
+	if err != nil {
+		return err
+	}
+
+	if mode == "export" {
+		var w io.Writer = os.Stdout
+		if *file != "-" {
+			f, err := os.Create(*file)
+			if err != nil {
+				return err
+			}
+			defer func() {
+				_ = f.Close()
+			}()
+			w = f
+		}
+		return markdown.Render(w, current)
+	}
+
+	var r io.Reader = os.Stdin
+	if *file != "-" {
+		f, err := os.Open(*file)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			_ = f.Close()
+		}()
+		r = f
+	}
+	edited, err := markdown.Parse(r)
+	if err != nil {
+		return err
+	}
+
+	changes := markdown.Diff(current, edited)
+	for _, change := range changes {
+		fmt.Printf("%s\t%s\t%s\n", change.Kind, change.ParentName, change.Task.Name)
+	}
+	if *dryRun {
+		return nil
+	}
+	report, err := markdown.Apply(ctx, target, changes)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("%d parent(s) created, %d task(s) created, %d updated, %d deleted\n",
+		report.ParentsCreated, report.Created, report.Updated, report.Deleted)
+	return nil
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/jo-hoe/todoapi/internal/providers"
+	"github.com/jo-hoe/todoapi/todoclient/todotxt"
+)
+
+func runTodoTxt(ctx context.Context, args []string) error {
+	if len(args) == 0 || (args[0] != "export" && args[0] != "import") {
+		return fmt.Errorf("usage: todocli todotxt export|import [flags]")
+	}
+	mode := args[0]
+
+	flags := flag.NewFlagSet("todotxt "+mode, flag.ExitOnError)
+	provider := flags.String("provider", providers.Todoist, "provider to read from or write to")
+	file := flags.String("file", "todo.txt", "todo.txt file, - for stdin/stdout")
+	parentID := flags.String("parent", "", "import: ID of the parent for tasks without +project")
+	dryRun := flags.Bool("dry-run", false, "import: only report what would change")
+	parse(flags, args[1:])
+
+	target, err := client(*provider)
+	if err != nil {
+		return err
+	}
+
+	if mode == "export" {
+		var w io.Writer = os.Stdout
+		if *file != "-" {
+			f, err := os.Create(*file)
+			if err != nil {
+				return err
+			}
+			defer func() {
+				_ = f.Close()
+			}()
+			w = f
+		}
+		return todotxt.Export(ctx, target, w)
+	}
+
+	var r io.Reader = os.Stdin
+	if *file != "-" {
+		f, err := os.Open(*file)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			_ = f.Close()
+		}()
+		r = f
+	}
+	report, err := todotxt.Import(ctx, target, r, todotxt.ImportOptions{DefaultParentID: *parentID, DryRun: *dryRun})
+	if err != nil {
+		return err
+	}
+	for _, message := range report.Errors {
+		fmt.Fprintln(os.Stderr, message)
+	}
+	fmt.Printf("%d parent(s) created, %d task(s) created, %d updated, %d unchanged, %d failed\n",
+		report.ParentsCreated, report.Created, report.Updated, report.Unchanged, report.Failed)
+	return nil
+}
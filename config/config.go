@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"os"
 	"strconv"
+	"strings"
 	"time"
 )
 
@@ -13,6 +14,14 @@ type Config struct {
 	Server    ServerConfig
 	Todoist   TodoistConfig
 	Microsoft MicrosoftConfig
+	Local     LocalConfig
+	Embedded  EmbeddedConfig
+	CalDAV    CalDAVConfig
+	Google    GoogleConfig
+	GitHub    GitHubConfig
+	GitLab    GitLabConfig
+	Search    SearchConfig
+	Cache     CacheConfig
 }
 
 // ServerConfig holds server-related configuration
@@ -31,12 +40,69 @@ type TodoistConfig struct {
 
 // MicrosoftConfig holds Microsoft To Do API configuration
 type MicrosoftConfig struct {
-	ClientID     string `json:"client_id"`
+	ClientID     string `json:"client_id"` // OAuth2 client, the provider is disabled if empty
 	ClientSecret string `json:"client_secret"`
 	TenantID     string `json:"tenant_id"`
+	RefreshToken string `json:"refresh_token"`
+	TokenFile    string `json:"token_file"` // File refreshed tokens are persisted to and read from on start
 	BaseURL      string `json:"base_url"`
 }
 
+// LocalConfig holds configuration of the local file system provider
+type LocalConfig struct {
+	Dir string `json:"dir"` // Directory of the data files, the provider is disabled if empty
+}
+
+// EmbeddedConfig holds configuration of the embedded database provider
+type EmbeddedConfig struct {
+	Path string `json:"path"` // Database file, the provider is disabled if empty
+}
+
+// CalDAVConfig holds configuration of a CalDAV server
+type CalDAVConfig struct {
+	URL      string `json:"url"` // Endpoint of the server, the provider is disabled if empty
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+// GoogleConfig holds Google Tasks API configuration
+type GoogleConfig struct {
+	ClientID     string `json:"client_id"` // OAuth2 client, the provider is disabled if empty
+	ClientSecret string `json:"client_secret"`
+	RefreshToken string `json:"refresh_token"`
+	TokenFile    string `json:"token_file"` // File refreshed tokens are persisted to and read from on start
+	BaseURL      string `json:"base_url"`
+}
+
+// GitHubConfig holds GitHub issues configuration
+type GitHubConfig struct {
+	Token   string   `json:"token"` // Personal access token, the provider is disabled if empty
+	Repos   []string `json:"repos"` // Repositories as owner/repo, all repositories of the user if empty
+	BaseURL string   `json:"base_url"`
+}
+
+// GitLabConfig holds GitLab issues configuration
+type GitLabConfig struct {
+	Token    string   `json:"token"`    // Personal access token, the provider is disabled if empty
+	Projects []string `json:"projects"` // Projects as group/project, all projects of the user if empty
+	BaseURL  string   `json:"base_url"`
+}
+
+// SearchConfig holds configuration of the task search
+type SearchConfig struct {
+	UseIndex        bool          `json:"use_index"`
+	RefreshInterval time.Duration `json:"refresh_interval"`
+}
+
+// CacheConfig holds configuration of the provider response cache
+type CacheConfig struct {
+	Enabled    bool          `json:"enabled"`
+	Dir        string        `json:"dir"` // Directory of a file-backed cache, in-memory if empty
+	Size       int           `json:"size"`
+	ParentsTTL time.Duration `json:"parents_ttl"`
+	TasksTTL   time.Duration `json:"tasks_ttl"`
+}
+
 // Load loads configuration from environment variables
 func Load() (*Config, error) {
 	config := &Config{
@@ -54,8 +120,49 @@ func Load() (*Config, error) {
 			ClientID:     getEnv("MS_CLIENT_ID", ""),
 			ClientSecret: getEnv("MS_CLIENT_SECRET", ""),
 			TenantID:     getEnv("MS_TENANT_ID", ""),
+			RefreshToken: getEnv("MS_REFRESH_TOKEN", ""),
+			TokenFile:    getEnv("MS_TOKEN_FILE", ""),
 			BaseURL:      getEnv("MS_BASE_URL", "https://graph.microsoft.com/v1.0/me/todo/"),
 		},
+		Local: LocalConfig{
+			Dir: getEnv("LOCAL_DIR", ""),
+		},
+		Embedded: EmbeddedConfig{
+			Path: getEnv("EMBEDDED_PATH", ""),
+		},
+		CalDAV: CalDAVConfig{
+			URL:      getEnv("CALDAV_URL", ""),
+			Username: getEnv("CALDAV_USERNAME", ""),
+			Password: getEnv("CALDAV_PASSWORD", ""),
+		},
+		Google: GoogleConfig{
+			ClientID:     getEnv("GOOGLE_CLIENT_ID", ""),
+			ClientSecret: getEnv("GOOGLE_CLIENT_SECRET", ""),
+			RefreshToken: getEnv("GOOGLE_REFRESH_TOKEN", ""),
+			TokenFile:    getEnv("GOOGLE_TOKEN_FILE", ""),
+			BaseURL:      getEnv("GOOGLE_BASE_URL", "https://tasks.googleapis.com/tasks/v1/"),
+		},
+		GitHub: GitHubConfig{
+			Token:   getEnv("GITHUB_TOKEN", ""),
+			Repos:   getEnvAsList("GITHUB_REPOS"),
+			BaseURL: getEnv("GITHUB_BASE_URL", "https://api.github.com/"),
+		},
+		GitLab: GitLabConfig{
+			Token:    getEnv("GITLAB_TOKEN", ""),
+			Projects: getEnvAsList("GITLAB_PROJECTS"),
+			BaseURL:  getEnv("GITLAB_BASE_URL", "https://gitlab.com/api/v4/"),
+		},
+		Search: SearchConfig{
+			UseIndex:        getEnvAsBool("SEARCH_USE_INDEX", true),
+			RefreshInterval: getEnvAsDuration("SEARCH_REFRESH_INTERVAL", 5*time.Minute),
+		},
+		Cache: CacheConfig{
+			Enabled:    getEnvAsBool("CACHE_ENABLED", true),
+			Dir:        getEnv("CACHE_DIR", ""),
+			Size:       getEnvAsInt("CACHE_SIZE", 1000),
+			ParentsTTL: getEnvAsDuration("CACHE_PARENTS_TTL", 10*time.Minute),
+			TasksTTL:   getEnvAsDuration("CACHE_TASKS_TTL", time.Minute),
+		},
 	}
 
 	if err := config.Validate(); err != nil {
@@ -82,6 +189,17 @@ func getEnv(key, defaultValue string) string {
 	return defaultValue
 }
 
+// getEnvAsList splits a comma-separated value, skipping empty entries
+func getEnvAsList(key string) []string {
+	result := make([]string, 0)
+	for _, value := range strings.Split(os.Getenv(key), ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			result = append(result, value)
+		}
+	}
+	return result
+}
+
 func getEnvAsInt(key string, defaultValue int) int {
 	if value := os.Getenv(key); value != "" {
 		if intValue, err := strconv.Atoi(value); err == nil {
@@ -91,6 +209,15 @@ func getEnvAsInt(key string, defaultValue int) int {
 	return defaultValue
 }
 
+func getEnvAsBool(key string, defaultValue bool) bool {
+	if value := os.Getenv(key); value != "" {
+		if boolValue, err := strconv.ParseBool(value); err == nil {
+			return boolValue
+		}
+	}
+	return defaultValue
+}
+
 func getEnvAsDuration(key string, defaultValue time.Duration) time.Duration {
 	if value := os.Getenv(key); value != "" {
 		if duration, err := time.ParseDuration(value); err == nil {
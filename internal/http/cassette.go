@@ -0,0 +1,404 @@
+package http
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// RecordCassettesEnv is the environment variable which switches cassette tests to recording
+const RecordCassettesEnv = "RECORD_CASSETTES"
+
+// scrubbedValue replaces secrets in cassettes
+const scrubbedValue = "REDACTED"
+
+// CassetteMode selects whether a CassetteTransport records or replays interactions
+type CassetteMode int
+
+const (
+	// ModeReplay serves responses from the cassette file without network access
+	ModeReplay CassetteMode = iota
+	// ModeRecord forwards requests and writes the interactions to the cassette file on Save
+	ModeRecord
+)
+
+// CassetteModeFromEnv returns ModeRecord if RECORD_CASSETTES is set to "true"
+func CassetteModeFromEnv() CassetteMode {
+	if os.Getenv(RecordCassettesEnv) == "true" {
+		return ModeRecord
+	}
+	return ModeReplay
+}
+
+// Cassette holds the recorded interactions of a test
+type Cassette struct {
+	Interactions []Interaction `json:"interactions"`
+}
+
+// Interaction is a recorded request and its response
+type Interaction struct {
+	Request  RecordedRequest  `json:"request"`
+	Response RecordedResponse `json:"response"`
+}
+
+// RecordedRequest is the part of a request used to match it during replay
+type RecordedRequest struct {
+	Method  string       `json:"method"`
+	URL     string       `json:"url"`
+	Headers http.Header  `json:"headers,omitempty"`
+	Body    CassetteBody `json:"body,omitempty"`
+}
+
+// RecordedResponse is a response as served during replay
+type RecordedResponse struct {
+	Status  int          `json:"status"`
+	Headers http.Header  `json:"headers,omitempty"`
+	Body    CassetteBody `json:"body,omitempty"`
+}
+
+// CassetteBody is a message body. JSON objects and arrays are stored as JSON to keep
+// cassettes readable, other bodies as strings.
+type CassetteBody string
+
+// MarshalJSON implements json.Marshaler
+func (b CassetteBody) MarshalJSON() ([]byte, error) {
+	trimmed := bytes.TrimSpace([]byte(b))
+	if len(trimmed) > 0 && (trimmed[0] == '{' || trimmed[0] == '[') && json.Valid(trimmed) {
+		return trimmed, nil
+	}
+	return json.Marshal(string(b))
+}
+
+// UnmarshalJSON implements json.Unmarshaler
+func (b *CassetteBody) UnmarshalJSON(data []byte) error {
+	if len(data) > 0 && data[0] == '"' {
+		var value string
+		if err := json.Unmarshal(data, &value); err != nil {
+			return err
+		}
+		*b = CassetteBody(value)
+		return nil
+	}
+	var compacted bytes.Buffer
+	if err := json.Compact(&compacted, data); err != nil {
+		return err
+	}
+	*b = CassetteBody(compacted.String())
+	return nil
+}
+
+// Scrubber removes secrets from an interaction before it is saved or matched
+type Scrubber func(interaction *Interaction)
+
+// ScrubHeaders replaces the values of the request and response headers
+func ScrubHeaders(names ...string) Scrubber {
+	return func(interaction *Interaction) {
+		for _, name := range names {
+			for _, headers := range []http.Header{interaction.Request.Headers, interaction.Response.Headers} {
+				if headers.Get(name) != "" {
+					headers.Set(name, scrubbedValue)
+				}
+			}
+		}
+	}
+}
+
+// ScrubFields replaces the values of the fields in URL query parameters, form bodies
+// and JSON bodies at any depth
+func ScrubFields(names ...string) Scrubber {
+	fields := make(map[string]bool, len(names))
+	for _, name := range names {
+		fields[name] = true
+	}
+	return func(interaction *Interaction) {
+		if parsed, err := url.Parse(interaction.Request.URL); err == nil && parsed.RawQuery != "" {
+			if query, changed := scrubValues(parsed.Query(), fields); changed {
+				parsed.RawQuery = query.Encode()
+				interaction.Request.URL = parsed.String()
+			}
+		}
+		interaction.Request.Body = scrubBody(interaction.Request.Body, fields)
+		interaction.Response.Body = scrubBody(interaction.Response.Body, fields)
+	}
+}
+
+// ScrubString replaces all occurrences of the value in URLs, headers and bodies,
+// e.g. an account email address
+func ScrubString(value, replacement string) Scrubber {
+	if value == "" {
+		return func(interaction *Interaction) {}
+	}
+	return ScrubRegexp(regexp.MustCompile(regexp.QuoteMeta(value)), replacement)
+}
+
+// ScrubRegexp replaces all matches of the pattern in URLs, headers and bodies
+func ScrubRegexp(pattern *regexp.Regexp, replacement string) Scrubber {
+	return func(interaction *Interaction) {
+		interaction.Request.URL = pattern.ReplaceAllString(interaction.Request.URL, replacement)
+		interaction.Request.Body = CassetteBody(pattern.ReplaceAllString(string(interaction.Request.Body), replacement))
+		interaction.Response.Body = CassetteBody(pattern.ReplaceAllString(string(interaction.Response.Body), replacement))
+		for _, headers := range []http.Header{interaction.Request.Headers, interaction.Response.Headers} {
+			for _, values := range headers {
+				for i := range values {
+					values[i] = pattern.ReplaceAllString(values[i], replacement)
+				}
+			}
+		}
+	}
+}
+
+// DefaultScrubbers removes credentials and tokens used by the providers of this module.
+// Account details such as email addresses need to be scrubbed with ScrubString.
+func DefaultScrubbers() []Scrubber {
+	return []Scrubber{
+		ScrubHeaders("Authorization", "Cookie", "Set-Cookie"),
+		ScrubFields("access_token", "refresh_token", "id_token", "client_secret"),
+	}
+}
+
+func scrubValues(values url.Values, fields map[string]bool) (url.Values, bool) {
+	changed := false
+	for key := range values {
+		if fields[key] {
+			values.Set(key, scrubbedValue)
+			changed = true
+		}
+	}
+	return values, changed
+}
+
+func scrubBody(body CassetteBody, fields map[string]bool) CassetteBody {
+	if body == "" {
+		return body
+	}
+	var data interface{}
+	if err := json.Unmarshal([]byte(body), &data); err == nil {
+		if scrubJSON(data, fields) {
+			if scrubbed, err := json.Marshal(data); err == nil {
+				return CassetteBody(scrubbed)
+			}
+		}
+		return body
+	}
+	if values, err := url.ParseQuery(string(body)); err == nil {
+		if scrubbed, changed := scrubValues(values, fields); changed {
+			return CassetteBody(scrubbed.Encode())
+		}
+	}
+	return body
+}
+
+func scrubJSON(data interface{}, fields map[string]bool) bool {
+	changed := false
+	switch value := data.(type) {
+	case map[string]interface{}:
+		for key, child := range value {
+			if _, isString := child.(string); isString && fields[key] {
+				value[key] = scrubbedValue
+				changed = true
+				continue
+			}
+			changed = scrubJSON(child, fields) || changed
+		}
+	case []interface{}:
+		for _, child := range value {
+			changed = scrubJSON(child, fields) || changed
+		}
+	}
+	return changed
+}
+
+// CassetteTransport is a RoundTripper recording interactions to a cassette file or
+// replaying them from it. Replayed requests are matched in order by method, URL and
+// body after scrubbing, each interaction is served once.
+type CassetteTransport struct {
+	Transport http.RoundTripper
+
+	mode      CassetteMode
+	path      string
+	scrubbers []Scrubber
+	mu        sync.Mutex
+	cassette  Cassette
+	used      []bool
+}
+
+// NewCassetteTransport creates a transport for the cassette file. The transport is only
+// used when recording and defaults to http.DefaultTransport. Without scrubbers,
+// DefaultScrubbers are applied.
+func NewCassetteTransport(path string, mode CassetteMode, transport http.RoundTripper, scrubbers ...Scrubber) (*CassetteTransport, error) {
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	if len(scrubbers) == 0 {
+		scrubbers = DefaultScrubbers()
+	}
+	t := &CassetteTransport{
+		Transport: transport,
+		mode:      mode,
+		path:      path,
+		scrubbers: scrubbers,
+	}
+	if mode == ModeReplay {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("could not read cassette: %w", err)
+		}
+		if err := json.Unmarshal(data, &t.cassette); err != nil {
+			return nil, fmt.Errorf("could not decode cassette '%s': %w", path, err)
+		}
+		t.used = make([]bool, len(t.cassette.Interactions))
+	}
+	return t, nil
+}
+
+// RoundTrip implements the http.RoundTripper interface
+func (t *CassetteTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	recorded, err := t.recordRequest(req)
+	if err != nil {
+		return nil, err
+	}
+	if t.mode == ModeRecord {
+		return t.record(req, recorded)
+	}
+	return t.replay(req, recorded)
+}
+
+// Save writes the scrubbed interactions to the cassette file when recording
+func (t *CassetteTransport) Save() error {
+	if t.mode != ModeRecord {
+		return nil
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	data, err := json.MarshalIndent(t.cassette, "", "  ")
+	if err != nil {
+		return fmt.Errorf("could not encode cassette: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(t.path), 0o755); err != nil {
+		return fmt.Errorf("could not create cassette directory: %w", err)
+	}
+	return os.WriteFile(t.path, append(data, '\n'), 0o644)
+}
+
+// Unused returns the number of recorded interactions which were not replayed
+func (t *CassetteTransport) Unused() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	unused := 0
+	for _, used := range t.used {
+		if !used {
+			unused++
+		}
+	}
+	return unused
+}
+
+func (t *CassetteTransport) record(req *http.Request, recorded RecordedRequest) (*http.Response, error) {
+	if req.Body != nil && req.Body != http.NoBody {
+		req = req.Clone(req.Context())
+		req.Body = io.NopCloser(strings.NewReader(string(recorded.Body)))
+	}
+	resp, err := t.Transport.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("could not read response: %w", err)
+	}
+
+	interaction := Interaction{
+		Request:  recorded,
+		Response: RecordedResponse{Status: resp.StatusCode, Headers: resp.Header.Clone(), Body: CassetteBody(body)},
+	}
+	t.scrub(&interaction)
+	t.mu.Lock()
+	t.cassette.Interactions = append(t.cassette.Interactions, interaction)
+	t.mu.Unlock()
+
+	// the caller receives the unscrubbed response
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	return resp, nil
+}
+
+func (t *CassetteTransport) replay(req *http.Request, recorded RecordedRequest) (*http.Response, error) {
+	// scrub the request like it was scrubbed when recording
+	interaction := Interaction{Request: recorded}
+	t.scrub(&interaction)
+	recorded = interaction.Request
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for i, candidate := range t.cassette.Interactions {
+		if t.used[i] || candidate.Request.Method != recorded.Method || candidate.Request.URL != recorded.URL ||
+			normalizeBody(candidate.Request.Body) != normalizeBody(recorded.Body) {
+			continue
+		}
+		t.used[i] = true
+		headers := candidate.Response.Headers.Clone()
+		if headers == nil {
+			headers = http.Header{}
+		}
+		return &http.Response{
+			Status:        fmt.Sprintf("%d %s", candidate.Response.Status, http.StatusText(candidate.Response.Status)),
+			StatusCode:    candidate.Response.Status,
+			Proto:         "HTTP/1.1",
+			ProtoMajor:    1,
+			ProtoMinor:    1,
+			Header:        headers,
+			Body:          io.NopCloser(strings.NewReader(string(candidate.Response.Body))),
+			ContentLength: int64(len(candidate.Response.Body)),
+			Request:       req,
+		}, nil
+	}
+	return nil, fmt.Errorf("no recorded interaction in '%s' for %s %s", t.path, recorded.Method, recorded.URL)
+}
+
+// recordRequest reads the request, its body is consumed and closed
+func (t *CassetteTransport) recordRequest(req *http.Request) (RecordedRequest, error) {
+	recorded := RecordedRequest{Method: req.Method, URL: req.URL.String()}
+	if contentType := req.Header.Get("Content-Type"); contentType != "" {
+		recorded.Headers = http.Header{"Content-Type": {contentType}}
+	}
+	if req.Header.Get("Authorization") != "" {
+		if recorded.Headers == nil {
+			recorded.Headers = http.Header{}
+		}
+		recorded.Headers.Set("Authorization", req.Header.Get("Authorization"))
+	}
+	if req.Body == nil || req.Body == http.NoBody {
+		return recorded, nil
+	}
+	body, err := io.ReadAll(req.Body)
+	_ = req.Body.Close()
+	if err != nil {
+		return recorded, fmt.Errorf("could not read request: %w", err)
+	}
+	recorded.Body = CassetteBody(body)
+	return recorded, nil
+}
+
+func (t *CassetteTransport) scrub(interaction *Interaction) {
+	for _, scrubber := range t.scrubbers {
+		scrubber(interaction)
+	}
+}
+
+// normalizeBody compacts JSON bodies so that formatting does not affect matching
+func normalizeBody(body CassetteBody) string {
+	var compacted bytes.Buffer
+	if err := json.Compact(&compacted, []byte(body)); err == nil {
+		return compacted.String()
+	}
+	return string(body)
+}
@@ -0,0 +1,132 @@
+package http
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func newCassetteServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if len(body) == 0 {
+			body = []byte("null")
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Set-Cookie", "session=secret")
+		if r.Method == http.MethodPost {
+			w.WriteHeader(http.StatusCreated)
+		}
+		_, _ = w.Write([]byte(`{"access_token":"secret-token","user":"alice@example.com","echo":` + string(body) + `}`))
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func doCassette(t *testing.T, client *http.Client, method, url, body string) (int, string) {
+	t.Helper()
+	req, err := http.NewRequest(method, url, strings.NewReader(body))
+	if err != nil {
+		t.Fatalf("error was not nil but '%v'", err)
+	}
+	req.Header.Set("Authorization", "Bearer secret-token")
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("error was not nil but '%v'", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	data, _ := io.ReadAll(resp.Body)
+	return resp.StatusCode, string(data)
+}
+
+func TestCassetteTransport_RecordAndReplay(t *testing.T) {
+	server := newCassetteServer(t)
+	path := filepath.Join(t.TempDir(), "cassettes", "test.json")
+	scrubbers := append(DefaultScrubbers(), ScrubString("alice@example.com", "user@example.com"))
+
+	recorder, err := NewCassetteTransport(path, ModeRecord, nil, scrubbers...)
+	if err != nil {
+		t.Fatalf("error was not nil but '%v'", err)
+	}
+	status, body := doCassette(t, &http.Client{Transport: recorder}, http.MethodPost, server.URL+"/items", `{"name":"first"}`)
+	if status != http.StatusCreated || !strings.Contains(body, "secret-token") {
+		t.Errorf("expected unscrubbed live response but found %d '%s'", status, body)
+	}
+	doCassette(t, &http.Client{Transport: recorder}, http.MethodGet, server.URL+"/items?refresh_token=secret-token", "")
+	if err := recorder.Save(); err != nil {
+		t.Fatalf("error was not nil but '%v'", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("error was not nil but '%v'", err)
+	}
+	for _, secret := range []string{"secret-token", "session=secret", "alice@example.com"} {
+		if strings.Contains(string(data), secret) {
+			t.Errorf("expected '%s' to be scrubbed from cassette:\n%s", secret, data)
+		}
+	}
+
+	replayer, err := NewCassetteTransport(path, ModeReplay, nil, scrubbers...)
+	if err != nil {
+		t.Fatalf("error was not nil but '%v'", err)
+	}
+	server.Close()
+	client := &http.Client{Transport: replayer}
+	status, body = doCassette(t, client, http.MethodPost, server.URL+"/items", `{ "name": "first" }`)
+	if status != http.StatusCreated || !strings.Contains(body, `"name":"first"`) || !strings.Contains(body, "user@example.com") {
+		t.Errorf("expected recorded response but found %d '%s'", status, body)
+	}
+	if replayer.Unused() != 1 {
+		t.Errorf("expected 1 unused interaction but found %d", replayer.Unused())
+	}
+	if status, _ := doCassette(t, client, http.MethodGet, server.URL+"/items?refresh_token=other-token", ""); status != http.StatusOK {
+		t.Errorf("expected scrubbed query to match but found status %d", status)
+	}
+}
+
+func TestCassetteTransport_ReplayUnmatched(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.json")
+	if err := os.WriteFile(path, []byte(`{"interactions":[{"request":{"method":"GET","url":"https://example.com/items"},"response":{"status":200,"body":"plain text"}}]}`), 0o644); err != nil {
+		t.Fatalf("error was not nil but '%v'", err)
+	}
+	replayer, err := NewCassetteTransport(path, ModeReplay, nil)
+	if err != nil {
+		t.Fatalf("error was not nil but '%v'", err)
+	}
+	client := &http.Client{Transport: replayer}
+
+	if status, body := doCassette(t, client, http.MethodGet, "https://example.com/items", ""); status != http.StatusOK || body != "plain text" {
+		t.Errorf("expected recorded response but found %d '%s'", status, body)
+	}
+	// every interaction is replayed once
+	req, _ := http.NewRequest(http.MethodGet, "https://example.com/items", nil)
+	if resp, err := client.Do(req); err == nil {
+		_ = resp.Body.Close()
+		t.Error("expected error for request without recorded interaction")
+	}
+}
+
+func TestCassetteTransport_MissingCassette(t *testing.T) {
+	if _, err := NewCassetteTransport(filepath.Join(t.TempDir(), "missing.json"), ModeReplay, nil); err == nil {
+		t.Error("expected error for missing cassette")
+	}
+}
+
+func TestScrubRegexp(t *testing.T) {
+	interaction := Interaction{
+		Request:  RecordedRequest{URL: "https://example.com/users/42"},
+		Response: RecordedResponse{Body: `{"@odata.context":"$metadata#users('alice%40example.com')/todo/lists"}`},
+	}
+	ScrubRegexp(regexp.MustCompile(`users\('[^']*'\)`), "users('REDACTED')")(&interaction)
+
+	if interaction.Response.Body != `{"@odata.context":"$metadata#users('REDACTED')/todo/lists"}` {
+		t.Errorf("expected scrubbed body but found '%s'", interaction.Response.Body)
+	}
+}
@@ -0,0 +1,202 @@
+// Package providers creates the todo clients enabled by the configuration
+package providers
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+
+	"github.com/jo-hoe/todoapi/config"
+	"github.com/jo-hoe/todoapi/todoclient"
+	"github.com/jo-hoe/todoapi/todoclient/cache"
+	"github.com/jo-hoe/todoapi/todoclient/caldav"
+	"github.com/jo-hoe/todoapi/todoclient/embedded"
+	"github.com/jo-hoe/todoapi/todoclient/github"
+	"github.com/jo-hoe/todoapi/todoclient/gitlab"
+	"github.com/jo-hoe/todoapi/todoclient/google"
+	"github.com/jo-hoe/todoapi/todoclient/local"
+	"github.com/jo-hoe/todoapi/todoclient/microsoft"
+	"github.com/jo-hoe/todoapi/todoclient/todoist"
+	"golang.org/x/oauth2"
+)
+
+// Provider names used as keys of the client map
+const (
+	Todoist   = "todoist"
+	Local     = "local"
+	Embedded  = "embedded"
+	CalDAV    = "caldav"
+	Google    = "google"
+	GitHub    = "github"
+	GitLab    = "gitlab"
+	Microsoft = "microsoft"
+)
+
+// FromConfig creates a client for every provider with credentials in the configuration.
+// The returned map is keyed by provider name and is empty if nothing is configured.
+// Every client is wrapped with todoclient.ValidationInterceptor, so invalid input
+// is rejected the same way by all providers.
+func FromConfig(cfg *config.Config) (map[string]todoclient.ToDoClient, error) {
+	clients := make(map[string]todoclient.ToDoClient)
+
+	if cfg.Todoist.APIToken != "" {
+		client, err := todoist.NewTodoistClientWithBaseURL(todoist.NewTodoistHTTPClient(cfg.Todoist.APIToken), cfg.Todoist.BaseURL)
+		if err != nil {
+			return nil, err
+		}
+		clients[Todoist] = client
+	}
+
+	if cfg.Local.Dir != "" {
+		client, err := local.NewLocalClient(cfg.Local.Dir)
+		if err != nil {
+			return nil, err
+		}
+		clients[Local] = client
+	}
+
+	if cfg.Embedded.Path != "" {
+		client, err := embedded.NewEmbeddedClient(cfg.Embedded.Path)
+		if err != nil {
+			return nil, err
+		}
+		clients[Embedded] = client
+	}
+
+	if cfg.CalDAV.URL != "" {
+		httpClient := caldav.NewCalDAVHTTPClient(cfg.CalDAV.Username, cfg.CalDAV.Password)
+		client, err := caldav.NewCalDAVClient(httpClient, cfg.CalDAV.URL)
+		if err != nil {
+			return nil, err
+		}
+		clients[CalDAV] = client
+	}
+
+	if cfg.Google.ClientID != "" {
+		client, err := newGoogleClient(cfg.Google)
+		if err != nil {
+			return nil, err
+		}
+		clients[Google] = client
+	}
+
+	if cfg.Microsoft.ClientID != "" {
+		client, err := newMicrosoftClient(cfg.Microsoft)
+		if err != nil {
+			return nil, err
+		}
+		clients[Microsoft] = client
+	}
+
+	if cfg.GitHub.Token != "" {
+		client, err := github.NewGitHubClient(github.NewGitHubHTTPClient(cfg.GitHub.Token), cfg.GitHub.BaseURL, cfg.GitHub.Repos...)
+		if err != nil {
+			return nil, err
+		}
+		clients[GitHub] = client
+	}
+
+	if cfg.GitLab.Token != "" {
+		client, err := gitlab.NewGitLabClient(gitlab.NewGitLabHTTPClient(cfg.GitLab.Token), cfg.GitLab.BaseURL, cfg.GitLab.Projects...)
+		if err != nil {
+			return nil, err
+		}
+		clients[GitLab] = client
+	}
+
+	return WithMiddleware(clients, todoclient.Intercept(todoclient.ValidationInterceptor())), nil
+}
+
+// newGoogleClient prefers the token of the token file over the configured refresh token,
+// as the file holds the latest token of previous runs
+func newGoogleClient(cfg config.GoogleConfig) (*google.GoogleTasksClient, error) {
+	clientConfig := google.ClientConfig{
+		ClientID:     cfg.ClientID,
+		ClientSecret: cfg.ClientSecret,
+	}
+	clientConfig.Token.RefreshToken = cfg.RefreshToken
+
+	var saveToken func(*oauth2.Token)
+	if cfg.TokenFile != "" {
+		saveToken = google.FileTokenSaver(cfg.TokenFile)
+		if _, err := os.Stat(cfg.TokenFile); err == nil {
+			token, err := google.LoadToken(cfg.TokenFile)
+			if err != nil {
+				return nil, err
+			}
+			clientConfig.Token = token
+		}
+	}
+
+	httpClient, err := google.NewHTTPClient(context.Background(), clientConfig, saveToken)
+	if err != nil {
+		return nil, err
+	}
+	return google.NewGoogleTasksClient(httpClient, cfg.BaseURL)
+}
+
+// newMicrosoftClient prefers the token of the token file over the configured refresh token
+// like newGoogleClient
+func newMicrosoftClient(cfg config.MicrosoftConfig) (*microsoft.MSToDo, error) {
+	clientConfig := microsoft.MSClientConfig{
+		ClientCredentials: microsoft.MSClientCredentials{
+			ClientId:     cfg.ClientID,
+			ClientSecret: cfg.ClientSecret,
+		},
+		Tenant: cfg.TenantID,
+	}
+	clientConfig.Token.RefreshToken = cfg.RefreshToken
+
+	var saveToken func(*oauth2.Token)
+	if cfg.TokenFile != "" {
+		saveToken = microsoft.FileTokenSaver(cfg.TokenFile)
+		if _, err := os.Stat(cfg.TokenFile); err == nil {
+			token, err := microsoft.LoadToken(cfg.TokenFile)
+			if err != nil {
+				return nil, err
+			}
+			clientConfig.Token = token
+		}
+	}
+
+	httpClient, err := microsoft.NewHTTPClient(context.Background(), clientConfig, saveToken)
+	if err != nil {
+		return nil, err
+	}
+	return microsoft.NewMSToDoWithBaseURL(httpClient, cfg.BaseURL)
+}
+
+// WithMiddleware wraps every client with the middlewares, see todoclient.Chain
+func WithMiddleware(clients map[string]todoclient.ToDoClient, middlewares ...todoclient.Middleware) map[string]todoclient.ToDoClient {
+	result := make(map[string]todoclient.ToDoClient, len(clients))
+	for name, client := range clients {
+		result[name] = todoclient.Chain(client, middlewares...)
+	}
+	return result
+}
+
+// WithCache wraps every client with a cache as configured. Each provider gets
+// its own storage, file-backed storages use a subdirectory per provider.
+func WithCache(cfg config.CacheConfig, clients map[string]todoclient.ToDoClient) (map[string]todoclient.ToDoClient, error) {
+	if !cfg.Enabled {
+		return clients, nil
+	}
+
+	options := cache.Options{
+		ParentsTTL: cfg.ParentsTTL,
+		TasksTTL:   cfg.TasksTTL,
+	}
+	result := make(map[string]todoclient.ToDoClient, len(clients))
+	for name, client := range clients {
+		var storage cache.Storage = cache.NewMemoryStorage(cfg.Size)
+		if cfg.Dir != "" {
+			fileStorage, err := cache.NewFileStorage(filepath.Join(cfg.Dir, name))
+			if err != nil {
+				return nil, err
+			}
+			storage = fileStorage
+		}
+		result[name] = todoclient.Chain(client, cache.Middleware(storage, options))
+	}
+	return result, nil
+}
@@ -3,86 +3,323 @@ package testutil
 
 import (
 	"context"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
+	"github.com/jo-hoe/todoapi/pkg/errors"
 	"github.com/jo-hoe/todoapi/todoclient"
 )
 
-// MockToDoClient is a mock implementation of ToDoClient for testing
+// MockToDoClient is an in-memory ToDoClient for testing which behaves like a provider:
+// tasks are stored per parent, IDs are unique, unknown IDs yield errors wrapping
+// errors.ErrNotFound and deleting a parent or task deletes its children.
+// It is safe for concurrent use.
 type MockToDoClient struct {
-	tasks   []todoclient.ToDoTask
-	parents []todoclient.ToDoParent
+	mu      sync.Mutex
+	nextID  int
+	parents []*mockParent
+	hook    FaultHook
+	faults  []mockFault
+	calls   map[string]int
+	now     func() time.Time
 }
 
+type mockParent struct {
+	parent todoclient.ToDoParent
+	tasks  []todoclient.ToDoTask
+}
+
+type mockFault struct {
+	operation string
+	err       error
+	count     int
+}
+
+// FaultHook is called before every operation of a MockToDoClient without holding its lock,
+// so it may block. A returned error is returned by the operation without executing it.
+type FaultHook func(ctx context.Context, inv todoclient.Invocation) error
+
 // NewMockToDoClient creates a new mock client
 func NewMockToDoClient() *MockToDoClient {
 	return &MockToDoClient{
-		tasks:   make([]todoclient.ToDoTask, 0),
-		parents: make([]todoclient.ToDoParent, 0),
+		parents: make([]*mockParent, 0),
+		calls:   make(map[string]int),
+		now:     time.Now,
 	}
 }
 
-func (m *MockToDoClient) GetAllTasks(ctx context.Context) ([]todoclient.ToDoTask, error) {
-	return m.tasks, nil
+// SetFaultHook sets the hook called before every operation, nil removes it
+func (m *MockToDoClient) SetFaultHook(hook FaultHook) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.hook = hook
 }
 
-func (m *MockToDoClient) GetChildrenTasks(ctx context.Context, parentID string) ([]todoclient.ToDoTask, error) {
-	// In a real implementation, you'd filter tasks by parent ID
-	result := append([]todoclient.ToDoTask(nil), m.tasks...)
-	return result, nil
+// FailNext makes the next count calls of the operation return the error. The operation
+// is one of the todoclient Op constants, an empty operation matches all operations.
+func (m *MockToDoClient) FailNext(operation string, err error, count int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.faults = append(m.faults, mockFault{operation: operation, err: err, count: count})
+}
+
+// Calls returns how often the operation was called, including failed calls
+func (m *MockToDoClient) Calls(operation string) int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.calls[operation]
+}
+
+func (m *MockToDoClient) GetAllTasks(ctx context.Context, query *todoclient.TaskQuery) ([]todoclient.ToDoTask, error) {
+	result := make([]todoclient.ToDoTask, 0)
+	err := m.do(ctx, todoclient.OpGetAllTasks, []interface{}{query}, func() error {
+		for _, parent := range m.parents {
+			result = append(result, copyTasks(parent.tasks)...)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return query.Apply(result), nil
+}
+
+func (m *MockToDoClient) GetChildrenTasks(ctx context.Context, parentID string, query *todoclient.TaskQuery) ([]todoclient.ToDoTask, error) {
+	var result []todoclient.ToDoTask
+	err := m.do(ctx, todoclient.OpGetChildrenTasks, []interface{}{parentID, query}, func() error {
+		parent, err := m.findParent(parentID)
+		if err != nil {
+			return err
+		}
+		result = copyTasks(parent.tasks)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return query.Apply(result), nil
+}
+
+func (m *MockToDoClient) GetTask(ctx context.Context, parentID, taskID string) (todoclient.ToDoTask, error) {
+	var result todoclient.ToDoTask
+	err := m.do(ctx, todoclient.OpGetTask, []interface{}{parentID, taskID}, func() error {
+		parent, err := m.findParent(parentID)
+		if err != nil {
+			return err
+		}
+		i, err := findMockTask(parent, taskID)
+		if err != nil {
+			return err
+		}
+		result = copyTasks(parent.tasks[i : i+1])[0]
+		return nil
+	})
+	return result, err
 }
 
 func (m *MockToDoClient) CreateTask(ctx context.Context, parentID string, task todoclient.ToDoTask) (todoclient.ToDoTask, error) {
-	task.ID = "mock-id"
-	task.CreationTime = time.Now()
-	m.tasks = append(m.tasks, task)
-	return task, nil
+	err := m.do(ctx, todoclient.OpCreateTask, []interface{}{parentID, task}, func() error {
+		if err := task.Validate(); err != nil {
+			return err
+		}
+		parent, err := m.findParent(parentID)
+		if err != nil {
+			return err
+		}
+		if task.ParentTaskID != "" {
+			if _, err := findMockTask(parent, task.ParentTaskID); err != nil {
+				return err
+			}
+		}
+		m.nextID++
+		task.ID = fmt.Sprintf("mock-task-%d", m.nextID)
+		task.CreationTime = m.now().UTC()
+		task.ModifiedTime = task.CreationTime
+		task.Labels = append(make([]string, 0, len(task.Labels)), task.Labels...)
+		parent.tasks = append(parent.tasks, task)
+		return nil
+	})
+	if err != nil {
+		return todoclient.ToDoTask{}, err
+	}
+	return copyTasks([]todoclient.ToDoTask{task})[0], nil
 }
 
 func (m *MockToDoClient) UpdateTask(ctx context.Context, parentID string, task todoclient.ToDoTask) error {
-	for i, t := range m.tasks {
-		if t.ID == task.ID {
-			m.tasks[i] = task
-			return nil
+	return m.do(ctx, todoclient.OpUpdateTask, []interface{}{parentID, task}, func() error {
+		if err := task.Validate(); err != nil {
+			return err
 		}
-	}
-	return nil
+		parent, err := m.findParent(parentID)
+		if err != nil {
+			return err
+		}
+		i, err := findMockTask(parent, task.ID)
+		if err != nil {
+			return err
+		}
+		// the creation time is owned by the store
+		task.CreationTime = parent.tasks[i].CreationTime
+		task.ModifiedTime = m.now().UTC()
+		task.Labels = append(make([]string, 0, len(task.Labels)), task.Labels...)
+		parent.tasks[i] = task
+		return nil
+	})
 }
 
 func (m *MockToDoClient) DeleteTask(ctx context.Context, parentID, taskID string) error {
-	for i, task := range m.tasks {
-		if task.ID == taskID {
-			m.tasks = append(m.tasks[:i], m.tasks[i+1:]...)
-			return nil
+	return m.do(ctx, todoclient.OpDeleteTask, []interface{}{parentID, taskID}, func() error {
+		parent, err := m.findParent(parentID)
+		if err != nil {
+			return err
 		}
-	}
-	return nil
+		if _, err := findMockTask(parent, taskID); err != nil {
+			return err
+		}
+		// subtasks are deleted with their task
+		deleted := map[string]bool{taskID: true}
+		for changed := true; changed; {
+			changed = false
+			for _, task := range parent.tasks {
+				if !deleted[task.ID] && deleted[task.ParentTaskID] {
+					deleted[task.ID], changed = true, true
+				}
+			}
+		}
+		tasks := make([]todoclient.ToDoTask, 0, len(parent.tasks))
+		for _, task := range parent.tasks {
+			if !deleted[task.ID] {
+				tasks = append(tasks, task)
+			}
+		}
+		parent.tasks = tasks
+		return nil
+	})
 }
 
 func (m *MockToDoClient) GetAllParents(ctx context.Context) ([]todoclient.ToDoParent, error) {
-	return m.parents, nil
+	result := make([]todoclient.ToDoParent, 0)
+	err := m.do(ctx, todoclient.OpGetAllParents, nil, func() error {
+		for _, parent := range m.parents {
+			result = append(result, parent.parent)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
 }
 
 func (m *MockToDoClient) CreateParent(ctx context.Context, parentName string) (todoclient.ToDoParent, error) {
-	parent := todoclient.ToDoParent{
-		ID:   "mock-parent-id",
-		Name: parentName,
+	var parent todoclient.ToDoParent
+	err := m.do(ctx, todoclient.OpCreateParent, []interface{}{parentName}, func() error {
+		parent.Name = strings.TrimSpace(parentName)
+		if err := parent.Validate(); err != nil {
+			return err
+		}
+		m.nextID++
+		parent.ID = fmt.Sprintf("mock-parent-%d", m.nextID)
+		m.parents = append(m.parents, &mockParent{parent: parent, tasks: make([]todoclient.ToDoTask, 0)})
+		return nil
+	})
+	if err != nil {
+		return todoclient.ToDoParent{}, err
 	}
-	m.parents = append(m.parents, parent)
 	return parent, nil
 }
 
+// DeleteParent deletes the parent with all of its tasks
 func (m *MockToDoClient) DeleteParent(ctx context.Context, parentID string) error {
-	for i, parent := range m.parents {
-		if parent.ID == parentID {
-			m.parents = append(m.parents[:i], m.parents[i+1:]...)
-			return nil
+	return m.do(ctx, todoclient.OpDeleteParent, []interface{}{parentID}, func() error {
+		for i, parent := range m.parents {
+			if parent.parent.ID == parentID {
+				m.parents = append(m.parents[:i], m.parents[i+1:]...)
+				return nil
+			}
+		}
+		return errors.NewAPIError("MOCK_NOT_FOUND", "parent not found", errors.ErrNotFound)
+	})
+}
+
+// Capabilities reports the features of the mock, which stores tasks as they are
+func (m *MockToDoClient) Capabilities() todoclient.Capabilities {
+	return todoclient.Capabilities{
+		Subtasks:     true,
+		Labels:       true,
+		DueDates:     true,
+		DueTime:      true,
+		CreateParent: true,
+		DeleteParent: true,
+	}
+}
+
+// do counts the call, runs the fault hook and injected faults and executes fn under the lock
+func (m *MockToDoClient) do(ctx context.Context, operation string, args []interface{}, fn func() error) error {
+	m.mu.Lock()
+	m.calls[operation]++
+	hook := m.hook
+	var fault error
+	for i, f := range m.faults {
+		if f.operation == "" || f.operation == operation {
+			fault = f.err
+			m.faults[i].count--
+			if m.faults[i].count <= 0 {
+				m.faults = append(m.faults[:i], m.faults[i+1:]...)
+			}
+			break
+		}
+	}
+	m.mu.Unlock()
+
+	if fault != nil {
+		return fault
+	}
+	if hook != nil {
+		if err := hook(ctx, todoclient.Invocation{Operation: operation, Args: args}); err != nil {
+			return err
+		}
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return fn()
+}
+
+func (m *MockToDoClient) findParent(parentID string) (*mockParent, error) {
+	for _, parent := range m.parents {
+		if parent.parent.ID == parentID {
+			return parent, nil
 		}
 	}
-	return nil
+	return nil, errors.NewAPIError("MOCK_NOT_FOUND", "parent not found", errors.ErrNotFound)
+}
+
+func findMockTask(parent *mockParent, taskID string) (int, error) {
+	for i, task := range parent.tasks {
+		if task.ID == taskID {
+			return i, nil
+		}
+	}
+	return -1, errors.NewAPIError("MOCK_NOT_FOUND", "task not found", errors.ErrNotFound)
+}
+
+// copyTasks copies tasks so that callers cannot modify the stored labels
+func copyTasks(tasks []todoclient.ToDoTask) []todoclient.ToDoTask {
+	result := make([]todoclient.ToDoTask, 0, len(tasks))
+	for _, task := range tasks {
+		task.Labels = append(make([]string, 0, len(task.Labels)), task.Labels...)
+		result = append(result, task)
+	}
+	return result
 }
 
 // CreateTestServer creates a test HTTP server for testing
@@ -0,0 +1,141 @@
+package testutil
+
+import (
+	"context"
+	stderrors "errors"
+	"sync"
+	"testing"
+
+	"github.com/jo-hoe/todoapi/pkg/errors"
+	"github.com/jo-hoe/todoapi/todoclient"
+	"github.com/jo-hoe/todoapi/todoclient/conformance"
+)
+
+func TestMockToDoClient_ImplementationTest(t *testing.T) {
+	// tests if interface is implemented
+	var _ todoclient.ToDoClient = (*MockToDoClient)(nil)
+}
+
+func TestMockToDoClient_Conformance(t *testing.T) {
+	conformance.Run(t, NewMockToDoClient(), conformance.Options{})
+}
+
+func TestMockToDoClient_ParentsAreSeparate(t *testing.T) {
+	client := NewMockToDoClient()
+	ctx := context.Background()
+	first, _ := client.CreateParent(ctx, "first")
+	second, _ := client.CreateParent(ctx, "second")
+	task, err := client.CreateTask(ctx, first.ID, todoclient.ToDoTask{Name: "task"})
+	if err != nil {
+		t.Fatalf("error was not nil but '%v'", err)
+	}
+
+	tasks, err := client.GetChildrenTasks(ctx, second.ID, nil)
+	if err != nil {
+		t.Fatalf("error was not nil but '%v'", err)
+	}
+	if len(tasks) != 0 {
+		t.Errorf("expected no tasks in other parent but found %+v", tasks)
+	}
+	if _, err := client.GetTask(ctx, second.ID, task.ID); !stderrors.Is(err, errors.ErrNotFound) {
+		t.Errorf("expected not found error for task of other parent but found '%v'", err)
+	}
+	if err := client.UpdateTask(ctx, first.ID, todoclient.ToDoTask{ID: "unknown", Name: "task"}); !stderrors.Is(err, errors.ErrNotFound) {
+		t.Errorf("expected not found error for unknown task but found '%v'", err)
+	}
+	if _, err := client.CreateTask(ctx, "unknown", todoclient.ToDoTask{Name: "task"}); !stderrors.Is(err, errors.ErrNotFound) {
+		t.Errorf("expected not found error for unknown parent but found '%v'", err)
+	}
+}
+
+func TestMockToDoClient_CascadingDeletes(t *testing.T) {
+	client := NewMockToDoClient()
+	ctx := context.Background()
+	parent, _ := client.CreateParent(ctx, "parent")
+	task, _ := client.CreateTask(ctx, parent.ID, todoclient.ToDoTask{Name: "task"})
+	subtask, _ := client.CreateTask(ctx, parent.ID, todoclient.ToDoTask{Name: "subtask", ParentTaskID: task.ID})
+	client.CreateTask(ctx, parent.ID, todoclient.ToDoTask{Name: "nested", ParentTaskID: subtask.ID})
+	client.CreateTask(ctx, parent.ID, todoclient.ToDoTask{Name: "other"})
+
+	if err := client.DeleteTask(ctx, parent.ID, task.ID); err != nil {
+		t.Fatalf("error was not nil but '%v'", err)
+	}
+	tasks, _ := client.GetChildrenTasks(ctx, parent.ID, nil)
+	if len(tasks) != 1 || tasks[0].Name != "other" {
+		t.Errorf("expected subtasks to be deleted with their task but found %+v", tasks)
+	}
+
+	if err := client.DeleteParent(ctx, parent.ID); err != nil {
+		t.Fatalf("error was not nil but '%v'", err)
+	}
+	if tasks, _ := client.GetAllTasks(ctx, nil); len(tasks) != 0 {
+		t.Errorf("expected tasks to be deleted with their parent but found %+v", tasks)
+	}
+	if err := client.DeleteParent(ctx, parent.ID); !stderrors.Is(err, errors.ErrNotFound) {
+		t.Errorf("expected not found error but found '%v'", err)
+	}
+}
+
+func TestMockToDoClient_Faults(t *testing.T) {
+	client := NewMockToDoClient()
+	ctx := context.Background()
+	unavailable := stderrors.New("unavailable")
+	client.FailNext(todoclient.OpCreateParent, unavailable, 1)
+
+	if _, err := client.CreateParent(ctx, "parent"); !stderrors.Is(err, unavailable) {
+		t.Errorf("expected injected error but found '%v'", err)
+	}
+	if _, err := client.CreateParent(ctx, "parent"); err != nil {
+		t.Errorf("error was not nil but '%v'", err)
+	}
+	if client.Calls(todoclient.OpCreateParent) != 2 {
+		t.Errorf("expected 2 calls but found %d", client.Calls(todoclient.OpCreateParent))
+	}
+
+	client.SetFaultHook(func(ctx context.Context, inv todoclient.Invocation) error {
+		if inv.Operation == todoclient.OpGetAllParents {
+			return unavailable
+		}
+		return nil
+	})
+	if _, err := client.GetAllParents(ctx); !stderrors.Is(err, unavailable) {
+		t.Errorf("expected error of hook but found '%v'", err)
+	}
+	if _, err := client.GetAllTasks(ctx, nil); err != nil {
+		t.Errorf("error was not nil but '%v'", err)
+	}
+
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	if _, err := client.GetAllTasks(canceled, nil); !stderrors.Is(err, context.Canceled) {
+		t.Errorf("expected canceled context error but found '%v'", err)
+	}
+}
+
+func TestMockToDoClient_Concurrency(t *testing.T) {
+	client := NewMockToDoClient()
+	ctx := context.Background()
+	parent, _ := client.CreateParent(ctx, "parent")
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := client.CreateTask(ctx, parent.ID, todoclient.ToDoTask{Name: "task"}); err != nil {
+				t.Errorf("error was not nil but '%v'", err)
+			}
+			_, _ = client.GetAllTasks(ctx, nil)
+		}()
+	}
+	wg.Wait()
+
+	tasks, _ := client.GetChildrenTasks(ctx, parent.ID, nil)
+	ids := make(map[string]bool)
+	for _, task := range tasks {
+		ids[task.ID] = true
+	}
+	if len(tasks) != 50 || len(ids) != 50 {
+		t.Errorf("expected 50 tasks with unique IDs but found %d with %d IDs", len(tasks), len(ids))
+	}
+}
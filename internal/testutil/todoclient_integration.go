@@ -7,13 +7,14 @@ import (
 	"time"
 
 	"github.com/jo-hoe/todoapi/todoclient"
+	"github.com/jo-hoe/todoapi/todoclient/conformance"
 )
 
 func IntegrationTest_GetAllTasks(t *testing.T, client todoclient.ToDoClient) {
 	checkPrerequisites(t)
 	ctx := context.Background()
 
-	tasks, err := client.GetAllTasks(ctx)
+	tasks, err := client.GetAllTasks(ctx, nil)
 
 	if err != nil {
 		t.Errorf("error was not nil but '%v'", err)
@@ -32,7 +33,7 @@ func IntegrationTest_Parents(t *testing.T, client todoclient.ToDoClient) {
 		t.Errorf("error was not nil but '%v'", err)
 	}
 	if len(parent.ID) <= 0 {
-		t.Error("expected more than 0 tasks")
+		t.Error("expected parent to have an ID")
 	}
 
 	err = client.DeleteParent(ctx, parent.ID)
@@ -51,7 +52,7 @@ func IntegrationTest_CRUD(t *testing.T, client todoclient.ToDoClient) {
 		t.Errorf("could not get parents '%v'", err)
 	}
 	if len(parents) <= 0 {
-		t.Error("expected more than 0 parent")
+		t.Fatal("expected more than 0 parents")
 	}
 
 	// test create
@@ -61,7 +62,7 @@ func IntegrationTest_CRUD(t *testing.T, client todoclient.ToDoClient) {
 		Description: "test test test",
 	})
 	if err != nil {
-		t.Errorf("issue creating task '%v'", err)
+		t.Fatalf("issue creating task '%v'", err)
 	}
 
 	// test update
@@ -74,10 +75,16 @@ func IntegrationTest_CRUD(t *testing.T, client todoclient.ToDoClient) {
 	// test delete
 	err = client.DeleteTask(ctx, parents[0].ID, task.ID)
 	if err != nil {
-		t.Errorf("issue updating task '%v'", err)
+		t.Errorf("issue deleting task '%v'", err)
 	}
 }
 
+// IntegrationTest_Conformance runs the conformance suite against a live service
+func IntegrationTest_Conformance(t *testing.T, client todoclient.ToDoClient) {
+	checkPrerequisites(t)
+	conformance.Run(t, client, conformance.Options{})
+}
+
 // Skips integration test if requirements are not meet
 func checkPrerequisites(t *testing.T) {
 	if os.Getenv("GITHUB_ACTIONS") == "true" {
@@ -9,6 +9,8 @@ import (
 // Common error variables
 var (
 	ErrNotFound           = errors.New("resource not found")
+	ErrConflict           = errors.New("resource was modified concurrently")
+	ErrNotSupported       = errors.New("operation not supported by the provider")
 	ErrInvalidInput       = errors.New("invalid input")
 	ErrUnauthorized       = errors.New("unauthorized")
 	ErrInternalServer     = errors.New("internal server error")
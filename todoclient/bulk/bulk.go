@@ -0,0 +1,175 @@
+package bulk
+
+import (
+	"context"
+	stderrors "errors"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/jo-hoe/todoapi/pkg/errors"
+	"github.com/jo-hoe/todoapi/todoclient"
+)
+
+// Export writes the tasks of all parents of the client
+func Export(ctx context.Context, client todoclient.ToDoClient, codec *Codec, w io.Writer) error {
+	parents, err := client.GetAllParents(ctx)
+	if err != nil {
+		return errors.NewAPIError("BULK_GET_PARENTS_FAILED", "failed to retrieve parents", err)
+	}
+
+	rows := make([]Row, 0)
+	for _, parent := range parents {
+		tasks, err := client.GetChildrenTasks(ctx, parent.ID, nil)
+		if err != nil {
+			return errors.NewAPIError("BULK_GET_TASKS_FAILED", "failed to retrieve tasks", err)
+		}
+		for _, task := range tasks {
+			rows = append(rows, Row{ParentID: parent.ID, ParentName: parent.Name, Task: task})
+		}
+	}
+	return codec.EncodeRows(w, rows)
+}
+
+// ImportOptions configures an import
+type ImportOptions struct {
+	DefaultParentID string // Parent of rows without parent ID and name
+	DryRun          bool   // Validate and count the changes without applying them
+}
+
+// ImportReport summarizes an import
+type ImportReport struct {
+	ParentsCreated int        `json:"parents_created"`
+	Created        int        `json:"created"`
+	Updated        int        `json:"updated"`
+	Failed         int        `json:"failed"`
+	Errors         []RowError `json:"errors"` // Ordered by line
+}
+
+// Import upserts the rows into the client. Rows with an ID update the task if
+// it exists, other rows create a task. Updates only change the mapped fields.
+//
+// The parent is selected by parent ID, then by parent name, creating missing
+// parents, and then by the default parent. Created tasks are validated as they
+// are, updated tasks after merging the row into the existing task, so that rows
+// may contain a subset of the fields like id and is_completed. Rows failing to
+// decode, to validate or to be applied are reported with their line without
+// stopping the import.
+func Import(ctx context.Context, client todoclient.ToDoClient, codec *Codec, r io.Reader, options ImportOptions) (ImportReport, error) {
+	report := ImportReport{Errors: make([]RowError, 0)}
+
+	rows, rowErrors, err := codec.DecodeRows(r)
+	if err != nil {
+		return report, err
+	}
+	report.Errors = append(report.Errors, rowErrors...)
+
+	parents, err := client.GetAllParents(ctx)
+	if err != nil {
+		return report, errors.NewAPIError("BULK_GET_PARENTS_FAILED", "failed to retrieve parents", err)
+	}
+	byName := make(map[string]string, len(parents))
+	for _, parent := range parents {
+		byName[strings.ToLower(parent.Name)] = parent.ID
+	}
+
+	for _, decoded := range rows {
+		if err := importRow(ctx, client, codec, decoded.Row, byName, options, &report); err != nil {
+			report.Errors = append(report.Errors, RowError{Line: decoded.Line, Error: err.Error()})
+		}
+	}
+
+	report.Failed = len(report.Errors)
+	sort.SliceStable(report.Errors, func(i, j int) bool {
+		return report.Errors[i].Line < report.Errors[j].Line
+	})
+	return report, nil
+}
+
+func importRow(ctx context.Context, client todoclient.ToDoClient, codec *Codec, row Row, byName map[string]string, options ImportOptions, report *ImportReport) error {
+	parentID := row.ParentID
+	if parentID == "" && row.ParentName != "" {
+		key := strings.ToLower(row.ParentName)
+		id, ok := byName[key]
+		if !ok {
+			// tasks of new parents are created, so invalid rows must not create the parent
+			if err := row.Task.Validate(); err != nil {
+				return err
+			}
+			if !options.DryRun {
+				parent, err := client.CreateParent(ctx, row.ParentName)
+				if err != nil {
+					return err
+				}
+				id = parent.ID
+			}
+			byName[key] = id
+			report.ParentsCreated++
+		}
+		parentID = id
+		if !ok && options.DryRun {
+			// the parent does not exist yet, so the task would be created
+			report.Created++
+			return nil
+		}
+	}
+	if parentID == "" {
+		parentID = options.DefaultParentID
+	}
+	if parentID == "" {
+		return errors.NewValidationError(FieldParentID, "row has no parent and no default parent is set")
+	}
+
+	if row.Task.ID != "" {
+		existing, err := client.GetTask(ctx, parentID, row.Task.ID)
+		switch {
+		case err == nil:
+			task := merge(codec, existing, row.Task)
+			if err := task.Validate(); err != nil {
+				return err
+			}
+			if !options.DryRun {
+				if err := client.UpdateTask(ctx, parentID, task); err != nil {
+					return err
+				}
+			}
+			report.Updated++
+			return nil
+		case !stderrors.Is(err, errors.ErrNotFound):
+			return err
+		}
+	}
+
+	if err := row.Task.Validate(); err != nil {
+		return err
+	}
+	if !options.DryRun {
+		task := row.Task
+		task.ID = ""
+		if _, err := client.CreateTask(ctx, parentID, task); err != nil {
+			return err
+		}
+	}
+	report.Created++
+	return nil
+}
+
+// merge overwrites the fields of existing which are part of the mapping
+func merge(codec *Codec, existing, task todoclient.ToDoTask) todoclient.ToDoTask {
+	if codec.HasField(FieldName) {
+		existing.Name = task.Name
+	}
+	if codec.HasField(FieldDescription) {
+		existing.Description = task.Description
+	}
+	if codec.HasField(FieldDueDate) {
+		existing.DueDate = task.DueDate
+	}
+	if codec.HasField(FieldIsCompleted) {
+		existing.IsCompleted = task.IsCompleted
+	}
+	if codec.HasField(FieldLabels) {
+		existing.Labels = task.Labels
+	}
+	return existing
+}
@@ -0,0 +1,118 @@
+package bulk
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/jo-hoe/todoapi/internal/testutil"
+	"github.com/jo-hoe/todoapi/todoclient"
+)
+
+func taskCount(client todoclient.ToDoClient) int {
+	tasks, _ := client.GetAllTasks(context.Background(), nil)
+	return len(tasks)
+}
+
+func parentCount(client todoclient.ToDoClient) int {
+	parents, _ := client.GetAllParents(context.Background())
+	return len(parents)
+}
+
+func TestImport_Upsert(t *testing.T) {
+	ctx := context.Background()
+	client := testutil.NewMockToDoClient()
+	work, _ := client.CreateParent(ctx, "Work")
+	existing, _ := client.CreateTask(ctx, work.ID, todoclient.ToDoTask{Name: "old", Description: "kept"})
+
+	codec, _ := NewCodec(CSV, Mapping{
+		{Header: "id", Field: FieldID},
+		{Header: "list", Field: FieldParentName},
+		{Header: "name", Field: FieldName},
+	})
+	input := "id,list,name\n" +
+		existing.ID + ",work,renamed\n" +
+		",Work,new\n" +
+		"unknown,Home,foreign id\n" +
+		",Work,\n" +
+		",,no parent\n"
+
+	report, err := Import(ctx, client, codec, strings.NewReader(input), ImportOptions{})
+	if err != nil {
+		t.Fatalf("error was not nil but '%v'", err)
+	}
+	if report.Updated != 1 || report.Created != 2 || report.ParentsCreated != 1 || report.Failed != 2 {
+		t.Errorf("unexpected report %+v", report)
+	}
+	if len(report.Errors) != 2 || report.Errors[0].Line != 5 || report.Errors[1].Line != 6 {
+		t.Errorf("unexpected row errors %+v", report.Errors)
+	}
+
+	updated, _ := client.GetTask(ctx, work.ID, existing.ID)
+	if updated.Name != "renamed" || updated.Description != "kept" {
+		t.Errorf("expected only the mapped fields to change but found %+v", updated)
+	}
+	if tasks, _ := client.GetChildrenTasks(ctx, work.ID, nil); len(tasks) != 2 || parentCount(client) != 2 {
+		t.Errorf("unexpected state with %d parents and tasks %+v", parentCount(client), tasks)
+	}
+}
+
+func TestImport_DryRun(t *testing.T) {
+	ctx := context.Background()
+	client := testutil.NewMockToDoClient()
+	work, _ := client.CreateParent(ctx, "Work")
+	codec, _ := NewCodec(NDJSON, DefaultMapping())
+	input := `{"parent_name":"New","name":"a"}` + "\n" + `{"name":"b"}` + "\n" + `{"name":""}` + "\n"
+
+	report, err := Import(ctx, client, codec, strings.NewReader(input), ImportOptions{DefaultParentID: work.ID, DryRun: true})
+	if err != nil {
+		t.Fatalf("error was not nil but '%v'", err)
+	}
+	if report.Created != 2 || report.ParentsCreated != 1 || report.Failed != 1 {
+		t.Errorf("unexpected report %+v", report)
+	}
+	if parentCount(client) != 1 || taskCount(client) != 0 {
+		t.Errorf("dry-run changed the provider with %d parents and %d tasks", parentCount(client), taskCount(client))
+	}
+}
+
+func TestExport(t *testing.T) {
+	ctx := context.Background()
+	client := testutil.NewMockToDoClient()
+	work, _ := client.CreateParent(ctx, "Work")
+	_, _ = client.CreateTask(ctx, work.ID, todoclient.ToDoTask{Name: "a", Labels: []string{"x"}})
+	codec, _ := NewCodec(CSV, Mapping{{Header: "List", Field: FieldParentName}, {Header: "Task", Field: FieldName}, {Header: "Labels", Field: FieldLabels}})
+
+	var buffer bytes.Buffer
+	if err := Export(ctx, client, codec, &buffer); err != nil {
+		t.Fatalf("error was not nil but '%v'", err)
+	}
+	if buffer.String() != "List,Task,Labels\nWork,a,x\n" {
+		t.Errorf("unexpected export '%s'", buffer.String())
+	}
+}
+
+func TestImport_PartialUpdate(t *testing.T) {
+	ctx := context.Background()
+	client := testutil.NewMockToDoClient()
+	work, _ := client.CreateParent(ctx, "Work")
+	existing, _ := client.CreateTask(ctx, work.ID, todoclient.ToDoTask{Name: "report", Labels: []string{"office"}})
+	codec, _ := NewCodec(CSV, Mapping{{Header: "id", Field: FieldID}, {Header: "is_completed", Field: FieldIsCompleted}})
+	input := "id,is_completed\n" +
+		existing.ID + ",true\n" +
+		"unknown,true\n"
+
+	report, err := Import(ctx, client, codec, strings.NewReader(input), ImportOptions{DefaultParentID: work.ID})
+	if err != nil {
+		t.Fatalf("error was not nil but '%v'", err)
+	}
+	// the row of the unknown task would create a task without name
+	if report.Updated != 1 || report.Created != 0 || report.Failed != 1 || report.Errors[0].Line != 3 {
+		t.Errorf("unexpected report %+v", report)
+	}
+	updated, _ := client.GetTask(ctx, work.ID, existing.ID)
+	if !updated.IsCompleted || updated.Name != "report" || len(updated.Labels) != 1 {
+		t.Errorf("expected only the completion to change but found %+v", updated)
+	}
+}
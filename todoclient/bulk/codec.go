@@ -0,0 +1,416 @@
+// Package bulk exports and imports tasks and parents as CSV or newline-delimited JSON
+package bulk
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	stderrors "errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jo-hoe/todoapi/pkg/errors"
+	"github.com/jo-hoe/todoapi/todoclient"
+)
+
+// Format of the encoded data
+type Format string
+
+const (
+	CSV    Format = "csv"
+	NDJSON Format = "ndjson" // one JSON object per line
+)
+
+// Fields of a row, which columns are mapped to
+const (
+	FieldParentID     = "parent_id"
+	FieldParentName   = "parent_name"
+	FieldID           = "id"
+	FieldName         = "name"
+	FieldDescription  = "description"
+	FieldDueDate      = "due_date"
+	FieldCreationTime = "creation_time"
+	FieldModifiedTime = "modified_time"
+	FieldIsCompleted  = "is_completed"
+	FieldLabels       = "labels"
+)
+
+var fields = []string{
+	FieldParentID, FieldParentName, FieldID, FieldName, FieldDescription,
+	FieldDueDate, FieldCreationTime, FieldModifiedTime, FieldIsCompleted, FieldLabels,
+}
+
+// Column maps a column header, or JSON key, to a field
+type Column struct {
+	Header string
+	Field  string
+}
+
+// Mapping lists the columns in output order
+type Mapping []Column
+
+// DefaultMapping returns a mapping of all fields with the field names as headers
+func DefaultMapping() Mapping {
+	mapping := make(Mapping, 0, len(fields))
+	for _, field := range fields {
+		mapping = append(mapping, Column{Header: field, Field: field})
+	}
+	return mapping
+}
+
+// Row is a task with its parent
+type Row struct {
+	ParentID   string
+	ParentName string
+	Task       todoclient.ToDoTask
+}
+
+// RowError is a problem with a single row
+type RowError struct {
+	Line  int    `json:"line"` // Line of the row in the input, starting at 1
+	Error string `json:"error"`
+}
+
+// Codec encodes and decodes rows in a format with a column mapping
+type Codec struct {
+	format         Format
+	mapping        Mapping
+	labelSeparator string
+}
+
+// NewCodec creates a codec. Labels are separated by semicolons in CSV cells
+// and written as arrays in JSON.
+func NewCodec(format Format, mapping Mapping) (*Codec, error) {
+	if format != CSV && format != NDJSON {
+		return nil, errors.NewValidationError("format", fmt.Sprintf("unsupported format '%s'", format))
+	}
+	if len(mapping) == 0 {
+		return nil, errors.NewValidationError("mapping", "mapping cannot be empty")
+	}
+	headers := make(map[string]bool, len(mapping))
+	for _, column := range mapping {
+		if !isField(column.Field) {
+			return nil, errors.NewValidationError("mapping", fmt.Sprintf("unknown field '%s'", column.Field))
+		}
+		key := strings.ToLower(column.Header)
+		if key == "" || headers[key] {
+			return nil, errors.NewValidationError("mapping", fmt.Sprintf("empty or duplicate header '%s'", column.Header))
+		}
+		headers[key] = true
+	}
+	return &Codec{
+		format:         format,
+		mapping:        mapping,
+		labelSeparator: ";",
+	}, nil
+}
+
+// HasField reports whether the mapping contains the field
+func (c *Codec) HasField(field string) bool {
+	for _, column := range c.mapping {
+		if column.Field == field {
+			return true
+		}
+	}
+	return false
+}
+
+// EncodeRows writes the rows, with a header line for CSV
+func (c *Codec) EncodeRows(w io.Writer, rows []Row) error {
+	var err error
+	if c.format == CSV {
+		err = c.encodeCSV(w, rows)
+	} else {
+		err = c.encodeNDJSON(w, rows)
+	}
+	if err != nil {
+		return errors.NewAPIError("BULK_WRITE_FAILED", "failed to write rows", err)
+	}
+	return nil
+}
+
+func (c *Codec) encodeCSV(w io.Writer, rows []Row) error {
+	writer := csv.NewWriter(w)
+	record := make([]string, len(c.mapping))
+	for i, column := range c.mapping {
+		record[i] = column.Header
+	}
+	if err := writer.Write(record); err != nil {
+		return err
+	}
+	for _, row := range rows {
+		for i, column := range c.mapping {
+			record[i] = c.cell(row, column.Field)
+		}
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+func (c *Codec) encodeNDJSON(w io.Writer, rows []Row) error {
+	encoder := json.NewEncoder(w)
+	for _, row := range rows {
+		object := make(map[string]interface{}, len(c.mapping))
+		for _, column := range c.mapping {
+			switch column.Field {
+			case FieldIsCompleted:
+				object[column.Header] = row.Task.IsCompleted
+			case FieldLabels:
+				object[column.Header] = nonNil(row.Task.Labels)
+			default:
+				object[column.Header] = c.cell(row, column.Field)
+			}
+		}
+		if err := encoder.Encode(object); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// cell returns the text of a field
+func (c *Codec) cell(row Row, field string) string {
+	switch field {
+	case FieldParentID:
+		return row.ParentID
+	case FieldParentName:
+		return row.ParentName
+	case FieldID:
+		return row.Task.ID
+	case FieldName:
+		return row.Task.Name
+	case FieldDescription:
+		return row.Task.Description
+	case FieldDueDate:
+		return formatTime(row.Task.DueDate)
+	case FieldCreationTime:
+		return formatTime(row.Task.CreationTime)
+	case FieldModifiedTime:
+		return formatTime(row.Task.ModifiedTime)
+	case FieldIsCompleted:
+		return strconv.FormatBool(row.Task.IsCompleted)
+	case FieldLabels:
+		return strings.Join(row.Task.Labels, c.labelSeparator)
+	}
+	return ""
+}
+
+// DecodeRows reads all rows. Rows which cannot be decoded are reported as
+// row errors, the returned error is only set if the input cannot be read.
+// Columns without a mapping are ignored.
+func (c *Codec) DecodeRows(r io.Reader) ([]DecodedRow, []RowError, error) {
+	if c.format == CSV {
+		return c.decodeCSV(r)
+	}
+	return c.decodeNDJSON(r)
+}
+
+// DecodedRow is a row with its line in the input
+type DecodedRow struct {
+	Line int
+	Row  Row
+}
+
+func (c *Codec) decodeCSV(r io.Reader) ([]DecodedRow, []RowError, error) {
+	rows := make([]DecodedRow, 0)
+	rowErrors := make([]RowError, 0)
+
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	header, err := reader.Read()
+	if err == io.EOF {
+		return rows, rowErrors, nil
+	}
+	if err != nil {
+		return nil, nil, errors.NewAPIError("BULK_READ_FAILED", "failed to read header", err)
+	}
+	columns := make([]string, len(header))
+	for i, name := range header {
+		columns[i] = c.fieldOf(strings.TrimPrefix(name, "\ufeff"))
+	}
+
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			var parseErr *csv.ParseError
+			if !stderrors.As(err, &parseErr) {
+				return nil, nil, errors.NewAPIError("BULK_READ_FAILED", "failed to read rows", err)
+			}
+			rowErrors = append(rowErrors, RowError{Line: parseErr.Line, Error: parseErr.Err.Error()})
+			continue
+		}
+		line, _ := reader.FieldPos(0)
+
+		var row Row
+		var rowErr error
+		for i, value := range record {
+			if i < len(columns) && columns[i] != "" && rowErr == nil {
+				rowErr = c.setField(&row, columns[i], value)
+			}
+		}
+		if rowErr != nil {
+			rowErrors = append(rowErrors, RowError{Line: line, Error: rowErr.Error()})
+			continue
+		}
+		rows = append(rows, DecodedRow{Line: line, Row: row})
+	}
+	return rows, rowErrors, nil
+}
+
+func (c *Codec) decodeNDJSON(r io.Reader) ([]DecodedRow, []RowError, error) {
+	rows := make([]DecodedRow, 0)
+	rowErrors := make([]RowError, 0)
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 10*1024*1024)
+	line := 0
+	for scanner.Scan() {
+		line++
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+
+		var object map[string]json.RawMessage
+		if err := json.Unmarshal(scanner.Bytes(), &object); err != nil {
+			rowErrors = append(rowErrors, RowError{Line: line, Error: err.Error()})
+			continue
+		}
+		var row Row
+		var rowErr error
+		for key, raw := range object {
+			field := c.fieldOf(key)
+			if field == "" || rowErr != nil {
+				continue
+			}
+			rowErr = c.setJSONField(&row, field, raw)
+		}
+		if rowErr != nil {
+			rowErrors = append(rowErrors, RowError{Line: line, Error: rowErr.Error()})
+			continue
+		}
+		rows = append(rows, DecodedRow{Line: line, Row: row})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, nil, errors.NewAPIError("BULK_READ_FAILED", "failed to read rows", err)
+	}
+	return rows, rowErrors, nil
+}
+
+func (c *Codec) setJSONField(row *Row, field string, raw json.RawMessage) error {
+	if string(raw) == "null" {
+		return nil
+	}
+	switch field {
+	case FieldIsCompleted:
+		var completed bool
+		if err := json.Unmarshal(raw, &completed); err == nil {
+			row.Task.IsCompleted = completed
+			return nil
+		}
+	case FieldLabels:
+		var labels []string
+		if err := json.Unmarshal(raw, &labels); err == nil {
+			row.Task.Labels = labels
+			return nil
+		}
+	}
+	var value string
+	if err := json.Unmarshal(raw, &value); err != nil {
+		return fmt.Errorf("invalid value for %s: %s", field, string(raw))
+	}
+	return c.setField(row, field, value)
+}
+
+// setField parses the text of a field into the row
+func (c *Codec) setField(row *Row, field, value string) error {
+	var err error
+	switch field {
+	case FieldParentID:
+		row.ParentID = strings.TrimSpace(value)
+	case FieldParentName:
+		row.ParentName = strings.TrimSpace(value)
+	case FieldID:
+		row.Task.ID = strings.TrimSpace(value)
+	case FieldName:
+		row.Task.Name = value
+	case FieldDescription:
+		row.Task.Description = value
+	case FieldDueDate:
+		row.Task.DueDate, err = parseTime(field, value)
+	case FieldCreationTime:
+		row.Task.CreationTime, err = parseTime(field, value)
+	case FieldModifiedTime:
+		row.Task.ModifiedTime, err = parseTime(field, value)
+	case FieldIsCompleted:
+		if value = strings.TrimSpace(value); value != "" {
+			if row.Task.IsCompleted, err = strconv.ParseBool(value); err != nil {
+				err = fmt.Errorf("invalid value for %s: '%s'", field, value)
+			}
+		}
+	case FieldLabels:
+		row.Task.Labels = make([]string, 0)
+		for _, label := range strings.Split(value, c.labelSeparator) {
+			if label = strings.TrimSpace(label); label != "" {
+				row.Task.Labels = append(row.Task.Labels, label)
+			}
+		}
+	}
+	return err
+}
+
+// fieldOf returns the field of a header, empty if the header is not mapped
+func (c *Codec) fieldOf(header string) string {
+	for _, column := range c.mapping {
+		if strings.EqualFold(strings.TrimSpace(header), column.Header) {
+			return column.Field
+		}
+	}
+	return ""
+}
+
+func isField(field string) bool {
+	for _, f := range fields {
+		if f == field {
+			return true
+		}
+	}
+	return false
+}
+
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format(time.RFC3339)
+}
+
+// parseTime accepts RFC 3339 times and plain dates, which spreadsheets tend to produce
+func parseTime(field, value string) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return time.Time{}, nil
+	}
+	for _, layout := range []string{time.RFC3339, "2006-01-02T15:04:05", "2006-01-02 15:04:05", "2006-01-02"} {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid value for %s: '%s'", field, value)
+}
+
+func nonNil(values []string) []string {
+	if values == nil {
+		return make([]string, 0)
+	}
+	return values
+}
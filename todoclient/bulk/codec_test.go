@@ -0,0 +1,136 @@
+package bulk
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jo-hoe/todoapi/todoclient"
+)
+
+func testRows() []Row {
+	return []Row{
+		{
+			ParentID:   "p1",
+			ParentName: "Work",
+			Task: todoclient.ToDoTask{
+				ID:           "t1",
+				Name:         "Report, \"final\"",
+				Description:  "multi\nline",
+				DueDate:      time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC),
+				CreationTime: time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC),
+				IsCompleted:  true,
+				Labels:       []string{"a", "b"},
+			},
+		},
+		{
+			ParentID: "p1",
+			Task:     todoclient.ToDoTask{ID: "t2", Name: "Call", Labels: []string{}},
+		},
+	}
+}
+
+func TestCodec_RoundTrip(t *testing.T) {
+	for _, format := range []Format{CSV, NDJSON} {
+		codec, err := NewCodec(format, DefaultMapping())
+		if err != nil {
+			t.Fatalf("error was not nil but '%v'", err)
+		}
+
+		var buffer bytes.Buffer
+		if err := codec.EncodeRows(&buffer, testRows()); err != nil {
+			t.Fatalf("error was not nil but '%v'", err)
+		}
+		decoded, rowErrors, err := codec.DecodeRows(&buffer)
+		if err != nil || len(rowErrors) != 0 {
+			t.Fatalf("%s: unexpected errors '%v' %v", format, err, rowErrors)
+		}
+
+		rows := make([]Row, 0)
+		for _, row := range decoded {
+			rows = append(rows, row.Row)
+		}
+		if !reflect.DeepEqual(rows, testRows()) {
+			t.Errorf("%s: expected %+v but found %+v", format, testRows(), rows)
+		}
+	}
+}
+
+func TestCodec_CustomMapping(t *testing.T) {
+	codec, err := NewCodec(CSV, Mapping{
+		{Header: "Title", Field: FieldName},
+		{Header: "Due", Field: FieldDueDate},
+		{Header: "Tags", Field: FieldLabels},
+	})
+	if err != nil {
+		t.Fatalf("error was not nil but '%v'", err)
+	}
+
+	var buffer bytes.Buffer
+	if err := codec.EncodeRows(&buffer, testRows()[:1]); err != nil {
+		t.Fatalf("error was not nil but '%v'", err)
+	}
+	expected := "Title,Due,Tags\n\"Report, \"\"final\"\"\",2024-03-01T10:00:00Z,a;b\n"
+	if buffer.String() != expected {
+		t.Errorf("expected '%s' but found '%s'", expected, buffer.String())
+	}
+
+	input := "\ufefftitle,Ignored,due,tags\nfirst,x,2024-03-01, a ; b \nsecond,x,tomorrow,\n\"third\",x,,\n"
+	rows, rowErrors, err := codec.DecodeRows(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("error was not nil but '%v'", err)
+	}
+	if len(rows) != 2 || rows[0].Line != 2 || rows[1].Line != 4 {
+		t.Fatalf("unexpected rows %+v", rows)
+	}
+	if !rows[0].Row.Task.DueDate.Equal(time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)) || !reflect.DeepEqual(rows[0].Row.Task.Labels, []string{"a", "b"}) {
+		t.Errorf("unexpected first row %+v", rows[0].Row)
+	}
+	if len(rowErrors) != 1 || rowErrors[0].Line != 3 {
+		t.Errorf("expected error in line 3 but found %+v", rowErrors)
+	}
+}
+
+func TestCodec_NDJSONErrors(t *testing.T) {
+	codec, _ := NewCodec(NDJSON, DefaultMapping())
+	input := "{\"name\":\"ok\",\"labels\":\"a;b\",\"is_completed\":\"true\"}\n\nnot json\n{\"name\":\"x\",\"is_completed\":3}\n"
+
+	rows, rowErrors, err := codec.DecodeRows(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("error was not nil but '%v'", err)
+	}
+	if len(rows) != 1 || !rows[0].Row.Task.IsCompleted || !reflect.DeepEqual(rows[0].Row.Task.Labels, []string{"a", "b"}) {
+		t.Errorf("unexpected rows %+v", rows)
+	}
+	if len(rowErrors) != 2 || rowErrors[0].Line != 3 || rowErrors[1].Line != 4 {
+		t.Errorf("unexpected row errors %+v", rowErrors)
+	}
+}
+
+func TestNewCodec_Invalid(t *testing.T) {
+	if _, err := NewCodec("xml", DefaultMapping()); err == nil {
+		t.Error("expected error for unknown format")
+	}
+	if _, err := NewCodec(CSV, Mapping{{Header: "a", Field: "unknown"}}); err == nil {
+		t.Error("expected error for unknown field")
+	}
+	if _, err := NewCodec(CSV, Mapping{{Header: "a", Field: FieldName}, {Header: "A", Field: FieldID}}); err == nil {
+		t.Error("expected error for duplicate header")
+	}
+}
+
+func TestParents_RoundTrip(t *testing.T) {
+	parents := []todoclient.ToDoParent{{ID: "1", Name: "Work"}, {ID: "2", Name: "Home"}}
+	for _, format := range []Format{CSV, NDJSON} {
+		var buffer bytes.Buffer
+		if err := EncodeParents(&buffer, format, parents); err != nil {
+			t.Fatalf("error was not nil but '%v'", err)
+		}
+		decoded, rowErrors, err := DecodeParents(&buffer, format)
+		if err != nil || len(rowErrors) != 0 || !reflect.DeepEqual(decoded, parents) {
+			t.Errorf("%s: expected %v but found %v, %v and '%v'", format, parents, decoded, rowErrors, err)
+		}
+	}
+}
@@ -0,0 +1,63 @@
+package bulk
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"io"
+
+	"github.com/jo-hoe/todoapi/pkg/errors"
+	"github.com/jo-hoe/todoapi/todoclient"
+)
+
+// EncodeParents writes parents with the columns id and name
+func EncodeParents(w io.Writer, format Format, parents []todoclient.ToDoParent) error {
+	var err error
+	if format == CSV {
+		writer := csv.NewWriter(w)
+		err = writer.Write([]string{FieldID, FieldName})
+		for _, parent := range parents {
+			if err != nil {
+				break
+			}
+			err = writer.Write([]string{parent.ID, parent.Name})
+		}
+		if err == nil {
+			writer.Flush()
+			err = writer.Error()
+		}
+	} else {
+		encoder := json.NewEncoder(w)
+		for _, parent := range parents {
+			if err = encoder.Encode(parent); err != nil {
+				break
+			}
+		}
+	}
+	if err != nil {
+		return errors.NewAPIError("BULK_WRITE_FAILED", "failed to write parents", err)
+	}
+	return nil
+}
+
+// DecodeParents reads parents written by EncodeParents
+func DecodeParents(r io.Reader, format Format) ([]todoclient.ToDoParent, []RowError, error) {
+	codec, err := NewCodec(format, Mapping{{Header: FieldID, Field: FieldID}, {Header: FieldName, Field: FieldName}})
+	if err != nil {
+		return nil, nil, err
+	}
+	rows, rowErrors, err := codec.DecodeRows(r)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	parents := make([]todoclient.ToDoParent, 0, len(rows))
+	for _, row := range rows {
+		parent := todoclient.ToDoParent{ID: row.Row.Task.ID, Name: row.Row.Task.Name}
+		if err := parent.Validate(); err != nil {
+			rowErrors = append(rowErrors, RowError{Line: row.Line, Error: err.Error()})
+			continue
+		}
+		parents = append(parents, parent)
+	}
+	return parents, rowErrors, nil
+}
@@ -0,0 +1,173 @@
+// Package cache provides a caching decorator for todo clients
+package cache
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/url"
+	"sync/atomic"
+	"time"
+
+	"github.com/jo-hoe/todoapi/todoclient"
+)
+
+const (
+	parentsKey        = "parents"
+	allTasksPrefix    = "tasks/all/"
+	parentTasksPrefix = "tasks/parent/"
+)
+
+// Options configures the time to live of cached listings
+type Options struct {
+	ParentsTTL time.Duration // TTL of GetAllParents results
+	TasksTTL   time.Duration // TTL of GetAllTasks and GetChildrenTasks results
+}
+
+// DefaultOptions returns options suitable for interactive usage
+func DefaultOptions() Options {
+	return Options{
+		ParentsTTL: 10 * time.Minute,
+		TasksTTL:   time.Minute,
+	}
+}
+
+// CachingClient is a ToDoClient decorator caching listings of parents and tasks.
+// Writes through the client invalidate all affected listings.
+type CachingClient struct {
+	next    todoclient.ToDoClient
+	storage Storage
+	options Options
+	// generation is incremented on every write, so that listings fetched
+	// concurrently to a write are not stored after the invalidation
+	generation atomic.Uint64
+}
+
+// NewCachingClient wraps a client with a cache using the given storage
+func NewCachingClient(next todoclient.ToDoClient, storage Storage, options Options) *CachingClient {
+	return &CachingClient{
+		next:    next,
+		storage: storage,
+		options: options,
+	}
+}
+
+// Middleware returns a middleware wrapping clients with a cache using the given storage
+func Middleware(storage Storage, options Options) todoclient.Middleware {
+	return func(next todoclient.ToDoClient) todoclient.ToDoClient {
+		return NewCachingClient(next, storage, options)
+	}
+}
+
+func (c *CachingClient) GetAllTasks(ctx context.Context, query *todoclient.TaskQuery) ([]todoclient.ToDoTask, error) {
+	return cached(c, allTasksPrefix+queryDigest(query), c.options.TasksTTL, func() ([]todoclient.ToDoTask, error) {
+		return c.next.GetAllTasks(ctx, query)
+	})
+}
+
+func (c *CachingClient) GetChildrenTasks(ctx context.Context, parentID string, query *todoclient.TaskQuery) ([]todoclient.ToDoTask, error) {
+	return cached(c, tasksOfParentPrefix(parentID)+queryDigest(query), c.options.TasksTTL, func() ([]todoclient.ToDoTask, error) {
+		return c.next.GetChildrenTasks(ctx, parentID, query)
+	})
+}
+
+func (c *CachingClient) GetTask(ctx context.Context, parentID, taskID string) (todoclient.ToDoTask, error) {
+	return c.next.GetTask(ctx, parentID, taskID)
+}
+
+func (c *CachingClient) CreateTask(ctx context.Context, parentID string, task todoclient.ToDoTask) (todoclient.ToDoTask, error) {
+	defer c.invalidateTasks(parentID)
+	return c.next.CreateTask(ctx, parentID, task)
+}
+
+func (c *CachingClient) UpdateTask(ctx context.Context, parentID string, task todoclient.ToDoTask) error {
+	defer c.invalidateTasks(parentID)
+	return c.next.UpdateTask(ctx, parentID, task)
+}
+
+func (c *CachingClient) DeleteTask(ctx context.Context, parentID, taskID string) error {
+	defer c.invalidateTasks(parentID)
+	return c.next.DeleteTask(ctx, parentID, taskID)
+}
+
+func (c *CachingClient) GetAllParents(ctx context.Context) ([]todoclient.ToDoParent, error) {
+	return cached(c, parentsKey, c.options.ParentsTTL, func() ([]todoclient.ToDoParent, error) {
+		return c.next.GetAllParents(ctx)
+	})
+}
+
+func (c *CachingClient) CreateParent(ctx context.Context, parentName string) (todoclient.ToDoParent, error) {
+	defer c.invalidateParents()
+	return c.next.CreateParent(ctx, parentName)
+}
+
+func (c *CachingClient) DeleteParent(ctx context.Context, parentID string) error {
+	defer c.invalidateParents()
+	defer c.invalidateTasks(parentID)
+	return c.next.DeleteParent(ctx, parentID)
+}
+
+// Capabilities reports the capabilities of the wrapped client
+func (c *CachingClient) Capabilities() todoclient.Capabilities {
+	return todoclient.CapabilitiesOf(c.next)
+}
+
+// Invalidate removes all cached listings
+func (c *CachingClient) Invalidate() {
+	c.generation.Add(1)
+	c.storage.Delete(parentsKey)
+	c.storage.DeletePrefix(allTasksPrefix)
+	c.storage.DeletePrefix(parentTasksPrefix)
+}
+
+// invalidateTasks removes the task listings affected by a write to a parent.
+// It is also called on failed writes, as the provider state may have changed anyway.
+func (c *CachingClient) invalidateTasks(parentID string) {
+	c.generation.Add(1)
+	c.storage.DeletePrefix(allTasksPrefix)
+	c.storage.DeletePrefix(tasksOfParentPrefix(parentID))
+}
+
+func (c *CachingClient) invalidateParents() {
+	c.generation.Add(1)
+	c.storage.Delete(parentsKey)
+}
+
+// cached returns the cached value of key or loads and stores it
+func cached[T any](c *CachingClient, key string, ttl time.Duration, load func() ([]T, error)) ([]T, error) {
+	if b, ok := c.storage.Get(key); ok {
+		var value []T
+		if err := json.Unmarshal(b, &value); err == nil && value != nil {
+			return value, nil
+		}
+	}
+
+	generation := c.generation.Load()
+	value, err := load()
+	if err != nil {
+		return value, err
+	}
+
+	if ttl > 0 && generation == c.generation.Load() {
+		if b, err := json.Marshal(value); err == nil {
+			c.storage.Set(key, b, ttl)
+		}
+	}
+	return value, nil
+}
+
+func tasksOfParentPrefix(parentID string) string {
+	// escaping keeps IDs containing slashes from matching other prefixes
+	return parentTasksPrefix + url.PathEscape(parentID) + "/"
+}
+
+// queryDigest identifies a query within a cache key
+func queryDigest(query *todoclient.TaskQuery) string {
+	if query.IsZero() {
+		return ""
+	}
+	b, _ := json.Marshal(query)
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:8])
+}
@@ -0,0 +1,246 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/jo-hoe/todoapi/todoclient"
+	"github.com/jo-hoe/todoapi/todoclient/conformance"
+	"github.com/jo-hoe/todoapi/todoclient/local"
+)
+
+func TestCachingClient_ImplementationTest(t *testing.T) {
+	// tests if interface is implemented
+	var _ todoclient.ToDoClient = (*CachingClient)(nil)
+}
+
+func TestCachingClient_Conformance(t *testing.T) {
+	// writes must invalidate listings for the suite to pass
+	next, err := local.NewLocalClient(t.TempDir())
+	if err != nil {
+		t.Fatalf("error was not nil but '%v'", err)
+	}
+	conformance.Run(t, NewCachingClient(next, NewMemoryStorage(100), DefaultOptions()), conformance.Options{})
+}
+
+// countingClient counts listing calls and keeps tasks per parent
+type countingClient struct {
+	calls   map[string]int
+	parents []todoclient.ToDoParent
+	tasks   map[string][]todoclient.ToDoTask
+	nextID  int
+}
+
+func newCountingClient() *countingClient {
+	return &countingClient{
+		calls:   make(map[string]int),
+		parents: []todoclient.ToDoParent{{ID: "p1", Name: "Work"}, {ID: "p2", Name: "Home"}},
+		tasks: map[string][]todoclient.ToDoTask{
+			"p1": {{ID: "t1", Name: "report"}},
+			"p2": {{ID: "t2", Name: "bike"}},
+		},
+	}
+}
+
+func (c *countingClient) GetAllTasks(ctx context.Context, query *todoclient.TaskQuery) ([]todoclient.ToDoTask, error) {
+	c.calls["GetAllTasks"]++
+	result := make([]todoclient.ToDoTask, 0)
+	for _, parent := range c.parents {
+		result = append(result, c.tasks[parent.ID]...)
+	}
+	return query.Apply(result), nil
+}
+
+func (c *countingClient) GetChildrenTasks(ctx context.Context, parentID string, query *todoclient.TaskQuery) ([]todoclient.ToDoTask, error) {
+	c.calls["GetChildrenTasks"]++
+	return query.Apply(c.tasks[parentID]), nil
+}
+
+func (c *countingClient) GetTask(ctx context.Context, parentID, taskID string) (todoclient.ToDoTask, error) {
+	c.calls["GetTask"]++
+	return todoclient.ToDoTask{ID: taskID}, nil
+}
+
+func (c *countingClient) CreateTask(ctx context.Context, parentID string, task todoclient.ToDoTask) (todoclient.ToDoTask, error) {
+	c.nextID++
+	task.ID = fmt.Sprintf("new%d", c.nextID)
+	c.tasks[parentID] = append(c.tasks[parentID], task)
+	return task, nil
+}
+
+func (c *countingClient) UpdateTask(ctx context.Context, parentID string, task todoclient.ToDoTask) error {
+	for i, existing := range c.tasks[parentID] {
+		if existing.ID == task.ID {
+			c.tasks[parentID][i] = task
+		}
+	}
+	return nil
+}
+
+func (c *countingClient) DeleteTask(ctx context.Context, parentID, taskID string) error {
+	return fmt.Errorf("delete failed")
+}
+
+func (c *countingClient) GetAllParents(ctx context.Context) ([]todoclient.ToDoParent, error) {
+	c.calls["GetAllParents"]++
+	return append([]todoclient.ToDoParent{}, c.parents...), nil
+}
+
+func (c *countingClient) CreateParent(ctx context.Context, parentName string) (todoclient.ToDoParent, error) {
+	parent := todoclient.ToDoParent{ID: parentName, Name: parentName}
+	c.parents = append(c.parents, parent)
+	return parent, nil
+}
+
+func (c *countingClient) DeleteParent(ctx context.Context, parentID string) error {
+	for i, parent := range c.parents {
+		if parent.ID == parentID {
+			c.parents = append(c.parents[:i], c.parents[i+1:]...)
+			break
+		}
+	}
+	delete(c.tasks, parentID)
+	return nil
+}
+
+func TestCachingClient_CachesListings(t *testing.T) {
+	next := newCountingClient()
+	client := NewCachingClient(next, NewMemoryStorage(100), DefaultOptions())
+	ctx := context.Background()
+
+	for i := 0; i < 3; i++ {
+		if _, err := client.GetAllParents(ctx); err != nil {
+			t.Fatalf("error was not nil but '%v'", err)
+		}
+		if _, err := client.GetAllTasks(ctx, nil); err != nil {
+			t.Fatalf("error was not nil but '%v'", err)
+		}
+		if _, err := client.GetChildrenTasks(ctx, "p1", nil); err != nil {
+			t.Fatalf("error was not nil but '%v'", err)
+		}
+	}
+
+	for _, method := range []string{"GetAllParents", "GetAllTasks", "GetChildrenTasks"} {
+		if next.calls[method] != 1 {
+			t.Errorf("expected 1 call of %s but found %d", method, next.calls[method])
+		}
+	}
+}
+
+func TestCachingClient_QueriesAreCachedSeparately(t *testing.T) {
+	next := newCountingClient()
+	client := NewCachingClient(next, NewMemoryStorage(100), DefaultOptions())
+	ctx := context.Background()
+
+	all, _ := client.GetChildrenTasks(ctx, "p1", nil)
+	filtered, _ := client.GetChildrenTasks(ctx, "p1", &todoclient.TaskQuery{Text: "unknown"})
+
+	if len(all) != 1 || len(filtered) != 0 {
+		t.Errorf("expected 1 and 0 tasks but found %d and %d", len(all), len(filtered))
+	}
+	if next.calls["GetChildrenTasks"] != 2 {
+		t.Errorf("expected 2 calls but found %d", next.calls["GetChildrenTasks"])
+	}
+}
+
+func TestCachingClient_WritesInvalidate(t *testing.T) {
+	next := newCountingClient()
+	client := NewCachingClient(next, NewMemoryStorage(100), DefaultOptions())
+	ctx := context.Background()
+
+	_, _ = client.GetChildrenTasks(ctx, "p1", nil)
+	_, _ = client.GetChildrenTasks(ctx, "p2", nil)
+	_, _ = client.GetAllTasks(ctx, nil)
+
+	created, err := client.CreateTask(ctx, "p1", todoclient.ToDoTask{Name: "new"})
+	if err != nil {
+		t.Fatalf("error was not nil but '%v'", err)
+	}
+
+	tasks, _ := client.GetChildrenTasks(ctx, "p1", nil)
+	if len(tasks) != 2 {
+		t.Errorf("expected 2 tasks after create but found %d", len(tasks))
+	}
+	all, _ := client.GetAllTasks(ctx, nil)
+	if len(all) != 3 {
+		t.Errorf("expected 3 tasks after create but found %d", len(all))
+	}
+	// the listing of the other parent is not affected
+	_, _ = client.GetChildrenTasks(ctx, "p2", nil)
+	if next.calls["GetChildrenTasks"] != 3 {
+		t.Errorf("expected 3 calls but found %d", next.calls["GetChildrenTasks"])
+	}
+
+	created.Name = "renamed"
+	if err := client.UpdateTask(ctx, "p1", created); err != nil {
+		t.Fatalf("error was not nil but '%v'", err)
+	}
+	tasks, _ = client.GetChildrenTasks(ctx, "p1", nil)
+	if tasks[1].Name != "renamed" {
+		t.Errorf("expected updated name but found '%s'", tasks[1].Name)
+	}
+
+	// failed writes invalidate as well
+	calls := next.calls["GetChildrenTasks"]
+	if err := client.DeleteTask(ctx, "p1", created.ID); err == nil {
+		t.Fatal("expected an error")
+	}
+	_, _ = client.GetChildrenTasks(ctx, "p1", nil)
+	if next.calls["GetChildrenTasks"] != calls+1 {
+		t.Error("expected listing to be reloaded after failed delete")
+	}
+}
+
+func TestCachingClient_ParentWritesInvalidate(t *testing.T) {
+	next := newCountingClient()
+	client := NewCachingClient(next, NewMemoryStorage(100), DefaultOptions())
+	ctx := context.Background()
+
+	_, _ = client.GetAllParents(ctx)
+	if _, err := client.CreateParent(ctx, "Errands"); err != nil {
+		t.Fatalf("error was not nil but '%v'", err)
+	}
+	parents, _ := client.GetAllParents(ctx)
+	if len(parents) != 3 {
+		t.Errorf("expected 3 parents but found %d", len(parents))
+	}
+
+	_, _ = client.GetChildrenTasks(ctx, "p2", nil)
+	if err := client.DeleteParent(ctx, "p2"); err != nil {
+		t.Fatalf("error was not nil but '%v'", err)
+	}
+	parents, _ = client.GetAllParents(ctx)
+	tasks, _ := client.GetChildrenTasks(ctx, "p2", nil)
+	if len(parents) != 2 || len(tasks) != 0 {
+		t.Errorf("expected 2 parents and 0 tasks but found %d and %d", len(parents), len(tasks))
+	}
+}
+
+func TestCachingClient_FileStorageSurvivesRestart(t *testing.T) {
+	dir := t.TempDir()
+	next := newCountingClient()
+	ctx := context.Background()
+
+	storage, err := NewFileStorage(dir)
+	if err != nil {
+		t.Fatalf("error was not nil but '%v'", err)
+	}
+	_, _ = NewCachingClient(next, storage, DefaultOptions()).GetAllParents(ctx)
+
+	restartedStorage, err := NewFileStorage(dir)
+	if err != nil {
+		t.Fatalf("error was not nil but '%v'", err)
+	}
+	parents, err := NewCachingClient(next, restartedStorage, DefaultOptions()).GetAllParents(ctx)
+	if err != nil {
+		t.Fatalf("error was not nil but '%v'", err)
+	}
+
+	if len(parents) != 2 {
+		t.Errorf("expected 2 parents but found %d", len(parents))
+	}
+	if next.calls["GetAllParents"] != 1 {
+		t.Errorf("expected 1 call but found %d", next.calls["GetAllParents"])
+	}
+}
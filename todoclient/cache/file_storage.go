@@ -0,0 +1,109 @@
+package cache
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+const fileExtension = ".json"
+
+// FileStorage is a Storage keeping one JSON file per entry in a directory,
+// so that cached data survives restarts. File names are the hex encoded keys
+// which keeps prefix deletion possible without an index.
+type FileStorage struct {
+	mu  sync.Mutex
+	dir string
+	now func() time.Time
+}
+
+type fileEntry struct {
+	ExpiresAt time.Time `json:"expires_at"`
+	Value     []byte    `json:"value"`
+}
+
+// NewFileStorage creates a file based storage in dir, creating the directory if needed
+func NewFileStorage(dir string) (*FileStorage, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, err
+	}
+	return &FileStorage{
+		dir: dir,
+		now: time.Now,
+	}, nil
+}
+
+func (s *FileStorage) Get(key string) ([]byte, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	path := s.path(key)
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, false
+	}
+
+	var entry fileEntry
+	if err := json.Unmarshal(b, &entry); err != nil {
+		_ = os.Remove(path)
+		return nil, false
+	}
+	if !s.now().Before(entry.ExpiresAt) {
+		_ = os.Remove(path)
+		return nil, false
+	}
+	return entry.Value, true
+}
+
+func (s *FileStorage) Set(key string, value []byte, ttl time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	b, err := json.Marshal(fileEntry{ExpiresAt: s.now().Add(ttl), Value: value})
+	if err != nil {
+		return
+	}
+
+	// Write atomically
+	path := s.path(key)
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, b, 0o600); err != nil {
+		_ = os.Remove(tmp)
+		return
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		_ = os.Remove(tmp)
+	}
+}
+
+func (s *FileStorage) Delete(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_ = os.Remove(s.path(key))
+}
+
+func (s *FileStorage) DeletePrefix(prefix string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return
+	}
+	encodedPrefix := hex.EncodeToString([]byte(prefix))
+	for _, entry := range entries {
+		name := entry.Name()
+		if strings.HasSuffix(name, fileExtension) && strings.HasPrefix(name, encodedPrefix) {
+			_ = os.Remove(filepath.Join(s.dir, name))
+		}
+	}
+}
+
+func (s *FileStorage) path(key string) string {
+	return filepath.Join(s.dir, hex.EncodeToString([]byte(key))+fileExtension)
+}
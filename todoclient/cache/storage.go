@@ -0,0 +1,121 @@
+package cache
+
+import (
+	"container/list"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Storage persists cache entries. Implementations must be safe for concurrent use.
+// Failures to read or write are treated as cache misses.
+type Storage interface {
+	// Get returns the value of a non-expired entry
+	Get(key string) ([]byte, bool)
+
+	// Set stores a value which expires after ttl
+	Set(key string, value []byte, ttl time.Duration)
+
+	// Delete removes an entry
+	Delete(key string)
+
+	// DeletePrefix removes all entries with keys starting with prefix
+	DeletePrefix(prefix string)
+}
+
+// MemoryStorage is an in-memory Storage which evicts the least recently used
+// entries once the capacity is reached.
+type MemoryStorage struct {
+	mu       sync.Mutex
+	capacity int
+	entries  map[string]*list.Element
+	order    *list.List // front is most recently used
+	now      func() time.Time
+}
+
+type memoryEntry struct {
+	key     string
+	value   []byte
+	expires time.Time
+}
+
+// NewMemoryStorage creates an LRU storage holding at most capacity entries
+func NewMemoryStorage(capacity int) *MemoryStorage {
+	if capacity <= 0 {
+		capacity = 1
+	}
+	return &MemoryStorage{
+		capacity: capacity,
+		entries:  make(map[string]*list.Element),
+		order:    list.New(),
+		now:      time.Now,
+	}
+}
+
+func (s *MemoryStorage) Get(key string) ([]byte, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	element, ok := s.entries[key]
+	if !ok {
+		return nil, false
+	}
+	entry := element.Value.(*memoryEntry)
+	if !s.now().Before(entry.expires) {
+		s.removeElement(element)
+		return nil, false
+	}
+	s.order.MoveToFront(element)
+	return entry.value, true
+}
+
+func (s *MemoryStorage) Set(key string, value []byte, ttl time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	expires := s.now().Add(ttl)
+	if element, ok := s.entries[key]; ok {
+		entry := element.Value.(*memoryEntry)
+		entry.value = value
+		entry.expires = expires
+		s.order.MoveToFront(element)
+		return
+	}
+
+	s.entries[key] = s.order.PushFront(&memoryEntry{key: key, value: value, expires: expires})
+	for s.order.Len() > s.capacity {
+		s.removeElement(s.order.Back())
+	}
+}
+
+func (s *MemoryStorage) Delete(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if element, ok := s.entries[key]; ok {
+		s.removeElement(element)
+	}
+}
+
+func (s *MemoryStorage) DeletePrefix(prefix string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for key, element := range s.entries {
+		if strings.HasPrefix(key, prefix) {
+			s.removeElement(element)
+		}
+	}
+}
+
+// Len returns the number of stored entries including expired ones not yet evicted
+func (s *MemoryStorage) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.order.Len()
+}
+
+func (s *MemoryStorage) removeElement(element *list.Element) {
+	s.order.Remove(element)
+	delete(s.entries, element.Value.(*memoryEntry).key)
+}
@@ -0,0 +1,93 @@
+package cache
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMemoryStorage_EvictsLeastRecentlyUsed(t *testing.T) {
+	storage := NewMemoryStorage(2)
+
+	storage.Set("a", []byte("1"), time.Minute)
+	storage.Set("b", []byte("2"), time.Minute)
+	storage.Get("a")
+	storage.Set("c", []byte("3"), time.Minute)
+
+	if _, ok := storage.Get("b"); ok {
+		t.Error("expected 'b' to be evicted")
+	}
+	if _, ok := storage.Get("a"); !ok {
+		t.Error("expected 'a' to be present")
+	}
+	if storage.Len() != 2 {
+		t.Errorf("expected 2 entries but found %d", storage.Len())
+	}
+}
+
+func TestMemoryStorage_Expiry(t *testing.T) {
+	now := time.Now()
+	storage := NewMemoryStorage(10)
+	storage.now = func() time.Time { return now }
+
+	storage.Set("a", []byte("1"), time.Minute)
+	now = now.Add(2 * time.Minute)
+
+	if _, ok := storage.Get("a"); ok {
+		t.Error("expected 'a' to be expired")
+	}
+}
+
+func TestStorage_DeletePrefix(t *testing.T) {
+	fileStorage, err := NewFileStorage(t.TempDir())
+	if err != nil {
+		t.Fatalf("error was not nil but '%v'", err)
+	}
+	storages := map[string]Storage{
+		"memory": NewMemoryStorage(10),
+		"file":   fileStorage,
+	}
+
+	for name, storage := range storages {
+		t.Run(name, func(t *testing.T) {
+			storage.Set("tasks/a/1", []byte("1"), time.Minute)
+			storage.Set("tasks/a/2", []byte("2"), time.Minute)
+			storage.Set("tasks/ab/1", []byte("3"), time.Minute)
+
+			storage.DeletePrefix("tasks/a/")
+
+			if _, ok := storage.Get("tasks/a/1"); ok {
+				t.Error("expected 'tasks/a/1' to be deleted")
+			}
+			if _, ok := storage.Get("tasks/a/2"); ok {
+				t.Error("expected 'tasks/a/2' to be deleted")
+			}
+			if value, ok := storage.Get("tasks/ab/1"); !ok || string(value) != "3" {
+				t.Error("expected 'tasks/ab/1' to be present")
+			}
+
+			storage.Delete("tasks/ab/1")
+			if _, ok := storage.Get("tasks/ab/1"); ok {
+				t.Error("expected 'tasks/ab/1' to be deleted")
+			}
+		})
+	}
+}
+
+func TestFileStorage_Expiry(t *testing.T) {
+	now := time.Now()
+	storage, err := NewFileStorage(t.TempDir())
+	if err != nil {
+		t.Fatalf("error was not nil but '%v'", err)
+	}
+	storage.now = func() time.Time { return now }
+
+	storage.Set("a", []byte("1"), time.Minute)
+	if _, ok := storage.Get("a"); !ok {
+		t.Error("expected 'a' to be present")
+	}
+	now = now.Add(2 * time.Minute)
+
+	if _, ok := storage.Get("a"); ok {
+		t.Error("expected 'a' to be expired")
+	}
+}
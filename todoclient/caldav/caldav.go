@@ -0,0 +1,515 @@
+// Package caldav provides a ToDoClient for CalDAV (RFC 4791) servers such as
+// Nextcloud or Radicale
+package caldav
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"sync"
+
+	"github.com/jo-hoe/todoapi/internal/common"
+	customhttp "github.com/jo-hoe/todoapi/internal/http"
+	"github.com/jo-hoe/todoapi/pkg/errors"
+	"github.com/jo-hoe/todoapi/todoclient"
+	"github.com/jo-hoe/todoapi/todoclient/ical"
+)
+
+const timeRangeFormat = "20060102T150405Z"
+
+// resource is the location and version of a task as last seen
+type resource struct {
+	href string
+	etag string
+}
+
+// CalDAVClient implements ToDoClient for a CalDAV server.
+//
+// Calendars supporting VTODO components are parents, their ID is the path of
+// the calendar collection. Tasks are identified by their UID.
+//
+// Updates and deletes send the ETag of the task as seen by the last read in an
+// If-Match header, so changes made by other clients in the meantime are not
+// overwritten but fail with an error wrapping ErrConflict. Updates replace the
+// whole VTODO, properties unknown to ToDoTask are not kept.
+type CalDAVClient struct {
+	httpClient *http.Client
+	endpoint   *url.URL
+
+	mu        sync.Mutex
+	home      *url.URL            // calendar home set, discovered on first use
+	resources map[string]resource // keyed by parent ID and UID
+}
+
+// NewCalDAVHTTPClient creates an HTTP client sending basic authentication with each request
+func NewCalDAVHTTPClient(username, password string) *http.Client {
+	credentials := base64.StdEncoding.EncodeToString([]byte(username + ":" + password))
+	return customhttp.NewHTTPClientWithHeader("Authorization", "Basic "+credentials)
+}
+
+// NewCalDAVClient creates a client for the server at endpoint, e.g.
+// https://cloud.example.com/remote.php/dav/ for Nextcloud. The calendars are
+// discovered on first use.
+func NewCalDAVClient(httpClient *http.Client, endpoint string) (*CalDAVClient, error) {
+	parsed, err := url.Parse(endpoint)
+	if err != nil || parsed.Scheme == "" || parsed.Host == "" {
+		return nil, errors.NewValidationError("endpoint", "invalid CalDAV endpoint '"+endpoint+"'")
+	}
+	return &CalDAVClient{
+		httpClient: httpClient,
+		endpoint:   parsed,
+		resources:  make(map[string]resource),
+	}, nil
+}
+
+func (client *CalDAVClient) GetAllTasks(ctx context.Context, query *todoclient.TaskQuery) ([]todoclient.ToDoTask, error) {
+	parents, err := client.GetAllParents(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]todoclient.ToDoTask, 0)
+	for _, parent := range parents {
+		tasks, err := client.report(ctx, parent.ID, todoQuery("", rangeStart(query), rangeEnd(query)))
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, tasks...)
+	}
+	return query.Apply(result), nil
+}
+
+func (client *CalDAVClient) GetChildrenTasks(ctx context.Context, parentID string, query *todoclient.TaskQuery) ([]todoclient.ToDoTask, error) {
+	tasks, err := client.report(ctx, parentID, todoQuery("", rangeStart(query), rangeEnd(query)))
+	if err != nil {
+		return nil, err
+	}
+	return query.Apply(tasks), nil
+}
+
+func (client *CalDAVClient) GetTask(ctx context.Context, parentID, taskID string) (todoclient.ToDoTask, error) {
+	tasks, err := client.report(ctx, parentID, todoQuery(taskID, "", ""))
+	if err != nil {
+		return todoclient.ToDoTask{}, err
+	}
+	// the UID filter matches substrings
+	for _, task := range tasks {
+		if task.ID == taskID {
+			return task, nil
+		}
+	}
+	return todoclient.ToDoTask{}, errors.NewAPIError("CALDAV_NOT_FOUND", fmt.Sprintf("task %s not found", taskID), errors.ErrNotFound)
+}
+
+func (client *CalDAVClient) CreateTask(ctx context.Context, parentID string, task todoclient.ToDoTask) (todoclient.ToDoTask, error) {
+	if err := task.Validate(); err != nil {
+		return todoclient.ToDoTask{}, err
+	}
+	uid, err := newUID()
+	if err != nil {
+		return todoclient.ToDoTask{}, err
+	}
+	task.ID = uid
+	if task.Labels == nil {
+		task.Labels = make([]string, 0)
+	}
+
+	calendar, err := client.resolve(parentID)
+	if err != nil {
+		return todoclient.ToDoTask{}, err
+	}
+	href := child(calendar, url.PathEscape(uid)+".ics")
+	// If-None-Match prevents replacing an existing resource
+	etag, err := client.put(ctx, href, task, "If-None-Match", "*")
+	if err != nil {
+		return todoclient.ToDoTask{}, err
+	}
+	client.remember(parentID, uid, resource{href: href.Path, etag: etag})
+	return task, nil
+}
+
+func (client *CalDAVClient) UpdateTask(ctx context.Context, parentID string, task todoclient.ToDoTask) error {
+	if err := task.Validate(); err != nil {
+		return err
+	}
+	known, err := client.lookup(ctx, parentID, task.ID)
+	if err != nil {
+		return err
+	}
+	href, err := client.resolve(known.href)
+	if err != nil {
+		return err
+	}
+
+	header, value := "If-Match", known.etag
+	if value == "" {
+		// servers without ETags cannot detect concurrent changes
+		header = ""
+	}
+	etag, err := client.put(ctx, href, task, header, value)
+	if err != nil {
+		return err
+	}
+	client.remember(parentID, task.ID, resource{href: known.href, etag: etag})
+	return nil
+}
+
+func (client *CalDAVClient) DeleteTask(ctx context.Context, parentID, taskID string) error {
+	known, err := client.lookup(ctx, parentID, taskID)
+	if err != nil {
+		return err
+	}
+	href, err := client.resolve(known.href)
+	if err != nil {
+		return err
+	}
+
+	header := make(http.Header)
+	if known.etag != "" {
+		header.Set("If-Match", known.etag)
+	}
+	resp, err := client.do(ctx, http.MethodDelete, href, header, nil)
+	if err != nil {
+		return err
+	}
+	defer common.CloseBody(resp.Body)
+
+	if err := checkStatus(resp, "CALDAV_DELETE_FAILED", http.StatusOK, http.StatusNoContent); err != nil {
+		return err
+	}
+	client.forget(parentID, taskID)
+	return nil
+}
+
+func (client *CalDAVClient) GetAllParents(ctx context.Context) ([]todoclient.ToDoParent, error) {
+	home, err := client.calendarHome(ctx)
+	if err != nil {
+		return nil, err
+	}
+	status, err := client.propfind(ctx, home, "1", calendarsRequest)
+	if err != nil {
+		return nil, errors.NewAPIError("CALDAV_GET_PARENTS_FAILED", "failed to list calendars", err)
+	}
+
+	result := make([]todoclient.ToDoParent, 0)
+	for _, response := range status.Responses {
+		p, ok := response.props()
+		if !ok || p.ResourceType.Calendar == nil || !p.supportsTodos() {
+			continue
+		}
+		href, err := client.resolve(response.Href)
+		if err != nil {
+			return nil, err
+		}
+		name := p.DisplayName
+		if name == "" {
+			name = path.Base(href.Path)
+		}
+		result = append(result, todoclient.ToDoParent{ID: href.Path, Name: name})
+	}
+	return result, nil
+}
+
+func (client *CalDAVClient) CreateParent(ctx context.Context, parentName string) (todoclient.ToDoParent, error) {
+	parentName = strings.TrimSpace(parentName)
+	if parentName == "" {
+		return todoclient.ToDoParent{}, errors.NewValidationError("name", "parent name cannot be empty")
+	}
+	home, err := client.calendarHome(ctx)
+	if err != nil {
+		return todoclient.ToDoParent{}, err
+	}
+	slug, err := newUID()
+	if err != nil {
+		return todoclient.ToDoParent{}, err
+	}
+	href := child(home, slug+"/")
+
+	header := make(http.Header)
+	header.Set("Content-Type", "application/xml; charset=utf-8")
+	resp, err := client.do(ctx, "MKCALENDAR", href, header, strings.NewReader(mkcalendarRequest(parentName)))
+	if err != nil {
+		return todoclient.ToDoParent{}, err
+	}
+	defer common.CloseBody(resp.Body)
+
+	if err := checkStatus(resp, "CALDAV_CREATE_PARENT_FAILED", http.StatusCreated); err != nil {
+		return todoclient.ToDoParent{}, err
+	}
+	return todoclient.ToDoParent{ID: href.Path, Name: parentName}, nil
+}
+
+func (client *CalDAVClient) DeleteParent(ctx context.Context, parentID string) error {
+	href, err := client.resolve(parentID)
+	if err != nil {
+		return err
+	}
+	resp, err := client.do(ctx, http.MethodDelete, href, nil, nil)
+	if err != nil {
+		return err
+	}
+	defer common.CloseBody(resp.Body)
+
+	return checkStatus(resp, "CALDAV_DELETE_PARENT_FAILED", http.StatusOK, http.StatusNoContent)
+}
+
+// Capabilities reports the features supported by the CalDAV client. Labels are
+// stored as categories, subtasks are not mapped.
+func (client *CalDAVClient) Capabilities() todoclient.Capabilities {
+	return todoclient.Capabilities{
+		Labels:       true,
+		DueDates:     true,
+		DueTime:      true,
+		CreateParent: true,
+		DeleteParent: true,
+	}
+}
+
+// calendarHome discovers the calendar home set of the current user, see RFC 6764 section 6
+func (client *CalDAVClient) calendarHome(ctx context.Context) (*url.URL, error) {
+	client.mu.Lock()
+	home := client.home
+	client.mu.Unlock()
+	if home != nil {
+		return home, nil
+	}
+
+	principal := client.endpoint
+	status, err := client.propfind(ctx, client.endpoint, "0", principalRequest)
+	if err != nil {
+		return nil, errors.NewAPIError("CALDAV_DISCOVERY_FAILED", "failed to find principal", err)
+	}
+	for _, response := range status.Responses {
+		if p, ok := response.props(); ok && p.CurrentUserPrincipal != nil {
+			if principal, err = client.resolve(p.CurrentUserPrincipal.Href); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	status, err = client.propfind(ctx, principal, "0", homeSetRequest)
+	if err != nil {
+		return nil, errors.NewAPIError("CALDAV_DISCOVERY_FAILED", "failed to find calendar home", err)
+	}
+	for _, response := range status.Responses {
+		if p, ok := response.props(); ok && p.CalendarHomeSet != nil {
+			if home, err = client.resolve(p.CalendarHomeSet.Href); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if home == nil {
+		return nil, errors.NewAPIError("CALDAV_DISCOVERY_FAILED", "server reported no calendar home", nil)
+	}
+
+	client.mu.Lock()
+	client.home = home
+	client.mu.Unlock()
+	return home, nil
+}
+
+// report runs a calendar-query on a calendar and remembers the ETags of the results
+func (client *CalDAVClient) report(ctx context.Context, parentID, body string) ([]todoclient.ToDoTask, error) {
+	href, err := client.resolve(parentID)
+	if err != nil {
+		return nil, err
+	}
+	status, err := client.multistatus(ctx, "REPORT", href, "1", body)
+	if err != nil {
+		return nil, errors.NewAPIError("CALDAV_GET_TASKS_FAILED", "failed to query tasks", err)
+	}
+
+	tasks := make([]todoclient.ToDoTask, 0, len(status.Responses))
+	for _, response := range status.Responses {
+		p, ok := response.props()
+		if !ok || p.CalendarData == "" {
+			continue
+		}
+		todos, err := ical.Decode(strings.NewReader(p.CalendarData))
+		if err != nil {
+			return nil, err
+		}
+		resourceHref, err := client.resolve(response.Href)
+		if err != nil {
+			return nil, err
+		}
+		for _, todo := range todos {
+			todo.Task.ID = todo.UID
+			tasks = append(tasks, todo.Task)
+			client.remember(parentID, todo.UID, resource{href: resourceHref.Path, etag: p.ETag})
+		}
+	}
+	return tasks, nil
+}
+
+// lookup returns the last seen resource of a task, querying the server if the task was not seen yet
+func (client *CalDAVClient) lookup(ctx context.Context, parentID, taskID string) (resource, error) {
+	client.mu.Lock()
+	known, ok := client.resources[resourceKey(parentID, taskID)]
+	client.mu.Unlock()
+	if ok {
+		return known, nil
+	}
+
+	if _, err := client.GetTask(ctx, parentID, taskID); err != nil {
+		return resource{}, err
+	}
+	client.mu.Lock()
+	defer client.mu.Unlock()
+	return client.resources[resourceKey(parentID, taskID)], nil
+}
+
+func (client *CalDAVClient) remember(parentID, taskID string, r resource) {
+	client.mu.Lock()
+	defer client.mu.Unlock()
+	client.resources[resourceKey(parentID, taskID)] = r
+}
+
+func (client *CalDAVClient) forget(parentID, taskID string) {
+	client.mu.Lock()
+	defer client.mu.Unlock()
+	delete(client.resources, resourceKey(parentID, taskID))
+}
+
+func resourceKey(parentID, taskID string) string {
+	return parentID + "\n" + taskID
+}
+
+// put writes a task as calendar resource and returns the new ETag, which is
+// empty if the server does not return one
+func (client *CalDAVClient) put(ctx context.Context, href *url.URL, task todoclient.ToDoTask, condition, value string) (string, error) {
+	var body bytes.Buffer
+	if err := ical.NewEncoder(&body).Encode(ical.Collection{Tasks: []todoclient.ToDoTask{task}}); err != nil {
+		return "", err
+	}
+
+	header := make(http.Header)
+	header.Set("Content-Type", "text/calendar; charset=utf-8")
+	if condition != "" {
+		header.Set(condition, value)
+	}
+	resp, err := client.do(ctx, http.MethodPut, href, header, &body)
+	if err != nil {
+		return "", err
+	}
+	defer common.CloseBody(resp.Body)
+
+	if err := checkStatus(resp, "CALDAV_PUT_FAILED", http.StatusOK, http.StatusCreated, http.StatusNoContent); err != nil {
+		return "", err
+	}
+	return resp.Header.Get("ETag"), nil
+}
+
+func (client *CalDAVClient) propfind(ctx context.Context, href *url.URL, depth, body string) (*multistatus, error) {
+	return client.multistatus(ctx, "PROPFIND", href, depth, body)
+}
+
+func (client *CalDAVClient) multistatus(ctx context.Context, method string, href *url.URL, depth, body string) (*multistatus, error) {
+	header := make(http.Header)
+	header.Set("Content-Type", "application/xml; charset=utf-8")
+	header.Set("Depth", depth)
+	resp, err := client.do(ctx, method, href, header, strings.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	defer common.CloseBody(resp.Body)
+
+	if err := checkStatus(resp, "CALDAV_"+method+"_FAILED", http.StatusMultiStatus); err != nil {
+		return nil, err
+	}
+	status := &multistatus{}
+	if err := xml.NewDecoder(resp.Body).Decode(status); err != nil {
+		return nil, errors.NewAPIError("CALDAV_DECODE_FAILED", "failed to decode multistatus", err)
+	}
+	return status, nil
+}
+
+func (client *CalDAVClient) do(ctx context.Context, method string, href *url.URL, header http.Header, body io.Reader) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, href.String(), body)
+	if err != nil {
+		return nil, errors.NewAPIError("CALDAV_REQUEST_FAILED", "failed to create request", err)
+	}
+	for name, values := range header {
+		req.Header[name] = values
+	}
+
+	resp, err := client.httpClient.Do(req)
+	if err != nil {
+		return nil, errors.NewAPIError("CALDAV_HTTP_FAILED", "HTTP request failed", err)
+	}
+	return resp, nil
+}
+
+// resolve returns the URL of a href, which may be a path or an absolute URL
+func (client *CalDAVClient) resolve(href string) (*url.URL, error) {
+	parsed, err := url.Parse(href)
+	if err != nil || href == "" {
+		return nil, errors.NewAPIError("CALDAV_NOT_FOUND", "invalid href '"+href+"'", errors.ErrNotFound)
+	}
+	return client.endpoint.ResolveReference(parsed), nil
+}
+
+// child returns the URL of a member of a collection
+func child(collection *url.URL, name string) *url.URL {
+	base := *collection
+	if !strings.HasSuffix(base.Path, "/") {
+		base.Path += "/"
+		base.RawPath = ""
+	}
+	return base.ResolveReference(&url.URL{Path: name})
+}
+
+// checkStatus maps unexpected status codes to errors. Missing resources wrap
+// ErrNotFound and failed preconditions ErrConflict.
+func checkStatus(resp *http.Response, code string, expected ...int) error {
+	for _, status := range expected {
+		if resp.StatusCode == status {
+			return nil
+		}
+	}
+	message := fmt.Sprintf("request failed with status %d", resp.StatusCode)
+	switch resp.StatusCode {
+	case http.StatusNotFound:
+		return errors.NewAPIError(code, message, errors.ErrNotFound)
+	case http.StatusPreconditionFailed:
+		return errors.NewAPIError(code, message, errors.ErrConflict)
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return errors.NewAPIError(code, message, errors.ErrUnauthorized)
+	}
+	return errors.NewAPIError(code, message, nil)
+}
+
+func rangeStart(query *todoclient.TaskQuery) string {
+	if query == nil || query.DueAfter.IsZero() {
+		return ""
+	}
+	return query.DueAfter.UTC().Format(timeRangeFormat)
+}
+
+func rangeEnd(query *todoclient.TaskQuery) string {
+	if query == nil || query.DueBefore.IsZero() {
+		return ""
+	}
+	return query.DueBefore.UTC().Format(timeRangeFormat)
+}
+
+// newUID returns a random UUID (version 4)
+func newUID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", errors.NewAPIError("CALDAV_ID_FAILED", "failed to generate uid", err)
+	}
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	s := hex.EncodeToString(b)
+	return s[:8] + "-" + s[8:12] + "-" + s[12:16] + "-" + s[16:20] + "-" + s[20:], nil
+}
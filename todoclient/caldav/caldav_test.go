@@ -0,0 +1,362 @@
+package caldav
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	stderrors "errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jo-hoe/todoapi/pkg/errors"
+	"github.com/jo-hoe/todoapi/todoclient"
+	"github.com/jo-hoe/todoapi/todoclient/conformance"
+)
+
+const (
+	principalPath = "/principals/alice/"
+	homePath      = "/calendars/alice/"
+)
+
+type fakeCalendar struct {
+	name       string
+	components []string
+	items      map[string]*fakeItem // keyed by path
+}
+
+type fakeItem struct {
+	data string
+	etag string
+}
+
+// fakeServer is a minimal in-process CalDAV server with a fixed principal and calendar home
+type fakeServer struct {
+	mu        sync.Mutex
+	calendars map[string]*fakeCalendar // keyed by path
+	version   int
+	reports   []string
+}
+
+func newFakeServer() *fakeServer {
+	return &fakeServer{
+		calendars: map[string]*fakeCalendar{
+			homePath + "tasks/":  {name: "Tasks", components: []string{"VTODO"}, items: make(map[string]*fakeItem)},
+			homePath + "events/": {name: "Events", components: []string{"VEVENT"}, items: make(map[string]*fakeItem)},
+		},
+	}
+}
+
+func (s *fakeServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	body, _ := io.ReadAll(r.Body)
+	switch r.Method {
+	case "PROPFIND":
+		s.propfind(w, r, string(body))
+	case "REPORT":
+		s.report(w, r, string(body))
+	case "MKCALENDAR":
+		var request struct {
+			Name string `xml:"set>prop>displayname"`
+		}
+		_ = xml.Unmarshal(body, &request)
+		s.calendars[r.URL.Path] = &fakeCalendar{name: request.Name, components: []string{"VTODO"}, items: make(map[string]*fakeItem)}
+		w.WriteHeader(http.StatusCreated)
+	case http.MethodPut:
+		calendar, item := s.find(r.URL.Path)
+		if calendar == nil {
+			w.WriteHeader(http.StatusConflict)
+			return
+		}
+		if r.Header.Get("If-None-Match") == "*" && item != nil ||
+			r.Header.Get("If-Match") != "" && (item == nil || item.etag != r.Header.Get("If-Match")) {
+			w.WriteHeader(http.StatusPreconditionFailed)
+			return
+		}
+		etag := s.nextETag()
+		calendar.items[r.URL.Path] = &fakeItem{data: string(body), etag: etag}
+		w.Header().Set("ETag", etag)
+		w.WriteHeader(http.StatusCreated)
+	case http.MethodDelete:
+		if _, ok := s.calendars[r.URL.Path]; ok {
+			delete(s.calendars, r.URL.Path)
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		calendar, item := s.find(r.URL.Path)
+		if item == nil {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if match := r.Header.Get("If-Match"); match != "" && match != item.etag {
+			w.WriteHeader(http.StatusPreconditionFailed)
+			return
+		}
+		delete(calendar.items, r.URL.Path)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func (s *fakeServer) propfind(w http.ResponseWriter, r *http.Request, body string) {
+	var b strings.Builder
+	switch {
+	case strings.Contains(body, "current-user-principal"):
+		writeResponse(&b, r.URL.Path, `<d:current-user-principal><d:href>`+principalPath+`</d:href></d:current-user-principal>`)
+	case strings.Contains(body, "calendar-home-set") && r.URL.Path == principalPath:
+		writeResponse(&b, r.URL.Path, `<c:calendar-home-set><d:href>`+homePath+`</d:href></c:calendar-home-set>`)
+	case r.URL.Path == homePath && r.Header.Get("Depth") == "1":
+		writeResponse(&b, homePath, `<d:resourcetype><d:collection/></d:resourcetype>`)
+		paths := make([]string, 0, len(s.calendars))
+		for path := range s.calendars {
+			paths = append(paths, path)
+		}
+		sort.Strings(paths)
+		for _, path := range paths {
+			calendar := s.calendars[path]
+			components := ""
+			for _, name := range calendar.components {
+				components += `<c:comp name="` + name + `"/>`
+			}
+			var name bytes.Buffer
+			_ = xml.EscapeText(&name, []byte(calendar.name))
+			writeResponse(&b, path, `<d:resourcetype><d:collection/><c:calendar/></d:resourcetype>`+
+				`<d:displayname>`+name.String()+`</d:displayname>`+
+				`<c:supported-calendar-component-set>`+components+`</c:supported-calendar-component-set>`)
+		}
+	default:
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	writeMultistatus(w, b.String())
+}
+
+func (s *fakeServer) report(w http.ResponseWriter, r *http.Request, body string) {
+	calendar, ok := s.calendars[r.URL.Path]
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	s.reports = append(s.reports, body)
+	var query struct {
+		UID string `xml:"filter>comp-filter>comp-filter>prop-filter>text-match"`
+	}
+	_ = xml.Unmarshal([]byte(body), &query)
+
+	paths := make([]string, 0, len(calendar.items))
+	for path := range calendar.items {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	var b strings.Builder
+	for _, path := range paths {
+		item := calendar.items[path]
+		if query.UID != "" && !strings.Contains(item.data, "UID:"+query.UID) {
+			continue
+		}
+		var data bytes.Buffer
+		_ = xml.EscapeText(&data, []byte(item.data))
+		writeResponse(&b, path, `<d:getetag>`+item.etag+`</d:getetag><c:calendar-data>`+data.String()+`</c:calendar-data>`)
+	}
+	writeMultistatus(w, b.String())
+}
+
+// find returns the calendar of a resource path and the resource, if it exists
+func (s *fakeServer) find(path string) (*fakeCalendar, *fakeItem) {
+	calendar := s.calendars[path[:strings.LastIndex(path, "/")+1]]
+	if calendar == nil {
+		return nil, nil
+	}
+	return calendar, calendar.items[path]
+}
+
+func (s *fakeServer) nextETag() string {
+	s.version++
+	return fmt.Sprintf(`"%d"`, s.version)
+}
+
+// modify changes a resource behind the back of the client
+func (s *fakeServer) modify(path string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, item := s.find(path)
+	item.etag = s.nextETag()
+}
+
+func writeResponse(b *strings.Builder, href, props string) {
+	fmt.Fprintf(b, `<d:response><d:href>%s</d:href><d:propstat><d:prop>%s</d:prop><d:status>HTTP/1.1 200 OK</d:status></d:propstat></d:response>`, href, props)
+}
+
+func writeMultistatus(w http.ResponseWriter, responses string) {
+	w.Header().Set("Content-Type", "application/xml; charset=utf-8")
+	w.WriteHeader(http.StatusMultiStatus)
+	_, _ = fmt.Fprintf(w, `<?xml version="1.0" encoding="utf-8"?><d:multistatus xmlns:d="%s" xmlns:c="%s">%s</d:multistatus>`, nsDAV, nsCalDAV, responses)
+}
+
+func newTestClient(t *testing.T) (*CalDAVClient, *fakeServer) {
+	t.Helper()
+	fake := newFakeServer()
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+
+	client, err := NewCalDAVClient(server.Client(), server.URL+"/")
+	if err != nil {
+		t.Fatalf("error was not nil but '%v'", err)
+	}
+	return client, fake
+}
+
+func TestCalDAVClient_ImplementationTest(t *testing.T) {
+	// tests if interface is implemented
+	var _ todoclient.ToDoClient = (*CalDAVClient)(nil)
+}
+
+func TestCalDAVClient_Conformance(t *testing.T) {
+	client, _ := newTestClient(t)
+	conformance.Run(t, client, conformance.Options{})
+}
+
+func TestNewCalDAVClient_InvalidEndpoint(t *testing.T) {
+	_, err := NewCalDAVClient(http.DefaultClient, "no-url")
+
+	if err == nil {
+		t.Error("expected error for invalid endpoint")
+	}
+}
+
+func TestCalDAVClient_GetAllParents(t *testing.T) {
+	client, _ := newTestClient(t)
+
+	parents, err := client.GetAllParents(context.Background())
+
+	if err != nil {
+		t.Fatalf("error was not nil but '%v'", err)
+	}
+	// the calendar without VTODO support is skipped
+	if len(parents) != 1 || parents[0].ID != homePath+"tasks/" || parents[0].Name != "Tasks" {
+		t.Errorf("expected the task calendar but found %v", parents)
+	}
+}
+
+func TestCalDAVClient_CRUD(t *testing.T) {
+	client, _ := newTestClient(t)
+	ctx := context.Background()
+
+	parent, err := client.CreateParent(ctx, "Work & Home")
+	if err != nil {
+		t.Fatalf("error was not nil but '%v'", err)
+	}
+	due := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	task, err := client.CreateTask(ctx, parent.ID, todoclient.ToDoTask{Name: "Report", DueDate: due, Labels: []string{"office"}})
+	if err != nil {
+		t.Fatalf("error was not nil but '%v'", err)
+	}
+
+	task.Name = "Final report"
+	task.IsCompleted = true
+	if err := client.UpdateTask(ctx, parent.ID, task); err != nil {
+		t.Errorf("error was not nil but '%v'", err)
+	}
+
+	found, err := client.GetTask(ctx, parent.ID, task.ID)
+	if err != nil {
+		t.Fatalf("error was not nil but '%v'", err)
+	}
+	if found.Name != "Final report" || !found.IsCompleted || !found.DueDate.Equal(due) || len(found.Labels) != 1 {
+		t.Errorf("expected updated task but found %+v", found)
+	}
+	parents, _ := client.GetAllParents(ctx)
+	created := false
+	for _, p := range parents {
+		created = created || p.ID == parent.ID && p.Name == "Work & Home"
+	}
+	if len(parents) != 2 || !created {
+		t.Errorf("expected new parent but found %v", parents)
+	}
+
+	if err := client.DeleteTask(ctx, parent.ID, task.ID); err != nil {
+		t.Errorf("error was not nil but '%v'", err)
+	}
+	tasks, err := client.GetChildrenTasks(ctx, parent.ID, nil)
+	if err != nil {
+		t.Errorf("error was not nil but '%v'", err)
+	}
+	if len(tasks) != 0 {
+		t.Errorf("expected no tasks but found %v", tasks)
+	}
+	if err := client.DeleteParent(ctx, parent.ID); err != nil {
+		t.Errorf("error was not nil but '%v'", err)
+	}
+}
+
+func TestCalDAVClient_UpdateTask_Conflict(t *testing.T) {
+	client, fake := newTestClient(t)
+	ctx := context.Background()
+	parentID := homePath + "tasks/"
+	task, err := client.CreateTask(ctx, parentID, todoclient.ToDoTask{Name: "Report"})
+	if err != nil {
+		t.Fatalf("error was not nil but '%v'", err)
+	}
+
+	fake.modify(parentID + task.ID + ".ics")
+	task.Name = "Overwrite"
+	err = client.UpdateTask(ctx, parentID, task)
+
+	if !stderrors.Is(err, errors.ErrConflict) {
+		t.Errorf("expected conflict error but found '%v'", err)
+	}
+	// reading the task picks up the new ETag
+	if _, err := client.GetTask(ctx, parentID, task.ID); err != nil {
+		t.Fatalf("error was not nil but '%v'", err)
+	}
+	if err := client.UpdateTask(ctx, parentID, task); err != nil {
+		t.Errorf("error was not nil but '%v'", err)
+	}
+}
+
+func TestCalDAVClient_GetTask_NotFound(t *testing.T) {
+	client, _ := newTestClient(t)
+	ctx := context.Background()
+	parentID := homePath + "tasks/"
+	task, _ := client.CreateTask(ctx, parentID, todoclient.ToDoTask{Name: "Report"})
+
+	// a prefix of an existing UID matches the server-side filter only
+	_, err := client.GetTask(ctx, parentID, task.ID[:8])
+
+	if !stderrors.Is(err, errors.ErrNotFound) {
+		t.Errorf("expected not found error but found '%v'", err)
+	}
+	if _, err := client.GetChildrenTasks(ctx, homePath+"unknown/", nil); !stderrors.Is(err, errors.ErrNotFound) {
+		t.Errorf("expected not found error but found '%v'", err)
+	}
+}
+
+func TestCalDAVClient_GetAllTasks_TimeRange(t *testing.T) {
+	client, fake := newTestClient(t)
+	ctx := context.Background()
+	parentID := homePath + "tasks/"
+	_, _ = client.CreateTask(ctx, parentID, todoclient.ToDoTask{Name: "early", DueDate: time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)})
+	_, _ = client.CreateTask(ctx, parentID, todoclient.ToDoTask{Name: "late", DueDate: time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC)})
+
+	tasks, err := client.GetAllTasks(ctx, &todoclient.TaskQuery{DueAfter: time.Date(2024, 3, 15, 0, 0, 0, 0, time.UTC)})
+
+	if err != nil {
+		t.Fatalf("error was not nil but '%v'", err)
+	}
+	if len(tasks) != 1 || tasks[0].Name != "late" {
+		t.Errorf("expected only 'late' but found %v", tasks)
+	}
+	last := fake.reports[len(fake.reports)-1]
+	if !strings.Contains(last, `<c:time-range start="20240315T000000Z"/>`) {
+		t.Errorf("expected time range in report but found %s", last)
+	}
+}
@@ -0,0 +1,157 @@
+package caldav
+
+import (
+	"encoding/xml"
+	"strings"
+)
+
+// XML namespaces of WebDAV (RFC 4918) and CalDAV (RFC 4791)
+const (
+	nsDAV    = "DAV:"
+	nsCalDAV = "urn:ietf:params:xml:ns:caldav"
+)
+
+// Request bodies of the discovery steps
+const (
+	principalRequest = `<?xml version="1.0" encoding="utf-8"?>
+<d:propfind xmlns:d="DAV:"><d:prop><d:current-user-principal/></d:prop></d:propfind>`
+
+	homeSetRequest = `<?xml version="1.0" encoding="utf-8"?>
+<d:propfind xmlns:d="DAV:" xmlns:c="urn:ietf:params:xml:ns:caldav"><d:prop><c:calendar-home-set/></d:prop></d:propfind>`
+
+	calendarsRequest = `<?xml version="1.0" encoding="utf-8"?>
+<d:propfind xmlns:d="DAV:" xmlns:c="urn:ietf:params:xml:ns:caldav">
+<d:prop><d:resourcetype/><d:displayname/><c:supported-calendar-component-set/></d:prop>
+</d:propfind>`
+)
+
+type multistatus struct {
+	XMLName   xml.Name   `xml:"DAV: multistatus"`
+	Responses []response `xml:"DAV: response"`
+}
+
+type response struct {
+	Href      string     `xml:"DAV: href"`
+	Propstats []propstat `xml:"DAV: propstat"`
+}
+
+type propstat struct {
+	Prop   prop   `xml:"DAV: prop"`
+	Status string `xml:"DAV: status"`
+}
+
+type prop struct {
+	DisplayName          string        `xml:"DAV: displayname"`
+	ResourceType         resourceType  `xml:"DAV: resourcetype"`
+	CurrentUserPrincipal *hrefProp     `xml:"DAV: current-user-principal"`
+	CalendarHomeSet      *hrefProp     `xml:"urn:ietf:params:xml:ns:caldav calendar-home-set"`
+	SupportedComponents  *componentSet `xml:"urn:ietf:params:xml:ns:caldav supported-calendar-component-set"`
+	ETag                 string        `xml:"DAV: getetag"`
+	CalendarData         string        `xml:"urn:ietf:params:xml:ns:caldav calendar-data"`
+}
+
+type hrefProp struct {
+	Href string `xml:"DAV: href"`
+}
+
+type resourceType struct {
+	Collection *struct{} `xml:"DAV: collection"`
+	Calendar   *struct{} `xml:"urn:ietf:params:xml:ns:caldav calendar"`
+}
+
+type componentSet struct {
+	Components []component `xml:"urn:ietf:params:xml:ns:caldav comp"`
+}
+
+type component struct {
+	Name string `xml:"name,attr"`
+}
+
+// supportsTodos reports whether a calendar may contain VTODO components.
+// Calendars without a component set accept all components.
+func (p prop) supportsTodos() bool {
+	if p.SupportedComponents == nil {
+		return true
+	}
+	for _, component := range p.SupportedComponents.Components {
+		if strings.EqualFold(component.Name, "VTODO") {
+			return true
+		}
+	}
+	return false
+}
+
+// props returns the merged properties of all successful propstat elements
+func (r response) props() (prop, bool) {
+	var result prop
+	found := false
+	for _, propstat := range r.Propstats {
+		if !strings.Contains(propstat.Status, " 200 ") {
+			continue
+		}
+		found = true
+		p := propstat.Prop
+		if p.DisplayName != "" {
+			result.DisplayName = p.DisplayName
+		}
+		if p.ResourceType.Collection != nil || p.ResourceType.Calendar != nil {
+			result.ResourceType = p.ResourceType
+		}
+		if p.CurrentUserPrincipal != nil {
+			result.CurrentUserPrincipal = p.CurrentUserPrincipal
+		}
+		if p.CalendarHomeSet != nil {
+			result.CalendarHomeSet = p.CalendarHomeSet
+		}
+		if p.SupportedComponents != nil {
+			result.SupportedComponents = p.SupportedComponents
+		}
+		if p.ETag != "" {
+			result.ETag = p.ETag
+		}
+		if p.CalendarData != "" {
+			result.CalendarData = p.CalendarData
+		}
+	}
+	return result, found
+}
+
+// todoQuery returns a calendar-query REPORT body for the VTODO components of a
+// calendar. The UID filter is a substring match, results have to be compared
+// exactly. Time bounds are in UTC and empty if unbounded.
+func todoQuery(uid, start, end string) string {
+	var b strings.Builder
+	b.WriteString(`<?xml version="1.0" encoding="utf-8"?>
+<c:calendar-query xmlns:d="DAV:" xmlns:c="urn:ietf:params:xml:ns:caldav">
+<d:prop><d:getetag/><c:calendar-data/></d:prop>
+<c:filter><c:comp-filter name="VCALENDAR"><c:comp-filter name="VTODO">`)
+	if start != "" || end != "" {
+		b.WriteString(`<c:time-range`)
+		if start != "" {
+			b.WriteString(` start="` + start + `"`)
+		}
+		if end != "" {
+			b.WriteString(` end="` + end + `"`)
+		}
+		b.WriteString(`/>`)
+	}
+	if uid != "" {
+		b.WriteString(`<c:prop-filter name="UID"><c:text-match collation="i;octet">`)
+		_ = xml.EscapeText(&b, []byte(uid))
+		b.WriteString(`</c:text-match></c:prop-filter>`)
+	}
+	b.WriteString(`</c:comp-filter></c:comp-filter></c:filter>
+</c:calendar-query>`)
+	return b.String()
+}
+
+// mkcalendarRequest returns a MKCALENDAR body creating a task calendar
+func mkcalendarRequest(name string) string {
+	var b strings.Builder
+	b.WriteString(`<?xml version="1.0" encoding="utf-8"?>
+<c:mkcalendar xmlns:d="DAV:" xmlns:c="urn:ietf:params:xml:ns:caldav"><d:set><d:prop><d:displayname>`)
+	_ = xml.EscapeText(&b, []byte(name))
+	b.WriteString(`</d:displayname><c:supported-calendar-component-set><c:comp name="VTODO"/></c:supported-calendar-component-set>`)
+	b.WriteString(`</d:prop></d:set></c:mkcalendar>`)
+	return b.String()
+}
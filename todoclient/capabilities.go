@@ -0,0 +1,51 @@
+package todoclient
+
+// Capabilities describes which features a provider supports through this API.
+// Callers use them to hide unsupported features or to avoid lossy conversions.
+type Capabilities struct {
+	Subtasks     bool `json:"subtasks"`      // ParentTaskID is stored
+	Labels       bool `json:"labels"`        // Labels are stored
+	DueDates     bool `json:"due_dates"`     // DueDate can be set, false if it is read-only
+	DueTime      bool `json:"due_time"`      // DueDate keeps the time of day, otherwise only the date
+	Reminders    bool `json:"reminders"`     // Reminders can be set
+	Recurrence   bool `json:"recurrence"`    // Recurring tasks can be created
+	CreateParent bool `json:"create_parent"` // CreateParent is supported
+	DeleteParent bool `json:"delete_parent"` // DeleteParent is supported
+}
+
+// CapabilityReporter is implemented by clients reporting their capabilities
+type CapabilityReporter interface {
+	Capabilities() Capabilities
+}
+
+// DefaultCapabilities returns the capabilities assumed for clients not reporting them
+func DefaultCapabilities() Capabilities {
+	return Capabilities{
+		Labels:       true,
+		DueDates:     true,
+		CreateParent: true,
+		DeleteParent: true,
+	}
+}
+
+// CapabilitiesOf returns the capabilities of a client, see CapabilityReporter
+func CapabilitiesOf(client ToDoClient) Capabilities {
+	if reporter, ok := client.(CapabilityReporter); ok {
+		return reporter.Capabilities()
+	}
+	return DefaultCapabilities()
+}
+
+// Intersect returns the capabilities supported by both c and other
+func (c Capabilities) Intersect(other Capabilities) Capabilities {
+	return Capabilities{
+		Subtasks:     c.Subtasks && other.Subtasks,
+		Labels:       c.Labels && other.Labels,
+		DueDates:     c.DueDates && other.DueDates,
+		DueTime:      c.DueTime && other.DueTime,
+		Reminders:    c.Reminders && other.Reminders,
+		Recurrence:   c.Recurrence && other.Recurrence,
+		CreateParent: c.CreateParent && other.CreateParent,
+		DeleteParent: c.DeleteParent && other.DeleteParent,
+	}
+}
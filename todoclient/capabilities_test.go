@@ -0,0 +1,40 @@
+package todoclient
+
+import (
+	"testing"
+)
+
+// reportingClient reports capabilities differing from the defaults
+type reportingClient struct {
+	recordingClient
+}
+
+func (c *reportingClient) Capabilities() Capabilities {
+	return Capabilities{Subtasks: true, DueDates: true}
+}
+
+func TestCapabilitiesOf(t *testing.T) {
+	if actual := CapabilitiesOf(&recordingClient{}); actual != DefaultCapabilities() {
+		t.Errorf("expected default capabilities but found %+v", actual)
+	}
+
+	expected := Capabilities{Subtasks: true, DueDates: true}
+	if actual := CapabilitiesOf(&reportingClient{}); actual != expected {
+		t.Errorf("expected %+v but found %+v", expected, actual)
+	}
+
+	wrapped := Chain(&reportingClient{}, Intercept(ValidationInterceptor()))
+	if actual := CapabilitiesOf(wrapped); actual != expected {
+		t.Errorf("expected capabilities to be passed through middleware but found %+v", actual)
+	}
+}
+
+func TestCapabilities_Intersect(t *testing.T) {
+	a := Capabilities{Subtasks: true, Labels: true, DueDates: true}
+	b := Capabilities{Labels: true, DueDates: true, DueTime: true}
+
+	expected := Capabilities{Labels: true, DueDates: true}
+	if actual := a.Intersect(b); actual != expected {
+		t.Errorf("expected %+v but found %+v", expected, actual)
+	}
+}
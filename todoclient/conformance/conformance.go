@@ -0,0 +1,390 @@
+// Package conformance provides a test suite checking that a ToDoClient implementation
+// follows the contract of the todoclient package. Adapters run it against their fake
+// servers in unit tests and against live services in integration tests.
+package conformance
+
+import (
+	"context"
+	stderrors "errors"
+	"fmt"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jo-hoe/todoapi/pkg/errors"
+	"github.com/jo-hoe/todoapi/todoclient"
+)
+
+// Options adapts the suite to a provider
+type Options struct {
+	// ParentID is an existing parent used for the task tests. If empty, every test
+	// creates its own parent and deletes it afterwards.
+	ParentID string
+	// MissingTaskID is the ID of a task which does not exist, defaults to "does-not-exist"
+	MissingTaskID string
+	// Tasks is the number of tasks created to exercise pagination, defaults to 25
+	Tasks int
+	// SoftDelete is set for providers keeping deleted tasks readable, e.g. as closed issues
+	SoftDelete bool
+}
+
+func (o Options) withDefaults() Options {
+	if o.MissingTaskID == "" {
+		o.MissingTaskID = "does-not-exist"
+	}
+	if o.Tasks <= 0 {
+		o.Tasks = 25
+	}
+	return o
+}
+
+// Run runs the conformance suite against the client. Capabilities reported by the
+// client decide which fields are compared, see todoclient.CapabilitiesOf.
+func Run(t *testing.T, client todoclient.ToDoClient, options Options) {
+	s := &suite{
+		client:       client,
+		capabilities: todoclient.CapabilitiesOf(client),
+		options:      options.withDefaults(),
+	}
+	t.Run("Parents", s.testParents)
+	t.Run("Empty", s.testEmpty)
+	t.Run("CRUD", s.testCRUD)
+	t.Run("Unicode", s.testUnicode)
+	t.Run("Pagination", s.testPagination)
+	t.Run("NotFound", s.testNotFound)
+}
+
+type suite struct {
+	client       todoclient.ToDoClient
+	capabilities todoclient.Capabilities
+	options      Options
+}
+
+// parent returns the parent to create tasks in
+func (s *suite) parent(t *testing.T) string {
+	t.Helper()
+	if s.options.ParentID != "" {
+		return s.options.ParentID
+	}
+	if !s.capabilities.CreateParent {
+		t.Skip("provider cannot create parents and no ParentID is configured")
+	}
+
+	parent, err := s.client.CreateParent(context.Background(), uniqueName("conformance"))
+	if err != nil {
+		t.Fatalf("error was not nil but '%v'", err)
+	}
+	if parent.ID == "" {
+		t.Fatal("expected parent to have an ID")
+	}
+	t.Cleanup(func() {
+		if err := s.client.DeleteParent(context.Background(), parent.ID); err != nil {
+			t.Errorf("could not delete parent '%v'", err)
+		}
+	})
+	return parent.ID
+}
+
+// createTask creates a task which is deleted after the test
+func (s *suite) createTask(t *testing.T, parentID string, task todoclient.ToDoTask) todoclient.ToDoTask {
+	t.Helper()
+	created, err := s.client.CreateTask(context.Background(), parentID, task)
+	if err != nil {
+		t.Fatalf("error was not nil but '%v'", err)
+	}
+	if created.ID == "" {
+		t.Fatal("expected created task to have an ID")
+	}
+	if s.options.ParentID != "" {
+		t.Cleanup(func() {
+			_ = s.client.DeleteTask(context.Background(), parentID, created.ID)
+		})
+	}
+	return created
+}
+
+func (s *suite) testParents(t *testing.T) {
+	ctx := context.Background()
+	parents, err := s.client.GetAllParents(ctx)
+	if err != nil {
+		t.Fatalf("error was not nil but '%v'", err)
+	}
+	if parents == nil {
+		t.Error("expected non-nil slice of parents")
+	}
+	if !s.capabilities.CreateParent {
+		return
+	}
+
+	name := uniqueName("conformance parent")
+	parent, err := s.client.CreateParent(ctx, name)
+	if err != nil {
+		t.Fatalf("error was not nil but '%v'", err)
+	}
+	if parent.ID == "" {
+		t.Error("expected parent to have an ID")
+	}
+	if parent.Name != name {
+		t.Errorf("expected name '%s' but found '%s'", name, parent.Name)
+	}
+	if found, ok := findParent(ctx, t, s.client, parent.ID); !ok || found.Name != name {
+		t.Errorf("expected created parent to be listed but found %+v", found)
+	}
+
+	if !s.capabilities.DeleteParent {
+		return
+	}
+	if err := s.client.DeleteParent(ctx, parent.ID); err != nil {
+		t.Fatalf("error was not nil but '%v'", err)
+	}
+	if _, ok := findParent(ctx, t, s.client, parent.ID); ok {
+		t.Error("expected deleted parent not to be listed")
+	}
+}
+
+func (s *suite) testEmpty(t *testing.T) {
+	ctx := context.Background()
+	parentID := s.parent(t)
+
+	if s.options.ParentID == "" {
+		tasks, err := s.client.GetChildrenTasks(ctx, parentID, nil)
+		if err != nil {
+			t.Fatalf("error was not nil but '%v'", err)
+		}
+		if tasks == nil || len(tasks) != 0 {
+			t.Errorf("expected empty non-nil slice for a new parent but found %v", tasks)
+		}
+	}
+
+	query := &todoclient.TaskQuery{Text: uniqueName("no task matches")}
+	tasks, err := s.client.GetChildrenTasks(ctx, parentID, query)
+	if err != nil {
+		t.Fatalf("error was not nil but '%v'", err)
+	}
+	if tasks == nil || len(tasks) != 0 {
+		t.Errorf("expected empty non-nil slice of children but found %v", tasks)
+	}
+	tasks, err = s.client.GetAllTasks(ctx, query)
+	if err != nil {
+		t.Fatalf("error was not nil but '%v'", err)
+	}
+	if tasks == nil || len(tasks) != 0 {
+		t.Errorf("expected empty non-nil slice of all tasks but found %v", tasks)
+	}
+}
+
+func (s *suite) testCRUD(t *testing.T) {
+	ctx := context.Background()
+	parentID := s.parent(t)
+
+	task := todoclient.ToDoTask{
+		Name:        uniqueName("conformance task"),
+		Description: "created by the conformance suite",
+	}
+	if s.capabilities.DueDates {
+		task.DueDate = time.Date(2030, 3, 14, 0, 0, 0, 0, time.UTC)
+	}
+	if s.capabilities.Labels {
+		task.Labels = []string{"conformance"}
+	}
+	created := s.createTask(t, parentID, task)
+	s.assertTask(t, task, created)
+	if created.Labels == nil {
+		t.Error("expected non-nil labels")
+	}
+
+	read, err := s.client.GetTask(ctx, parentID, created.ID)
+	if err != nil {
+		t.Fatalf("error was not nil but '%v'", err)
+	}
+	s.assertTask(t, task, read)
+	if read.ID != created.ID {
+		t.Errorf("expected ID '%s' but found '%s'", created.ID, read.ID)
+	}
+
+	tasks, err := s.client.GetAllTasks(ctx, nil)
+	if err != nil {
+		t.Fatalf("error was not nil but '%v'", err)
+	}
+	if !containsTask(tasks, created.ID) {
+		t.Error("expected created task in all tasks")
+	}
+
+	read.Name = uniqueName("conformance update")
+	read.Description = "updated by the conformance suite"
+	if s.capabilities.DueDates {
+		read.DueDate = time.Date(2030, 3, 15, 0, 0, 0, 0, time.UTC)
+	}
+	if err := s.client.UpdateTask(ctx, parentID, read); err != nil {
+		t.Fatalf("error was not nil but '%v'", err)
+	}
+	updated, err := s.client.GetTask(ctx, parentID, created.ID)
+	if err != nil {
+		t.Fatalf("error was not nil but '%v'", err)
+	}
+	s.assertTask(t, read, updated)
+
+	updated.IsCompleted = true
+	if err := s.client.UpdateTask(ctx, parentID, updated); err != nil {
+		t.Fatalf("error was not nil but '%v'", err)
+	}
+	completed, err := s.client.GetTask(ctx, parentID, created.ID)
+	if err != nil {
+		t.Fatalf("error was not nil but '%v'", err)
+	}
+	if !completed.IsCompleted {
+		t.Error("expected task to be completed")
+	}
+
+	if err := s.client.DeleteTask(ctx, parentID, created.ID); err != nil {
+		t.Fatalf("error was not nil but '%v'", err)
+	}
+	if s.options.SoftDelete {
+		return
+	}
+	if _, err := s.client.GetTask(ctx, parentID, created.ID); !stderrors.Is(err, errors.ErrNotFound) {
+		t.Errorf("expected deleted task not to be found but found '%v'", err)
+	}
+	tasks, err = s.client.GetChildrenTasks(ctx, parentID, nil)
+	if err != nil {
+		t.Fatalf("error was not nil but '%v'", err)
+	}
+	if containsTask(tasks, created.ID) {
+		t.Error("expected deleted task not to be listed")
+	}
+}
+
+func (s *suite) testUnicode(t *testing.T) {
+	ctx := context.Background()
+	parentID := s.parent(t)
+
+	task := todoclient.ToDoTask{
+		Name:        "Einkäufe 🛒 日本語 " + uniqueName("ünïcödé"),
+		Description: "Zeile 1, Komma; Semikolon \\ Backslash\nZeile 2 — ✓ \"quoted\" <tag> & more",
+	}
+	if s.capabilities.Labels {
+		task.Labels = []string{"größe"}
+	}
+	created := s.createTask(t, parentID, task)
+
+	read, err := s.client.GetTask(ctx, parentID, created.ID)
+	if err != nil {
+		t.Fatalf("error was not nil but '%v'", err)
+	}
+	s.assertTask(t, task, read)
+}
+
+func (s *suite) testPagination(t *testing.T) {
+	ctx := context.Background()
+	parentID := s.parent(t)
+
+	ids := make(map[string]bool, s.options.Tasks)
+	prefix := uniqueName("conformance page")
+	for i := 0; i < s.options.Tasks; i++ {
+		created := s.createTask(t, parentID, todoclient.ToDoTask{Name: fmt.Sprintf("%s %02d", prefix, i)})
+		if ids[created.ID] {
+			t.Fatalf("expected unique IDs but '%s' was returned twice", created.ID)
+		}
+		ids[created.ID] = true
+	}
+
+	tasks, err := s.client.GetChildrenTasks(ctx, parentID, nil)
+	if err != nil {
+		t.Fatalf("error was not nil but '%v'", err)
+	}
+	listed := make(map[string]bool, len(tasks))
+	for _, task := range tasks {
+		if listed[task.ID] {
+			t.Errorf("expected task '%s' to be listed once", task.ID)
+		}
+		listed[task.ID] = true
+	}
+	for id := range ids {
+		if !listed[id] {
+			t.Errorf("expected task '%s' to be listed", id)
+		}
+	}
+
+	limited, err := s.client.GetChildrenTasks(ctx, parentID, &todoclient.TaskQuery{Limit: 3})
+	if err != nil {
+		t.Fatalf("error was not nil but '%v'", err)
+	}
+	if len(limited) != 3 {
+		t.Errorf("expected 3 tasks with limit but found %d", len(limited))
+	}
+}
+
+func (s *suite) testNotFound(t *testing.T) {
+	parentID := s.parent(t)
+
+	_, err := s.client.GetTask(context.Background(), parentID, s.options.MissingTaskID)
+	if !stderrors.Is(err, errors.ErrNotFound) {
+		t.Errorf("expected not found error but found '%v'", err)
+	}
+}
+
+// assertTask compares the fields the provider supports
+func (s *suite) assertTask(t *testing.T, expected, actual todoclient.ToDoTask) {
+	t.Helper()
+	if actual.Name != expected.Name {
+		t.Errorf("expected name '%s' but found '%s'", expected.Name, actual.Name)
+	}
+	if actual.Description != expected.Description {
+		t.Errorf("expected description '%s' but found '%s'", expected.Description, actual.Description)
+	}
+	if actual.IsCompleted != expected.IsCompleted {
+		t.Errorf("expected completion %t but found %t", expected.IsCompleted, actual.IsCompleted)
+	}
+	if s.capabilities.DueDates && !sameDate(expected.DueDate, actual.DueDate) {
+		t.Errorf("expected due date %v but found %v", expected.DueDate, actual.DueDate)
+	}
+	if s.capabilities.Labels && !sameLabels(expected.Labels, actual.Labels) {
+		t.Errorf("expected labels %v but found %v", expected.Labels, actual.Labels)
+	}
+}
+
+func findParent(ctx context.Context, t *testing.T, client todoclient.ToDoClient, id string) (todoclient.ToDoParent, bool) {
+	t.Helper()
+	parents, err := client.GetAllParents(ctx)
+	if err != nil {
+		t.Fatalf("error was not nil but '%v'", err)
+	}
+	for _, parent := range parents {
+		if parent.ID == id {
+			return parent, true
+		}
+	}
+	return todoclient.ToDoParent{}, false
+}
+
+func containsTask(tasks []todoclient.ToDoTask, id string) bool {
+	for _, task := range tasks {
+		if task.ID == id {
+			return true
+		}
+	}
+	return false
+}
+
+// sameDate compares due dates by day, as not all providers keep the time of day
+func sameDate(a, b time.Time) bool {
+	if a.IsZero() || b.IsZero() {
+		return a.IsZero() == b.IsZero()
+	}
+	return a.UTC().Format("2006-01-02") == b.UTC().Format("2006-01-02")
+}
+
+func sameLabels(a, b []string) bool {
+	normalize := func(labels []string) string {
+		sorted := append([]string{}, labels...)
+		sort.Strings(sorted)
+		return strings.Join(sorted, "\n")
+	}
+	return normalize(a) == normalize(b)
+}
+
+// uniqueName returns a name which does not collide with earlier runs against live services
+func uniqueName(prefix string) string {
+	return fmt.Sprintf("%s %d", prefix, time.Now().UnixNano())
+}
@@ -0,0 +1,383 @@
+// Package embedded provides a ToDoClient backed by an embedded key-value store,
+// so that no external todo service is needed
+package embedded
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"sort"
+	"time"
+
+	"github.com/jo-hoe/todoapi/pkg/errors"
+	"github.com/jo-hoe/todoapi/todoclient"
+)
+
+// Key layout of the store. Tasks are indexed by parent and by due date, the
+// index keys carry no value.
+const (
+	parentPrefix      = "p/"  // p/<parent id> -> parentRecord
+	taskPrefix        = "t/"  // t/<task id> -> taskRecord
+	parentIndexPrefix = "ip/" // ip/<parent id>/<task id>
+	dueIndexPrefix    = "id/" // id/<due date>/<task id>
+
+	dueKeyFormat = "20060102T150405.000000000"
+)
+
+type parentRecord struct {
+	ID           string    `json:"id"`
+	Name         string    `json:"name"`
+	CreationTime time.Time `json:"creation_time"`
+}
+
+type taskRecord struct {
+	ParentID string              `json:"parent_id"`
+	Task     todoclient.ToDoTask `json:"task"`
+}
+
+// EmbeddedClient implements ToDoClient on a database file. Every method runs in
+// a single transaction, e.g. deleting a parent removes its tasks atomically.
+type EmbeddedClient struct {
+	db  *DB
+	now func() time.Time
+}
+
+// NewEmbeddedClient opens or creates the database at path
+func NewEmbeddedClient(path string) (*EmbeddedClient, error) {
+	db, err := Open(path, DefaultOptions())
+	if err != nil {
+		return nil, err
+	}
+	return NewEmbeddedClientFromDB(db), nil
+}
+
+// NewEmbeddedClientFromDB creates a client on an open database
+func NewEmbeddedClientFromDB(db *DB) *EmbeddedClient {
+	return &EmbeddedClient{
+		db:  db,
+		now: time.Now,
+	}
+}
+
+// Close closes the database
+func (client *EmbeddedClient) Close() error {
+	return client.db.Close()
+}
+
+func (client *EmbeddedClient) GetAllTasks(ctx context.Context, query *todoclient.TaskQuery) ([]todoclient.ToDoTask, error) {
+	result := make([]todoclient.ToDoTask, 0)
+	err := client.db.View(func(tx *Tx) error {
+		if query == nil || (query.DueAfter.IsZero() && query.DueBefore.IsZero()) {
+			var err error
+			tx.Scan(taskPrefix, func(_ string, value []byte) bool {
+				var record taskRecord
+				if err = json.Unmarshal(value, &record); err != nil {
+					return false
+				}
+				result = append(result, record.Task)
+				return true
+			})
+			return err
+		}
+
+		// only tasks within the due date range are read
+		start, end := dueIndexPrefix, dueIndexPrefix+"\xff"
+		if !query.DueAfter.IsZero() {
+			start = dueKey(query.DueAfter, "")
+		}
+		if !query.DueBefore.IsZero() {
+			end = dueKey(query.DueBefore, "")
+		}
+		var err error
+		tx.ScanRange(start, end, func(key string, _ []byte) bool {
+			var record *taskRecord
+			if record, err = getTask(tx, key[len(key)-idLength:]); err != nil {
+				return false
+			}
+			result = append(result, record.Task)
+			return true
+		})
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return query.Apply(sortTasks(result)), nil
+}
+
+func (client *EmbeddedClient) GetChildrenTasks(ctx context.Context, parentID string, query *todoclient.TaskQuery) ([]todoclient.ToDoTask, error) {
+	result := make([]todoclient.ToDoTask, 0)
+	err := client.db.View(func(tx *Tx) error {
+		if _, err := getParent(tx, parentID); err != nil {
+			return err
+		}
+		var err error
+		tx.Scan(parentIndexPrefix+parentID+"/", func(key string, _ []byte) bool {
+			var record *taskRecord
+			if record, err = getTask(tx, key[len(key)-idLength:]); err != nil {
+				return false
+			}
+			result = append(result, record.Task)
+			return true
+		})
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return query.Apply(sortTasks(result)), nil
+}
+
+func (client *EmbeddedClient) GetTask(ctx context.Context, parentID, taskID string) (todoclient.ToDoTask, error) {
+	var task todoclient.ToDoTask
+	err := client.db.View(func(tx *Tx) error {
+		record, err := getChildTask(tx, parentID, taskID)
+		if err != nil {
+			return err
+		}
+		task = record.Task
+		return nil
+	})
+	return task, err
+}
+
+func (client *EmbeddedClient) CreateTask(ctx context.Context, parentID string, task todoclient.ToDoTask) (todoclient.ToDoTask, error) {
+	err := client.db.Update(func(tx *Tx) error {
+		if _, err := getParent(tx, parentID); err != nil {
+			return err
+		}
+		var err error
+		if task.ID, err = newID(); err != nil {
+			return err
+		}
+		task.CreationTime = client.now().UTC()
+		task.ModifiedTime = task.CreationTime
+		task.Labels = nonNil(task.Labels)
+		return putTask(tx, nil, &taskRecord{ParentID: parentID, Task: task})
+	})
+	if err != nil {
+		return todoclient.ToDoTask{}, err
+	}
+	return task, nil
+}
+
+func (client *EmbeddedClient) UpdateTask(ctx context.Context, parentID string, task todoclient.ToDoTask) error {
+	return client.db.Update(func(tx *Tx) error {
+		old, err := getChildTask(tx, parentID, task.ID)
+		if err != nil {
+			return err
+		}
+		// the creation time is owned by the store
+		task.CreationTime = old.Task.CreationTime
+		task.ModifiedTime = client.now().UTC()
+		task.Labels = nonNil(task.Labels)
+		return putTask(tx, old, &taskRecord{ParentID: parentID, Task: task})
+	})
+}
+
+func (client *EmbeddedClient) DeleteTask(ctx context.Context, parentID, taskID string) error {
+	return client.db.Update(func(tx *Tx) error {
+		record, err := getChildTask(tx, parentID, taskID)
+		if err != nil {
+			return err
+		}
+		return deleteTask(tx, record)
+	})
+}
+
+func (client *EmbeddedClient) GetAllParents(ctx context.Context) ([]todoclient.ToDoParent, error) {
+	records := make([]parentRecord, 0)
+	err := client.db.View(func(tx *Tx) error {
+		var err error
+		tx.Scan(parentPrefix, func(_ string, value []byte) bool {
+			var record parentRecord
+			if err = json.Unmarshal(value, &record); err != nil {
+				return false
+			}
+			records = append(records, record)
+			return true
+		})
+		return err
+	})
+	if err != nil {
+		return nil, errors.NewAPIError("EMBEDDED_DECODE_FAILED", "failed to decode parent", err)
+	}
+
+	sort.SliceStable(records, func(i, j int) bool {
+		return records[i].CreationTime.Before(records[j].CreationTime)
+	})
+	result := make([]todoclient.ToDoParent, 0, len(records))
+	for _, record := range records {
+		result = append(result, todoclient.ToDoParent{ID: record.ID, Name: record.Name})
+	}
+	return result, nil
+}
+
+func (client *EmbeddedClient) CreateParent(ctx context.Context, parentName string) (todoclient.ToDoParent, error) {
+	record := parentRecord{
+		Name:         parentName,
+		CreationTime: client.now().UTC(),
+	}
+	err := client.db.Update(func(tx *Tx) error {
+		var err error
+		if record.ID, err = newID(); err != nil {
+			return err
+		}
+		return putJSON(tx, parentPrefix+record.ID, record)
+	})
+	if err != nil {
+		return todoclient.ToDoParent{}, err
+	}
+	return todoclient.ToDoParent{ID: record.ID, Name: record.Name}, nil
+}
+
+func (client *EmbeddedClient) DeleteParent(ctx context.Context, parentID string) error {
+	return client.db.Update(func(tx *Tx) error {
+		if _, err := getParent(tx, parentID); err != nil {
+			return err
+		}
+		taskIDs := make([]string, 0)
+		tx.Scan(parentIndexPrefix+parentID+"/", func(key string, _ []byte) bool {
+			taskIDs = append(taskIDs, key[len(key)-idLength:])
+			return true
+		})
+		for _, taskID := range taskIDs {
+			record, err := getTask(tx, taskID)
+			if err != nil {
+				return err
+			}
+			if err := deleteTask(tx, record); err != nil {
+				return err
+			}
+		}
+		return tx.Delete(parentPrefix + parentID)
+	})
+}
+
+// Capabilities reports the features supported by the embedded client, which stores tasks as they are
+func (client *EmbeddedClient) Capabilities() todoclient.Capabilities {
+	return todoclient.Capabilities{
+		Subtasks:     true,
+		Labels:       true,
+		DueDates:     true,
+		DueTime:      true,
+		CreateParent: true,
+		DeleteParent: true,
+	}
+}
+
+func getParent(tx *Tx, parentID string) (*parentRecord, error) {
+	value, ok := tx.Get(parentPrefix + parentID)
+	if !ok {
+		return nil, errors.NewAPIError("EMBEDDED_NOT_FOUND", "parent not found", errors.ErrNotFound)
+	}
+	record := &parentRecord{}
+	if err := json.Unmarshal(value, record); err != nil {
+		return nil, errors.NewAPIError("EMBEDDED_DECODE_FAILED", "failed to decode parent", err)
+	}
+	return record, nil
+}
+
+func getTask(tx *Tx, taskID string) (*taskRecord, error) {
+	value, ok := tx.Get(taskPrefix + taskID)
+	if !ok {
+		return nil, errors.NewAPIError("EMBEDDED_NOT_FOUND", "task not found", errors.ErrNotFound)
+	}
+	record := &taskRecord{}
+	if err := json.Unmarshal(value, record); err != nil {
+		return nil, errors.NewAPIError("EMBEDDED_DECODE_FAILED", "failed to decode task", err)
+	}
+	return record, nil
+}
+
+func getChildTask(tx *Tx, parentID, taskID string) (*taskRecord, error) {
+	if _, err := getParent(tx, parentID); err != nil {
+		return nil, err
+	}
+	record, err := getTask(tx, taskID)
+	if err != nil {
+		return nil, err
+	}
+	if record.ParentID != parentID {
+		return nil, errors.NewAPIError("EMBEDDED_NOT_FOUND", "task not found", errors.ErrNotFound)
+	}
+	return record, nil
+}
+
+// putTask writes a task and its index entries, replacing those of old if not nil
+func putTask(tx *Tx, old, record *taskRecord) error {
+	if old != nil {
+		if err := deleteIndexes(tx, old); err != nil {
+			return err
+		}
+	}
+	if err := putJSON(tx, taskPrefix+record.Task.ID, record); err != nil {
+		return err
+	}
+	if err := tx.Put(parentIndexPrefix+record.ParentID+"/"+record.Task.ID, nil); err != nil {
+		return err
+	}
+	if !record.Task.DueDate.IsZero() {
+		return tx.Put(dueKey(record.Task.DueDate, record.Task.ID), nil)
+	}
+	return nil
+}
+
+func deleteTask(tx *Tx, record *taskRecord) error {
+	if err := deleteIndexes(tx, record); err != nil {
+		return err
+	}
+	return tx.Delete(taskPrefix + record.Task.ID)
+}
+
+func deleteIndexes(tx *Tx, record *taskRecord) error {
+	if err := tx.Delete(parentIndexPrefix + record.ParentID + "/" + record.Task.ID); err != nil {
+		return err
+	}
+	if !record.Task.DueDate.IsZero() {
+		return tx.Delete(dueKey(record.Task.DueDate, record.Task.ID))
+	}
+	return nil
+}
+
+func putJSON(tx *Tx, key string, value interface{}) error {
+	b, err := json.Marshal(value)
+	if err != nil {
+		return errors.NewAPIError("EMBEDDED_ENCODE_FAILED", "failed to encode "+key, err)
+	}
+	return tx.Put(key, b)
+}
+
+// dueKey returns the due index key, which sorts in the order of the due dates
+func dueKey(due time.Time, taskID string) string {
+	key := dueIndexPrefix + due.UTC().Format(dueKeyFormat) + "/"
+	return key + taskID
+}
+
+// sortTasks orders tasks by creation time, since keys are in random order
+func sortTasks(tasks []todoclient.ToDoTask) []todoclient.ToDoTask {
+	sort.SliceStable(tasks, func(i, j int) bool {
+		return tasks[i].CreationTime.Before(tasks[j].CreationTime)
+	})
+	return tasks
+}
+
+// idLength is the length of the IDs returned by newID
+const idLength = 16
+
+// newID returns a random ID of 16 hex characters
+func newID() (string, error) {
+	b := make([]byte, idLength/2)
+	if _, err := rand.Read(b); err != nil {
+		return "", errors.NewAPIError("EMBEDDED_ID_FAILED", "failed to generate id", err)
+	}
+	return hex.EncodeToString(b), nil
+}
+
+func nonNil(labels []string) []string {
+	if labels == nil {
+		return make([]string, 0)
+	}
+	return labels
+}
@@ -0,0 +1,177 @@
+package embedded
+
+import (
+	"context"
+	stderrors "errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/jo-hoe/todoapi/pkg/errors"
+	"github.com/jo-hoe/todoapi/todoclient"
+	"github.com/jo-hoe/todoapi/todoclient/conformance"
+)
+
+func TestEmbeddedClient_ImplementationTest(t *testing.T) {
+	// tests if interface is implemented
+	var _ todoclient.ToDoClient = (*EmbeddedClient)(nil)
+}
+
+func TestEmbeddedClient_Conformance(t *testing.T) {
+	conformance.Run(t, newTestClient(t, filepath.Join(t.TempDir(), "todo.db")), conformance.Options{})
+}
+
+func newTestClient(t *testing.T, path string) *EmbeddedClient {
+	t.Helper()
+	client, err := NewEmbeddedClient(path)
+	if err != nil {
+		t.Fatalf("error was not nil but '%v'", err)
+	}
+	t.Cleanup(func() { _ = client.Close() })
+	return client
+}
+
+func TestEmbeddedClient_CRUD(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "todo.db")
+	client := newTestClient(t, path)
+	ctx := context.Background()
+
+	parent, err := client.CreateParent(ctx, "Work")
+	if err != nil {
+		t.Fatalf("error was not nil but '%v'", err)
+	}
+	task, err := client.CreateTask(ctx, parent.ID, todoclient.ToDoTask{Name: "Report"})
+	if err != nil {
+		t.Fatalf("error was not nil but '%v'", err)
+	}
+	if task.ID == "" || task.CreationTime.IsZero() || task.Labels == nil {
+		t.Errorf("expected id, creation time and labels to be set but found %+v", task)
+	}
+
+	task.Name = "Final report"
+	task.IsCompleted = true
+	if err := client.UpdateTask(ctx, parent.ID, task); err != nil {
+		t.Errorf("error was not nil but '%v'", err)
+	}
+
+	// data survives reopening
+	_ = client.Close()
+	client = newTestClient(t, path)
+
+	found, err := client.GetTask(ctx, parent.ID, task.ID)
+	if err != nil {
+		t.Fatalf("error was not nil but '%v'", err)
+	}
+	if found.Name != "Final report" || !found.IsCompleted {
+		t.Errorf("expected updated task but found %+v", found)
+	}
+	parents, err := client.GetAllParents(ctx)
+	if err != nil {
+		t.Errorf("error was not nil but '%v'", err)
+	}
+	if len(parents) != 1 || parents[0].Name != "Work" {
+		t.Errorf("expected parent 'Work' but found %v", parents)
+	}
+
+	if err := client.DeleteTask(ctx, parent.ID, task.ID); err != nil {
+		t.Errorf("error was not nil but '%v'", err)
+	}
+	tasks, err := client.GetChildrenTasks(ctx, parent.ID, nil)
+	if err != nil {
+		t.Errorf("error was not nil but '%v'", err)
+	}
+	if tasks == nil || len(tasks) != 0 {
+		t.Errorf("expected empty non-nil tasks but found %v", tasks)
+	}
+}
+
+func TestEmbeddedClient_NotFound(t *testing.T) {
+	client := newTestClient(t, filepath.Join(t.TempDir(), "todo.db"))
+	ctx := context.Background()
+	work, _ := client.CreateParent(ctx, "Work")
+	home, _ := client.CreateParent(ctx, "Home")
+	task, _ := client.CreateTask(ctx, work.ID, todoclient.ToDoTask{Name: "Report"})
+
+	if _, err := client.GetTask(ctx, home.ID, task.ID); !stderrors.Is(err, errors.ErrNotFound) {
+		t.Errorf("expected not found error but found '%v'", err)
+	}
+	if _, err := client.GetChildrenTasks(ctx, "unknown", nil); !stderrors.Is(err, errors.ErrNotFound) {
+		t.Errorf("expected not found error but found '%v'", err)
+	}
+	if err := client.UpdateTask(ctx, work.ID, todoclient.ToDoTask{ID: "unknown"}); !stderrors.Is(err, errors.ErrNotFound) {
+		t.Errorf("expected not found error but found '%v'", err)
+	}
+	if err := client.DeleteParent(ctx, "unknown"); !stderrors.Is(err, errors.ErrNotFound) {
+		t.Errorf("expected not found error but found '%v'", err)
+	}
+}
+
+func TestEmbeddedClient_DeleteParent(t *testing.T) {
+	client := newTestClient(t, filepath.Join(t.TempDir(), "todo.db"))
+	ctx := context.Background()
+	work, _ := client.CreateParent(ctx, "Work")
+	home, _ := client.CreateParent(ctx, "Home")
+	due := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	_, _ = client.CreateTask(ctx, work.ID, todoclient.ToDoTask{Name: "Report", DueDate: due})
+	_, _ = client.CreateTask(ctx, home.ID, todoclient.ToDoTask{Name: "Dishes", DueDate: due})
+
+	if err := client.DeleteParent(ctx, work.ID); err != nil {
+		t.Fatalf("error was not nil but '%v'", err)
+	}
+
+	tasks, err := client.GetAllTasks(ctx, &todoclient.TaskQuery{DueAfter: due})
+	if err != nil {
+		t.Errorf("error was not nil but '%v'", err)
+	}
+	if len(tasks) != 1 || tasks[0].Name != "Dishes" {
+		t.Errorf("expected only 'Dishes' but found %v", tasks)
+	}
+	count := 0
+	_ = client.db.View(func(tx *Tx) error {
+		tx.Scan("", func(_ string, _ []byte) bool {
+			count++
+			return true
+		})
+		return nil
+	})
+	// parent, task and two index entries of the remaining parent
+	if count != 4 {
+		t.Errorf("expected %d keys but found %d", 4, count)
+	}
+}
+
+func TestEmbeddedClient_GetAllTasks_DueIndex(t *testing.T) {
+	client := newTestClient(t, filepath.Join(t.TempDir(), "todo.db"))
+	ctx := context.Background()
+	parent, _ := client.CreateParent(ctx, "Work")
+	for i, name := range []string{"first", "second", "third"} {
+		_, _ = client.CreateTask(ctx, parent.ID, todoclient.ToDoTask{
+			Name:    name,
+			DueDate: time.Date(2024, 3, 1+i, 0, 0, 0, 0, time.UTC),
+		})
+	}
+	task, _ := client.CreateTask(ctx, parent.ID, todoclient.ToDoTask{Name: "undated"})
+
+	// moving a due date updates the index
+	task.DueDate = time.Date(2024, 3, 2, 12, 0, 0, 0, time.UTC)
+	if err := client.UpdateTask(ctx, parent.ID, task); err != nil {
+		t.Fatalf("error was not nil but '%v'", err)
+	}
+
+	tasks, err := client.GetAllTasks(ctx, &todoclient.TaskQuery{
+		DueAfter:  time.Date(2024, 3, 2, 0, 0, 0, 0, time.UTC),
+		DueBefore: time.Date(2024, 3, 3, 0, 0, 0, 0, time.UTC),
+		OrderBy:   todoclient.OrderByDueDate,
+	})
+
+	if err != nil {
+		t.Errorf("error was not nil but '%v'", err)
+	}
+	if len(tasks) != 2 || tasks[0].Name != "second" || tasks[1].Name != "undated" {
+		t.Errorf("expected 'second' and 'undated' but found %v", tasks)
+	}
+	all, _ := client.GetAllTasks(ctx, nil)
+	if len(all) != 4 {
+		t.Errorf("expected %d tasks but found %d", 4, len(all))
+	}
+}
@@ -0,0 +1,393 @@
+package embedded
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"hash/crc32"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/jo-hoe/todoapi/pkg/errors"
+)
+
+// recordHeaderSize is the size of the length and the checksum preceding each record
+const recordHeaderSize = 8
+
+// Options configures a database
+type Options struct {
+	// CompactMinSize is the log size in bytes from which the log is compacted
+	// automatically once it is more than twice as large as the live data.
+	CompactMinSize int64
+	// NoSync skips fsync after commits, trading durability for speed, e.g. in tests
+	NoSync bool
+}
+
+// DefaultOptions returns options compacting logs from 4 MiB
+func DefaultOptions() Options {
+	return Options{
+		CompactMinSize: 4 << 20,
+	}
+}
+
+// operation is a single write of a transaction
+type operation struct {
+	Key    string `json:"k"`
+	Value  []byte `json:"v,omitempty"`
+	Delete bool   `json:"d,omitempty"`
+}
+
+// DB is an embedded key-value store keeping all data in memory and persisting
+// every committed transaction as a record appended to a log file.
+//
+// A record is the little-endian payload length and CRC-32 checksum followed by
+// the JSON encoded operations of the transaction, so a transaction is either
+// fully applied or not at all. On open the log is replayed; a torn or corrupted
+// record at the end, as left by a crash during a write, is truncated.
+type DB struct {
+	mu         sync.RWMutex
+	path       string
+	file       *os.File
+	options    Options
+	data       map[string][]byte
+	keys       []string // sorted keys of data for prefix and range scans
+	size       int64    // size of the log file
+	live       int64    // size of a record holding only the current data
+	recovered  int64    // bytes truncated during the last open
+	compactErr error    // error of the last automatic compaction
+}
+
+// Open opens or creates the database at path
+func Open(path string, options Options) (*DB, error) {
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o600)
+	if err != nil {
+		return nil, errors.NewAPIError("EMBEDDED_OPEN_FAILED", "failed to open database", err)
+	}
+	db := &DB{
+		path:    path,
+		file:    file,
+		options: options,
+		data:    make(map[string][]byte),
+	}
+	if err := db.replay(); err != nil {
+		_ = file.Close()
+		return nil, err
+	}
+	return db, nil
+}
+
+// replay applies all intact records and truncates the log after the last one
+func (db *DB) replay() error {
+	info, err := db.file.Stat()
+	if err != nil {
+		return errors.NewAPIError("EMBEDDED_OPEN_FAILED", "failed to read database", err)
+	}
+	reader := bufio.NewReader(db.file)
+	var offset int64
+	for {
+		ops, n, err := readRecord(reader, info.Size()-offset)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			// torn or corrupted tail, everything after the last intact record is dropped
+			db.recovered = info.Size() - offset
+			if err := db.file.Truncate(offset); err != nil {
+				return errors.NewAPIError("EMBEDDED_RECOVERY_FAILED", "failed to truncate corrupted log", err)
+			}
+			break
+		}
+		db.apply(ops)
+		offset += n
+	}
+
+	if _, err := db.file.Seek(offset, io.SeekStart); err != nil {
+		return errors.NewAPIError("EMBEDDED_OPEN_FAILED", "failed to read database", err)
+	}
+	db.size = offset
+	return nil
+}
+
+// CompactErr returns the error of the last automatic compaction, nil if it succeeded.
+// The compaction after a commit does not fail the commit, it is retried after the next one.
+func (db *DB) CompactErr() error {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	return db.compactErr
+}
+
+// Recovered returns the number of bytes of a torn or corrupted log tail
+// discarded when the database was opened
+func (db *DB) Recovered() int64 {
+	return db.recovered
+}
+
+// readRecord reads the next record of the remaining bytes of the log. A length
+// beyond the end of the log is reported like a torn record without allocating it.
+func readRecord(r io.Reader, remaining int64) ([]operation, int64, error) {
+	header := make([]byte, recordHeaderSize)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, 0, err
+	}
+	length := binary.LittleEndian.Uint32(header[:4])
+	checksum := binary.LittleEndian.Uint32(header[4:])
+	if int64(length) > remaining-recordHeaderSize {
+		return nil, 0, io.ErrUnexpectedEOF
+	}
+
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return nil, 0, io.ErrUnexpectedEOF
+	}
+	if crc32.ChecksumIEEE(payload) != checksum {
+		return nil, 0, errors.NewAPIError("EMBEDDED_CORRUPTED", "checksum mismatch", nil)
+	}
+
+	var ops []operation
+	if err := json.Unmarshal(payload, &ops); err != nil {
+		return nil, 0, errors.NewAPIError("EMBEDDED_CORRUPTED", "invalid record", err)
+	}
+	return ops, int64(recordHeaderSize + len(payload)), nil
+}
+
+func encodeRecord(ops []operation) ([]byte, error) {
+	payload, err := json.Marshal(ops)
+	if err != nil {
+		return nil, err
+	}
+	record := make([]byte, recordHeaderSize, recordHeaderSize+len(payload))
+	binary.LittleEndian.PutUint32(record[:4], uint32(len(payload)))
+	binary.LittleEndian.PutUint32(record[4:], crc32.ChecksumIEEE(payload))
+	return append(record, payload...), nil
+}
+
+// apply changes the in-memory state
+func (db *DB) apply(ops []operation) {
+	for _, op := range ops {
+		old, exists := db.data[op.Key]
+		if exists {
+			db.live -= entrySize(op.Key, old)
+		}
+		if op.Delete {
+			if exists {
+				delete(db.data, op.Key)
+				i := sort.SearchStrings(db.keys, op.Key)
+				db.keys = append(db.keys[:i], db.keys[i+1:]...)
+			}
+			continue
+		}
+		db.data[op.Key] = op.Value
+		db.live += entrySize(op.Key, op.Value)
+		if !exists {
+			i := sort.SearchStrings(db.keys, op.Key)
+			db.keys = append(db.keys, "")
+			copy(db.keys[i+1:], db.keys[i:])
+			db.keys[i] = op.Key
+		}
+	}
+}
+
+// entrySize approximates the encoded size of a key-value pair in a record
+func entrySize(key string, value []byte) int64 {
+	return int64(len(key) + (len(value)+2)/3*4 + 16)
+}
+
+// View runs fn in a read-only transaction
+func (db *DB) View(fn func(tx *Tx) error) error {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	if db.file == nil {
+		return errors.NewAPIError("EMBEDDED_CLOSED", "database is closed", nil)
+	}
+	return fn(&Tx{db: db})
+}
+
+// Update runs fn in a read-write transaction. The writes of fn are committed
+// atomically if fn returns nil and discarded otherwise. Transactions are
+// serialized, reads within fn see the writes of fn.
+func (db *DB) Update(fn func(tx *Tx) error) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	if db.file == nil {
+		return errors.NewAPIError("EMBEDDED_CLOSED", "database is closed", nil)
+	}
+	tx := &Tx{db: db, writable: true, writes: make(map[string]operation)}
+	if err := fn(tx); err != nil {
+		return err
+	}
+	if len(tx.writes) == 0 {
+		return nil
+	}
+
+	ops := make([]operation, 0, len(tx.writes))
+	for _, op := range tx.writes {
+		ops = append(ops, op)
+	}
+	sort.Slice(ops, func(i, j int) bool {
+		return ops[i].Key < ops[j].Key
+	})
+	record, err := encodeRecord(ops)
+	if err != nil {
+		return errors.NewAPIError("EMBEDDED_COMMIT_FAILED", "failed to encode transaction", err)
+	}
+	if _, err := db.file.Write(record); err != nil {
+		// a partial record is truncated when the log is replayed
+		return errors.NewAPIError("EMBEDDED_COMMIT_FAILED", "failed to write transaction", err)
+	}
+	if !db.options.NoSync {
+		if err := db.file.Sync(); err != nil {
+			return errors.NewAPIError("EMBEDDED_COMMIT_FAILED", "failed to sync transaction", err)
+		}
+	}
+	db.size += int64(len(record))
+	db.apply(ops)
+
+	// the transaction is committed at this point, so compaction errors are kept apart
+	if db.options.CompactMinSize > 0 && db.size >= db.options.CompactMinSize && db.size > 2*db.live {
+		db.compactErr = db.compact()
+	}
+	return nil
+}
+
+// Compact rewrites the log to contain only the current data
+func (db *DB) Compact() error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	if db.file == nil {
+		return errors.NewAPIError("EMBEDDED_CLOSED", "database is closed", nil)
+	}
+	return db.compact()
+}
+
+func (db *DB) compact() error {
+	tmp := db.path + ".compact"
+	file, err := os.OpenFile(tmp, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0o600)
+	if err != nil {
+		return errors.NewAPIError("EMBEDDED_COMPACT_FAILED", "failed to create compacted log", err)
+	}
+	fail := func(err error) error {
+		_ = file.Close()
+		_ = os.Remove(tmp)
+		return errors.NewAPIError("EMBEDDED_COMPACT_FAILED", "failed to write compacted log", err)
+	}
+
+	// the whole state is a single record, so a crash never leaves a partial state behind
+	ops := make([]operation, 0, len(db.keys))
+	for _, key := range db.keys {
+		ops = append(ops, operation{Key: key, Value: db.data[key]})
+	}
+	var size int64
+	if len(ops) > 0 {
+		record, err := encodeRecord(ops)
+		if err != nil {
+			return fail(err)
+		}
+		if _, err := file.Write(record); err != nil {
+			return fail(err)
+		}
+		size = int64(len(record))
+	}
+	if err := file.Sync(); err != nil {
+		return fail(err)
+	}
+	if err := os.Rename(tmp, db.path); err != nil {
+		return fail(err)
+	}
+
+	_ = db.file.Close()
+	db.file = file
+	db.size = size
+	return nil
+}
+
+// Close closes the log file. Transactions fail after closing.
+func (db *DB) Close() error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	if db.file == nil {
+		return nil
+	}
+	err := db.file.Close()
+	db.file = nil
+	return err
+}
+
+// Tx is a transaction, see DB.View and DB.Update
+type Tx struct {
+	db       *DB
+	writable bool
+	writes   map[string]operation
+}
+
+// Get returns the value of a key
+func (tx *Tx) Get(key string) ([]byte, bool) {
+	if op, ok := tx.writes[key]; ok {
+		return op.Value, !op.Delete
+	}
+	value, ok := tx.db.data[key]
+	return value, ok
+}
+
+// Put sets the value of a key
+func (tx *Tx) Put(key string, value []byte) error {
+	if !tx.writable {
+		return errors.NewAPIError("EMBEDDED_READ_ONLY", "transaction is read-only", nil)
+	}
+	tx.writes[key] = operation{Key: key, Value: append([]byte{}, value...)}
+	return nil
+}
+
+// Delete removes a key
+func (tx *Tx) Delete(key string) error {
+	if !tx.writable {
+		return errors.NewAPIError("EMBEDDED_READ_ONLY", "transaction is read-only", nil)
+	}
+	tx.writes[key] = operation{Key: key, Delete: true}
+	return nil
+}
+
+// Scan calls fn for all keys with the prefix in ascending order until fn returns false
+func (tx *Tx) Scan(prefix string, fn func(key string, value []byte) bool) {
+	tx.scan(prefix, func(key string) bool {
+		return strings.HasPrefix(key, prefix)
+	}, fn)
+}
+
+// ScanRange calls fn for all keys from start up to, not including, end in
+// ascending order until fn returns false
+func (tx *Tx) ScanRange(start, end string, fn func(key string, value []byte) bool) {
+	tx.scan(start, func(key string) bool {
+		return key < end
+	}, fn)
+}
+
+func (tx *Tx) scan(start string, inRange func(key string) bool, fn func(key string, value []byte) bool) {
+	keys := make([]string, 0)
+	for i := sort.SearchStrings(tx.db.keys, start); i < len(tx.db.keys) && inRange(tx.db.keys[i]); i++ {
+		keys = append(keys, tx.db.keys[i])
+	}
+	if len(tx.writes) > 0 {
+		for key := range tx.writes {
+			if key >= start && inRange(key) {
+				if _, ok := tx.db.data[key]; !ok {
+					keys = append(keys, key)
+				}
+			}
+		}
+		sort.Strings(keys)
+	}
+
+	for _, key := range keys {
+		value, ok := tx.Get(key)
+		if ok && !fn(key, value) {
+			return
+		}
+	}
+}
@@ -0,0 +1,221 @@
+package embedded
+
+import (
+	stderrors "errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func openTestDB(t *testing.T, path string, options Options) *DB {
+	t.Helper()
+	db, err := Open(path, options)
+	if err != nil {
+		t.Fatalf("error was not nil but '%v'", err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+	return db
+}
+
+func TestDB_Update_Rollback(t *testing.T) {
+	db := openTestDB(t, filepath.Join(t.TempDir(), "todo.db"), Options{NoSync: true})
+	errAbort := stderrors.New("abort")
+
+	err := db.Update(func(tx *Tx) error {
+		if err := tx.Put("a", []byte("1")); err != nil {
+			return err
+		}
+		if value, ok := tx.Get("a"); !ok || string(value) != "1" {
+			t.Errorf("expected own write to be visible but found '%s'", value)
+		}
+		return errAbort
+	})
+
+	if !stderrors.Is(err, errAbort) {
+		t.Errorf("expected abort error but found '%v'", err)
+	}
+	_ = db.View(func(tx *Tx) error {
+		if _, ok := tx.Get("a"); ok {
+			t.Error("expected rolled back write to be discarded")
+		}
+		return nil
+	})
+}
+
+func TestDB_Scan(t *testing.T) {
+	db := openTestDB(t, filepath.Join(t.TempDir(), "todo.db"), Options{NoSync: true})
+	err := db.Update(func(tx *Tx) error {
+		for _, key := range []string{"b/2", "a/1", "b/1", "c/1"} {
+			if err := tx.Put(key, []byte(key)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("error was not nil but '%v'", err)
+	}
+
+	_ = db.Update(func(tx *Tx) error {
+		_ = tx.Delete("b/1")
+		_ = tx.Put("b/0", nil)
+		keys := make([]string, 0)
+		tx.Scan("b/", func(key string, _ []byte) bool {
+			keys = append(keys, key)
+			return true
+		})
+		if len(keys) != 2 || keys[0] != "b/0" || keys[1] != "b/2" {
+			t.Errorf("expected [b/0 b/2] but found %v", keys)
+		}
+		return nil
+	})
+}
+
+func TestDB_Reopen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "todo.db")
+	db, err := Open(path, Options{})
+	if err != nil {
+		t.Fatalf("error was not nil but '%v'", err)
+	}
+	_ = db.Update(func(tx *Tx) error { return tx.Put("a", []byte("1")) })
+	_ = db.Update(func(tx *Tx) error { return tx.Put("b", []byte("2")) })
+	_ = db.Update(func(tx *Tx) error { return tx.Delete("a") })
+	_ = db.Close()
+
+	db = openTestDB(t, path, Options{})
+
+	_ = db.View(func(tx *Tx) error {
+		if _, ok := tx.Get("a"); ok {
+			t.Error("expected deleted key to stay deleted")
+		}
+		if value, ok := tx.Get("b"); !ok || string(value) != "2" {
+			t.Errorf("expected '2' but found '%s'", value)
+		}
+		return nil
+	})
+}
+
+func TestDB_Recovery(t *testing.T) {
+	tests := []struct {
+		name     string
+		corrupt  func(b []byte) []byte
+		expected string // value of "a" after the recovery
+	}{
+		{name: "torn record", expected: "1", corrupt: func(b []byte) []byte { return b[:len(b)-3] }},
+		{name: "torn header", expected: "2", corrupt: func(b []byte) []byte { return append(b, 1, 2, 3) }},
+		{name: "oversized length", expected: "2", corrupt: func(b []byte) []byte {
+			// a length of almost 4 GiB must not be allocated
+			return append(b, 0xf0, 0xff, 0xff, 0xff, 0, 0, 0, 0, 1, 2)
+		}},
+		{name: "checksum mismatch", expected: "1", corrupt: func(b []byte) []byte {
+			b[len(b)-2] ^= 0xff
+			return b
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "todo.db")
+			db, err := Open(path, Options{})
+			if err != nil {
+				t.Fatalf("error was not nil but '%v'", err)
+			}
+			_ = db.Update(func(tx *Tx) error { return tx.Put("a", []byte("1")) })
+			_ = db.Update(func(tx *Tx) error {
+				_ = tx.Put("a", []byte("2"))
+				return tx.Put("b", []byte("2"))
+			})
+			_ = db.Close()
+			b, _ := os.ReadFile(path)
+			_ = os.WriteFile(path, tt.corrupt(b), 0o600)
+
+			db = openTestDB(t, path, Options{})
+
+			if db.Recovered() == 0 {
+				t.Error("expected recovered bytes")
+			}
+			// an interrupted transaction is dropped as a whole
+			_ = db.View(func(tx *Tx) error {
+				value, ok := tx.Get("a")
+				if !ok || string(value) != tt.expected {
+					t.Errorf("expected '%s' but found '%s'", tt.expected, value)
+				}
+				if _, ok := tx.Get("b"); ok != (tt.expected == "2") {
+					t.Errorf("expected key 'b' of the second transaction to exist: %t", tt.expected == "2")
+				}
+				return nil
+			})
+			// the log is writable after the truncation
+			if err := db.Update(func(tx *Tx) error { return tx.Put("c", []byte("3")) }); err != nil {
+				t.Errorf("error was not nil but '%v'", err)
+			}
+			_ = db.Close()
+			db = openTestDB(t, path, Options{})
+			if db.Recovered() != 0 {
+				t.Errorf("expected no recovered bytes but found %d", db.Recovered())
+			}
+		})
+	}
+}
+
+func TestDB_Compact(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "todo.db")
+	db := openTestDB(t, path, Options{NoSync: true, CompactMinSize: 1024})
+
+	for i := 0; i < 200; i++ {
+		err := db.Update(func(tx *Tx) error {
+			return tx.Put("counter", []byte{byte(i)})
+		})
+		if err != nil {
+			t.Fatalf("error was not nil but '%v'", err)
+		}
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("error was not nil but '%v'", err)
+	}
+	if info.Size() >= 1024 {
+		t.Errorf("expected compacted log but size is %d", info.Size())
+	}
+	_ = db.Close()
+	db = openTestDB(t, path, Options{})
+	_ = db.View(func(tx *Tx) error {
+		if value, ok := tx.Get("counter"); !ok || value[0] != 199 {
+			t.Errorf("expected 199 but found %v", value)
+		}
+		return nil
+	})
+}
+
+func TestDB_CompactFailureKeepsCommit(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "todo.db")
+	db := openTestDB(t, path, Options{NoSync: true, CompactMinSize: 64})
+	// the compacted log cannot be created
+	if err := os.Mkdir(path+".compact", 0o700); err != nil {
+		t.Fatalf("error was not nil but '%v'", err)
+	}
+
+	for i := 0; i < 10; i++ {
+		err := db.Update(func(tx *Tx) error {
+			return tx.Put("counter", []byte{byte(i)})
+		})
+		if err != nil {
+			t.Fatalf("expected commit to succeed but found '%v'", err)
+		}
+	}
+	if db.CompactErr() == nil {
+		t.Error("expected compaction error")
+	}
+	_ = db.View(func(tx *Tx) error {
+		if value, ok := tx.Get("counter"); !ok || value[0] != 9 {
+			t.Errorf("expected 9 but found %v", value)
+		}
+		return nil
+	})
+
+	_ = os.Remove(path + ".compact")
+	_ = db.Update(func(tx *Tx) error { return tx.Put("counter", []byte{10}) })
+	if db.CompactErr() != nil {
+		t.Errorf("expected compaction to be retried but found '%v'", db.CompactErr())
+	}
+}
@@ -0,0 +1,419 @@
+// Package github provides a ToDoClient for GitHub issues
+package github
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	neturl "net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jo-hoe/todoapi/internal/common"
+	customhttp "github.com/jo-hoe/todoapi/internal/http"
+	"github.com/jo-hoe/todoapi/pkg/errors"
+	"github.com/jo-hoe/todoapi/todoclient"
+)
+
+const (
+	// DefaultBaseURL is the base URL of the GitHub REST API
+	DefaultBaseURL = "https://api.github.com/"
+
+	reposPath  = "user/repos"
+	issuesPath = "repos/%s/issues"  // %s = owner/repo
+	issuePath  = issuesPath + "/%s" // %s = owner/repo; %s = issue number
+	pageSize   = "100"
+
+	stateOpen   = "open"
+	stateClosed = "closed"
+)
+
+// nextLinkPattern extracts the URL of the next page from a Link header
+var nextLinkPattern = regexp.MustCompile(`<([^>]+)>;\s*rel="next"`)
+
+// GitHubClient implements ToDoClient for the issues of GitHub repositories.
+// https://docs.github.com/en/rest/issues
+//
+// Repositories are parents with "owner/repo" as ID, issues are tasks with
+// "owner/repo#12" as ID, which is unique across repositories unlike the issue
+// number. Plain issue numbers are accepted as task IDs as well. Closed issues are completed tasks, the due date is the
+// due date of the milestone of an issue. Pull requests are skipped.
+//
+// Due dates are read-only, as they belong to milestones shared by many issues.
+// Issues cannot be deleted through the REST API, deleting a task closes the
+// issue as not planned. Repositories are neither created nor deleted.
+type GitHubClient struct {
+	client  *http.Client
+	baseURL *neturl.URL
+	repos   []string
+}
+
+type githubIssue struct {
+	Number      int              `json:"number,omitempty"`
+	Title       string           `json:"title"`
+	Body        *string          `json:"body"`
+	State       string           `json:"state,omitempty"`
+	StateReason string           `json:"state_reason,omitempty"`
+	Labels      []githubLabel    `json:"labels"`
+	Milestone   *githubMilestone `json:"milestone,omitempty"`
+	CreatedAt   time.Time        `json:"created_at"`
+	UpdatedAt   time.Time        `json:"updated_at"`
+	PullRequest *struct{}        `json:"pull_request,omitempty"`
+}
+
+type githubIssueRequest struct {
+	Title       string   `json:"title"`
+	Body        string   `json:"body"`
+	State       string   `json:"state,omitempty"`
+	StateReason string   `json:"state_reason,omitempty"`
+	Labels      []string `json:"labels"`
+}
+
+type githubLabel struct {
+	Name string `json:"name"`
+}
+
+type githubMilestone struct {
+	Title string     `json:"title"`
+	DueOn *time.Time `json:"due_on"`
+}
+
+type githubRepo struct {
+	FullName  string `json:"full_name"`
+	HasIssues bool   `json:"has_issues"`
+	Archived  bool   `json:"archived"`
+}
+
+// NewGitHubHTTPClient creates an HTTP client sending a personal access token with each request
+func NewGitHubHTTPClient(token string) *http.Client {
+	return customhttp.NewHTTPClientWithHeaders(map[string]string{
+		"Authorization":        "Bearer " + token,
+		"Accept":               "application/vnd.github+json",
+		"X-GitHub-Api-Version": "2022-11-28",
+	})
+}
+
+// NewGitHubClient creates a client for the repositories given as "owner/repo".
+// Without repositories, all repositories of the authenticated user with issues
+// enabled are parents. An empty base URL selects DefaultBaseURL.
+func NewGitHubClient(httpClient *http.Client, baseURL string, repos ...string) (*GitHubClient, error) {
+	if baseURL == "" {
+		baseURL = DefaultBaseURL
+	}
+	if !strings.HasSuffix(baseURL, "/") {
+		baseURL += "/"
+	}
+	parsed, err := neturl.Parse(baseURL)
+	if err != nil || parsed.Scheme == "" || parsed.Host == "" {
+		return nil, errors.NewValidationError("base_url", "invalid base URL '"+baseURL+"'")
+	}
+	for _, repo := range repos {
+		if !isRepo(repo) {
+			return nil, errors.NewValidationError("repos", "invalid repository '"+repo+"', expected owner/repo")
+		}
+	}
+	return &GitHubClient{
+		client:  httpClient,
+		baseURL: parsed,
+		repos:   repos,
+	}, nil
+}
+
+func (client *GitHubClient) GetAllTasks(ctx context.Context, query *todoclient.TaskQuery) ([]todoclient.ToDoTask, error) {
+	parents, err := client.GetAllParents(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]todoclient.ToDoTask, 0)
+	for _, parent := range parents {
+		tasks, err := client.getIssues(ctx, parent.ID, query)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, tasks...)
+	}
+	return query.Apply(result), nil
+}
+
+func (client *GitHubClient) GetChildrenTasks(ctx context.Context, parentID string, query *todoclient.TaskQuery) ([]todoclient.ToDoTask, error) {
+	tasks, err := client.getIssues(ctx, parentID, query)
+	if err != nil {
+		return nil, err
+	}
+	return query.Apply(tasks), nil
+}
+
+func (client *GitHubClient) GetTask(ctx context.Context, parentID, taskID string) (todoclient.ToDoTask, error) {
+	path, err := issueURL(parentID, taskID)
+	if err != nil {
+		return todoclient.ToDoTask{}, err
+	}
+	var issue githubIssue
+	if _, err := client.do(ctx, http.MethodGet, client.url(path, nil), nil, &issue); err != nil {
+		return todoclient.ToDoTask{}, errors.NewAPIError("GITHUB_GET_TASK_FAILED", "failed to retrieve issue", err)
+	}
+	if issue.PullRequest != nil {
+		return todoclient.ToDoTask{}, errors.NewAPIError("GITHUB_GET_TASK_FAILED", fmt.Sprintf("%s is a pull request", issueID(parentID, issue.Number)), errors.ErrNotFound)
+	}
+	return toToDoTask(parentID, issue), nil
+}
+
+func (client *GitHubClient) CreateTask(ctx context.Context, parentID string, task todoclient.ToDoTask) (todoclient.ToDoTask, error) {
+	if err := task.Validate(); err != nil {
+		return todoclient.ToDoTask{}, err
+	}
+	if !isRepo(parentID) {
+		return todoclient.ToDoTask{}, notFound(parentID)
+	}
+
+	// issues are always opened, a completed task is closed afterwards
+	request := fromToDoTask(task)
+	request.State, request.StateReason = "", ""
+	var created githubIssue
+	path := fmt.Sprintf(issuesPath, parentID)
+	if _, err := client.do(ctx, http.MethodPost, client.url(path, nil), request, &created); err != nil {
+		return todoclient.ToDoTask{}, errors.NewAPIError("GITHUB_CREATE_FAILED", "failed to create issue", err)
+	}
+
+	if task.IsCompleted {
+		task.ID = issueID(parentID, created.Number)
+		if err := client.UpdateTask(ctx, parentID, task); err != nil {
+			return todoclient.ToDoTask{}, err
+		}
+		created.State = stateClosed
+	}
+	return toToDoTask(parentID, created), nil
+}
+
+func (client *GitHubClient) UpdateTask(ctx context.Context, parentID string, task todoclient.ToDoTask) error {
+	if err := task.Validate(); err != nil {
+		return err
+	}
+	path, err := issueURL(parentID, task.ID)
+	if err != nil {
+		return err
+	}
+	if _, err := client.do(ctx, http.MethodPatch, client.url(path, nil), fromToDoTask(task), nil); err != nil {
+		return errors.NewAPIError("GITHUB_UPDATE_FAILED", "failed to update issue", err)
+	}
+	return nil
+}
+
+func (client *GitHubClient) DeleteTask(ctx context.Context, parentID, taskID string) error {
+	path, err := issueURL(parentID, taskID)
+	if err != nil {
+		return err
+	}
+	request := map[string]string{"state": stateClosed, "state_reason": "not_planned"}
+	if _, err := client.do(ctx, http.MethodPatch, client.url(path, nil), request, nil); err != nil {
+		return errors.NewAPIError("GITHUB_DELETE_FAILED", "failed to close issue", err)
+	}
+	return nil
+}
+
+func (client *GitHubClient) GetAllParents(ctx context.Context) ([]todoclient.ToDoParent, error) {
+	result := make([]todoclient.ToDoParent, 0)
+	if len(client.repos) > 0 {
+		for _, repo := range client.repos {
+			result = append(result, todoclient.ToDoParent{ID: repo, Name: repo})
+		}
+		return result, nil
+	}
+
+	next := client.url(reposPath, neturl.Values{"per_page": {pageSize}})
+	for next != "" {
+		var repos []githubRepo
+		var err error
+		if next, err = client.do(ctx, http.MethodGet, next, nil, &repos); err != nil {
+			return nil, errors.NewAPIError("GITHUB_GET_PARENTS_FAILED", "failed to retrieve repositories", err)
+		}
+		for _, repo := range repos {
+			if repo.HasIssues && !repo.Archived {
+				result = append(result, todoclient.ToDoParent{ID: repo.FullName, Name: repo.FullName})
+			}
+		}
+	}
+	return result, nil
+}
+
+func (client *GitHubClient) CreateParent(ctx context.Context, parentName string) (todoclient.ToDoParent, error) {
+	return todoclient.ToDoParent{}, errors.NewAPIError("GITHUB_NOT_SUPPORTED", "repositories cannot be created", errors.ErrNotSupported)
+}
+
+func (client *GitHubClient) DeleteParent(ctx context.Context, parentID string) error {
+	return errors.NewAPIError("GITHUB_NOT_SUPPORTED", "repositories cannot be deleted", errors.ErrNotSupported)
+}
+
+// Capabilities reports the features supported by the GitHub client. Due dates
+// are read from milestones and repositories cannot be created or deleted.
+func (client *GitHubClient) Capabilities() todoclient.Capabilities {
+	return todoclient.Capabilities{
+		Labels: true,
+	}
+}
+
+// getIssues reads all pages of the issues of a repository. Labels and the
+// completion state of the query are passed to the API.
+func (client *GitHubClient) getIssues(ctx context.Context, parentID string, query *todoclient.TaskQuery) ([]todoclient.ToDoTask, error) {
+	if !isRepo(parentID) {
+		return nil, notFound(parentID)
+	}
+	params := neturl.Values{
+		"state":    {"all"},
+		"per_page": {pageSize},
+	}
+	if query != nil && query.Completed != nil {
+		params.Set("state", stateOpen)
+		if *query.Completed {
+			params.Set("state", stateClosed)
+		}
+	}
+	if query != nil && len(query.Labels) > 0 {
+		params.Set("labels", strings.Join(query.Labels, ","))
+	}
+
+	result := make([]todoclient.ToDoTask, 0)
+	next := client.url(fmt.Sprintf(issuesPath, parentID), params)
+	for next != "" {
+		var issues []githubIssue
+		var err error
+		if next, err = client.do(ctx, http.MethodGet, next, nil, &issues); err != nil {
+			return nil, errors.NewAPIError("GITHUB_GET_TASKS_FAILED", "failed to retrieve issues", err)
+		}
+		for _, issue := range issues {
+			if issue.PullRequest == nil {
+				result = append(result, toToDoTask(parentID, issue))
+			}
+		}
+	}
+	return result, nil
+}
+
+func (client *GitHubClient) url(path string, params neturl.Values) string {
+	url := client.baseURL.JoinPath(path)
+	url.RawQuery = params.Encode()
+	return url.String()
+}
+
+// do sends a request with an optional JSON payload and decodes the response
+// into data if not nil. It returns the URL of the next page, if any.
+func (client *GitHubClient) do(ctx context.Context, method, url string, payload, data interface{}) (string, error) {
+	var body io.Reader
+	if payload != nil {
+		b, err := json.Marshal(payload)
+		if err != nil {
+			return "", errors.NewAPIError("GITHUB_MARSHAL_FAILED", "failed to marshal request", err)
+		}
+		body = bytes.NewReader(b)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, url, body)
+	if err != nil {
+		return "", errors.NewAPIError("GITHUB_REQUEST_FAILED", "failed to create request", err)
+	}
+	if payload != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := client.client.Do(req)
+	if err != nil {
+		return "", errors.NewAPIError("GITHUB_HTTP_FAILED", "HTTP request failed", err)
+	}
+	defer common.CloseBody(resp.Body)
+
+	switch {
+	// GitHub answers 404 for private repositories without access, and 410 for deleted issues
+	case resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusGone:
+		return "", errors.NewAPIError("GITHUB_NOT_FOUND", "resource not found", errors.ErrNotFound)
+	case resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden:
+		return "", errors.NewAPIError("GITHUB_UNAUTHORIZED", fmt.Sprintf("request failed with status %d", resp.StatusCode), errors.ErrUnauthorized)
+	case resp.StatusCode < 200 || resp.StatusCode > 299:
+		return "", errors.NewAPIError("GITHUB_STATUS_FAILED", fmt.Sprintf("request failed with status %d", resp.StatusCode), nil)
+	}
+
+	if data != nil {
+		if err := json.NewDecoder(resp.Body).Decode(data); err != nil {
+			return "", errors.NewAPIError("GITHUB_DECODE_FAILED", "failed to decode response", err)
+		}
+	}
+	if match := nextLinkPattern.FindStringSubmatch(resp.Header.Get("Link")); match != nil {
+		return match[1], nil
+	}
+	return "", nil
+}
+
+func toToDoTask(parentID string, issue githubIssue) todoclient.ToDoTask {
+	task := todoclient.ToDoTask{
+		ID:           issueID(parentID, issue.Number),
+		Name:         issue.Title,
+		CreationTime: issue.CreatedAt,
+		ModifiedTime: issue.UpdatedAt,
+		IsCompleted:  issue.State == stateClosed,
+		Labels:       make([]string, 0, len(issue.Labels)),
+	}
+	if issue.Body != nil {
+		task.Description = *issue.Body
+	}
+	for _, label := range issue.Labels {
+		task.Labels = append(task.Labels, label.Name)
+	}
+	if issue.Milestone != nil && issue.Milestone.DueOn != nil {
+		task.DueDate = *issue.Milestone.DueOn
+	}
+	return task
+}
+
+func fromToDoTask(task todoclient.ToDoTask) githubIssueRequest {
+	request := githubIssueRequest{
+		Title:  task.Name,
+		Body:   task.Description,
+		State:  stateOpen,
+		Labels: task.Labels,
+	}
+	if request.Labels == nil {
+		request.Labels = make([]string, 0)
+	}
+	if task.IsCompleted {
+		request.State = stateClosed
+		request.StateReason = "completed"
+	}
+	return request
+}
+
+// issueID returns the task ID of an issue, e.g. owner/repo#12
+func issueID(parentID string, number int) string {
+	return parentID + "#" + strconv.Itoa(number)
+}
+
+func issueURL(parentID, taskID string) (string, error) {
+	if !isRepo(parentID) {
+		return "", notFound(parentID)
+	}
+	// the parent of a task ID like owner/repo#12 has to match
+	number := taskID
+	if i := strings.LastIndex(taskID, "#"); i >= 0 {
+		if !strings.EqualFold(taskID[:i], parentID) {
+			return "", errors.NewAPIError("GITHUB_NOT_FOUND", fmt.Sprintf("issue '%s' is not part of '%s'", taskID, parentID), errors.ErrNotFound)
+		}
+		number = taskID[i+1:]
+	}
+	if _, err := strconv.Atoi(number); err != nil {
+		return "", errors.NewAPIError("GITHUB_NOT_FOUND", fmt.Sprintf("invalid issue number '%s'", taskID), errors.ErrNotFound)
+	}
+	return fmt.Sprintf(issuePath, parentID, number), nil
+}
+
+// isRepo reports whether id has the form owner/repo
+func isRepo(id string) bool {
+	owner, repo, ok := strings.Cut(id, "/")
+	return ok && owner != "" && repo != "" && !strings.ContainsAny(repo, "/?#") && owner != ".." && repo != ".."
+}
+
+func notFound(parentID string) error {
+	return errors.NewAPIError("GITHUB_NOT_FOUND", fmt.Sprintf("invalid repository '%s'", parentID), errors.ErrNotFound)
+}
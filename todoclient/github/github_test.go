@@ -0,0 +1,298 @@
+package github
+
+import (
+	"context"
+	"encoding/json"
+	stderrors "errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jo-hoe/todoapi/pkg/errors"
+	"github.com/jo-hoe/todoapi/todoclient"
+	"github.com/jo-hoe/todoapi/todoclient/conformance"
+	"github.com/jo-hoe/todoapi/todoclient/search"
+)
+
+// fakeGitHub is an in-memory stand-in for the issues API with pages of two items
+type fakeGitHub struct {
+	mu       sync.Mutex
+	url      string
+	issues   map[string][]*githubIssue // keyed by owner/repo
+	requests []string
+}
+
+func newFakeGitHub(t *testing.T) *fakeGitHub {
+	fake := &fakeGitHub{issues: make(map[string][]*githubIssue)}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /user/repos", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, []githubRepo{
+			{FullName: "alice/app", HasIssues: true},
+			{FullName: "alice/wiki", HasIssues: false},
+			{FullName: "alice/old", HasIssues: true, Archived: true},
+		})
+	})
+	mux.HandleFunc("GET /repos/{owner}/{repo}/issues", func(w http.ResponseWriter, r *http.Request) {
+		fake.mu.Lock()
+		defer fake.mu.Unlock()
+		fake.requests = append(fake.requests, r.URL.String())
+		issues, ok := fake.issues[r.PathValue("owner")+"/"+r.PathValue("repo")]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+		page = max(page, 1)
+		start, end := min((page-1)*2, len(issues)), min(page*2, len(issues))
+		if end < len(issues) {
+			next := *r.URL
+			query := next.Query()
+			query.Set("page", strconv.Itoa(page+1))
+			next.RawQuery = query.Encode()
+			w.Header().Set("Link", fmt.Sprintf(`<%s%s>; rel="next", <%s/last>; rel="last"`, fake.url, next.String(), fake.url))
+		}
+		writeJSON(w, issues[start:end])
+	})
+	mux.HandleFunc("POST /repos/{owner}/{repo}/issues", func(w http.ResponseWriter, r *http.Request) {
+		fake.mu.Lock()
+		defer fake.mu.Unlock()
+		repo := r.PathValue("owner") + "/" + r.PathValue("repo")
+		var request githubIssueRequest
+		_ = json.NewDecoder(r.Body).Decode(&request)
+		if request.State != "" {
+			w.WriteHeader(http.StatusUnprocessableEntity)
+			return
+		}
+		issue := &githubIssue{Number: len(fake.issues[repo]) + 1, State: stateOpen, CreatedAt: time.Now().UTC(), UpdatedAt: time.Now().UTC()}
+		applyRequest(issue, request)
+		fake.issues[repo] = append(fake.issues[repo], issue)
+		w.WriteHeader(http.StatusCreated)
+		writeJSON(w, issue)
+	})
+	mux.HandleFunc("GET /repos/{owner}/{repo}/issues/{number}", func(w http.ResponseWriter, r *http.Request) {
+		fake.withIssue(w, r, func(issue *githubIssue) { writeJSON(w, issue) })
+	})
+	mux.HandleFunc("PATCH /repos/{owner}/{repo}/issues/{number}", func(w http.ResponseWriter, r *http.Request) {
+		fake.withIssue(w, r, func(issue *githubIssue) {
+			var request githubIssueRequest
+			_ = json.NewDecoder(r.Body).Decode(&request)
+			applyRequest(issue, request)
+			writeJSON(w, issue)
+		})
+	})
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	fake.url = server.URL
+	return fake
+}
+
+func (f *fakeGitHub) withIssue(w http.ResponseWriter, r *http.Request, fn func(issue *githubIssue)) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	number, _ := strconv.Atoi(r.PathValue("number"))
+	issues := f.issues[r.PathValue("owner")+"/"+r.PathValue("repo")]
+	if number < 1 || number > len(issues) {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	fn(issues[number-1])
+}
+
+// applyRequest applies the set fields of a create or update request
+func applyRequest(issue *githubIssue, request githubIssueRequest) {
+	if request.Title != "" {
+		issue.Title = request.Title
+	}
+	body := request.Body
+	issue.Body = &body
+	if request.State != "" {
+		issue.State = request.State
+		issue.StateReason = request.StateReason
+	}
+	if request.Labels != nil {
+		issue.Labels = make([]githubLabel, 0)
+		for _, label := range request.Labels {
+			issue.Labels = append(issue.Labels, githubLabel{Name: label})
+		}
+	}
+}
+
+func writeJSON(w http.ResponseWriter, value interface{}) {
+	_ = json.NewEncoder(w).Encode(value)
+}
+
+func newTestClient(t *testing.T, repos ...string) (*GitHubClient, *fakeGitHub) {
+	t.Helper()
+	fake := newFakeGitHub(t)
+	client, err := NewGitHubClient(http.DefaultClient, fake.url, repos...)
+	if err != nil {
+		t.Fatalf("error was not nil but '%v'", err)
+	}
+	return client, fake
+}
+
+func TestGitHubClient_ImplementationTest(t *testing.T) {
+	// tests if interface is implemented
+	var _ todoclient.ToDoClient = (*GitHubClient)(nil)
+}
+
+func TestGitHubClient_Conformance(t *testing.T) {
+	client, fake := newTestClient(t, "alice/app")
+	fake.issues["alice/app"] = []*githubIssue{}
+	conformance.Run(t, client, conformance.Options{ParentID: "alice/app", SoftDelete: true})
+}
+
+func TestNewGitHubClient_InvalidRepo(t *testing.T) {
+	_, err := NewGitHubClient(http.DefaultClient, "", "no-owner")
+
+	if err == nil {
+		t.Error("expected error for invalid repository")
+	}
+}
+
+func TestGitHubClient_GetAllParents(t *testing.T) {
+	client, _ := newTestClient(t)
+
+	parents, err := client.GetAllParents(context.Background())
+
+	if err != nil {
+		t.Errorf("error was not nil but '%v'", err)
+	}
+	if len(parents) != 1 || parents[0].ID != "alice/app" {
+		t.Errorf("expected only 'alice/app' but found %v", parents)
+	}
+}
+
+func TestGitHubClient_CRUD(t *testing.T) {
+	client, _ := newTestClient(t, "alice/app")
+	ctx := context.Background()
+
+	task, err := client.CreateTask(ctx, "alice/app", todoclient.ToDoTask{Name: "Crash on start", Labels: []string{"bug"}, IsCompleted: true})
+	if err != nil {
+		t.Fatalf("error was not nil but '%v'", err)
+	}
+	if task.ID != "alice/app#1" || !task.IsCompleted {
+		t.Errorf("expected closed issue 1 but found %+v", task)
+	}
+
+	task.IsCompleted = false
+	task.Description = "stack trace"
+	if err := client.UpdateTask(ctx, "alice/app", task); err != nil {
+		t.Errorf("error was not nil but '%v'", err)
+	}
+	found, err := client.GetTask(ctx, "alice/app", task.ID)
+	if err != nil {
+		t.Fatalf("error was not nil but '%v'", err)
+	}
+	if found.IsCompleted || found.Description != "stack trace" || len(found.Labels) != 1 || found.Labels[0] != "bug" {
+		t.Errorf("expected reopened issue but found %+v", found)
+	}
+
+	if err := client.DeleteTask(ctx, "alice/app", task.ID); err != nil {
+		t.Errorf("error was not nil but '%v'", err)
+	}
+	found, _ = client.GetTask(ctx, "alice/app", task.ID)
+	if !found.IsCompleted {
+		t.Error("expected deleted issue to be closed")
+	}
+}
+
+func TestGitHubClient_GetChildrenTasks(t *testing.T) {
+	client, fake := newTestClient(t, "alice/app")
+	due := time.Date(2024, 3, 1, 8, 0, 0, 0, time.UTC)
+	body := "body"
+	fake.issues["alice/app"] = []*githubIssue{
+		{Number: 1, Title: "first", Body: &body, State: stateOpen, Milestone: &githubMilestone{Title: "v1", DueOn: &due}},
+		{Number: 2, Title: "pull request", State: stateOpen, PullRequest: &struct{}{}},
+		{Number: 3, Title: "third", State: stateClosed, Labels: []githubLabel{{Name: "bug"}}},
+		{Number: 4, Title: "fourth", State: stateOpen, Milestone: &githubMilestone{Title: "backlog"}},
+		{Number: 5, Title: "fifth", State: stateOpen},
+	}
+
+	tasks, err := client.GetChildrenTasks(context.Background(), "alice/app", &todoclient.TaskQuery{Labels: []string{"bug"}})
+
+	if err != nil {
+		t.Fatalf("error was not nil but '%v'", err)
+	}
+	// the fake ignores the label filter, so it is applied client-side as well
+	if len(tasks) != 1 || tasks[0].ID != "alice/app#3" || !tasks[0].IsCompleted {
+		t.Errorf("expected closed issue 3 but found %v", tasks)
+	}
+	if !strings.Contains(fake.requests[0], "labels=bug") || !strings.Contains(fake.requests[0], "state=all") {
+		t.Errorf("expected label and state parameters but found '%s'", fake.requests[0])
+	}
+
+	tasks, _ = client.GetChildrenTasks(context.Background(), "alice/app", nil)
+	if len(tasks) != 4 {
+		t.Fatalf("expected %d tasks without pull request but found %d", 4, len(tasks))
+	}
+	if !tasks[0].DueDate.Equal(due) || tasks[0].Description != "body" {
+		t.Errorf("expected milestone due date and body but found %+v", tasks[0])
+	}
+	if !tasks[2].DueDate.IsZero() {
+		t.Errorf("expected no due date for milestone without due date but found %v", tasks[2].DueDate)
+	}
+}
+
+func TestGitHubClient_SameIssueNumberInTwoProjects(t *testing.T) {
+	client, _ := newTestClient(t, "alice/app", "alice/web")
+	ctx := context.Background()
+	app, _ := client.CreateTask(ctx, "alice/app", todoclient.ToDoTask{Name: "crash"})
+	web, _ := client.CreateTask(ctx, "alice/web", todoclient.ToDoTask{Name: "crash"})
+
+	if app.ID != "alice/app#1" || web.ID != "alice/web#1" {
+		t.Errorf("expected IDs unique across projects but found '%s' and '%s'", app.ID, web.ID)
+	}
+	if _, err := client.GetTask(ctx, "alice/app", web.ID); !stderrors.Is(err, errors.ErrNotFound) {
+		t.Errorf("expected not found error for issue of other project but found '%v'", err)
+	}
+	if task, err := client.GetTask(ctx, "alice/web", "1"); err != nil || task.ID != web.ID {
+		t.Errorf("expected issue for plain number but found %+v and '%v'", task, err)
+	}
+
+	// consumers keying tasks by ID see both issues
+	results, err := search.NewIndexedSearcher(map[string]todoclient.ToDoClient{"github": client}, 0).Search(ctx, "crash", 0)
+	if err != nil {
+		t.Fatalf("error was not nil but '%v'", err)
+	}
+	if len(results) != 2 || results[0].Task.ID == results[1].Task.ID {
+		t.Errorf("expected both issues but found %+v", results)
+	}
+}
+
+func TestGitHubClient_NotFound(t *testing.T) {
+	client, fake := newTestClient(t, "alice/app")
+	fake.issues["alice/app"] = []*githubIssue{{Number: 1, Title: "pull request", PullRequest: &struct{}{}}}
+	ctx := context.Background()
+
+	for name, err := range map[string]error{
+		"unknown repo":  func() error { _, err := client.GetChildrenTasks(ctx, "alice/unknown", nil); return err }(),
+		"invalid repo":  func() error { _, err := client.GetTask(ctx, "alice", "1"); return err }(),
+		"invalid issue": func() error { _, err := client.GetTask(ctx, "alice/app", "abc"); return err }(),
+		"pull request":  func() error { _, err := client.GetTask(ctx, "alice/app", "1"); return err }(),
+	} {
+		if !stderrors.Is(err, errors.ErrNotFound) {
+			t.Errorf("%s: expected not found error but found '%v'", name, err)
+		}
+	}
+}
+
+func TestGitHubClient_NotSupported(t *testing.T) {
+	client, _ := newTestClient(t, "alice/app")
+	ctx := context.Background()
+
+	if _, err := client.CreateParent(ctx, "alice/new"); !stderrors.Is(err, errors.ErrNotSupported) {
+		t.Errorf("expected not supported error but found '%v'", err)
+	}
+	if err := client.DeleteParent(ctx, "alice/app"); !stderrors.Is(err, errors.ErrNotSupported) {
+		t.Errorf("expected not supported error but found '%v'", err)
+	}
+	if capabilities := client.Capabilities(); capabilities.CreateParent || capabilities.DeleteParent || capabilities.DueDates {
+		t.Errorf("expected read-only repositories and due dates but found %+v", capabilities)
+	}
+}
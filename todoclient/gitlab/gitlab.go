@@ -0,0 +1,428 @@
+// Package gitlab provides a ToDoClient for GitLab issues
+package gitlab
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	neturl "net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jo-hoe/todoapi/internal/common"
+	customhttp "github.com/jo-hoe/todoapi/internal/http"
+	"github.com/jo-hoe/todoapi/pkg/errors"
+	"github.com/jo-hoe/todoapi/todoclient"
+)
+
+const (
+	// DefaultBaseURL is the base URL of the GitLab REST API
+	DefaultBaseURL = "https://gitlab.com/api/v4/"
+
+	projectsPath  = "projects"
+	issuesPath    = "projects/%s/issues" // %s = escaped group/project
+	issuePath     = issuesPath + "/%s"   // %s = escaped group/project; %s = issue IID
+	pageSize      = "100"
+	dueDateLayout = "2006-01-02"
+
+	stateOpened = "opened"
+	stateClosed = "closed"
+)
+
+// nextLinkPattern extracts the URL of the next page from a Link header
+var nextLinkPattern = regexp.MustCompile(`<([^>]+)>;\s*rel="next"`)
+
+// GitLabClient implements ToDoClient for the issues of GitLab projects.
+// https://docs.gitlab.com/ee/api/issues.html
+//
+// Projects are parents with their full path like "group/project" as ID, issues
+// are tasks with a reference like "group/project#12" as ID, which is unique across
+// projects unlike the project-scoped issue IID. Plain IIDs are accepted as task
+// IDs as well. Closed issues are completed
+// tasks, the due date is the due date of the issue or else of its milestone.
+//
+// Due dates are read-only like for GitHub, as milestones are shared by many
+// issues. Deleting issues requires owner permissions, deleting a task closes
+// the issue instead. Projects are neither created nor deleted.
+type GitLabClient struct {
+	client   *http.Client
+	baseURL  *neturl.URL
+	projects []string
+}
+
+type gitlabIssue struct {
+	IID         int              `json:"iid"`
+	Title       string           `json:"title"`
+	Description *string          `json:"description"`
+	State       string           `json:"state"`
+	Labels      []string         `json:"labels"`
+	DueDate     *string          `json:"due_date"`
+	Milestone   *gitlabMilestone `json:"milestone"`
+	CreatedAt   time.Time        `json:"created_at"`
+	UpdatedAt   time.Time        `json:"updated_at"`
+}
+
+// gitlabIssueRequest creates or updates an issue. Labels are a comma-separated
+// list, an empty list removes all labels.
+type gitlabIssueRequest struct {
+	Title       string `json:"title"`
+	Description string `json:"description"`
+	Labels      string `json:"labels"`
+	StateEvent  string `json:"state_event,omitempty"`
+}
+
+type gitlabMilestone struct {
+	Title   string  `json:"title"`
+	DueDate *string `json:"due_date"`
+}
+
+type gitlabProject struct {
+	PathWithNamespace string `json:"path_with_namespace"`
+	IssuesEnabled     bool   `json:"issues_enabled"`
+	Archived          bool   `json:"archived"`
+}
+
+// NewGitLabHTTPClient creates an HTTP client sending a personal access token with each request
+func NewGitLabHTTPClient(token string) *http.Client {
+	return customhttp.NewHTTPClientWithHeaders(map[string]string{
+		"PRIVATE-TOKEN": token,
+	})
+}
+
+// NewGitLabClient creates a client for the projects given as "group/project".
+// Without projects, all projects the user is a member of with issues enabled
+// are parents. An empty base URL selects DefaultBaseURL.
+func NewGitLabClient(httpClient *http.Client, baseURL string, projects ...string) (*GitLabClient, error) {
+	if baseURL == "" {
+		baseURL = DefaultBaseURL
+	}
+	if !strings.HasSuffix(baseURL, "/") {
+		baseURL += "/"
+	}
+	parsed, err := neturl.Parse(baseURL)
+	if err != nil || parsed.Scheme == "" || parsed.Host == "" {
+		return nil, errors.NewValidationError("base_url", "invalid base URL '"+baseURL+"'")
+	}
+	for _, project := range projects {
+		if !isProject(project) {
+			return nil, errors.NewValidationError("projects", "invalid project '"+project+"', expected group/project")
+		}
+	}
+	return &GitLabClient{
+		client:   httpClient,
+		baseURL:  parsed,
+		projects: projects,
+	}, nil
+}
+
+func (client *GitLabClient) GetAllTasks(ctx context.Context, query *todoclient.TaskQuery) ([]todoclient.ToDoTask, error) {
+	parents, err := client.GetAllParents(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]todoclient.ToDoTask, 0)
+	for _, parent := range parents {
+		tasks, err := client.getIssues(ctx, parent.ID, query)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, tasks...)
+	}
+	return query.Apply(result), nil
+}
+
+func (client *GitLabClient) GetChildrenTasks(ctx context.Context, parentID string, query *todoclient.TaskQuery) ([]todoclient.ToDoTask, error) {
+	tasks, err := client.getIssues(ctx, parentID, query)
+	if err != nil {
+		return nil, err
+	}
+	return query.Apply(tasks), nil
+}
+
+func (client *GitLabClient) GetTask(ctx context.Context, parentID, taskID string) (todoclient.ToDoTask, error) {
+	path, err := issueURL(parentID, taskID)
+	if err != nil {
+		return todoclient.ToDoTask{}, err
+	}
+	var issue gitlabIssue
+	if _, err := client.do(ctx, http.MethodGet, client.url(path, nil), nil, &issue); err != nil {
+		return todoclient.ToDoTask{}, errors.NewAPIError("GITLAB_GET_TASK_FAILED", "failed to retrieve issue", err)
+	}
+	return toToDoTask(parentID, issue), nil
+}
+
+func (client *GitLabClient) CreateTask(ctx context.Context, parentID string, task todoclient.ToDoTask) (todoclient.ToDoTask, error) {
+	if err := task.Validate(); err != nil {
+		return todoclient.ToDoTask{}, err
+	}
+	if !isProject(parentID) {
+		return todoclient.ToDoTask{}, notFound(parentID)
+	}
+
+	// issues are always opened, a completed task is closed afterwards
+	request := fromToDoTask(task)
+	request.StateEvent = ""
+	var created gitlabIssue
+	path := fmt.Sprintf(issuesPath, neturl.PathEscape(parentID))
+	if _, err := client.do(ctx, http.MethodPost, client.url(path, nil), request, &created); err != nil {
+		return todoclient.ToDoTask{}, errors.NewAPIError("GITLAB_CREATE_FAILED", "failed to create issue", err)
+	}
+
+	if task.IsCompleted {
+		task.ID = issueID(parentID, created.IID)
+		if err := client.UpdateTask(ctx, parentID, task); err != nil {
+			return todoclient.ToDoTask{}, err
+		}
+		created.State = stateClosed
+	}
+	return toToDoTask(parentID, created), nil
+}
+
+func (client *GitLabClient) UpdateTask(ctx context.Context, parentID string, task todoclient.ToDoTask) error {
+	if err := task.Validate(); err != nil {
+		return err
+	}
+	path, err := issueURL(parentID, task.ID)
+	if err != nil {
+		return err
+	}
+	if _, err := client.do(ctx, http.MethodPut, client.url(path, nil), fromToDoTask(task), nil); err != nil {
+		return errors.NewAPIError("GITLAB_UPDATE_FAILED", "failed to update issue", err)
+	}
+	return nil
+}
+
+func (client *GitLabClient) DeleteTask(ctx context.Context, parentID, taskID string) error {
+	path, err := issueURL(parentID, taskID)
+	if err != nil {
+		return err
+	}
+	request := map[string]string{"state_event": "close"}
+	if _, err := client.do(ctx, http.MethodPut, client.url(path, nil), request, nil); err != nil {
+		return errors.NewAPIError("GITLAB_DELETE_FAILED", "failed to close issue", err)
+	}
+	return nil
+}
+
+func (client *GitLabClient) GetAllParents(ctx context.Context) ([]todoclient.ToDoParent, error) {
+	result := make([]todoclient.ToDoParent, 0)
+	if len(client.projects) > 0 {
+		for _, project := range client.projects {
+			result = append(result, todoclient.ToDoParent{ID: project, Name: project})
+		}
+		return result, nil
+	}
+
+	next := client.url(projectsPath, neturl.Values{
+		"membership":          {"true"},
+		"with_issues_enabled": {"true"},
+		"archived":            {"false"},
+		"per_page":            {pageSize},
+	})
+	for next != "" {
+		var projects []gitlabProject
+		var err error
+		if next, err = client.do(ctx, http.MethodGet, next, nil, &projects); err != nil {
+			return nil, errors.NewAPIError("GITLAB_GET_PARENTS_FAILED", "failed to retrieve projects", err)
+		}
+		for _, project := range projects {
+			// filtered by the API as well, older instances ignore the parameters
+			if project.IssuesEnabled && !project.Archived {
+				result = append(result, todoclient.ToDoParent{ID: project.PathWithNamespace, Name: project.PathWithNamespace})
+			}
+		}
+	}
+	return result, nil
+}
+
+func (client *GitLabClient) CreateParent(ctx context.Context, parentName string) (todoclient.ToDoParent, error) {
+	return todoclient.ToDoParent{}, errors.NewAPIError("GITLAB_NOT_SUPPORTED", "projects cannot be created", errors.ErrNotSupported)
+}
+
+func (client *GitLabClient) DeleteParent(ctx context.Context, parentID string) error {
+	return errors.NewAPIError("GITLAB_NOT_SUPPORTED", "projects cannot be deleted", errors.ErrNotSupported)
+}
+
+// Capabilities reports the features supported by the GitLab client. Due dates
+// are read-only and projects cannot be created or deleted.
+func (client *GitLabClient) Capabilities() todoclient.Capabilities {
+	return todoclient.Capabilities{
+		Labels: true,
+	}
+}
+
+// getIssues reads all pages of the issues of a project. Labels and the
+// completion state of the query are passed to the API.
+func (client *GitLabClient) getIssues(ctx context.Context, parentID string, query *todoclient.TaskQuery) ([]todoclient.ToDoTask, error) {
+	if !isProject(parentID) {
+		return nil, notFound(parentID)
+	}
+	params := neturl.Values{
+		"state":    {"all"},
+		"per_page": {pageSize},
+	}
+	if query != nil && query.Completed != nil {
+		params.Set("state", stateOpened)
+		if *query.Completed {
+			params.Set("state", stateClosed)
+		}
+	}
+	if query != nil && len(query.Labels) > 0 {
+		params.Set("labels", strings.Join(query.Labels, ","))
+	}
+
+	result := make([]todoclient.ToDoTask, 0)
+	next := client.url(fmt.Sprintf(issuesPath, neturl.PathEscape(parentID)), params)
+	for next != "" {
+		var issues []gitlabIssue
+		var err error
+		if next, err = client.do(ctx, http.MethodGet, next, nil, &issues); err != nil {
+			return nil, errors.NewAPIError("GITLAB_GET_TASKS_FAILED", "failed to retrieve issues", err)
+		}
+		for _, issue := range issues {
+			result = append(result, toToDoTask(parentID, issue))
+		}
+	}
+	return result, nil
+}
+
+// url appends an already escaped path to the base URL. Project paths are
+// escaped as a single segment, which JoinPath would escape a second time.
+func (client *GitLabClient) url(path string, params neturl.Values) string {
+	url := client.baseURL.String() + path
+	if len(params) > 0 {
+		url += "?" + params.Encode()
+	}
+	return url
+}
+
+// do sends a request with an optional JSON payload and decodes the response
+// into data if not nil. It returns the URL of the next page, if any.
+func (client *GitLabClient) do(ctx context.Context, method, url string, payload, data interface{}) (string, error) {
+	var body io.Reader
+	if payload != nil {
+		b, err := json.Marshal(payload)
+		if err != nil {
+			return "", errors.NewAPIError("GITLAB_MARSHAL_FAILED", "failed to marshal request", err)
+		}
+		body = bytes.NewReader(b)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, url, body)
+	if err != nil {
+		return "", errors.NewAPIError("GITLAB_REQUEST_FAILED", "failed to create request", err)
+	}
+	if payload != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := client.client.Do(req)
+	if err != nil {
+		return "", errors.NewAPIError("GITLAB_HTTP_FAILED", "HTTP request failed", err)
+	}
+	defer common.CloseBody(resp.Body)
+
+	switch {
+	// GitLab answers 404 for private projects without access
+	case resp.StatusCode == http.StatusNotFound:
+		return "", errors.NewAPIError("GITLAB_NOT_FOUND", "resource not found", errors.ErrNotFound)
+	case resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden:
+		return "", errors.NewAPIError("GITLAB_UNAUTHORIZED", fmt.Sprintf("request failed with status %d", resp.StatusCode), errors.ErrUnauthorized)
+	case resp.StatusCode < 200 || resp.StatusCode > 299:
+		return "", errors.NewAPIError("GITLAB_STATUS_FAILED", fmt.Sprintf("request failed with status %d", resp.StatusCode), nil)
+	}
+
+	if data != nil {
+		if err := json.NewDecoder(resp.Body).Decode(data); err != nil {
+			return "", errors.NewAPIError("GITLAB_DECODE_FAILED", "failed to decode response", err)
+		}
+	}
+	if match := nextLinkPattern.FindStringSubmatch(resp.Header.Get("Link")); match != nil {
+		return match[1], nil
+	}
+	return "", nil
+}
+
+func toToDoTask(parentID string, issue gitlabIssue) todoclient.ToDoTask {
+	task := todoclient.ToDoTask{
+		ID:           issueID(parentID, issue.IID),
+		Name:         issue.Title,
+		CreationTime: issue.CreatedAt,
+		ModifiedTime: issue.UpdatedAt,
+		IsCompleted:  issue.State == stateClosed,
+		Labels:       append(make([]string, 0, len(issue.Labels)), issue.Labels...),
+	}
+	if issue.Description != nil {
+		task.Description = *issue.Description
+	}
+	dueDate := issue.DueDate
+	if dueDate == nil && issue.Milestone != nil {
+		dueDate = issue.Milestone.DueDate
+	}
+	if dueDate != nil {
+		if parsed, err := time.Parse(dueDateLayout, *dueDate); err == nil {
+			task.DueDate = parsed
+		}
+	}
+	return task
+}
+
+func fromToDoTask(task todoclient.ToDoTask) gitlabIssueRequest {
+	request := gitlabIssueRequest{
+		Title:       task.Name,
+		Description: task.Description,
+		Labels:      strings.Join(task.Labels, ","),
+		StateEvent:  "reopen",
+	}
+	if task.IsCompleted {
+		request.StateEvent = "close"
+	}
+	return request
+}
+
+// issueID returns the task ID of an issue, e.g. owner/repo#12
+func issueID(parentID string, iid int) string {
+	return parentID + "#" + strconv.Itoa(iid)
+}
+
+func issueURL(parentID, taskID string) (string, error) {
+	if !isProject(parentID) {
+		return "", notFound(parentID)
+	}
+	// the parent of a task ID like owner/repo#12 has to match
+	iid := taskID
+	if i := strings.LastIndex(taskID, "#"); i >= 0 {
+		if !strings.EqualFold(taskID[:i], parentID) {
+			return "", errors.NewAPIError("GITLAB_NOT_FOUND", fmt.Sprintf("issue '%s' is not part of '%s'", taskID, parentID), errors.ErrNotFound)
+		}
+		iid = taskID[i+1:]
+	}
+	if _, err := strconv.Atoi(iid); err != nil {
+		return "", errors.NewAPIError("GITLAB_NOT_FOUND", fmt.Sprintf("invalid issue IID '%s'", taskID), errors.ErrNotFound)
+	}
+	return fmt.Sprintf(issuePath, neturl.PathEscape(parentID), iid), nil
+}
+
+// isProject reports whether id is a full project path like group/project or
+// group/subgroup/project
+func isProject(id string) bool {
+	segments := strings.Split(id, "/")
+	if len(segments) < 2 || strings.ContainsAny(id, "?#") {
+		return false
+	}
+	for _, segment := range segments {
+		if segment == "" || segment == "." || segment == ".." {
+			return false
+		}
+	}
+	return true
+}
+
+func notFound(parentID string) error {
+	return errors.NewAPIError("GITLAB_NOT_FOUND", fmt.Sprintf("invalid project '%s'", parentID), errors.ErrNotFound)
+}
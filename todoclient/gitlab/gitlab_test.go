@@ -0,0 +1,309 @@
+package gitlab
+
+import (
+	"context"
+	"encoding/json"
+	stderrors "errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jo-hoe/todoapi/pkg/errors"
+	"github.com/jo-hoe/todoapi/todoclient"
+	"github.com/jo-hoe/todoapi/todoclient/conformance"
+	"github.com/jo-hoe/todoapi/todoclient/search"
+)
+
+// fakeGitLab is an in-memory stand-in for the issues API with pages of two items
+type fakeGitLab struct {
+	mu       sync.Mutex
+	url      string
+	issues   map[string][]*gitlabIssue // keyed by group/project
+	requests []string
+}
+
+func newFakeGitLab(t *testing.T) *fakeGitLab {
+	fake := &fakeGitLab{issues: make(map[string][]*gitlabIssue)}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /projects", func(w http.ResponseWriter, r *http.Request) {
+		writePage(fake, w, r, []gitlabProject{
+			{PathWithNamespace: "alice/app", IssuesEnabled: true},
+			{PathWithNamespace: "alice/wiki", IssuesEnabled: false},
+			{PathWithNamespace: "alice/old", IssuesEnabled: true, Archived: true},
+			{PathWithNamespace: "team/backend/api", IssuesEnabled: true},
+		})
+	})
+	// the project path is a single escaped segment like alice%2Fapp
+	mux.HandleFunc("GET /projects/{project}/issues", func(w http.ResponseWriter, r *http.Request) {
+		fake.mu.Lock()
+		defer fake.mu.Unlock()
+		fake.requests = append(fake.requests, r.URL.String())
+		issues, ok := fake.issues[r.PathValue("project")]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		writePage(fake, w, r, issues)
+	})
+	mux.HandleFunc("POST /projects/{project}/issues", func(w http.ResponseWriter, r *http.Request) {
+		fake.mu.Lock()
+		defer fake.mu.Unlock()
+		project := r.PathValue("project")
+		var request gitlabIssueRequest
+		_ = json.NewDecoder(r.Body).Decode(&request)
+		if request.StateEvent != "" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		issue := &gitlabIssue{IID: len(fake.issues[project]) + 1, State: stateOpened, CreatedAt: time.Now().UTC(), UpdatedAt: time.Now().UTC()}
+		applyRequest(issue, request)
+		fake.issues[project] = append(fake.issues[project], issue)
+		w.WriteHeader(http.StatusCreated)
+		writeJSON(w, issue)
+	})
+	mux.HandleFunc("GET /projects/{project}/issues/{iid}", func(w http.ResponseWriter, r *http.Request) {
+		fake.withIssue(w, r, func(issue *gitlabIssue) { writeJSON(w, issue) })
+	})
+	mux.HandleFunc("PUT /projects/{project}/issues/{iid}", func(w http.ResponseWriter, r *http.Request) {
+		fake.withIssue(w, r, func(issue *gitlabIssue) {
+			var request gitlabIssueRequest
+			_ = json.NewDecoder(r.Body).Decode(&request)
+			applyRequest(issue, request)
+			writeJSON(w, issue)
+		})
+	})
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	fake.url = server.URL
+	return fake
+}
+
+// writePage writes the page of the items selected by the page parameter with a Link to the next page
+func writePage[T any](f *fakeGitLab, w http.ResponseWriter, r *http.Request, items []T) {
+	page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+	page = max(page, 1)
+	start, end := min((page-1)*2, len(items)), min(page*2, len(items))
+	if end < len(items) {
+		next := *r.URL
+		query := next.Query()
+		query.Set("page", strconv.Itoa(page+1))
+		next.RawQuery = query.Encode()
+		w.Header().Set("Link", fmt.Sprintf(`<%s%s>; rel="next", <%s/last>; rel="last"`, f.url, next.String(), f.url))
+	}
+	writeJSON(w, items[start:end])
+}
+
+func (f *fakeGitLab) withIssue(w http.ResponseWriter, r *http.Request, fn func(issue *gitlabIssue)) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	iid, _ := strconv.Atoi(r.PathValue("iid"))
+	issues := f.issues[r.PathValue("project")]
+	if iid < 1 || iid > len(issues) {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	fn(issues[iid-1])
+}
+
+// applyRequest applies a create or update request
+func applyRequest(issue *gitlabIssue, request gitlabIssueRequest) {
+	if request.Title != "" {
+		issue.Title = request.Title
+	}
+	description := request.Description
+	issue.Description = &description
+	switch request.StateEvent {
+	case "close":
+		issue.State = stateClosed
+	case "reopen":
+		issue.State = stateOpened
+	}
+	issue.Labels = make([]string, 0)
+	if request.Labels != "" {
+		issue.Labels = strings.Split(request.Labels, ",")
+	}
+}
+
+func writeJSON(w http.ResponseWriter, value interface{}) {
+	_ = json.NewEncoder(w).Encode(value)
+}
+
+func newTestClient(t *testing.T, projects ...string) (*GitLabClient, *fakeGitLab) {
+	t.Helper()
+	fake := newFakeGitLab(t)
+	client, err := NewGitLabClient(http.DefaultClient, fake.url, projects...)
+	if err != nil {
+		t.Fatalf("error was not nil but '%v'", err)
+	}
+	return client, fake
+}
+
+func TestGitLabClient_ImplementationTest(t *testing.T) {
+	// tests if interface is implemented
+	var _ todoclient.ToDoClient = (*GitLabClient)(nil)
+}
+
+func TestGitLabClient_Conformance(t *testing.T) {
+	client, fake := newTestClient(t, "team/backend/api")
+	fake.issues["team/backend/api"] = []*gitlabIssue{}
+	conformance.Run(t, client, conformance.Options{ParentID: "team/backend/api", SoftDelete: true})
+}
+
+func TestNewGitLabClient_InvalidProject(t *testing.T) {
+	for _, project := range []string{"no-group", "alice//app", "alice/../app", "alice/app?x"} {
+		if _, err := NewGitLabClient(http.DefaultClient, "", project); err == nil {
+			t.Errorf("expected error for invalid project '%s'", project)
+		}
+	}
+}
+
+func TestGitLabClient_GetAllParents(t *testing.T) {
+	client, _ := newTestClient(t)
+
+	parents, err := client.GetAllParents(context.Background())
+
+	if err != nil {
+		t.Errorf("error was not nil but '%v'", err)
+	}
+	if len(parents) != 2 || parents[0].ID != "alice/app" || parents[1].ID != "team/backend/api" {
+		t.Errorf("expected 'alice/app' and 'team/backend/api' but found %v", parents)
+	}
+}
+
+func TestGitLabClient_CRUD(t *testing.T) {
+	client, _ := newTestClient(t, "alice/app")
+	ctx := context.Background()
+
+	task, err := client.CreateTask(ctx, "alice/app", todoclient.ToDoTask{Name: "Crash on start", Labels: []string{"bug", "p1"}, IsCompleted: true})
+	if err != nil {
+		t.Fatalf("error was not nil but '%v'", err)
+	}
+	if task.ID != "alice/app#1" || !task.IsCompleted {
+		t.Errorf("expected closed issue 1 but found %+v", task)
+	}
+
+	task.IsCompleted = false
+	task.Description = "stack trace"
+	task.Labels = []string{"bug"}
+	if err := client.UpdateTask(ctx, "alice/app", task); err != nil {
+		t.Errorf("error was not nil but '%v'", err)
+	}
+	found, err := client.GetTask(ctx, "alice/app", task.ID)
+	if err != nil {
+		t.Fatalf("error was not nil but '%v'", err)
+	}
+	if found.IsCompleted || found.Description != "stack trace" || len(found.Labels) != 1 || found.Labels[0] != "bug" {
+		t.Errorf("expected reopened issue but found %+v", found)
+	}
+
+	if err := client.DeleteTask(ctx, "alice/app", task.ID); err != nil {
+		t.Errorf("error was not nil but '%v'", err)
+	}
+	found, _ = client.GetTask(ctx, "alice/app", task.ID)
+	if !found.IsCompleted {
+		t.Error("expected deleted issue to be closed")
+	}
+}
+
+func TestGitLabClient_GetChildrenTasks(t *testing.T) {
+	client, fake := newTestClient(t, "alice/app")
+	issueDue, milestoneDue := "2024-02-01", "2024-03-01"
+	description := "body"
+	fake.issues["alice/app"] = []*gitlabIssue{
+		{IID: 1, Title: "first", Description: &description, State: stateOpened, Milestone: &gitlabMilestone{Title: "v1", DueDate: &milestoneDue}},
+		{IID: 2, Title: "second", State: stateOpened, DueDate: &issueDue, Milestone: &gitlabMilestone{Title: "v1", DueDate: &milestoneDue}},
+		{IID: 3, Title: "third", State: stateClosed, Labels: []string{"bug"}},
+		{IID: 4, Title: "fourth", State: stateOpened, Milestone: &gitlabMilestone{Title: "backlog"}},
+		{IID: 5, Title: "fifth", State: stateOpened},
+	}
+
+	tasks, err := client.GetChildrenTasks(context.Background(), "alice/app", &todoclient.TaskQuery{Labels: []string{"bug"}})
+
+	if err != nil {
+		t.Fatalf("error was not nil but '%v'", err)
+	}
+	// the fake ignores the label filter, so it is applied client-side as well
+	if len(tasks) != 1 || tasks[0].ID != "alice/app#3" || !tasks[0].IsCompleted {
+		t.Errorf("expected closed issue 3 but found %v", tasks)
+	}
+	if !strings.Contains(fake.requests[0], "labels=bug") || !strings.Contains(fake.requests[0], "state=all") {
+		t.Errorf("expected label and state parameters but found '%s'", fake.requests[0])
+	}
+
+	tasks, _ = client.GetChildrenTasks(context.Background(), "alice/app", nil)
+	if len(tasks) != 5 {
+		t.Fatalf("expected %d tasks but found %d", 5, len(tasks))
+	}
+	if !tasks[0].DueDate.Equal(time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)) || tasks[0].Description != "body" {
+		t.Errorf("expected milestone due date and description but found %+v", tasks[0])
+	}
+	if !tasks[1].DueDate.Equal(time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("expected due date of the issue before the milestone but found %v", tasks[1].DueDate)
+	}
+	if !tasks[3].DueDate.IsZero() {
+		t.Errorf("expected no due date for milestone without due date but found %v", tasks[3].DueDate)
+	}
+}
+
+func TestGitLabClient_SameIssueIIDInTwoProjects(t *testing.T) {
+	client, _ := newTestClient(t, "alice/app", "alice/web")
+	ctx := context.Background()
+	app, _ := client.CreateTask(ctx, "alice/app", todoclient.ToDoTask{Name: "crash"})
+	web, _ := client.CreateTask(ctx, "alice/web", todoclient.ToDoTask{Name: "crash"})
+
+	if app.ID != "alice/app#1" || web.ID != "alice/web#1" {
+		t.Errorf("expected IDs unique across projects but found '%s' and '%s'", app.ID, web.ID)
+	}
+	if _, err := client.GetTask(ctx, "alice/app", web.ID); !stderrors.Is(err, errors.ErrNotFound) {
+		t.Errorf("expected not found error for issue of other project but found '%v'", err)
+	}
+	if task, err := client.GetTask(ctx, "alice/web", "1"); err != nil || task.ID != web.ID {
+		t.Errorf("expected issue for plain number but found %+v and '%v'", task, err)
+	}
+
+	// consumers keying tasks by ID see both issues
+	results, err := search.NewIndexedSearcher(map[string]todoclient.ToDoClient{"gitlab": client}, 0).Search(ctx, "crash", 0)
+	if err != nil {
+		t.Fatalf("error was not nil but '%v'", err)
+	}
+	if len(results) != 2 || results[0].Task.ID == results[1].Task.ID {
+		t.Errorf("expected both issues but found %+v", results)
+	}
+}
+
+func TestGitLabClient_NotFound(t *testing.T) {
+	client, fake := newTestClient(t, "alice/app")
+	fake.issues["alice/app"] = []*gitlabIssue{}
+	ctx := context.Background()
+
+	for name, err := range map[string]error{
+		"unknown project": func() error { _, err := client.GetChildrenTasks(ctx, "alice/unknown", nil); return err }(),
+		"invalid project": func() error { _, err := client.GetTask(ctx, "alice", "1"); return err }(),
+		"invalid issue":   func() error { _, err := client.GetTask(ctx, "alice/app", "abc"); return err }(),
+		"unknown issue":   func() error { _, err := client.GetTask(ctx, "alice/app", "7"); return err }(),
+	} {
+		if !stderrors.Is(err, errors.ErrNotFound) {
+			t.Errorf("%s: expected not found error but found '%v'", name, err)
+		}
+	}
+}
+
+func TestGitLabClient_NotSupported(t *testing.T) {
+	client, _ := newTestClient(t, "alice/app")
+	ctx := context.Background()
+
+	if _, err := client.CreateParent(ctx, "alice/new"); !stderrors.Is(err, errors.ErrNotSupported) {
+		t.Errorf("expected not supported error but found '%v'", err)
+	}
+	if err := client.DeleteParent(ctx, "alice/app"); !stderrors.Is(err, errors.ErrNotSupported) {
+		t.Errorf("expected not supported error but found '%v'", err)
+	}
+	if capabilities := client.Capabilities(); capabilities.CreateParent || capabilities.DeleteParent || capabilities.DueDates {
+		t.Errorf("expected read-only projects and due dates but found %+v", capabilities)
+	}
+}
@@ -0,0 +1,344 @@
+// Package google provides a Google Tasks API client implementation
+package google
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	neturl "net/url"
+	"strings"
+	"time"
+
+	"github.com/jo-hoe/todoapi/internal/common"
+	"github.com/jo-hoe/todoapi/pkg/errors"
+	"github.com/jo-hoe/todoapi/todoclient"
+)
+
+const (
+	// DefaultBaseURL is the base URL of the Google Tasks API
+	DefaultBaseURL = "https://tasks.googleapis.com/tasks/v1/"
+
+	listsPath = "users/@me/lists"
+	listPath  = listsPath + "/%s"  // %s = list id
+	tasksPath = "lists/%s/tasks"   // %s = list id
+	taskPath  = tasksPath + "/%s"  // %s = list id; %s = task id
+	movePath  = taskPath + "/move" // %s = list id; %s = task id
+	pageSize  = "100"              // maximum page size of the API
+	dueLayout = "2006-01-02T00:00:00.000Z"
+
+	statusCompleted   = "completed"
+	statusNeedsAction = "needsAction"
+)
+
+// GoogleTasksClient implements ToDoClient for Google Tasks.
+// https://developers.google.com/tasks/reference/rest
+//
+// Task lists are parents. Google Tasks knows neither labels nor creation times,
+// labels are dropped and the creation time is zero. Due dates have no time of
+// day, only the date is kept.
+type GoogleTasksClient struct {
+	client  *http.Client
+	baseURL *neturl.URL
+}
+
+type googleTaskList struct {
+	ID    string `json:"id,omitempty"`
+	Title string `json:"title"`
+}
+
+type googleTaskLists struct {
+	Items         []googleTaskList `json:"items"`
+	NextPageToken string           `json:"nextPageToken,omitempty"`
+}
+
+type googleTask struct {
+	ID      string  `json:"id,omitempty"`
+	Title   string  `json:"title"`
+	Notes   string  `json:"notes"`
+	Status  string  `json:"status,omitempty"`
+	Due     *string `json:"due"`               // null removes the due date on updates
+	Updated string  `json:"updated,omitempty"` // read-only
+	Parent  string  `json:"parent,omitempty"`  // read-only, changed by moving the task
+	Deleted bool    `json:"deleted,omitempty"` // read-only
+}
+
+type googleTasks struct {
+	Items         []googleTask `json:"items"`
+	NextPageToken string       `json:"nextPageToken,omitempty"`
+}
+
+// NewGoogleTasksClient creates a client using an HTTP client authorized for
+// Google Tasks, see NewHTTPClient. An empty base URL selects DefaultBaseURL.
+func NewGoogleTasksClient(httpClient *http.Client, baseURL string) (*GoogleTasksClient, error) {
+	if baseURL == "" {
+		baseURL = DefaultBaseURL
+	}
+	if !strings.HasSuffix(baseURL, "/") {
+		baseURL += "/"
+	}
+	parsed, err := neturl.Parse(baseURL)
+	if err != nil || parsed.Scheme == "" || parsed.Host == "" {
+		return nil, errors.NewValidationError("base_url", "invalid base URL '"+baseURL+"'")
+	}
+	return &GoogleTasksClient{
+		client:  httpClient,
+		baseURL: parsed,
+	}, nil
+}
+
+func (client *GoogleTasksClient) GetAllTasks(ctx context.Context, query *todoclient.TaskQuery) ([]todoclient.ToDoTask, error) {
+	parents, err := client.GetAllParents(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]todoclient.ToDoTask, 0)
+	for _, parent := range parents {
+		tasks, err := client.getTasks(ctx, parent.ID, query)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, tasks...)
+	}
+	return query.Apply(result), nil
+}
+
+func (client *GoogleTasksClient) GetChildrenTasks(ctx context.Context, parentID string, query *todoclient.TaskQuery) ([]todoclient.ToDoTask, error) {
+	tasks, err := client.getTasks(ctx, parentID, query)
+	if err != nil {
+		return nil, err
+	}
+	return query.Apply(tasks), nil
+}
+
+func (client *GoogleTasksClient) GetTask(ctx context.Context, parentID, taskID string) (todoclient.ToDoTask, error) {
+	var task googleTask
+	if err := client.do(ctx, http.MethodGet, fmt.Sprintf(taskPath, escape(parentID), escape(taskID)), nil, nil, &task); err != nil {
+		return todoclient.ToDoTask{}, errors.NewAPIError("GOOGLE_GET_TASK_FAILED", "failed to retrieve task", err)
+	}
+	if task.Deleted {
+		return todoclient.ToDoTask{}, errors.NewAPIError("GOOGLE_GET_TASK_FAILED", "task is deleted", errors.ErrNotFound)
+	}
+	return toToDoTask(task), nil
+}
+
+func (client *GoogleTasksClient) CreateTask(ctx context.Context, parentID string, task todoclient.ToDoTask) (todoclient.ToDoTask, error) {
+	if err := task.Validate(); err != nil {
+		return todoclient.ToDoTask{}, err
+	}
+
+	params := neturl.Values{}
+	if task.ParentTaskID != "" {
+		params.Set("parent", task.ParentTaskID)
+	}
+	var created googleTask
+	if err := client.do(ctx, http.MethodPost, fmt.Sprintf(tasksPath, escape(parentID)), params, fromToDoTask(task), &created); err != nil {
+		return todoclient.ToDoTask{}, errors.NewAPIError("GOOGLE_CREATE_FAILED", "failed to create task", err)
+	}
+	return toToDoTask(created), nil
+}
+
+func (client *GoogleTasksClient) UpdateTask(ctx context.Context, parentID string, task todoclient.ToDoTask) error {
+	if err := task.Validate(); err != nil {
+		return err
+	}
+
+	path := fmt.Sprintf(taskPath, escape(parentID), escape(task.ID))
+	var updated googleTask
+	if err := client.do(ctx, http.MethodPatch, path, nil, fromToDoTask(task), &updated); err != nil {
+		return errors.NewAPIError("GOOGLE_UPDATE_FAILED", "failed to update task", err)
+	}
+
+	// the parent task is not writable, a changed parent requires a move
+	if updated.Parent != task.ParentTaskID {
+		params := neturl.Values{}
+		if task.ParentTaskID != "" {
+			params.Set("parent", task.ParentTaskID)
+		}
+		if err := client.do(ctx, http.MethodPost, fmt.Sprintf(movePath, escape(parentID), escape(task.ID)), params, nil, nil); err != nil {
+			return errors.NewAPIError("GOOGLE_MOVE_FAILED", "failed to move task", err)
+		}
+	}
+	return nil
+}
+
+func (client *GoogleTasksClient) DeleteTask(ctx context.Context, parentID, taskID string) error {
+	if err := client.do(ctx, http.MethodDelete, fmt.Sprintf(taskPath, escape(parentID), escape(taskID)), nil, nil, nil); err != nil {
+		return errors.NewAPIError("GOOGLE_DELETE_FAILED", "failed to delete task", err)
+	}
+	return nil
+}
+
+func (client *GoogleTasksClient) GetAllParents(ctx context.Context) ([]todoclient.ToDoParent, error) {
+	result := make([]todoclient.ToDoParent, 0)
+	params := neturl.Values{"maxResults": {pageSize}}
+	for {
+		var page googleTaskLists
+		if err := client.do(ctx, http.MethodGet, listsPath, params, nil, &page); err != nil {
+			return nil, errors.NewAPIError("GOOGLE_GET_PARENTS_FAILED", "failed to retrieve task lists", err)
+		}
+		for _, list := range page.Items {
+			result = append(result, todoclient.ToDoParent{ID: list.ID, Name: list.Title})
+		}
+		if page.NextPageToken == "" {
+			return result, nil
+		}
+		params.Set("pageToken", page.NextPageToken)
+	}
+}
+
+func (client *GoogleTasksClient) CreateParent(ctx context.Context, parentName string) (todoclient.ToDoParent, error) {
+	parentName = strings.TrimSpace(parentName)
+	if parentName == "" {
+		return todoclient.ToDoParent{}, errors.NewValidationError("name", "parent name cannot be empty")
+	}
+
+	var created googleTaskList
+	if err := client.do(ctx, http.MethodPost, listsPath, nil, googleTaskList{Title: parentName}, &created); err != nil {
+		return todoclient.ToDoParent{}, errors.NewAPIError("GOOGLE_CREATE_PARENT_FAILED", "failed to create task list", err)
+	}
+	return todoclient.ToDoParent{ID: created.ID, Name: created.Title}, nil
+}
+
+func (client *GoogleTasksClient) DeleteParent(ctx context.Context, parentID string) error {
+	if err := client.do(ctx, http.MethodDelete, fmt.Sprintf(listPath, escape(parentID)), nil, nil, nil); err != nil {
+		return errors.NewAPIError("GOOGLE_DELETE_PARENT_FAILED", "failed to delete task list", err)
+	}
+	return nil
+}
+
+// Capabilities reports the features supported by Google Tasks, which has
+// subtasks but neither labels nor due times.
+func (client *GoogleTasksClient) Capabilities() todoclient.Capabilities {
+	return todoclient.Capabilities{
+		Subtasks:     true,
+		DueDates:     true,
+		CreateParent: true,
+		DeleteParent: true,
+	}
+}
+
+// getTasks reads all pages of the tasks of a list including completed tasks.
+// Due date bounds of the query are passed to the API, the remaining criteria
+// are left to the caller.
+func (client *GoogleTasksClient) getTasks(ctx context.Context, parentID string, query *todoclient.TaskQuery) ([]todoclient.ToDoTask, error) {
+	params := neturl.Values{
+		"maxResults":    {pageSize},
+		"showCompleted": {"true"},
+		"showHidden":    {"true"},
+	}
+	if query != nil && !query.DueAfter.IsZero() {
+		params.Set("dueMin", query.DueAfter.UTC().Format(time.RFC3339))
+	}
+	if query != nil && !query.DueBefore.IsZero() {
+		params.Set("dueMax", query.DueBefore.UTC().Format(time.RFC3339))
+	}
+
+	result := make([]todoclient.ToDoTask, 0)
+	for {
+		var page googleTasks
+		if err := client.do(ctx, http.MethodGet, fmt.Sprintf(tasksPath, escape(parentID)), params, nil, &page); err != nil {
+			return nil, errors.NewAPIError("GOOGLE_GET_TASKS_FAILED", "failed to retrieve tasks", err)
+		}
+		for _, task := range page.Items {
+			if !task.Deleted {
+				result = append(result, toToDoTask(task))
+			}
+		}
+		if page.NextPageToken == "" {
+			return result, nil
+		}
+		params.Set("pageToken", page.NextPageToken)
+	}
+}
+
+// do sends a request with an optional JSON payload and decodes the response into data if not nil
+func (client *GoogleTasksClient) do(ctx context.Context, method, path string, params neturl.Values, payload, data interface{}) error {
+	url := client.baseURL.JoinPath(path)
+	if len(params) > 0 {
+		url.RawQuery = params.Encode()
+	}
+
+	var body io.Reader
+	if payload != nil {
+		b, err := json.Marshal(payload)
+		if err != nil {
+			return errors.NewAPIError("GOOGLE_MARSHAL_FAILED", "failed to marshal request", err)
+		}
+		body = bytes.NewReader(b)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, url.String(), body)
+	if err != nil {
+		return errors.NewAPIError("GOOGLE_REQUEST_FAILED", "failed to create request", err)
+	}
+	if payload != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := client.client.Do(req)
+	if err != nil {
+		return errors.NewAPIError("GOOGLE_HTTP_FAILED", "HTTP request failed", err)
+	}
+	defer common.CloseBody(resp.Body)
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return errors.NewAPIError("GOOGLE_NOT_FOUND", "resource not found", errors.ErrNotFound)
+	case resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden:
+		return errors.NewAPIError("GOOGLE_UNAUTHORIZED", fmt.Sprintf("request failed with status %d", resp.StatusCode), errors.ErrUnauthorized)
+	case resp.StatusCode < 200 || resp.StatusCode > 299:
+		return errors.NewAPIError("GOOGLE_STATUS_FAILED", fmt.Sprintf("request failed with status %d", resp.StatusCode), nil)
+	}
+
+	if data == nil || resp.StatusCode == http.StatusNoContent {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(data); err != nil {
+		return errors.NewAPIError("GOOGLE_DECODE_FAILED", "failed to decode response", err)
+	}
+	return nil
+}
+
+func toToDoTask(task googleTask) todoclient.ToDoTask {
+	result := todoclient.ToDoTask{
+		ID:           task.ID,
+		Name:         task.Title,
+		Description:  task.Notes,
+		IsCompleted:  task.Status == statusCompleted,
+		Labels:       make([]string, 0),
+		ParentTaskID: task.Parent,
+	}
+	if task.Due != nil {
+		if due, err := time.Parse(time.RFC3339, *task.Due); err == nil {
+			result.DueDate = due.UTC()
+		}
+	}
+	if modified, err := time.Parse(time.RFC3339, task.Updated); err == nil {
+		result.ModifiedTime = modified
+	}
+	return result
+}
+
+func fromToDoTask(task todoclient.ToDoTask) googleTask {
+	result := googleTask{
+		Title:  task.Name,
+		Notes:  task.Description,
+		Status: statusNeedsAction,
+	}
+	if task.IsCompleted {
+		result.Status = statusCompleted
+	}
+	if !task.DueDate.IsZero() {
+		// the day is taken in the location of the due date, not in UTC
+		due := task.DueDate.Format(dueLayout)
+		result.Due = &due
+	}
+	return result
+}
+
+func escape(id string) string {
+	return neturl.PathEscape(id)
+}
@@ -0,0 +1,353 @@
+package google
+
+import (
+	"context"
+	"encoding/json"
+	stderrors "errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jo-hoe/todoapi/pkg/errors"
+	"github.com/jo-hoe/todoapi/todoclient"
+	"github.com/jo-hoe/todoapi/todoclient/conformance"
+	"golang.org/x/oauth2"
+)
+
+// fakeTasksAPI is an in-memory stand-in for the Google Tasks API with pages of two items
+type fakeTasksAPI struct {
+	mu     sync.Mutex
+	nextID int
+	lists  []googleTaskList
+	tasks  map[string][]*googleTask // keyed by list id
+	moves  int
+}
+
+func newFakeTasksAPI() *fakeTasksAPI {
+	return &fakeTasksAPI{tasks: make(map[string][]*googleTask)}
+}
+
+func (f *fakeTasksAPI) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /users/@me/lists", func(w http.ResponseWriter, r *http.Request) {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		items, next := page(len(f.lists), r.URL.Query().Get("pageToken"))
+		writeJSON(w, googleTaskLists{Items: f.lists[items[0]:items[1]], NextPageToken: next})
+	})
+	mux.HandleFunc("POST /users/@me/lists", func(w http.ResponseWriter, r *http.Request) {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		var list googleTaskList
+		_ = json.NewDecoder(r.Body).Decode(&list)
+		list.ID = f.id("list")
+		f.lists = append(f.lists, list)
+		f.tasks[list.ID] = make([]*googleTask, 0)
+		writeJSON(w, list)
+	})
+	mux.HandleFunc("DELETE /users/@me/lists/{list}", func(w http.ResponseWriter, r *http.Request) {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		for i, list := range f.lists {
+			if list.ID == r.PathValue("list") {
+				f.lists = append(f.lists[:i], f.lists[i+1:]...)
+				delete(f.tasks, list.ID)
+				w.WriteHeader(http.StatusNoContent)
+				return
+			}
+		}
+		w.WriteHeader(http.StatusNotFound)
+	})
+	mux.HandleFunc("GET /lists/{list}/tasks", func(w http.ResponseWriter, r *http.Request) {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		if r.URL.Query().Get("showCompleted") != "true" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		tasks, ok := f.tasks[r.PathValue("list")]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		items, next := page(len(tasks), r.URL.Query().Get("pageToken"))
+		result := googleTasks{Items: make([]googleTask, 0), NextPageToken: next}
+		for _, task := range tasks[items[0]:items[1]] {
+			result.Items = append(result.Items, *task)
+		}
+		writeJSON(w, result)
+	})
+	mux.HandleFunc("POST /lists/{list}/tasks", func(w http.ResponseWriter, r *http.Request) {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		var task googleTask
+		_ = json.NewDecoder(r.Body).Decode(&task)
+		task.ID = f.id("task")
+		task.Parent = r.URL.Query().Get("parent")
+		task.Updated = "2024-03-01T10:00:00.000Z"
+		f.tasks[r.PathValue("list")] = append(f.tasks[r.PathValue("list")], &task)
+		writeJSON(w, task)
+	})
+	mux.HandleFunc("GET /lists/{list}/tasks/{task}", func(w http.ResponseWriter, r *http.Request) {
+		f.withTask(w, r, func(task *googleTask) { writeJSON(w, task) })
+	})
+	mux.HandleFunc("PATCH /lists/{list}/tasks/{task}", func(w http.ResponseWriter, r *http.Request) {
+		f.withTask(w, r, func(task *googleTask) {
+			var patch googleTask
+			_ = json.NewDecoder(r.Body).Decode(&patch)
+			task.Title, task.Notes, task.Status, task.Due = patch.Title, patch.Notes, patch.Status, patch.Due
+			writeJSON(w, task)
+		})
+	})
+	mux.HandleFunc("POST /lists/{list}/tasks/{task}/move", func(w http.ResponseWriter, r *http.Request) {
+		f.withTask(w, r, func(task *googleTask) {
+			f.moves++
+			task.Parent = r.URL.Query().Get("parent")
+			writeJSON(w, task)
+		})
+	})
+	mux.HandleFunc("DELETE /lists/{list}/tasks/{task}", func(w http.ResponseWriter, r *http.Request) {
+		f.withTask(w, r, func(task *googleTask) {
+			task.Deleted = true
+			w.WriteHeader(http.StatusNoContent)
+		})
+	})
+	return mux
+}
+
+func (f *fakeTasksAPI) withTask(w http.ResponseWriter, r *http.Request, fn func(task *googleTask)) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, task := range f.tasks[r.PathValue("list")] {
+		if task.ID == r.PathValue("task") {
+			fn(task)
+			return
+		}
+	}
+	w.WriteHeader(http.StatusNotFound)
+}
+
+func (f *fakeTasksAPI) id(prefix string) string {
+	f.nextID++
+	return fmt.Sprintf("%s%d", prefix, f.nextID)
+}
+
+// page returns the bounds of a page of two items and the token of the next page
+func page(total int, token string) ([2]int, string) {
+	start, _ := strconv.Atoi(token)
+	end := min(start+2, total)
+	next := ""
+	if end < total {
+		next = strconv.Itoa(end)
+	}
+	return [2]int{start, end}, next
+}
+
+func writeJSON(w http.ResponseWriter, value interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(value)
+}
+
+func newTestClient(t *testing.T) (*GoogleTasksClient, *fakeTasksAPI) {
+	t.Helper()
+	fake := newFakeTasksAPI()
+	server := httptest.NewServer(fake.handler())
+	t.Cleanup(server.Close)
+
+	client, err := NewGoogleTasksClient(server.Client(), server.URL)
+	if err != nil {
+		t.Fatalf("error was not nil but '%v'", err)
+	}
+	return client, fake
+}
+
+func TestGoogleTasksClient_ImplementationTest(t *testing.T) {
+	// tests if interface is implemented
+	var _ todoclient.ToDoClient = (*GoogleTasksClient)(nil)
+}
+
+func TestGoogleTasksClient_Conformance(t *testing.T) {
+	client, _ := newTestClient(t)
+	conformance.Run(t, client, conformance.Options{})
+}
+
+func TestGoogleTasksClient_CRUD(t *testing.T) {
+	client, _ := newTestClient(t)
+	ctx := context.Background()
+
+	parent, err := client.CreateParent(ctx, "Work")
+	if err != nil {
+		t.Fatalf("error was not nil but '%v'", err)
+	}
+	due := time.Date(2024, 3, 5, 0, 0, 0, 0, time.UTC)
+	task, err := client.CreateTask(ctx, parent.ID, todoclient.ToDoTask{Name: "Report", Description: "draft", DueDate: due})
+	if err != nil {
+		t.Fatalf("error was not nil but '%v'", err)
+	}
+	if task.ID == "" || task.ModifiedTime.IsZero() || task.Labels == nil {
+		t.Errorf("expected id, modified time and labels but found %+v", task)
+	}
+
+	task.IsCompleted = true
+	task.DueDate = time.Time{}
+	if err := client.UpdateTask(ctx, parent.ID, task); err != nil {
+		t.Errorf("error was not nil but '%v'", err)
+	}
+	found, err := client.GetTask(ctx, parent.ID, task.ID)
+	if err != nil {
+		t.Fatalf("error was not nil but '%v'", err)
+	}
+	if !found.IsCompleted || !found.DueDate.IsZero() || found.Description != "draft" {
+		t.Errorf("expected completed task without due date but found %+v", found)
+	}
+
+	if err := client.DeleteTask(ctx, parent.ID, task.ID); err != nil {
+		t.Errorf("error was not nil but '%v'", err)
+	}
+	if _, err := client.GetTask(ctx, parent.ID, task.ID); !stderrors.Is(err, errors.ErrNotFound) {
+		t.Errorf("expected not found error but found '%v'", err)
+	}
+	tasks, _ := client.GetChildrenTasks(ctx, parent.ID, nil)
+	if len(tasks) != 0 {
+		t.Errorf("expected no tasks but found %v", tasks)
+	}
+	if err := client.DeleteParent(ctx, parent.ID); err != nil {
+		t.Errorf("error was not nil but '%v'", err)
+	}
+}
+
+func TestGoogleTasksClient_Paging(t *testing.T) {
+	client, _ := newTestClient(t)
+	ctx := context.Background()
+	for i := 0; i < 3; i++ {
+		parent, _ := client.CreateParent(ctx, fmt.Sprintf("list %d", i))
+		for j := 0; j < 5; j++ {
+			_, _ = client.CreateTask(ctx, parent.ID, todoclient.ToDoTask{Name: fmt.Sprintf("task %d", j)})
+		}
+	}
+
+	parents, err := client.GetAllParents(ctx)
+	if err != nil {
+		t.Errorf("error was not nil but '%v'", err)
+	}
+	tasks, err := client.GetAllTasks(ctx, nil)
+	if err != nil {
+		t.Errorf("error was not nil but '%v'", err)
+	}
+
+	if len(parents) != 3 {
+		t.Errorf("expected %d parents but found %d", 3, len(parents))
+	}
+	if len(tasks) != 15 {
+		t.Errorf("expected %d tasks but found %d", 15, len(tasks))
+	}
+}
+
+func TestGoogleTasksClient_Subtasks(t *testing.T) {
+	client, fake := newTestClient(t)
+	ctx := context.Background()
+	parent, _ := client.CreateParent(ctx, "Work")
+	main, _ := client.CreateTask(ctx, parent.ID, todoclient.ToDoTask{Name: "Release"})
+
+	sub, err := client.CreateTask(ctx, parent.ID, todoclient.ToDoTask{Name: "Changelog", ParentTaskID: main.ID})
+	if err != nil {
+		t.Fatalf("error was not nil but '%v'", err)
+	}
+	if sub.ParentTaskID != main.ID {
+		t.Errorf("expected parent task %s but found '%s'", main.ID, sub.ParentTaskID)
+	}
+
+	// an unchanged parent needs no move, a removed parent does
+	sub.Name = "Write changelog"
+	_ = client.UpdateTask(ctx, parent.ID, sub)
+	sub.ParentTaskID = ""
+	if err := client.UpdateTask(ctx, parent.ID, sub); err != nil {
+		t.Errorf("error was not nil but '%v'", err)
+	}
+	found, _ := client.GetTask(ctx, parent.ID, sub.ID)
+
+	if found.ParentTaskID != "" || found.Name != "Write changelog" {
+		t.Errorf("expected top-level task but found %+v", found)
+	}
+	if fake.moves != 1 {
+		t.Errorf("expected %d move but found %d", 1, fake.moves)
+	}
+}
+
+func TestGoogleTasksClient_NotFound(t *testing.T) {
+	client, _ := newTestClient(t)
+
+	_, err := client.GetChildrenTasks(context.Background(), "unknown", nil)
+
+	if !stderrors.Is(err, errors.ErrNotFound) {
+		t.Errorf("expected not found error but found '%v'", err)
+	}
+	if _, err := client.GetTask(context.Background(), "unknown", "unknown"); !stderrors.Is(err, errors.ErrNotFound) {
+		t.Errorf("expected not found error but found '%v'", err)
+	}
+}
+
+func TestNewHTTPClient_RefreshesAndSavesToken(t *testing.T) {
+	var refreshes int
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /token", func(w http.ResponseWriter, r *http.Request) {
+		refreshes++
+		if r.FormValue("refresh_token") != "refresh" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		writeJSON(w, map[string]interface{}{"access_token": "fresh", "token_type": "Bearer", "expires_in": 3600})
+	})
+	mux.HandleFunc("GET /users/@me/lists", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer fresh" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		writeJSON(w, googleTaskLists{Items: []googleTaskList{{ID: "1", Title: "Inbox"}}})
+	})
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	path := filepath.Join(t.TempDir(), "token.json")
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, server.Client())
+	httpClient, err := NewHTTPClient(ctx, ClientConfig{
+		ClientID: "id",
+		Token:    oauth2.Token{AccessToken: "stale", RefreshToken: "refresh", Expiry: time.Now().Add(-time.Hour)},
+		Endpoint: oauth2.Endpoint{TokenURL: server.URL + "/token"},
+	}, FileTokenSaver(path))
+	if err != nil {
+		t.Fatalf("error was not nil but '%v'", err)
+	}
+	client, _ := NewGoogleTasksClient(httpClient, server.URL)
+
+	for i := 0; i < 2; i++ {
+		if _, err := client.GetAllParents(ctx); err != nil {
+			t.Fatalf("error was not nil but '%v'", err)
+		}
+	}
+
+	if refreshes != 1 {
+		t.Errorf("expected %d refresh but found %d", 1, refreshes)
+	}
+	saved, err := LoadToken(path)
+	if err != nil {
+		t.Fatalf("error was not nil but '%v'", err)
+	}
+	// the refresh token is kept although the response did not repeat it
+	if saved.AccessToken != "fresh" || saved.RefreshToken != "refresh" || saved.Expiry.IsZero() {
+		t.Errorf("expected saved fresh token but found %+v", saved)
+	}
+}
+
+func TestNewHTTPClient_MissingToken(t *testing.T) {
+	_, err := NewHTTPClient(context.Background(), ClientConfig{ClientID: "id"}, nil)
+
+	if err == nil {
+		t.Error("expected error for missing token")
+	}
+}
@@ -0,0 +1,144 @@
+package google
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/jo-hoe/todoapi/pkg/errors"
+	"golang.org/x/oauth2"
+)
+
+// TasksScope grants read and write access to Google Tasks
+const TasksScope = "https://www.googleapis.com/auth/tasks"
+
+// Endpoint is the OAuth2 endpoint of Google
+var Endpoint = oauth2.Endpoint{
+	AuthURL:  "https://accounts.google.com/o/oauth2/auth",
+	TokenURL: "https://oauth2.googleapis.com/token",
+}
+
+// ClientConfig holds the OAuth2 client and the token to start with
+type ClientConfig struct {
+	ClientID     string
+	ClientSecret string
+	Token        oauth2.Token    // at least the refresh token is needed to keep the client working
+	Scopes       []string        // defaults to TasksScope
+	Endpoint     oauth2.Endpoint // defaults to Endpoint
+}
+
+// NewHTTPClient creates an HTTP client authorizing requests with the token of
+// the configuration. Expired tokens are refreshed on use and every refreshed
+// token is passed to saveToken, which may be nil.
+func NewHTTPClient(ctx context.Context, config ClientConfig, saveToken func(token *oauth2.Token)) (*http.Client, error) {
+	if config.Token.RefreshToken == "" && config.Token.AccessToken == "" {
+		return nil, errors.NewValidationError("token", "access or refresh token required")
+	}
+	scopes := config.Scopes
+	if len(scopes) == 0 {
+		scopes = []string{TasksScope}
+	}
+	endpoint := config.Endpoint
+	if endpoint.TokenURL == "" {
+		endpoint = Endpoint
+	}
+	oauthConfig := &oauth2.Config{
+		ClientID:     config.ClientID,
+		ClientSecret: config.ClientSecret,
+		Scopes:       scopes,
+		Endpoint:     endpoint,
+	}
+
+	token := config.Token
+	return oauth2.NewClient(ctx, &savingTokenSource{
+		base:    oauthConfig.TokenSource(ctx, &token),
+		current: token.AccessToken,
+		save:    saveToken,
+	}), nil
+}
+
+// savingTokenSource passes every new token of the base source to save
+type savingTokenSource struct {
+	mu      sync.Mutex
+	base    oauth2.TokenSource
+	current string
+	save    func(*oauth2.Token)
+}
+
+func (ts *savingTokenSource) Token() (*oauth2.Token, error) {
+	token, err := ts.base.Token()
+	if err != nil {
+		return nil, errors.NewAPIError("GOOGLE_TOKEN_FAILED", "failed to refresh token", err)
+	}
+
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	if token.AccessToken != ts.current {
+		ts.current = token.AccessToken
+		if ts.save != nil {
+			ts.save(token)
+		}
+	}
+	return token, nil
+}
+
+// storedToken is the file format of FileTokenSaver
+type storedToken struct {
+	AccessToken  string    `json:"access_token"`
+	TokenType    string    `json:"token_type,omitempty"`
+	RefreshToken string    `json:"refresh_token"`
+	ExpiresAt    time.Time `json:"expires_at,omitempty"`
+}
+
+// LoadToken reads a token written by FileTokenSaver
+func LoadToken(path string) (oauth2.Token, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return oauth2.Token{}, errors.NewAPIError("GOOGLE_TOKEN_READ_FAILED", "failed to read token file", err)
+	}
+	var stored storedToken
+	if err := json.Unmarshal(b, &stored); err != nil {
+		return oauth2.Token{}, errors.NewAPIError("GOOGLE_TOKEN_READ_FAILED", "failed to decode token file", err)
+	}
+	return oauth2.Token{
+		AccessToken:  stored.AccessToken,
+		TokenType:    stored.TokenType,
+		RefreshToken: stored.RefreshToken,
+		Expiry:       stored.ExpiresAt,
+	}, nil
+}
+
+// FileTokenSaver returns a saveToken callback persisting tokens to a JSON file.
+// Google usually sends no new refresh token on refresh, the stored one is kept then.
+func FileTokenSaver(path string) func(token *oauth2.Token) {
+	return func(token *oauth2.Token) {
+		stored := storedToken{
+			AccessToken:  token.AccessToken,
+			TokenType:    token.TokenType,
+			RefreshToken: token.RefreshToken,
+			ExpiresAt:    token.Expiry.UTC(),
+		}
+		if stored.RefreshToken == "" {
+			if previous, err := LoadToken(path); err == nil {
+				stored.RefreshToken = previous.RefreshToken
+			}
+		}
+		b, err := json.MarshalIndent(stored, "", "  ")
+		if err != nil {
+			return
+		}
+
+		// Write atomically, failures are ignored as the token stays valid in memory
+		tmp := path + ".tmp"
+		if err := os.WriteFile(tmp, b, 0o600); err != nil {
+			_ = os.Remove(tmp)
+			return
+		}
+		if err := os.Rename(tmp, path); err != nil {
+			_ = os.Remove(tmp)
+		}
+	}
+}
@@ -0,0 +1,314 @@
+package ical
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/jo-hoe/todoapi/pkg/errors"
+	"github.com/jo-hoe/todoapi/todoclient"
+)
+
+// Todo is a task read from a VTODO component
+type Todo struct {
+	UID        string
+	ParentName string // Value of ParentProperty, empty for calendars of other tools
+	Task       todoclient.ToDoTask
+}
+
+// property is a content line, see RFC 5545 3.1
+type property struct {
+	name   string
+	params map[string]string
+	value  string
+}
+
+// Decode reads all VTODO components of a calendar. Nested components such as
+// VALARM are ignored.
+//
+// Dates become midnight UTC. Date-times are read in the time zone of their TZID
+// parameter, which may be an IANA name, a prefixed IANA name as written by
+// Thunderbird or a time zone defined by a VTIMEZONE component of the calendar.
+// Floating date-times are read in the local time zone.
+func Decode(r io.Reader) ([]Todo, error) {
+	lines, err := unfold(r)
+	if err != nil {
+		return nil, errors.NewAPIError("ICAL_READ_FAILED", "failed to read calendar", err)
+	}
+
+	properties := make([]property, 0, len(lines))
+	for i, line := range lines {
+		prop, err := parseLine(line)
+		if err != nil {
+			return nil, errors.NewAPIError("ICAL_PARSE_FAILED", fmt.Sprintf("invalid content line %d", i+1), err)
+		}
+		properties = append(properties, prop)
+	}
+
+	zones := parseTimezones(properties)
+	todos := make([]Todo, 0)
+	var stack []string
+	var current []property
+	for _, prop := range properties {
+		switch prop.name {
+		case "BEGIN":
+			stack = append(stack, strings.ToUpper(prop.value))
+			if len(stack) == 2 && stack[1] == "VTODO" {
+				current = make([]property, 0)
+			}
+		case "END":
+			if len(stack) == 0 || stack[len(stack)-1] != strings.ToUpper(prop.value) {
+				return nil, errors.NewAPIError("ICAL_PARSE_FAILED", "unbalanced END:"+prop.value, nil)
+			}
+			if len(stack) == 2 && stack[1] == "VTODO" {
+				todo, err := toTodo(current, zones)
+				if err != nil {
+					return nil, err
+				}
+				todos = append(todos, todo)
+			}
+			stack = stack[:len(stack)-1]
+		default:
+			if len(stack) == 2 && stack[1] == "VTODO" {
+				current = append(current, prop)
+			}
+		}
+	}
+	if len(stack) != 0 {
+		return nil, errors.NewAPIError("ICAL_PARSE_FAILED", "missing END:"+stack[len(stack)-1], nil)
+	}
+	return todos, nil
+}
+
+// unfold joins folded lines. Lines may end with CRLF or LF, continuation
+// lines start with a space or a horizontal tab.
+func unfold(r io.Reader) ([]string, error) {
+	lines := make([]string, 0)
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSuffix(scanner.Text(), "\r")
+		if (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")) && len(lines) > 0 {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+		if line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines, scanner.Err()
+}
+
+// parseLine splits a content line into name, parameters and value. Colons and
+// semicolons inside quoted parameter values do not end the parameter.
+func parseLine(line string) (property, error) {
+	prop := property{params: make(map[string]string)}
+
+	quoted := false
+	start := 0
+	var key string
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		switch {
+		case c == '"':
+			quoted = !quoted
+		case quoted:
+		case c == '=' && prop.name != "" && key == "":
+			key = strings.ToUpper(line[start:i])
+			start = i + 1
+		case c == ';' || c == ':':
+			if prop.name == "" {
+				prop.name = strings.ToUpper(line[:i])
+			} else if key != "" {
+				prop.params[key] = strings.Trim(line[start:i], `"`)
+				key = ""
+			} else {
+				return prop, fmt.Errorf("parameter without value in '%s'", line)
+			}
+			start = i + 1
+			if c == ':' {
+				prop.value = line[i+1:]
+				if prop.name == "" {
+					return prop, fmt.Errorf("missing name in '%s'", line)
+				}
+				return prop, nil
+			}
+		}
+	}
+	return prop, fmt.Errorf("missing value in '%s'", line)
+}
+
+func toTodo(properties []property, zones map[string]*time.Location) (Todo, error) {
+	var todo Todo
+	percentComplete := ""
+	for _, prop := range properties {
+		var err error
+		switch prop.name {
+		case "UID":
+			todo.UID = unescapeText(prop.value)
+		case "SUMMARY":
+			todo.Task.Name = unescapeText(prop.value)
+		case "DESCRIPTION":
+			todo.Task.Description = unescapeText(prop.value)
+		case "DUE":
+			todo.Task.DueDate, err = parseTime(prop, zones)
+		case "CREATED":
+			todo.Task.CreationTime, err = parseTime(prop, zones)
+		case "LAST-MODIFIED":
+			todo.Task.ModifiedTime, err = parseTime(prop, zones)
+		case "STATUS":
+			todo.Task.IsCompleted = todo.Task.IsCompleted || strings.EqualFold(prop.value, "COMPLETED")
+		case "COMPLETED":
+			todo.Task.IsCompleted = true
+		case "PERCENT-COMPLETE":
+			percentComplete = prop.value
+		case "CATEGORIES":
+			todo.Task.Labels = append(todo.Task.Labels, splitText(prop.value)...)
+		case ParentProperty:
+			todo.ParentName = unescapeText(prop.value)
+		}
+		if err != nil {
+			return todo, errors.NewAPIError("ICAL_PARSE_FAILED", fmt.Sprintf("invalid %s of VTODO '%s'", prop.name, todo.UID), err)
+		}
+	}
+	if strings.TrimSpace(percentComplete) == "100" {
+		todo.Task.IsCompleted = true
+	}
+	if todo.Task.Labels == nil {
+		todo.Task.Labels = make([]string, 0)
+	}
+	return todo, nil
+}
+
+// parseTime reads a DATE or DATE-TIME value
+func parseTime(prop property, zones map[string]*time.Location) (time.Time, error) {
+	value := strings.TrimSpace(prop.value)
+	if strings.EqualFold(prop.params["VALUE"], "DATE") || len(value) == len(dateFormat) {
+		return time.Parse(dateFormat, value)
+	}
+	if strings.HasSuffix(value, "Z") {
+		return time.Parse(utcDateTimeFormat, value)
+	}
+
+	location := time.Local
+	if tzid := prop.params["TZID"]; tzid != "" {
+		var err error
+		if location, err = loadLocation(tzid, zones); err != nil {
+			return time.Time{}, err
+		}
+	}
+	return time.ParseInLocation(dateTimeFormat, value, location)
+}
+
+// loadLocation resolves a TZID to a location
+func loadLocation(tzid string, zones map[string]*time.Location) (*time.Location, error) {
+	if location, err := time.LoadLocation(tzid); err == nil {
+		return location, nil
+	}
+	// e.g. /mozilla.org/20050126_1/Europe/Berlin
+	if parts := strings.Split(strings.Trim(tzid, "/"), "/"); len(parts) > 2 {
+		if location, err := time.LoadLocation(strings.Join(parts[len(parts)-2:], "/")); err == nil {
+			return location, nil
+		}
+	}
+	if location, ok := zones[tzid]; ok {
+		return location, nil
+	}
+	return nil, fmt.Errorf("unknown time zone '%s'", tzid)
+}
+
+// parseTimezones returns fixed offset locations for the VTIMEZONE components,
+// based on the standard time offset. This is used for TZIDs which are not IANA
+// names, e.g. Windows time zone names used by Outlook.
+func parseTimezones(properties []property) map[string]*time.Location {
+	zones := make(map[string]*time.Location)
+	var tzid, component string
+	for _, prop := range properties {
+		switch {
+		case prop.name == "BEGIN":
+			component = strings.ToUpper(prop.value)
+		case prop.name == "END" && strings.EqualFold(prop.value, "VTIMEZONE"):
+			tzid = ""
+		case prop.name == "TZID":
+			tzid = prop.value
+		case prop.name == "TZOFFSETTO" && component == "STANDARD" && tzid != "":
+			if offset, ok := parseOffset(prop.value); ok {
+				zones[tzid] = time.FixedZone(tzid, offset)
+			}
+		}
+	}
+	return zones
+}
+
+// parseOffset reads a UTC offset like +0100 or -053000 in seconds
+func parseOffset(value string) (int, bool) {
+	value = strings.TrimSpace(value)
+	if len(value) != 5 && len(value) != 7 {
+		return 0, false
+	}
+	sign := 1
+	switch value[0] {
+	case '-':
+		sign = -1
+	case '+':
+	default:
+		return 0, false
+	}
+	var hours, minutes, seconds int
+	if _, err := fmt.Sscanf(value[1:5], "%02d%02d", &hours, &minutes); err != nil {
+		return 0, false
+	}
+	if len(value) == 7 {
+		if _, err := fmt.Sscanf(value[5:], "%02d", &seconds); err != nil {
+			return 0, false
+		}
+	}
+	return sign * (hours*3600 + minutes*60 + seconds), true
+}
+
+// unescapeText reverses escapeText
+func unescapeText(value string) string {
+	if !strings.Contains(value, `\`) {
+		return value
+	}
+	var b strings.Builder
+	for i := 0; i < len(value); i++ {
+		if value[i] != '\\' || i == len(value)-1 {
+			b.WriteByte(value[i])
+			continue
+		}
+		i++
+		switch value[i] {
+		case 'n', 'N':
+			b.WriteByte('\n')
+		default:
+			b.WriteByte(value[i])
+		}
+	}
+	return b.String()
+}
+
+// splitText splits a list of TEXT values at unescaped commas
+func splitText(value string) []string {
+	result := make([]string, 0)
+	start := 0
+	for i := 0; i < len(value); i++ {
+		switch value[i] {
+		case '\\':
+			i++
+		case ',':
+			result = appendText(result, value[start:i])
+			start = i + 1
+		}
+	}
+	return appendText(result, value[start:])
+}
+
+func appendText(values []string, value string) []string {
+	if value = strings.TrimSpace(unescapeText(value)); value != "" {
+		values = append(values, value)
+	}
+	return values
+}
@@ -0,0 +1,137 @@
+package ical
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jo-hoe/todoapi/todoclient"
+)
+
+const thunderbirdCalendar = "BEGIN:VCALENDAR\r\n" +
+	"PRODID:-//Mozilla.org/NONSGML Mozilla Calendar V1.1//EN\r\n" +
+	"VERSION:2.0\r\n" +
+	"BEGIN:VTIMEZONE\r\n" +
+	"TZID:W. Europe Standard Time\r\n" +
+	"BEGIN:DAYLIGHT\r\n" +
+	"TZOFFSETFROM:+0100\r\n" +
+	"TZOFFSETTO:+0200\r\n" +
+	"END:DAYLIGHT\r\n" +
+	"BEGIN:STANDARD\r\n" +
+	"TZOFFSETFROM:+0200\r\n" +
+	"TZOFFSETTO:+0100\r\n" +
+	"END:STANDARD\r\n" +
+	"END:VTIMEZONE\r\n" +
+	"BEGIN:VTODO\r\n" +
+	"UID:a1\r\n" +
+	"SUMMARY:Prepare report\\, slides\\; notes\r\n" +
+	"DESCRIPTION:first line\\nsecond line with a very long text which is folded \r\n" +
+	" over multiple lines\r\n" +
+	"DUE;TZID=/mozilla.org/20050126_1/Europe/Berlin:20240301T100000\r\n" +
+	"CREATED:20240201T083000Z\r\n" +
+	"CATEGORIES:work,a\\,b\r\n" +
+	"CATEGORIES:urgent\r\n" +
+	"BEGIN:VALARM\r\n" +
+	"ACTION:DISPLAY\r\n" +
+	"DESCRIPTION:alarm\r\n" +
+	"END:VALARM\r\n" +
+	"END:VTODO\r\n" +
+	"BEGIN:VTODO\r\n" +
+	"UID:a2\r\n" +
+	"SUMMARY:Call\r\n" +
+	"DUE;VALUE=DATE:20240305\r\n" +
+	"PERCENT-COMPLETE:100\r\n" +
+	"END:VTODO\r\n" +
+	"BEGIN:VTODO\r\n" +
+	"UID:a3\r\n" +
+	"SUMMARY:Outlook\r\n" +
+	"DUE;TZID=\"W. Europe Standard Time\":20240110T120000\r\n" +
+	"STATUS:COMPLETED\r\n" +
+	"END:VTODO\r\n" +
+	"END:VCALENDAR\r\n"
+
+func TestDecode(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Skip("time zone database not available")
+	}
+
+	todos, err := Decode(strings.NewReader(thunderbirdCalendar))
+	if err != nil {
+		t.Fatalf("error was not nil but '%v'", err)
+	}
+	if len(todos) != 3 {
+		t.Fatalf("expected 3 todos but found %d", len(todos))
+	}
+
+	first := todos[0].Task
+	if first.Name != "Prepare report, slides; notes" {
+		t.Errorf("unexpected name '%s'", first.Name)
+	}
+	if first.Description != "first line\nsecond line with a very long text which is folded over multiple lines" {
+		t.Errorf("unexpected description '%s'", first.Description)
+	}
+	if !first.DueDate.Equal(time.Date(2024, 3, 1, 10, 0, 0, 0, berlin)) {
+		t.Errorf("unexpected due date %v", first.DueDate)
+	}
+	if !first.CreationTime.Equal(time.Date(2024, 2, 1, 8, 30, 0, 0, time.UTC)) {
+		t.Errorf("unexpected creation time %v", first.CreationTime)
+	}
+	if !reflect.DeepEqual(first.Labels, []string{"work", "a,b", "urgent"}) {
+		t.Errorf("unexpected labels %v", first.Labels)
+	}
+
+	second := todos[1].Task
+	if !second.IsCompleted || !second.DueDate.Equal(time.Date(2024, 3, 5, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("unexpected second task %+v", second)
+	}
+	third := todos[2].Task
+	if !third.IsCompleted || !third.DueDate.Equal(time.Date(2024, 1, 10, 11, 0, 0, 0, time.UTC)) {
+		t.Errorf("unexpected third task %+v", third)
+	}
+}
+
+func TestDecode_RoundTrip(t *testing.T) {
+	parent := todoclient.ToDoParent{ID: "p", Name: "Work"}
+	task := todoclient.ToDoTask{
+		ID:           "t1",
+		Name:         strings.Repeat("long name with ümlauts, commas; and semicolons ", 4),
+		Description:  "back\\slash\r\nnew line",
+		DueDate:      time.Date(2024, 3, 1, 15, 4, 5, 0, time.UTC),
+		CreationTime: time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC),
+		IsCompleted:  true,
+		Labels:       []string{"a,b", "c"},
+	}
+	b, err := Marshal(Collection{Parent: parent, Tasks: []todoclient.ToDoTask{task}})
+	if err != nil {
+		t.Fatalf("error was not nil but '%v'", err)
+	}
+
+	todos, err := Decode(strings.NewReader(string(b)))
+	if err != nil {
+		t.Fatalf("error was not nil but '%v'", err)
+	}
+	if len(todos) != 1 || todos[0].UID != "t1" || todos[0].ParentName != "Work" {
+		t.Fatalf("unexpected todos %+v", todos)
+	}
+	decoded := todos[0].Task
+	decoded.ID = task.ID
+	task.Description = "back\\slash\nnew line"
+	if !reflect.DeepEqual(decoded, task) {
+		t.Errorf("expected %+v but found %+v", task, decoded)
+	}
+}
+
+func TestDecode_Invalid(t *testing.T) {
+	for _, calendar := range []string{
+		"BEGIN:VCALENDAR\r\nBEGIN:VTODO\r\nEND:VCALENDAR\r\n",
+		"BEGIN:VCALENDAR\r\nBEGIN:VTODO\r\nSUMMARY\r\nEND:VTODO\r\nEND:VCALENDAR\r\n",
+		"BEGIN:VCALENDAR\r\nBEGIN:VTODO\r\nDUE;TZID=Nowhere/City:20240101T000000\r\nEND:VTODO\r\nEND:VCALENDAR\r\n",
+		"BEGIN:VCALENDAR\r\nBEGIN:VTODO\r\n",
+	} {
+		if _, err := Decode(strings.NewReader(calendar)); err == nil {
+			t.Errorf("expected error for %q", calendar)
+		}
+	}
+}
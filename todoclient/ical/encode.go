@@ -0,0 +1,163 @@
+// Package ical converts tasks from and to iCalendar (RFC 5545) VTODO components
+package ical
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/jo-hoe/todoapi/pkg/errors"
+	"github.com/jo-hoe/todoapi/todoclient"
+)
+
+// ProductID identifies this library in exported calendars
+const ProductID = "-//jo-hoe//todoapi//EN"
+
+// ParentProperty is a non-standard property holding the name of the parent of a task,
+// so that calendars with tasks of several parents keep their structure
+const ParentProperty = "X-TODOAPI-PARENT"
+
+// Collection is a parent with its tasks
+type Collection struct {
+	Provider string // Optional, qualifies the UIDs of the tasks, see TaskUID
+	Parent   todoclient.ToDoParent
+	Tasks    []todoclient.ToDoTask
+}
+
+// TaskUID returns the UID of an exported task, e.g. 12@owner/repo.github.
+// Task IDs of some providers are only unique within their parent, so the UID
+// contains the parent ID and the provider name.
+func TaskUID(provider, parentID, taskID string) string {
+	return taskID + "@" + parentID + "." + provider
+}
+
+// parseTaskUID returns the task ID of a UID created by TaskUID for the parent
+func parseTaskUID(uid, parentID string) (string, bool) {
+	i := strings.LastIndex(uid, "@"+parentID+".")
+	if i <= 0 {
+		return "", false
+	}
+	provider := uid[i+len(parentID)+2:]
+	if provider == "" || strings.ContainsAny(provider, "@.") {
+		return "", false
+	}
+	return uid[:i], true
+}
+
+// Encoder writes collections as VCALENDAR with one VTODO per task
+type Encoder struct {
+	w   io.Writer
+	now func() time.Time
+}
+
+// NewEncoder creates an encoder writing to w
+func NewEncoder(w io.Writer) *Encoder {
+	return &Encoder{
+		w:   w,
+		now: time.Now,
+	}
+}
+
+// Marshal returns the calendar of the collections
+func Marshal(collections ...Collection) ([]byte, error) {
+	var buffer bytes.Buffer
+	if err := NewEncoder(&buffer).Encode(collections...); err != nil {
+		return nil, err
+	}
+	return buffer.Bytes(), nil
+}
+
+// Encode writes a single calendar containing the tasks of all collections.
+// The calendar is named after the parent if there is only one collection.
+//
+// UIDs are created by TaskUID for collections with provider, otherwise the task
+// ID is used, e.g. for CalDAV tasks whose IDs are UIDs. Due dates at midnight are
+// written as DATE values, other times as UTC DATE-TIME values, which keeps the
+// calendar free of VTIMEZONE definitions. COMPLETED is not written, as providers
+// do not report when a task was completed.
+func (e *Encoder) Encode(collections ...Collection) error {
+	var b strings.Builder
+	write := func(name, value string) {
+		b.WriteString(foldLine(name + ":" + value))
+	}
+
+	write("BEGIN", "VCALENDAR")
+	write("VERSION", "2.0")
+	write("PRODID", ProductID)
+	write("CALSCALE", "GREGORIAN")
+	if len(collections) == 1 {
+		write("X-WR-CALNAME", escapeText(collections[0].Parent.Name))
+	}
+
+	stamp := e.now()
+	for _, collection := range collections {
+		for _, task := range collection.Tasks {
+			uid := task.ID
+			if collection.Provider != "" {
+				uid = TaskUID(collection.Provider, collection.Parent.ID, task.ID)
+			}
+			write("BEGIN", "VTODO")
+			write("UID", escapeText(uid))
+			write("DTSTAMP", formatUTC(firstNonZero(task.ModifiedTime, task.CreationTime, stamp)))
+			write("SUMMARY", escapeText(task.Name))
+			if task.Description != "" {
+				write("DESCRIPTION", escapeText(task.Description))
+			}
+			if !task.CreationTime.IsZero() {
+				write("CREATED", formatUTC(task.CreationTime))
+			}
+			if !task.ModifiedTime.IsZero() {
+				write("LAST-MODIFIED", formatUTC(task.ModifiedTime))
+			}
+			if !task.DueDate.IsZero() {
+				if isDate(task.DueDate) {
+					write("DUE;VALUE=DATE", task.DueDate.Format(dateFormat))
+				} else {
+					write("DUE", formatUTC(task.DueDate))
+				}
+			}
+			if task.IsCompleted {
+				write("STATUS", "COMPLETED")
+				write("PERCENT-COMPLETE", "100")
+			} else {
+				write("STATUS", "NEEDS-ACTION")
+			}
+			if len(task.Labels) > 0 {
+				labels := make([]string, 0, len(task.Labels))
+				for _, label := range task.Labels {
+					labels = append(labels, escapeText(label))
+				}
+				write("CATEGORIES", strings.Join(labels, ","))
+			}
+			if collection.Parent.Name != "" {
+				write(ParentProperty, escapeText(collection.Parent.Name))
+			}
+			write("END", "VTODO")
+		}
+	}
+	write("END", "VCALENDAR")
+
+	if _, err := io.WriteString(e.w, b.String()); err != nil {
+		return errors.NewAPIError("ICAL_WRITE_FAILED", "failed to write calendar", err)
+	}
+	return nil
+}
+
+// isDate reports whether the time is midnight in its location, i.e. a date without time
+func isDate(t time.Time) bool {
+	return t.Hour() == 0 && t.Minute() == 0 && t.Second() == 0 && t.Nanosecond() == 0
+}
+
+func formatUTC(t time.Time) string {
+	return t.UTC().Format(utcDateTimeFormat)
+}
+
+func firstNonZero(times ...time.Time) time.Time {
+	for _, t := range times {
+		if !t.IsZero() {
+			return t
+		}
+	}
+	return time.Time{}
+}
@@ -0,0 +1,128 @@
+package ical
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/jo-hoe/todoapi/todoclient"
+)
+
+func TestEncoder_Encode(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Skip("time zone database not available")
+	}
+	collection := Collection{
+		Provider: "todoist",
+		Parent:   todoclient.ToDoParent{ID: "p1", Name: "Work, Home"},
+		Tasks: []todoclient.ToDoTask{
+			{
+				ID:           "t1",
+				Name:         "Report; draft",
+				Description:  "line one\nline two",
+				DueDate:      time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC),
+				CreationTime: time.Date(2024, 2, 1, 8, 30, 0, 0, time.UTC),
+				Labels:       []string{"office", "a,b"},
+			},
+			{
+				ID:           "t2",
+				Name:         "Call",
+				DueDate:      time.Date(2024, 3, 1, 10, 0, 0, 0, berlin),
+				ModifiedTime: time.Date(2024, 2, 3, 12, 0, 0, 0, time.UTC),
+				IsCompleted:  true,
+			},
+		},
+	}
+	var buffer bytes.Buffer
+	encoder := NewEncoder(&buffer)
+	encoder.now = func() time.Time { return time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC) }
+
+	if err := encoder.Encode(collection); err != nil {
+		t.Fatalf("error was not nil but '%v'", err)
+	}
+
+	output := buffer.String()
+	for _, expected := range []string{
+		"BEGIN:VCALENDAR\r\nVERSION:2.0\r\nPRODID:" + ProductID + "\r\n",
+		"X-WR-CALNAME:Work\\, Home\r\n",
+		"UID:t1@p1.todoist\r\nDTSTAMP:20240201T083000Z\r\nSUMMARY:Report\\; draft\r\n",
+		"DESCRIPTION:line one\\nline two\r\n",
+		"CREATED:20240201T083000Z\r\n",
+		"DUE;VALUE=DATE:20240301\r\n",
+		"STATUS:NEEDS-ACTION\r\n",
+		"CATEGORIES:office,a\\,b\r\n",
+		"DUE:20240301T090000Z\r\n",
+		"STATUS:COMPLETED\r\nPERCENT-COMPLETE:100\r\n",
+		"END:VTODO\r\nEND:VCALENDAR\r\n",
+	} {
+		if !strings.Contains(output, expected) {
+			t.Errorf("expected '%s' in output:\n%s", expected, output)
+		}
+	}
+	// providers do not report when a task was completed
+	if strings.Contains(output, "\nCOMPLETED:") {
+		t.Errorf("expected no completion time in output:\n%s", output)
+	}
+	if strings.Count(output, "BEGIN:VTODO") != 2 {
+		t.Errorf("expected 2 VTODO components in output:\n%s", output)
+	}
+}
+
+func TestTaskUID(t *testing.T) {
+	tests := []struct {
+		uid      string
+		parentID string
+		want     string
+		ok       bool
+	}{
+		{uid: TaskUID("github", "owner/repo", "12"), parentID: "owner/repo", want: "12", ok: true},
+		{uid: TaskUID("caldav", "/dav/a.b/", "x@y"), parentID: "/dav/a.b/", want: "x@y", ok: true},
+		{uid: TaskUID("github", "owner/other", "12"), parentID: "owner/repo"},
+		{uid: "12", parentID: "owner/repo"},
+		{uid: "12@owner/repo.", parentID: "owner/repo"},
+	}
+	for _, tt := range tests {
+		got, ok := parseTaskUID(tt.uid, tt.parentID)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("%s: expected '%s' and %v but found '%s' and %v", tt.uid, tt.want, tt.ok, got, ok)
+		}
+	}
+}
+
+func TestMarshal_Empty(t *testing.T) {
+	b, err := Marshal()
+	if err != nil {
+		t.Fatalf("error was not nil but '%v'", err)
+	}
+	expected := "BEGIN:VCALENDAR\r\nVERSION:2.0\r\nPRODID:" + ProductID + "\r\nCALSCALE:GREGORIAN\r\nEND:VCALENDAR\r\n"
+	if string(b) != expected {
+		t.Errorf("expected '%q' but found '%q'", expected, string(b))
+	}
+}
+
+func TestFoldLine(t *testing.T) {
+	line := "DESCRIPTION:" + strings.Repeat("ä", 100)
+	folded := foldLine(line)
+
+	parts := strings.Split(strings.TrimSuffix(folded, crlf), crlf)
+	if len(parts) < 3 {
+		t.Fatalf("expected folded lines but found %q", folded)
+	}
+	for i, part := range parts {
+		if len(part) > maxLineLength {
+			t.Errorf("line %d exceeds %d octets: %d", i, maxLineLength, len(part))
+		}
+		if i > 0 && !strings.HasPrefix(part, " ") {
+			t.Errorf("continuation line %d does not start with a space", i)
+		}
+		if !utf8.ValidString(part) {
+			t.Errorf("line %d splits a character", i)
+		}
+	}
+	if strings.ReplaceAll(folded, crlf+" ", "") != line+crlf {
+		t.Error("unfolded line differs from original")
+	}
+}
@@ -0,0 +1,47 @@
+package ical
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	crlf          = "\r\n"
+	maxLineLength = 75 // octets per content line without the line break, see RFC 5545 3.1
+
+	dateFormat        = "20060102"
+	dateTimeFormat    = "20060102T150405"
+	utcDateTimeFormat = "20060102T150405Z"
+)
+
+var textEscaper = strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`, "\r", `\n`)
+
+// escapeText escapes a TEXT value, see RFC 5545 3.3.11
+func escapeText(value string) string {
+	return textEscaper.Replace(value)
+}
+
+// foldLine splits a content line into lines of at most 75 octets. Continuation
+// lines start with a space, multi-byte characters are never split.
+func foldLine(line string) string {
+	if len(line) <= maxLineLength {
+		return line + crlf
+	}
+
+	var b strings.Builder
+	limit := maxLineLength
+	for len(line) > limit {
+		cut := limit
+		for cut > 0 && !utf8.RuneStart(line[cut]) {
+			cut--
+		}
+		b.WriteString(line[:cut])
+		b.WriteString(crlf + " ")
+		line = line[cut:]
+		// the leading space counts towards the length of continuation lines
+		limit = maxLineLength - 1
+	}
+	b.WriteString(line)
+	b.WriteString(crlf)
+	return b.String()
+}
@@ -0,0 +1,212 @@
+package ical
+
+import (
+	"context"
+	"encoding/json"
+	stderrors "errors"
+	"io"
+	"os"
+	"sync"
+
+	"github.com/jo-hoe/todoapi/pkg/errors"
+	"github.com/jo-hoe/todoapi/todoclient"
+)
+
+// Import statuses of single VTODO components
+const (
+	StatusCreated     = "created"
+	StatusWouldCreate = "would_create" // dry-run
+	StatusDuplicate   = "duplicate"
+	StatusFailed      = "failed"
+)
+
+// Registry remembers the tasks created for UIDs, so that importing the same
+// calendar again does not duplicate tasks
+type Registry interface {
+	Lookup(uid string) (taskID string, ok bool)
+	Record(uid, taskID string) error
+}
+
+// ImportOptions configures an import
+type ImportOptions struct {
+	DryRun   bool     // Report what would be imported without creating tasks
+	Registry Registry // Optional, UIDs of earlier imports
+}
+
+// ImportReport summarizes an import
+type ImportReport struct {
+	Created    int          `json:"created"` // Tasks created, or which would be created in a dry-run
+	Duplicates int          `json:"duplicates"`
+	Failed     int          `json:"failed"`
+	Items      []ImportItem `json:"items"`
+}
+
+// ImportItem is the result for a single VTODO component
+type ImportItem struct {
+	UID    string `json:"uid"`
+	Name   string `json:"name"`
+	Status string `json:"status"`
+	TaskID string `json:"task_id,omitempty"` // Created or existing task
+	Error  string `json:"error,omitempty"`
+}
+
+// Import creates the VTODO components of the calendar as tasks in the parent.
+//
+// A component is a duplicate if its UID appeared earlier in the calendar, if it
+// is the ID of a task of the parent or its TaskUID, which is the case for calendars
+// exported by this package, or if the registry holds a task for it which still exists.
+// Components which cannot be created are reported as failed without stopping
+// the import.
+func Import(ctx context.Context, client todoclient.ToDoClient, parentID string, r io.Reader, options ImportOptions) (ImportReport, error) {
+	report := ImportReport{Items: make([]ImportItem, 0)}
+
+	todos, err := Decode(r)
+	if err != nil {
+		return report, err
+	}
+	existing, err := client.GetChildrenTasks(ctx, parentID, nil)
+	if err != nil {
+		return report, errors.NewAPIError("ICAL_GET_TASKS_FAILED", "failed to retrieve tasks", err)
+	}
+	known := make(map[string]string, len(existing))
+	for _, task := range existing {
+		known[task.ID] = task.ID
+	}
+
+	for _, todo := range todos {
+		item := ImportItem{UID: todo.UID, Name: todo.Task.Name}
+
+		taskID, duplicate, err := findDuplicate(ctx, client, parentID, todo.UID, known, options.Registry)
+		switch {
+		case err != nil:
+			item.Status, item.Error = StatusFailed, err.Error()
+		case duplicate:
+			item.Status, item.TaskID = StatusDuplicate, taskID
+		default:
+			item.Status, item.TaskID, item.Error = create(ctx, client, parentID, todo, options)
+		}
+
+		switch item.Status {
+		case StatusCreated, StatusWouldCreate:
+			report.Created++
+			if todo.UID != "" {
+				known[todo.UID] = item.TaskID
+			}
+		case StatusDuplicate:
+			report.Duplicates++
+		default:
+			report.Failed++
+		}
+		report.Items = append(report.Items, item)
+	}
+	return report, nil
+}
+
+func findDuplicate(ctx context.Context, client todoclient.ToDoClient, parentID, uid string, known map[string]string, registry Registry) (string, bool, error) {
+	if uid == "" {
+		return "", false, nil
+	}
+	if taskID, ok := known[uid]; ok {
+		return taskID, true, nil
+	}
+	if exportedID, ok := parseTaskUID(uid, parentID); ok {
+		if taskID, ok := known[exportedID]; ok {
+			return taskID, true, nil
+		}
+	}
+	if registry == nil {
+		return "", false, nil
+	}
+	taskID, ok := registry.Lookup(uid)
+	if !ok {
+		return "", false, nil
+	}
+	// tasks deleted since the last import are imported again
+	_, err := client.GetTask(ctx, parentID, taskID)
+	if stderrors.Is(err, errors.ErrNotFound) {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, errors.NewAPIError("ICAL_GET_TASK_FAILED", "failed to retrieve task", err)
+	}
+	return taskID, true, nil
+}
+
+func create(ctx context.Context, client todoclient.ToDoClient, parentID string, todo Todo, options ImportOptions) (status, taskID, message string) {
+	task := todo.Task
+	task.ID = ""
+	if err := task.Validate(); err != nil {
+		return StatusFailed, "", err.Error()
+	}
+	if options.DryRun {
+		return StatusWouldCreate, "", ""
+	}
+
+	created, err := client.CreateTask(ctx, parentID, task)
+	if err != nil {
+		return StatusFailed, "", err.Error()
+	}
+	if options.Registry != nil && todo.UID != "" {
+		if err := options.Registry.Record(todo.UID, created.ID); err != nil {
+			return StatusCreated, created.ID, "failed to record UID: " + err.Error()
+		}
+	}
+	return StatusCreated, created.ID, ""
+}
+
+// FileRegistry is a Registry persisted as JSON file
+type FileRegistry struct {
+	mu   sync.Mutex
+	path string
+	uids map[string]string
+}
+
+// NewFileRegistry loads the registry at path. The file is created on the first record.
+func NewFileRegistry(path string) (*FileRegistry, error) {
+	registry := &FileRegistry{
+		path: path,
+		uids: make(map[string]string),
+	}
+	b, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return registry, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(b, &registry.uids); err != nil {
+		return nil, err
+	}
+	return registry, nil
+}
+
+func (r *FileRegistry) Lookup(uid string) (string, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	taskID, ok := r.uids[uid]
+	return taskID, ok
+}
+
+func (r *FileRegistry) Record(uid, taskID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.uids[uid] = taskID
+	b, err := json.MarshalIndent(r.uids, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	// Write atomically
+	tmp := r.path + ".tmp"
+	if err := os.WriteFile(tmp, b, 0o600); err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+	if err := os.Rename(tmp, r.path); err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+	return nil
+}
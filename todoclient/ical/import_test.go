@@ -0,0 +1,114 @@
+package ical
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jo-hoe/todoapi/internal/testutil"
+	"github.com/jo-hoe/todoapi/todoclient"
+)
+
+// importCalendar contains duplicates within the calendar, a component without name
+// and a component exported earlier from the task with the given ID
+func importCalendar(existingID string) string {
+	return "BEGIN:VCALENDAR\r\n" +
+		"BEGIN:VTODO\r\nUID:u1\r\nSUMMARY:first\r\nEND:VTODO\r\n" +
+		"BEGIN:VTODO\r\nUID:u2\r\nSUMMARY:second\r\nEND:VTODO\r\n" +
+		"BEGIN:VTODO\r\nUID:u1\r\nSUMMARY:first again\r\nEND:VTODO\r\n" +
+		"BEGIN:VTODO\r\nUID:u3\r\nEND:VTODO\r\n" +
+		"BEGIN:VTODO\r\nUID:" + existingID + "\r\nSUMMARY:exported earlier\r\nEND:VTODO\r\n" +
+		"END:VCALENDAR\r\n"
+}
+
+// newImportClient creates a mock with a parent holding the task exported earlier
+func newImportClient(t *testing.T) (*testutil.MockToDoClient, string, string) {
+	t.Helper()
+	ctx := context.Background()
+	client := testutil.NewMockToDoClient()
+	parent, err := client.CreateParent(ctx, "Work")
+	if err != nil {
+		t.Fatalf("error was not nil but '%v'", err)
+	}
+	existing, err := client.CreateTask(ctx, parent.ID, todoclient.ToDoTask{Name: "exported earlier"})
+	if err != nil {
+		t.Fatalf("error was not nil but '%v'", err)
+	}
+	return client, parent.ID, existing.ID
+}
+
+func taskCount(client todoclient.ToDoClient, parentID string) int {
+	tasks, _ := client.GetChildrenTasks(context.Background(), parentID, nil)
+	return len(tasks)
+}
+
+func TestImport(t *testing.T) {
+	ctx := context.Background()
+	client, parentID, existingID := newImportClient(t)
+	registry, err := NewFileRegistry(filepath.Join(t.TempDir(), "uids.json"))
+	if err != nil {
+		t.Fatalf("error was not nil but '%v'", err)
+	}
+	options := ImportOptions{Registry: registry}
+
+	report, err := Import(ctx, client, parentID, strings.NewReader(importCalendar(existingID)), options)
+	if err != nil {
+		t.Fatalf("error was not nil but '%v'", err)
+	}
+	if report.Created != 2 || report.Duplicates != 2 || report.Failed != 1 || taskCount(client, parentID) != 3 {
+		t.Errorf("unexpected report %+v", report)
+	}
+	statuses := make([]string, 0)
+	for _, item := range report.Items {
+		statuses = append(statuses, item.Status)
+	}
+	expected := []string{StatusCreated, StatusCreated, StatusDuplicate, StatusFailed, StatusDuplicate}
+	if fmt.Sprint(statuses) != fmt.Sprint(expected) {
+		t.Errorf("expected %v but found %v", expected, statuses)
+	}
+
+	// the registry prevents duplicates on a second import
+	report, err = Import(ctx, client, parentID, strings.NewReader(importCalendar(existingID)), options)
+	if err != nil {
+		t.Fatalf("error was not nil but '%v'", err)
+	}
+	if report.Created != 0 || report.Duplicates != 4 || taskCount(client, parentID) != 3 {
+		t.Errorf("unexpected report of second import %+v", report)
+	}
+}
+
+func TestImport_DryRun(t *testing.T) {
+	client := testutil.NewMockToDoClient()
+	parent, _ := client.CreateParent(context.Background(), "Work")
+
+	report, err := Import(context.Background(), client, parent.ID, strings.NewReader(importCalendar("unknown")), ImportOptions{DryRun: true})
+	if err != nil {
+		t.Fatalf("error was not nil but '%v'", err)
+	}
+	if report.Created != 3 || report.Duplicates != 1 || taskCount(client, parent.ID) != 0 {
+		t.Errorf("unexpected dry-run report %+v", report)
+	}
+	if report.Items[0].Status != StatusWouldCreate {
+		t.Errorf("expected status '%s' but found '%s'", StatusWouldCreate, report.Items[0].Status)
+	}
+}
+
+func TestImport_ExportedUIDs(t *testing.T) {
+	ctx := context.Background()
+	client, parentID, existingID := newImportClient(t)
+	// task IDs like GitHub issue numbers repeat across parents
+	calendar := "BEGIN:VCALENDAR\r\n" +
+		"BEGIN:VTODO\r\nUID:" + TaskUID("github", parentID, existingID) + "\r\nSUMMARY:same parent\r\nEND:VTODO\r\n" +
+		"BEGIN:VTODO\r\nUID:" + TaskUID("github", "other", existingID) + "\r\nSUMMARY:other parent\r\nEND:VTODO\r\n" +
+		"END:VCALENDAR\r\n"
+
+	report, err := Import(ctx, client, parentID, strings.NewReader(calendar), ImportOptions{})
+	if err != nil {
+		t.Fatalf("error was not nil but '%v'", err)
+	}
+	if report.Duplicates != 1 || report.Items[0].TaskID != existingID || report.Created != 1 || report.Items[1].Status != StatusCreated {
+		t.Errorf("expected only the task of the same parent to be a duplicate but found %+v", report)
+	}
+}
@@ -0,0 +1,90 @@
+package todoclient
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+	"time"
+)
+
+// loggerKey is the context key of the logger of a LoggingInterceptor
+type loggerKey struct{}
+
+// LoggingInterceptor logs every invocation with its arguments and duration.
+// Successful calls are logged at debug level, failed calls at error level.
+// The logger is passed to the wrapped client, see Logger.
+func LoggingInterceptor(logger *slog.Logger) Interceptor {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	return func(ctx context.Context, inv Invocation, next Handler) (interface{}, error) {
+		start := time.Now()
+		result, err := next(context.WithValue(ctx, loggerKey{}, logger), inv)
+
+		attrs := []any{"operation", inv.Operation, "args", inv.Args, "duration", time.Since(start)}
+		if err != nil {
+			logger.ErrorContext(ctx, "todo client call failed", append(attrs, "error", err)...)
+		} else {
+			logger.DebugContext(ctx, "todo client call", attrs...)
+		}
+		return result, err
+	}
+}
+
+// Logger returns the logger of the LoggingInterceptor handling the call, or the
+// default logger. Clients use it for problems that do not fail the call.
+func Logger(ctx context.Context) *slog.Logger {
+	if logger, ok := ctx.Value(loggerKey{}).(*slog.Logger); ok {
+		return logger
+	}
+	return slog.Default()
+}
+
+// ValidationInterceptor rejects invalid tasks, parent names and missing IDs
+// before they reach the wrapped client.
+func ValidationInterceptor() Interceptor {
+	return func(ctx context.Context, inv Invocation, next Handler) (interface{}, error) {
+		if err := validateInvocation(inv); err != nil {
+			return nil, err
+		}
+		return next(ctx, inv)
+	}
+}
+
+func validateInvocation(inv Invocation) error {
+	for _, arg := range inv.Args {
+		if task, ok := arg.(ToDoTask); ok {
+			if err := task.Validate(); err != nil {
+				return err
+			}
+		}
+	}
+
+	switch inv.Operation {
+	case OpCreateParent:
+		name, _ := argAt(inv, 0).(string)
+		parent := ToDoParent{Name: strings.TrimSpace(name)}
+		return parent.Validate()
+	case OpUpdateTask:
+		if task, _ := argAt(inv, 1).(ToDoTask); task.ID == "" {
+			return &ValidationError{Field: "id", Message: "task id cannot be empty"}
+		}
+	case OpGetTask, OpDeleteTask:
+		if taskID, _ := argAt(inv, 1).(string); taskID == "" {
+			return &ValidationError{Field: "id", Message: "task id cannot be empty"}
+		}
+	case OpDeleteParent:
+		if parentID, _ := argAt(inv, 0).(string); parentID == "" {
+			return &ValidationError{Field: "id", Message: "parent id cannot be empty"}
+		}
+	}
+	return nil
+}
+
+// argAt returns the argument at position i or nil if there is none
+func argAt(inv Invocation, i int) interface{} {
+	if i < len(inv.Args) {
+		return inv.Args[i]
+	}
+	return nil
+}
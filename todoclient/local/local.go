@@ -0,0 +1,357 @@
+// Package local provides a ToDoClient storing parents and tasks in a directory
+// on the local file system, for offline use and development
+package local
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jo-hoe/todoapi/pkg/errors"
+	"github.com/jo-hoe/todoapi/todoclient"
+)
+
+const (
+	fileExtension = ".json"
+	lockFile      = ".lock"
+)
+
+// parentFile is the content of the file of a parent
+type parentFile struct {
+	ID           string                `json:"id"`
+	Name         string                `json:"name"`
+	CreationTime time.Time             `json:"creation_time"`
+	Tasks        []todoclient.ToDoTask `json:"tasks"`
+}
+
+// LocalClient implements ToDoClient on a directory with one JSON file per parent.
+//
+// Files are replaced atomically and every operation holds a lock file in the
+// directory, so several processes can share a directory. IDs are random and
+// never change, creation and modification times are set on write.
+type LocalClient struct {
+	mu   sync.Mutex
+	dir  string
+	lock *fileLock
+	now  func() time.Time
+}
+
+// NewLocalClient creates a client storing its data in dir, creating the directory if needed
+func NewLocalClient(dir string) (*LocalClient, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, errors.NewAPIError("LOCAL_INIT_FAILED", "failed to create directory", err)
+	}
+	return &LocalClient{
+		dir:  dir,
+		lock: newFileLock(filepath.Join(dir, lockFile)),
+		now:  time.Now,
+	}, nil
+}
+
+func (client *LocalClient) GetAllTasks(ctx context.Context, query *todoclient.TaskQuery) ([]todoclient.ToDoTask, error) {
+	result := make([]todoclient.ToDoTask, 0)
+	err := client.locked(ctx, func() error {
+		parents, err := client.readAll()
+		for _, parent := range parents {
+			result = append(result, parent.Tasks...)
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return query.Apply(result), nil
+}
+
+func (client *LocalClient) GetChildrenTasks(ctx context.Context, parentID string, query *todoclient.TaskQuery) ([]todoclient.ToDoTask, error) {
+	var parent *parentFile
+	err := client.locked(ctx, func() error {
+		var err error
+		parent, err = client.read(parentID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return query.Apply(parent.Tasks), nil
+}
+
+func (client *LocalClient) GetTask(ctx context.Context, parentID, taskID string) (todoclient.ToDoTask, error) {
+	var task todoclient.ToDoTask
+	err := client.locked(ctx, func() error {
+		parent, err := client.read(parentID)
+		if err != nil {
+			return err
+		}
+		i, err := findTask(parent, taskID)
+		if err != nil {
+			return err
+		}
+		task = parent.Tasks[i]
+		return nil
+	})
+	return task, err
+}
+
+func (client *LocalClient) CreateTask(ctx context.Context, parentID string, task todoclient.ToDoTask) (todoclient.ToDoTask, error) {
+	err := client.locked(ctx, func() error {
+		parent, err := client.read(parentID)
+		if err != nil {
+			return err
+		}
+		if task.ID, err = newID(); err != nil {
+			return err
+		}
+		task.CreationTime = client.now().UTC()
+		task.ModifiedTime = task.CreationTime
+		task.Labels = nonNil(task.Labels)
+		parent.Tasks = append(parent.Tasks, task)
+		return client.write(parent)
+	})
+	if err != nil {
+		return todoclient.ToDoTask{}, err
+	}
+	return task, nil
+}
+
+func (client *LocalClient) UpdateTask(ctx context.Context, parentID string, task todoclient.ToDoTask) error {
+	return client.locked(ctx, func() error {
+		parent, err := client.read(parentID)
+		if err != nil {
+			return err
+		}
+		i, err := findTask(parent, task.ID)
+		if err != nil {
+			return err
+		}
+		// the creation time is owned by the store
+		task.CreationTime = parent.Tasks[i].CreationTime
+		task.ModifiedTime = client.now().UTC()
+		task.Labels = nonNil(task.Labels)
+		parent.Tasks[i] = task
+		return client.write(parent)
+	})
+}
+
+func (client *LocalClient) DeleteTask(ctx context.Context, parentID, taskID string) error {
+	return client.locked(ctx, func() error {
+		parent, err := client.read(parentID)
+		if err != nil {
+			return err
+		}
+		i, err := findTask(parent, taskID)
+		if err != nil {
+			return err
+		}
+		parent.Tasks = append(parent.Tasks[:i], parent.Tasks[i+1:]...)
+		return client.write(parent)
+	})
+}
+
+func (client *LocalClient) GetAllParents(ctx context.Context) ([]todoclient.ToDoParent, error) {
+	result := make([]todoclient.ToDoParent, 0)
+	err := client.locked(ctx, func() error {
+		parents, err := client.readAll()
+		for _, parent := range parents {
+			result = append(result, todoclient.ToDoParent{ID: parent.ID, Name: parent.Name})
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (client *LocalClient) CreateParent(ctx context.Context, parentName string) (todoclient.ToDoParent, error) {
+	name := todoclient.ToDoParent{Name: strings.TrimSpace(parentName)}
+	if err := name.Validate(); err != nil {
+		return todoclient.ToDoParent{}, err
+	}
+	var parent parentFile
+	err := client.locked(ctx, func() error {
+		id, err := newID()
+		if err != nil {
+			return err
+		}
+		parent = parentFile{
+			ID:           id,
+			Name:         name.Name,
+			CreationTime: client.now().UTC(),
+			Tasks:        make([]todoclient.ToDoTask, 0),
+		}
+		return client.write(&parent)
+	})
+	if err != nil {
+		return todoclient.ToDoParent{}, err
+	}
+	return todoclient.ToDoParent{ID: parent.ID, Name: parent.Name}, nil
+}
+
+func (client *LocalClient) DeleteParent(ctx context.Context, parentID string) error {
+	return client.locked(ctx, func() error {
+		path, err := client.path(parentID)
+		if err != nil {
+			return err
+		}
+		err = os.Remove(path)
+		if os.IsNotExist(err) {
+			return errors.NewAPIError("LOCAL_NOT_FOUND", "parent not found", errors.ErrNotFound)
+		}
+		if err != nil {
+			return errors.NewAPIError("LOCAL_DELETE_FAILED", "failed to delete parent", err)
+		}
+		return nil
+	})
+}
+
+// Capabilities reports the features supported by the local client, which stores tasks as they are
+func (client *LocalClient) Capabilities() todoclient.Capabilities {
+	return todoclient.Capabilities{
+		Subtasks:     true,
+		Labels:       true,
+		DueDates:     true,
+		DueTime:      true,
+		CreateParent: true,
+		DeleteParent: true,
+	}
+}
+
+// locked runs fn while holding the lock of this process and the lock file
+func (client *LocalClient) locked(ctx context.Context, fn func() error) error {
+	client.mu.Lock()
+	defer client.mu.Unlock()
+
+	release, err := client.lock.acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer release()
+	return fn()
+}
+
+// path returns the file of a parent. IDs containing path elements are rejected,
+// so that no file outside the directory can be accessed.
+func (client *LocalClient) path(parentID string) (string, error) {
+	if parentID == "" || parentID != filepath.Base(parentID) || strings.HasPrefix(parentID, ".") {
+		return "", errors.NewAPIError("LOCAL_NOT_FOUND", "parent not found", errors.ErrNotFound)
+	}
+	return filepath.Join(client.dir, parentID+fileExtension), nil
+}
+
+func (client *LocalClient) read(parentID string) (*parentFile, error) {
+	path, err := client.path(parentID)
+	if err != nil {
+		return nil, err
+	}
+	b, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, errors.NewAPIError("LOCAL_NOT_FOUND", "parent not found", errors.ErrNotFound)
+	}
+	if err != nil {
+		return nil, errors.NewAPIError("LOCAL_READ_FAILED", "failed to read parent", err)
+	}
+
+	parent := &parentFile{}
+	if err := json.Unmarshal(b, parent); err != nil {
+		return nil, errors.NewAPIError("LOCAL_DECODE_FAILED", "failed to decode "+path, err)
+	}
+	if parent.Tasks == nil {
+		parent.Tasks = make([]todoclient.ToDoTask, 0)
+	}
+	return parent, nil
+}
+
+// readAll returns all parents ordered by creation time
+func (client *LocalClient) readAll() ([]*parentFile, error) {
+	entries, err := os.ReadDir(client.dir)
+	if err != nil {
+		return nil, errors.NewAPIError("LOCAL_READ_FAILED", "failed to read directory", err)
+	}
+
+	parents := make([]*parentFile, 0, len(entries))
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(name, fileExtension) || strings.HasPrefix(name, ".") {
+			continue
+		}
+		parent, err := client.read(strings.TrimSuffix(name, fileExtension))
+		if err != nil {
+			return nil, err
+		}
+		parents = append(parents, parent)
+	}
+	sort.SliceStable(parents, func(i, j int) bool {
+		return parents[i].CreationTime.Before(parents[j].CreationTime)
+	})
+	return parents, nil
+}
+
+// write replaces the file of the parent atomically
+func (client *LocalClient) write(parent *parentFile) error {
+	path, err := client.path(parent.ID)
+	if err != nil {
+		return err
+	}
+	b, err := json.MarshalIndent(parent, "", "  ")
+	if err != nil {
+		return errors.NewAPIError("LOCAL_ENCODE_FAILED", "failed to encode parent", err)
+	}
+
+	f, err := os.CreateTemp(client.dir, "."+parent.ID+"-*.tmp")
+	if err != nil {
+		return errors.NewAPIError("LOCAL_WRITE_FAILED", "failed to create temporary file", err)
+	}
+	tmp := f.Name()
+	if _, err := f.Write(b); err != nil {
+		_ = f.Close()
+		_ = os.Remove(tmp)
+		return errors.NewAPIError("LOCAL_WRITE_FAILED", "failed to write parent", err)
+	}
+	if err := f.Sync(); err != nil {
+		_ = f.Close()
+		_ = os.Remove(tmp)
+		return errors.NewAPIError("LOCAL_WRITE_FAILED", "failed to write parent", err)
+	}
+	if err := f.Close(); err != nil {
+		_ = os.Remove(tmp)
+		return errors.NewAPIError("LOCAL_WRITE_FAILED", "failed to write parent", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		_ = os.Remove(tmp)
+		return errors.NewAPIError("LOCAL_WRITE_FAILED", "failed to replace parent", err)
+	}
+	return nil
+}
+
+func findTask(parent *parentFile, taskID string) (int, error) {
+	for i, task := range parent.Tasks {
+		if task.ID == taskID {
+			return i, nil
+		}
+	}
+	return -1, errors.NewAPIError("LOCAL_NOT_FOUND", "task not found", errors.ErrNotFound)
+}
+
+// newID returns a random ID of 16 hex characters
+func newID() (string, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", errors.NewAPIError("LOCAL_ID_FAILED", "failed to generate id", err)
+	}
+	return hex.EncodeToString(b), nil
+}
+
+func nonNil(labels []string) []string {
+	if labels == nil {
+		return make([]string, 0)
+	}
+	return labels
+}
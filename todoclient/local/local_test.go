@@ -0,0 +1,188 @@
+package local
+
+import (
+	"context"
+	stderrors "errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jo-hoe/todoapi/pkg/errors"
+	"github.com/jo-hoe/todoapi/todoclient"
+	"github.com/jo-hoe/todoapi/todoclient/conformance"
+)
+
+func TestLocalClient_ImplementationTest(t *testing.T) {
+	var _ todoclient.ToDoClient = &LocalClient{}
+}
+
+func TestLocalClient_Conformance(t *testing.T) {
+	conformance.Run(t, newTestClient(t, t.TempDir()), conformance.Options{})
+}
+
+func newTestClient(t *testing.T, dir string) *LocalClient {
+	t.Helper()
+	client, err := NewLocalClient(dir)
+	if err != nil {
+		t.Fatalf("error was not nil but '%v'", err)
+	}
+	return client
+}
+
+func TestLocalClient_CRUD(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	client := newTestClient(t, dir)
+	now := time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)
+	client.now = func() time.Time { return now }
+
+	parent, err := client.CreateParent(ctx, "Work")
+	if err != nil || parent.ID == "" {
+		t.Fatalf("expected parent with id but found %+v and '%v'", parent, err)
+	}
+	created, err := client.CreateTask(ctx, parent.ID, todoclient.ToDoTask{ID: "ignored", Name: "task", Description: "text"})
+	if err != nil {
+		t.Fatalf("error was not nil but '%v'", err)
+	}
+	if created.ID == "" || created.ID == "ignored" || !created.CreationTime.Equal(now) {
+		t.Errorf("expected generated id and creation time but found %+v", created)
+	}
+
+	// a second client on the same directory sees the same data with the same IDs
+	other := newTestClient(t, dir)
+	task, err := other.GetTask(ctx, parent.ID, created.ID)
+	if err != nil || task.Name != "task" || task.Description != "text" {
+		t.Errorf("expected stored task but found %+v and '%v'", task, err)
+	}
+
+	client.now = func() time.Time { return now.Add(time.Hour) }
+	task.Name = "renamed"
+	task.CreationTime = time.Time{}
+	if err := client.UpdateTask(ctx, parent.ID, task); err != nil {
+		t.Fatalf("error was not nil but '%v'", err)
+	}
+	task, _ = client.GetTask(ctx, parent.ID, created.ID)
+	if task.Name != "renamed" || !task.CreationTime.Equal(now) || !task.ModifiedTime.Equal(now.Add(time.Hour)) {
+		t.Errorf("unexpected updated task %+v", task)
+	}
+
+	all, err := client.GetAllTasks(ctx, &todoclient.TaskQuery{Text: "renamed"})
+	if err != nil || len(all) != 1 {
+		t.Errorf("expected 1 task but found %d and '%v'", len(all), err)
+	}
+	parents, err := client.GetAllParents(ctx)
+	if err != nil || len(parents) != 1 || parents[0] != parent {
+		t.Errorf("expected %+v but found %+v and '%v'", parent, parents, err)
+	}
+
+	if err := client.DeleteTask(ctx, parent.ID, created.ID); err != nil {
+		t.Errorf("error was not nil but '%v'", err)
+	}
+	if err := client.DeleteParent(ctx, parent.ID); err != nil {
+		t.Errorf("error was not nil but '%v'", err)
+	}
+	parents, _ = client.GetAllParents(ctx)
+	if len(parents) != 0 {
+		t.Errorf("expected no parents but found %+v", parents)
+	}
+	// only the lock file is kept
+	if entries, _ := os.ReadDir(dir); len(entries) != 1 || entries[0].Name() != lockFile {
+		t.Errorf("expected only the lock file to be left but found %d files", len(entries))
+	}
+}
+
+func TestLocalClient_NotFound(t *testing.T) {
+	ctx := context.Background()
+	client := newTestClient(t, t.TempDir())
+	parent, _ := client.CreateParent(ctx, "Work")
+
+	checks := map[string]error{
+		"get task":          func() error { _, err := client.GetTask(ctx, parent.ID, "missing"); return err }(),
+		"update task":       client.UpdateTask(ctx, parent.ID, todoclient.ToDoTask{ID: "missing", Name: "x"}),
+		"delete task":       client.DeleteTask(ctx, parent.ID, "missing"),
+		"children":          func() error { _, err := client.GetChildrenTasks(ctx, "missing", nil); return err }(),
+		"delete parent":     client.DeleteParent(ctx, "missing"),
+		"path traversal":    func() error { _, err := client.GetChildrenTasks(ctx, "../secret", nil); return err }(),
+		"hidden file":       func() error { _, err := client.GetChildrenTasks(ctx, ".lock", nil); return err }(),
+		"create in nowhere": func() error { _, err := client.CreateTask(ctx, "missing", todoclient.ToDoTask{Name: "x"}); return err }(),
+	}
+	for name, err := range checks {
+		if !stderrors.Is(err, errors.ErrNotFound) {
+			t.Errorf("%s: expected not found error but found '%v'", name, err)
+		}
+	}
+}
+
+func TestLocalClient_CreateParentValidation(t *testing.T) {
+	ctx := context.Background()
+	client := newTestClient(t, t.TempDir())
+
+	var validationErr *todoclient.ValidationError
+	if _, err := client.CreateParent(ctx, "  "); !stderrors.As(err, &validationErr) {
+		t.Errorf("expected validation error for empty name but found '%v'", err)
+	}
+	parents, err := client.GetAllParents(ctx)
+	if err != nil || len(parents) != 0 {
+		t.Errorf("expected no parent to be created but found %v and '%v'", parents, err)
+	}
+}
+
+func TestLocalClient_ConcurrentClients(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	first := newTestClient(t, dir)
+	parent, _ := first.CreateParent(ctx, "Work")
+
+	var wg sync.WaitGroup
+	for _, client := range []*LocalClient{first, newTestClient(t, dir), newTestClient(t, dir)} {
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func(client *LocalClient) {
+				defer wg.Done()
+				if _, err := client.CreateTask(ctx, parent.ID, todoclient.ToDoTask{Name: "task"}); err != nil {
+					t.Errorf("error was not nil but '%v'", err)
+				}
+			}(client)
+		}
+	}
+	wg.Wait()
+
+	tasks, err := first.GetChildrenTasks(ctx, parent.ID, nil)
+	if err != nil || len(tasks) != 30 {
+		t.Errorf("expected 30 tasks but found %d and '%v'", len(tasks), err)
+	}
+}
+
+func TestFileLock(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), lockFile)
+	lock := newFileLock(path)
+	lock.timeout = 50 * time.Millisecond
+
+	release, err := lock.acquire(ctx)
+	if err != nil {
+		t.Fatalf("error was not nil but '%v'", err)
+	}
+	if _, err := lock.acquire(ctx); err == nil {
+		t.Error("expected timeout while the lock is held")
+	}
+	// a held lock is never taken over, however old the lock file is
+	old := time.Now().Add(-time.Hour)
+	_ = os.Chtimes(path, old, old)
+	if _, err := lock.acquire(ctx); err == nil {
+		t.Error("expected timeout while an old lock is held")
+	}
+	release()
+
+	// the operating system releases locks of crashed processes, the file stays behind
+	if _, err := os.Stat(path); err != nil {
+		t.Errorf("expected lock file to be kept but found '%v'", err)
+	}
+	release, err = lock.acquire(ctx)
+	if err != nil {
+		t.Errorf("expected left over lock file to be acquired but found '%v'", err)
+	}
+	release()
+}
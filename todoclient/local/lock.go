@@ -0,0 +1,66 @@
+package local
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/jo-hoe/todoapi/pkg/errors"
+)
+
+// fileLock is an advisory lock between processes based on an exclusive lock of
+// the operating system on a lock file. The lock is released by the operating
+// system if the holding process crashes, so there are no stale locks to detect.
+// The lock file itself is never removed.
+type fileLock struct {
+	path    string
+	timeout time.Duration
+	retry   time.Duration
+}
+
+func newFileLock(path string) *fileLock {
+	return &fileLock{
+		path:    path,
+		timeout: 10 * time.Second,
+		retry:   5 * time.Millisecond,
+	}
+}
+
+// acquire waits for the lock and returns the function releasing it
+func (l *fileLock) acquire(ctx context.Context) (func(), error) {
+	f, err := os.OpenFile(l.path, os.O_CREATE|os.O_RDWR, 0o600)
+	if err != nil {
+		return nil, errors.NewAPIError("LOCAL_LOCK_FAILED", "failed to open lock file", err)
+	}
+
+	deadline := time.Now().Add(l.timeout)
+	for {
+		locked, err := tryLockFile(f)
+		if err != nil {
+			_ = f.Close()
+			return nil, errors.NewAPIError("LOCAL_LOCK_FAILED", "failed to lock file", err)
+		}
+		if locked {
+			// the holder is informational only, e.g. for debugging
+			if err := f.Truncate(0); err == nil {
+				_, _ = f.WriteAt([]byte(fmt.Sprintf("%d\n", os.Getpid())), 0)
+			}
+			return func() {
+				_ = unlockFile(f)
+				_ = f.Close()
+			}, nil
+		}
+		if time.Now().After(deadline) {
+			_ = f.Close()
+			return nil, errors.NewAPIError("LOCAL_LOCK_TIMEOUT", "timed out waiting for lock file "+l.path, nil)
+		}
+
+		select {
+		case <-ctx.Done():
+			_ = f.Close()
+			return nil, errors.NewAPIError("LOCAL_LOCK_FAILED", "canceled while waiting for lock", ctx.Err())
+		case <-time.After(l.retry):
+		}
+	}
+}
@@ -0,0 +1,17 @@
+//go:build !(darwin || dragonfly || freebsd || linux || netbsd || openbsd || windows)
+
+package local
+
+import (
+	"os"
+
+	"github.com/jo-hoe/todoapi/pkg/errors"
+)
+
+func tryLockFile(f *os.File) (bool, error) {
+	return false, errors.NewAPIError("LOCAL_LOCK_UNSUPPORTED", "file locks are not supported on this platform", errors.ErrNotSupported)
+}
+
+func unlockFile(f *os.File) error {
+	return nil
+}
@@ -0,0 +1,186 @@
+package markdown
+
+import (
+	"context"
+	stderrors "errors"
+	"strings"
+
+	"github.com/jo-hoe/todoapi/pkg/errors"
+	"github.com/jo-hoe/todoapi/todoclient"
+)
+
+// ChangeKind is the kind of a change
+type ChangeKind string
+
+const (
+	CreateParent ChangeKind = "create_parent"
+	CreateTask   ChangeKind = "create_task"
+	UpdateTask   ChangeKind = "update_task"
+	DeleteTask   ChangeKind = "delete_task"
+)
+
+// Change is a modification of the edited document compared to its base
+type Change struct {
+	Kind       ChangeKind          `json:"kind"`
+	ParentID   string              `json:"parent_id"`   // Empty for tasks of parents to create
+	ParentName string              `json:"parent_name"` // Used if the parent ID is empty
+	Task       todoclient.ToDoTask `json:"task"`        // Name, due date and completion of the edited item
+}
+
+// Report summarizes the applied changes
+type Report struct {
+	ParentsCreated int `json:"parents_created"`
+	Created        int `json:"created"`
+	Updated        int `json:"updated"`
+	Deleted        int `json:"deleted"`
+}
+
+// Diff compares an edited document with its base, which is either the parsed
+// original document or the current state from Load.
+//
+// Sections are matched by parent ID and then by name. Items are matched to
+// tasks of the base section by ID and then by name, matched items are updated
+// if their name, due date or check mark changed, other items are created. Items of a base section which are missing
+// in the edited section are deleted. Base sections missing in the edited
+// document are ignored, so a document may cover only some parents.
+func Diff(base, edited []Section) []Change {
+	changes := make([]Change, 0)
+	used := make(map[int]bool)
+
+	for _, section := range edited {
+		index := matchSection(base, used, section.Parent)
+		if index < 0 {
+			changes = append(changes, Change{Kind: CreateParent, ParentName: section.Parent.Name})
+			for _, task := range section.Tasks {
+				changes = append(changes, Change{Kind: CreateTask, ParentName: section.Parent.Name, Task: task})
+			}
+			continue
+		}
+		used[index] = true
+		parent := base[index].Parent
+
+		baseTasks := make(map[string]todoclient.ToDoTask, len(base[index].Tasks))
+		for _, task := range base[index].Tasks {
+			baseTasks[task.ID] = task
+		}
+		// IDs referenced by items are claimed first, so that name matches cannot take them
+		kept := make(map[string]bool)
+		for _, task := range section.Tasks {
+			if _, ok := baseTasks[task.ID]; ok {
+				kept[task.ID] = false
+			}
+		}
+		for _, task := range section.Tasks {
+			original, ok := baseTasks[task.ID]
+			if !ok || kept[task.ID] {
+				original, ok = matchByName(base[index].Tasks, kept, task.Name)
+			}
+			if !ok {
+				task.ID = ""
+				changes = append(changes, Change{Kind: CreateTask, ParentID: parent.ID, ParentName: parent.Name, Task: task})
+				continue
+			}
+			task.ID = original.ID
+			kept[task.ID] = true
+			if !sameItem(original, task) {
+				changes = append(changes, Change{Kind: UpdateTask, ParentID: parent.ID, ParentName: parent.Name, Task: task})
+			}
+		}
+		for _, task := range base[index].Tasks {
+			if used, ok := kept[task.ID]; !ok || !used {
+				changes = append(changes, Change{Kind: DeleteTask, ParentID: parent.ID, ParentName: parent.Name, Task: task})
+			}
+		}
+	}
+	return changes
+}
+
+// Apply performs the changes. Updates only modify name, due date and
+// completion, keeping other fields such as descriptions. Due dates keep their
+// time if the date is unchanged.
+func Apply(ctx context.Context, client todoclient.ToDoClient, changes []Change) (Report, error) {
+	var report Report
+	created := make(map[string]string)
+
+	for _, change := range changes {
+		parentID := change.ParentID
+		if parentID == "" {
+			parentID = created[strings.ToLower(change.ParentName)]
+		}
+
+		switch change.Kind {
+		case CreateParent:
+			parent, err := client.CreateParent(ctx, change.ParentName)
+			if err != nil {
+				return report, errors.NewAPIError("MARKDOWN_CREATE_PARENT_FAILED", "failed to create parent", err)
+			}
+			created[strings.ToLower(change.ParentName)] = parent.ID
+			report.ParentsCreated++
+		case CreateTask:
+			if _, err := client.CreateTask(ctx, parentID, change.Task); err != nil {
+				return report, errors.NewAPIError("MARKDOWN_CREATE_TASK_FAILED", "failed to create task", err)
+			}
+			report.Created++
+		case UpdateTask:
+			existing, err := client.GetTask(ctx, parentID, change.Task.ID)
+			if err != nil {
+				return report, errors.NewAPIError("MARKDOWN_GET_TASK_FAILED", "failed to retrieve task", err)
+			}
+			existing.Name = change.Task.Name
+			existing.IsCompleted = change.Task.IsCompleted
+			if !sameDate(existing, change.Task) {
+				existing.DueDate = change.Task.DueDate
+			}
+			if err := client.UpdateTask(ctx, parentID, existing); err != nil {
+				return report, errors.NewAPIError("MARKDOWN_UPDATE_TASK_FAILED", "failed to update task", err)
+			}
+			report.Updated++
+		case DeleteTask:
+			err := client.DeleteTask(ctx, parentID, change.Task.ID)
+			if err != nil && !stderrors.Is(err, errors.ErrNotFound) {
+				return report, errors.NewAPIError("MARKDOWN_DELETE_TASK_FAILED", "failed to delete task", err)
+			}
+			report.Deleted++
+		}
+	}
+	return report, nil
+}
+
+func matchSection(sections []Section, used map[int]bool, parent todoclient.ToDoParent) int {
+	if parent.ID != "" {
+		for i, section := range sections {
+			if !used[i] && section.Parent.ID == parent.ID {
+				return i
+			}
+		}
+	}
+	for i, section := range sections {
+		if !used[i] && strings.EqualFold(singleLine(section.Parent.Name), singleLine(parent.Name)) {
+			return i
+		}
+	}
+	return -1
+}
+
+// matchByName finds an unclaimed task with the name, which makes applying a
+// hand-written document without IDs repeatedly idempotent
+func matchByName(tasks []todoclient.ToDoTask, kept map[string]bool, name string) (todoclient.ToDoTask, bool) {
+	for _, task := range tasks {
+		if _, claimed := kept[task.ID]; !claimed && singleLine(task.Name) == singleLine(name) {
+			return task, true
+		}
+	}
+	return todoclient.ToDoTask{}, false
+}
+
+// sameItem compares the fields represented in a checklist item
+func sameItem(a, b todoclient.ToDoTask) bool {
+	return singleLine(a.Name) == singleLine(b.Name) && a.IsCompleted == b.IsCompleted && sameDate(a, b)
+}
+
+func sameDate(a, b todoclient.ToDoTask) bool {
+	if a.DueDate.IsZero() || b.DueDate.IsZero() {
+		return a.DueDate.IsZero() == b.DueDate.IsZero()
+	}
+	return a.DueDate.Format(dateFormat) == b.DueDate.Format(dateFormat)
+}
@@ -0,0 +1,79 @@
+package markdown
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jo-hoe/todoapi/internal/testutil"
+	"github.com/jo-hoe/todoapi/todoclient"
+)
+
+func TestDiffAndApply(t *testing.T) {
+	ctx := context.Background()
+	client := testutil.NewMockToDoClient()
+	work, _ := client.CreateParent(ctx, "Work")
+	due := time.Date(2024, 3, 5, 14, 0, 0, 0, time.UTC)
+	report, _ := client.CreateTask(ctx, work.ID, todoclient.ToDoTask{Name: "Write report", Description: "kept", DueDate: due})
+	call, _ := client.CreateTask(ctx, work.ID, todoclient.ToDoTask{Name: "Call Bob"})
+	_, _ = client.CreateTask(ctx, work.ID, todoclient.ToDoTask{Name: "Unchanged"})
+
+	base, err := Load(ctx, client)
+	if err != nil {
+		t.Fatalf("error was not nil but '%v'", err)
+	}
+	var buffer bytes.Buffer
+	_ = Render(&buffer, base)
+
+	document := buffer.String()
+	document = strings.Replace(document, "- [ ] Write report", "- [x] Write report", 1)
+	document = strings.Replace(document, "- [ ] Call Bob <!-- id:"+call.ID+" -->\n", "", 1)
+	document += "- [ ] New item (due 2024-04-01)\n\n## Private\n\n- [ ] Groceries\n"
+
+	edited, err := Parse(strings.NewReader(document))
+	if err != nil {
+		t.Fatalf("error was not nil but '%v'", err)
+	}
+	changes := Diff(base, edited)
+	kinds := make([]ChangeKind, 0)
+	for _, change := range changes {
+		kinds = append(kinds, change.Kind)
+	}
+	expected := []ChangeKind{UpdateTask, CreateTask, DeleteTask, CreateParent, CreateTask}
+	if fmt.Sprint(kinds) != fmt.Sprint(expected) {
+		t.Fatalf("expected %v but found %v", expected, kinds)
+	}
+
+	result, err := Apply(ctx, client, changes)
+	if err != nil {
+		t.Fatalf("error was not nil but '%v'", err)
+	}
+	if result != (Report{ParentsCreated: 1, Created: 2, Updated: 1, Deleted: 1}) {
+		t.Errorf("unexpected report %+v", result)
+	}
+
+	updated, _ := client.GetTask(ctx, work.ID, report.ID)
+	if !updated.IsCompleted || updated.Description != "kept" || !updated.DueDate.Equal(due) {
+		t.Errorf("unexpected updated task %+v", updated)
+	}
+	if _, err := client.GetTask(ctx, work.ID, call.ID); err == nil {
+		t.Error("expected removed item to be deleted")
+	}
+	parents, _ := client.GetAllParents(ctx)
+	if len(parents) != 2 {
+		t.Fatalf("expected new parent but found %+v", parents)
+	}
+	if tasks, _ := client.GetChildrenTasks(ctx, parents[1].ID, nil); len(tasks) != 1 {
+		t.Errorf("expected new parent with one task but found %+v", tasks)
+	}
+
+	// applying the same document again changes nothing
+	base, _ = Load(ctx, client)
+	edited, _ = Parse(strings.NewReader(strings.Replace(document, "- [ ] New item (due 2024-04-01)\n", "", 1)))
+	if changes := Diff(base, edited); len(changes) != 1 || changes[0].Kind != DeleteTask {
+		t.Errorf("expected only the deletion of the item without ID but found %+v", changes)
+	}
+}
@@ -0,0 +1,145 @@
+// Package markdown renders tasks as GitHub-style Markdown checklists and
+// applies edited checklists to a provider
+package markdown
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/jo-hoe/todoapi/pkg/errors"
+	"github.com/jo-hoe/todoapi/todoclient"
+)
+
+const dateFormat = "2006-01-02"
+
+var (
+	headingPattern = regexp.MustCompile(`^#{1,6}\s+(.*?)(?:\s+<!--\s*id:(\S+)\s*-->)?\s*$`)
+	itemPattern    = regexp.MustCompile(`^\s*[-*+]\s+\[([ xX])\]\s+(.*?)(?:\s+\(due:?\s*(\d{4}-\d{2}-\d{2})\))?(?:\s+<!--\s*id:(\S+)\s*-->)?\s*$`)
+	dueSuffix      = regexp.MustCompile(`\(due:?\s*\d{4}-\d{2}-\d{2}\)$`)
+
+	// backslash escapes of Markdown, which render as the escaped character
+	unescaper = strings.NewReplacer(`\\`, `\`, `\(`, `(`, `\<`, `<`)
+)
+
+// Section is a parent with its tasks
+type Section struct {
+	Parent todoclient.ToDoParent
+	Tasks  []todoclient.ToDoTask
+}
+
+// Load reads the sections of all parents of the client
+func Load(ctx context.Context, client todoclient.ToDoClient) ([]Section, error) {
+	parents, err := client.GetAllParents(ctx)
+	if err != nil {
+		return nil, errors.NewAPIError("MARKDOWN_GET_PARENTS_FAILED", "failed to retrieve parents", err)
+	}
+	sections := make([]Section, 0, len(parents))
+	for _, parent := range parents {
+		tasks, err := client.GetChildrenTasks(ctx, parent.ID, nil)
+		if err != nil {
+			return nil, errors.NewAPIError("MARKDOWN_GET_TASKS_FAILED", "failed to retrieve tasks", err)
+		}
+		sections = append(sections, Section{Parent: parent, Tasks: tasks})
+	}
+	return sections, nil
+}
+
+// Render writes a heading per section followed by a checklist of its tasks.
+// IDs are kept in HTML comments, which are not visible in rendered Markdown.
+// Names ending like a due date or containing comments are escaped with backslashes.
+//
+//	## Work <!-- id:2203306141 -->
+//
+//	- [ ] Write report (due 2024-03-05) <!-- id:7025 -->
+//	- [x] Call Bob <!-- id:7026 -->
+func Render(w io.Writer, sections []Section) error {
+	var b strings.Builder
+	for i, section := range sections {
+		if i > 0 {
+			b.WriteString("\n")
+		}
+		b.WriteString("## " + escape(section.Parent.Name) + idComment(section.Parent.ID) + "\n\n")
+		for _, task := range section.Tasks {
+			check := " "
+			if task.IsCompleted {
+				check = "x"
+			}
+			b.WriteString("- [" + check + "] " + escape(task.Name))
+			if !task.DueDate.IsZero() {
+				b.WriteString(" (due " + task.DueDate.Format(dateFormat) + ")")
+			}
+			b.WriteString(idComment(task.ID) + "\n")
+		}
+	}
+
+	if _, err := io.WriteString(w, b.String()); err != nil {
+		return errors.NewAPIError("MARKDOWN_WRITE_FAILED", "failed to write markdown", err)
+	}
+	return nil
+}
+
+// Parse reads checklists grouped by headings. Lines which are neither headings
+// nor checklist items are ignored, as are items before the first heading.
+func Parse(r io.Reader) ([]Section, error) {
+	sections := make([]Section, 0)
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if match := headingPattern.FindStringSubmatch(line); match != nil {
+			sections = append(sections, Section{
+				Parent: todoclient.ToDoParent{ID: match[2], Name: unescaper.Replace(match[1])},
+				Tasks:  make([]todoclient.ToDoTask, 0),
+			})
+			continue
+		}
+
+		match := itemPattern.FindStringSubmatch(line)
+		if match == nil || len(sections) == 0 {
+			continue
+		}
+		task := todoclient.ToDoTask{
+			ID:          match[4],
+			Name:        unescaper.Replace(match[2]),
+			IsCompleted: match[1] != " ",
+		}
+		if match[3] != "" {
+			due, err := time.Parse(dateFormat, match[3])
+			if err != nil {
+				return nil, errors.NewAPIError("MARKDOWN_PARSE_FAILED", fmt.Sprintf("invalid due date in '%s'", line), err)
+			}
+			task.DueDate = due
+		}
+		current := &sections[len(sections)-1]
+		current.Tasks = append(current.Tasks, task)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, errors.NewAPIError("MARKDOWN_READ_FAILED", "failed to read markdown", err)
+	}
+	return sections, nil
+}
+
+func idComment(id string) string {
+	if id == "" {
+		return ""
+	}
+	return " <!-- id:" + id + " -->"
+}
+
+func singleLine(value string) string {
+	return strings.Join(strings.Fields(value), " ")
+}
+
+// escape turns the value into a single line which Parse reads as name only
+func escape(value string) string {
+	value = strings.ReplaceAll(singleLine(value), `\`, `\\`)
+	value = strings.ReplaceAll(value, "<!--", `\<!--`)
+	if loc := dueSuffix.FindStringIndex(value); loc != nil {
+		value = value[:loc[0]] + `\` + value[loc[0]:]
+	}
+	return value
+}
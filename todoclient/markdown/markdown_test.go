@@ -0,0 +1,104 @@
+package markdown
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jo-hoe/todoapi/todoclient"
+)
+
+func testSections() []Section {
+	return []Section{
+		{
+			Parent: todoclient.ToDoParent{ID: "p1", Name: "Work"},
+			Tasks: []todoclient.ToDoTask{
+				{ID: "t1", Name: "Write report", DueDate: time.Date(2024, 3, 5, 0, 0, 0, 0, time.UTC)},
+				{ID: "t2", Name: "Call Bob", IsCompleted: true},
+			},
+		},
+		{
+			Parent: todoclient.ToDoParent{ID: "p2", Name: "Home"},
+			Tasks:  []todoclient.ToDoTask{},
+		},
+	}
+}
+
+func TestRender(t *testing.T) {
+	var buffer bytes.Buffer
+	if err := Render(&buffer, testSections()); err != nil {
+		t.Fatalf("error was not nil but '%v'", err)
+	}
+
+	expected := "## Work <!-- id:p1 -->\n\n" +
+		"- [ ] Write report (due 2024-03-05) <!-- id:t1 -->\n" +
+		"- [x] Call Bob <!-- id:t2 -->\n" +
+		"\n## Home <!-- id:p2 -->\n\n"
+	if buffer.String() != expected {
+		t.Errorf("expected '%s' but found '%s'", expected, buffer.String())
+	}
+}
+
+func TestParse_RoundTrip(t *testing.T) {
+	var buffer bytes.Buffer
+	if err := Render(&buffer, testSections()); err != nil {
+		t.Fatalf("error was not nil but '%v'", err)
+	}
+
+	sections, err := Parse(&buffer)
+	if err != nil {
+		t.Fatalf("error was not nil but '%v'", err)
+	}
+	if !reflect.DeepEqual(sections, testSections()) {
+		t.Errorf("expected %+v but found %+v", testSections(), sections)
+	}
+}
+
+func TestParse_RoundTripEscapedNames(t *testing.T) {
+	due := time.Date(2024, 3, 5, 0, 0, 0, 0, time.UTC)
+	sections := []Section{{
+		Parent: todoclient.ToDoParent{ID: "p1", Name: "Work <!-- id:p2 -->"},
+		Tasks: []todoclient.ToDoTask{
+			{ID: "t1", Name: "Move meeting (due 2024-03-05)"},
+			{ID: "t2", Name: "Move meeting (due: 2024-03-05)", DueDate: due},
+			{ID: "t3", Name: "Explain <!-- id:t1 --> comments"},
+			{ID: "t4", Name: `Open C:\temp\(new) and \<!--`, IsCompleted: true},
+			{ID: "t5", Name: "Keep (notes) as they are <b>", DueDate: due},
+		},
+	}}
+
+	var buffer bytes.Buffer
+	if err := Render(&buffer, sections); err != nil {
+		t.Fatalf("error was not nil but '%v'", err)
+	}
+	parsed, err := Parse(strings.NewReader(buffer.String()))
+	if err != nil {
+		t.Fatalf("error was not nil but '%v'", err)
+	}
+
+	if !reflect.DeepEqual(parsed, sections) {
+		t.Errorf("expected %+v but found %+v in\n%s", sections, parsed, buffer.String())
+	}
+}
+
+func TestParse_HandWritten(t *testing.T) {
+	input := "Weekly report\n\n- [ ] before any heading\n\n### Team tasks\n\nSome text.\n\n" +
+		"* [X] Done item\n  - [ ] nested item (due: 2024-01-02)\n- not a checklist item\n"
+
+	sections, err := Parse(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("error was not nil but '%v'", err)
+	}
+	if len(sections) != 1 || sections[0].Parent.Name != "Team tasks" || sections[0].Parent.ID != "" {
+		t.Fatalf("unexpected sections %+v", sections)
+	}
+	tasks := sections[0].Tasks
+	if len(tasks) != 2 || !tasks[0].IsCompleted || tasks[0].Name != "Done item" {
+		t.Fatalf("unexpected tasks %+v", tasks)
+	}
+	if tasks[1].Name != "nested item" || !tasks[1].DueDate.Equal(time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("unexpected second task %+v", tasks[1])
+	}
+}
@@ -8,6 +8,7 @@ import (
 	"net/http"
 	"net/url"
 	"strings"
+	"sync"
 	"time"
 
 	"golang.org/x/oauth2"
@@ -73,63 +74,168 @@ func normalizeDefaultScopes(provided []string) []string {
 	return result
 }
 
+// DefaultEarlyRefresh is the time before expiry at which tokens are refreshed
+const DefaultEarlyRefresh = 5 * time.Minute
+
+// refreshRetryDelay is the minimum time between refresh attempts while the current token is still valid
+const refreshRetryDelay = 30 * time.Second
+
+var (
+	// ErrInvalidGrant means the refresh token was rejected, e.g. because it expired or was
+	// revoked. It cannot be retried, the user has to consent again.
+	ErrInvalidGrant = errors.New("refresh token rejected, consent required")
+	// ErrTransientRefresh means the refresh failed temporarily, e.g. due to a network
+	// or server error, and may succeed when retried.
+	ErrTransientRefresh = errors.New("token refresh failed temporarily")
+)
+
 // AzureV2TokenSource is a custom TokenSource for Azure AD v2.
 // It ensures that the refresh_token grant includes the required scope parameter,
 // and captures/persists refreshed tokens via the provided callback.
+// It is safe for concurrent use and refreshes at most once at a time.
 type AzureV2TokenSource struct {
-	ctx       context.Context
-	conf      *oauth2.Config
-	http      *http.Client
-	current   *oauth2.Token
-	onSave    func(*oauth2.Token)
-	scopesStr string
+	ctx          context.Context
+	conf         *oauth2.Config
+	http         *http.Client
+	onSave       func(*oauth2.Token)
+	scopesStr    string
+	earlyRefresh time.Duration
+	now          func() time.Time
+
+	mu         sync.Mutex
+	current    *oauth2.Token
+	refreshing chan struct{} // closed when the refresh in flight completes
+	lastErr    error         // error of the last refresh
+	retryAt    time.Time     // no refresh before this time while the current token is valid
 }
 
 // NewAzureV2TokenSource constructs an AzureV2TokenSource for the given config and seed token.
 // The save callback will be invoked after every successful refresh.
+// Refreshes use the values of ctx but not its cancellation, as they outlive the caller.
 func NewAzureV2TokenSource(ctx context.Context, conf *oauth2.Config, seed *oauth2.Token, save func(*oauth2.Token)) oauth2.TokenSource {
 	if ctx == nil {
 		ctx = context.Background()
 	}
+	ctx = context.WithoutCancel(ctx)
 	normalized := normalizeDefaultScopes(conf.Scopes)
 	conf.Scopes = normalized
 	scopeStr := strings.Join(normalized, " ")
 
 	return &AzureV2TokenSource{
-		ctx:       ctx,
-		conf:      conf,
-		http:      oauth2.NewClient(ctx, nil), // plain client without auth
-		current:   seed,
-		onSave:    save,
-		scopesStr: scopeStr,
+		ctx:          ctx,
+		conf:         conf,
+		http:         oauth2.NewClient(ctx, nil), // plain client without auth
+		current:      seed,
+		onSave:       save,
+		scopesStr:    scopeStr,
+		earlyRefresh: DefaultEarlyRefresh,
+		now:          time.Now,
 	}
 }
 
+// SetEarlyRefresh sets the time before expiry at which tokens are refreshed, the default
+// is DefaultEarlyRefresh. Callers keep using the current token during an early refresh.
+func (ts *AzureV2TokenSource) SetEarlyRefresh(window time.Duration) {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	ts.earlyRefresh = window
+}
+
 // Token returns a valid token, refreshing when needed using the v2 flow with scopes.
+// Concurrent callers share a single refresh: while a token within the early refresh
+// window is refreshed, the others keep using it, callers without a valid token wait.
+// Failed refreshes wrap ErrInvalidGrant or ErrTransientRefresh if they can be classified.
 func (ts *AzureV2TokenSource) Token() (*oauth2.Token, error) {
-	// If we have a current token and it's valid, return it
-	if ts.current != nil && ts.current.Valid() {
-		return ts.current, nil
-	}
+	ts.mu.Lock()
+	for {
+		current := ts.current
+		if current != nil && current.AccessToken != "" && !ts.expiresSoon(current) {
+			ts.mu.Unlock()
+			return current, nil
+		}
+
+		// Without a refresh token, we cannot refresh. Return current if set, else error.
+		if current == nil || current.RefreshToken == "" {
+			ts.mu.Unlock()
+			if current != nil {
+				return current, nil
+			}
+			return nil, errors.New("no token available and no refresh_token present")
+		}
+
+		if ts.refreshing == nil {
+			if current.Valid() && ts.now().Before(ts.retryAt) {
+				ts.mu.Unlock()
+				return current, nil
+			}
+			break
+		}
+		if current.Valid() {
+			ts.mu.Unlock()
+			return current, nil
+		}
 
-	// If we have a refresh token, perform refresh grant with scope
-	if ts.current != nil && ts.current.RefreshToken != "" {
-		refreshed, err := ts.refreshWithScope(ts.current.RefreshToken)
-		if err != nil {
+		// wait for the refresh in flight instead of redeeming the refresh token twice
+		done := ts.refreshing
+		ts.mu.Unlock()
+		<-done
+		ts.mu.Lock()
+		if ts.lastErr != nil && !ts.current.Valid() {
+			err := ts.lastErr
+			ts.mu.Unlock()
 			return nil, err
 		}
-		ts.current = refreshed
-		if ts.onSave != nil {
-			ts.onSave(refreshed)
+	}
+
+	done := make(chan struct{})
+	ts.refreshing = done
+	previous := ts.current
+	ts.mu.Unlock()
+
+	refreshed, err := ts.refreshWithScope(previous.RefreshToken)
+	// onSave is not called concurrently as there is a single refresh at a time
+	if err == nil && ts.onSave != nil {
+		ts.onSave(refreshed)
+	}
+
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	ts.refreshing = nil
+	ts.lastErr = err
+	close(done)
+	if err != nil {
+		// the current token stays in use until it expires
+		if previous.Valid() {
+			ts.retryAt = ts.now().Add(refreshRetryDelay)
+			if errors.Is(err, ErrInvalidGrant) {
+				ts.retryAt = previous.Expiry
+			}
+			return previous, nil
 		}
-		return refreshed, nil
+		return nil, err
 	}
+	ts.current = refreshed
+	return refreshed, nil
+}
 
-	// Without a refresh token, we cannot refresh. Return current if set, else error.
-	if ts.current != nil {
-		return ts.current, nil
+// expiresSoon reports whether the token expires within the early refresh window
+func (ts *AzureV2TokenSource) expiresSoon(token *oauth2.Token) bool {
+	return !token.Expiry.IsZero() && !ts.now().Add(ts.earlyRefresh).Before(token.Expiry)
+}
+
+// classifyRefreshError returns ErrInvalidGrant or ErrTransientRefresh for a failed
+// refresh, or nil if it is neither, e.g. for a wrong client secret
+func classifyRefreshError(status int, code string) error {
+	switch code {
+	case "invalid_grant", "interaction_required", "consent_required", "login_required":
+		return ErrInvalidGrant
+	case "temporarily_unavailable", "server_error":
+		return ErrTransientRefresh
+	}
+	if status == http.StatusRequestTimeout || status == http.StatusTooManyRequests || status >= http.StatusInternalServerError {
+		return ErrTransientRefresh
 	}
-	return nil, errors.New("no token available and no refresh_token present")
+	return nil
 }
 
 // refreshWithScope performs a refresh_token grant against the v2 token endpoint,
@@ -156,7 +262,7 @@ func (ts *AzureV2TokenSource) refreshWithScope(refreshToken string) (*oauth2.Tok
 
 	resp, err := ts.http.Do(req)
 	if err != nil {
-		return nil, fmt.Errorf("refresh request failed: %w", err)
+		return nil, fmt.Errorf("refresh request failed: %w: %w", ErrTransientRefresh, err)
 	}
 	defer resp.Body.Close()
 
@@ -175,14 +281,18 @@ func (ts *AzureV2TokenSource) refreshWithScope(refreshToken string) (*oauth2.Tok
 			ErrorDescription string `json:"error_description"`
 		}
 		_ = json.NewDecoder(resp.Body).Decode(&terr)
+		err := fmt.Errorf("token refresh failed with status %d", resp.StatusCode)
 		if terr.Error != "" || terr.ErrorDescription != "" {
-			return nil, fmt.Errorf("token refresh failed: %s (%s)", terr.ErrorDescription, terr.Error)
+			err = fmt.Errorf("token refresh failed: %s (%s)", terr.ErrorDescription, terr.Error)
+		}
+		if class := classifyRefreshError(resp.StatusCode, terr.Error); class != nil {
+			return nil, fmt.Errorf("%w: %w", err, class)
 		}
-		return nil, fmt.Errorf("token refresh failed with status %d", resp.StatusCode)
+		return nil, err
 	}
 
 	if err := json.NewDecoder(resp.Body).Decode(&raw); err != nil {
-		return nil, fmt.Errorf("failed to decode refresh response: %w", err)
+		return nil, fmt.Errorf("failed to decode refresh response: %w: %w", ErrTransientRefresh, err)
 	}
 	if raw.AccessToken == "" {
 		return nil, fmt.Errorf("refresh response missing access_token")
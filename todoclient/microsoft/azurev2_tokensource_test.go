@@ -3,11 +3,14 @@ package microsoft
 import (
 	"context"
 	"encoding/json"
+	stderrors "errors"
 	"io"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -225,4 +228,129 @@ func TestAzureV2TokenSource_UsesProvidedScopesNormalized(t *testing.T) {
 	if !strings.HasPrefix(seenScope, want) {
 		t.Errorf("expected scope to start with %q, got %q", want, seenScope)
 	}
-}
\ No newline at end of file
+}
+
+// newRefreshServer serves refresh grants with the handler and counts the requests
+func newRefreshServer(t *testing.T, handler http.HandlerFunc) (*oauth2.Config, *atomic.Int32) {
+	t.Helper()
+	var requests atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		handler(w, r)
+	}))
+	t.Cleanup(srv.Close)
+	return &oauth2.Config{ClientID: "client-id", Endpoint: oauth2.Endpoint{TokenURL: srv.URL}}, &requests
+}
+
+func writeRefreshedToken(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{
+		"access_token":  "new_access",
+		"token_type":    "Bearer",
+		"expires_in":    3600,
+		"refresh_token": "new_refresh",
+	})
+}
+
+func TestAzureV2TokenSource_ConcurrentRefreshOnce(t *testing.T) {
+	conf, requests := newRefreshServer(t, func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(50 * time.Millisecond)
+		writeRefreshedToken(w, r)
+	})
+	var saves atomic.Int32
+	seed := &oauth2.Token{AccessToken: "old_access", RefreshToken: "old_refresh", Expiry: time.Now().Add(-time.Hour)}
+	ts := NewAzureV2TokenSource(context.Background(), conf, seed, func(*oauth2.Token) { saves.Add(1) })
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			token, err := ts.Token()
+			if err != nil || token.AccessToken != "new_access" {
+				t.Errorf("expected refreshed token but found %v and '%v'", token, err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if requests.Load() != 1 || saves.Load() != 1 {
+		t.Errorf("expected a single refresh and save but found %d refreshes and %d saves", requests.Load(), saves.Load())
+	}
+}
+
+func TestAzureV2TokenSource_EarlyRefresh(t *testing.T) {
+	conf, requests := newRefreshServer(t, writeRefreshedToken)
+
+	valid := &oauth2.Token{AccessToken: "valid", RefreshToken: "rt", Expiry: time.Now().Add(time.Hour)}
+	if token, _ := NewAzureV2TokenSource(context.Background(), conf, valid, nil).Token(); token.AccessToken != "valid" || requests.Load() != 0 {
+		t.Errorf("expected valid token to be used but found %v after %d refreshes", token, requests.Load())
+	}
+
+	expiring := &oauth2.Token{AccessToken: "expiring", RefreshToken: "rt", Expiry: time.Now().Add(time.Minute)}
+	if token, _ := NewAzureV2TokenSource(context.Background(), conf, expiring, nil).Token(); token.AccessToken != "new_access" || requests.Load() != 1 {
+		t.Errorf("expected token to be refreshed before expiry but found %v after %d refreshes", token, requests.Load())
+	}
+
+	ts := NewAzureV2TokenSource(context.Background(), conf, expiring, nil).(*AzureV2TokenSource)
+	ts.SetEarlyRefresh(0)
+	if token, _ := ts.Token(); token.AccessToken != "expiring" {
+		t.Errorf("expected token to be used without early refresh but found %v", token)
+	}
+}
+
+func TestAzureV2TokenSource_EarlyRefreshFailureKeepsToken(t *testing.T) {
+	conf, requests := newRefreshServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	})
+	expiring := &oauth2.Token{AccessToken: "expiring", RefreshToken: "rt", Expiry: time.Now().Add(time.Minute)}
+	ts := NewAzureV2TokenSource(context.Background(), conf, expiring, nil)
+
+	for i := 0; i < 3; i++ {
+		token, err := ts.Token()
+		if err != nil || token.AccessToken != "expiring" {
+			t.Errorf("expected current token while it is valid but found %v and '%v'", token, err)
+		}
+	}
+	if requests.Load() != 1 {
+		t.Errorf("expected refresh not to be retried immediately but found %d refreshes", requests.Load())
+	}
+}
+
+func TestAzureV2TokenSource_ClassifiesErrors(t *testing.T) {
+	tests := []struct {
+		name      string
+		status    int
+		code      string
+		transient bool
+		invalid   bool
+	}{
+		{name: "invalid grant", status: http.StatusBadRequest, code: "invalid_grant", invalid: true},
+		{name: "interaction required", status: http.StatusBadRequest, code: "interaction_required", invalid: true},
+		{name: "server error", status: http.StatusServiceUnavailable, transient: true},
+		{name: "throttled", status: http.StatusTooManyRequests, code: "temporarily_unavailable", transient: true},
+		{name: "invalid client", status: http.StatusUnauthorized, code: "invalid_client"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conf, _ := newRefreshServer(t, func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.status)
+				_ = json.NewEncoder(w).Encode(map[string]string{"error": tt.code, "error_description": "AADSTS: " + tt.name})
+			})
+			seed := &oauth2.Token{RefreshToken: "rt"}
+			_, err := NewAzureV2TokenSource(context.Background(), conf, seed, nil).Token()
+			if err == nil {
+				t.Fatal("expected error")
+			}
+			if stderrors.Is(err, ErrTransientRefresh) != tt.transient || stderrors.Is(err, ErrInvalidGrant) != tt.invalid {
+				t.Errorf("unexpected classification of '%v'", err)
+			}
+		})
+	}
+
+	conf, _ := newRefreshServer(t, writeRefreshedToken)
+	conf.Endpoint.TokenURL = "http://127.0.0.1:1"
+	if _, err := NewAzureV2TokenSource(context.Background(), conf, &oauth2.Token{RefreshToken: "rt"}, nil).Token(); !stderrors.Is(err, ErrTransientRefresh) {
+		t.Errorf("expected network error to be transient but found '%v'", err)
+	}
+}
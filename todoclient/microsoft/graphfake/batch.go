@@ -0,0 +1,88 @@
+package graphfake
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+)
+
+type batchRequest struct {
+	ID      string            `json:"id"`
+	Method  string            `json:"method"`
+	URL     string            `json:"url"`
+	Headers map[string]string `json:"headers,omitempty"`
+	Body    json.RawMessage   `json:"body,omitempty"`
+}
+
+type batchResponse struct {
+	ID      string            `json:"id"`
+	Status  int               `json:"status"`
+	Headers map[string]string `json:"headers,omitempty"`
+	Body    json.RawMessage   `json:"body,omitempty"`
+}
+
+// batch handles JSON batching. Requests are executed in order, throttling and faults
+// apply to each of them and are reported in their responses.
+func (s *Server) batch(w http.ResponseWriter, r *http.Request) {
+	var request struct {
+		Requests []batchRequest `json:"requests"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		writeError(w, http.StatusBadRequest, "BadRequest", "Invalid batch payload.")
+		return
+	}
+	if len(request.Requests) == 0 || len(request.Requests) > maxBatchSize {
+		writeError(w, http.StatusBadRequest, "BadRequest", "A batch must contain between 1 and 20 requests.")
+		return
+	}
+	ids := make(map[string]bool, len(request.Requests))
+	for _, item := range request.Requests {
+		if item.ID == "" || ids[item.ID] {
+			writeError(w, http.StatusBadRequest, "BadRequest", "Batch request IDs must be unique and not empty.")
+			return
+		}
+		ids[item.ID] = true
+	}
+
+	responses := make([]batchResponse, 0, len(request.Requests))
+	for _, item := range request.Requests {
+		responses = append(responses, s.batchItem(r, item))
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"responses": responses})
+}
+
+func (s *Server) batchItem(r *http.Request, item batchRequest) batchResponse {
+	path := item.URL
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+	sub, err := http.NewRequestWithContext(r.Context(), strings.ToUpper(item.Method), "/v1.0"+path, bytes.NewReader(item.Body))
+	if err != nil {
+		return batchResponse{ID: item.ID, Status: http.StatusBadRequest}
+	}
+	sub.Host, sub.TLS = r.Host, r.TLS
+	for key, value := range item.Headers {
+		sub.Header.Set(key, value)
+	}
+	if len(sub.URL.Path) > 1 {
+		sub.URL.Path = strings.TrimSuffix(sub.URL.Path, "/")
+	}
+
+	recorder := httptest.NewRecorder()
+	if sub.URL.Path == BatchPath {
+		writeError(recorder, http.StatusBadRequest, "BadRequest", "Batches cannot be nested.")
+	} else {
+		s.serveGraph(recorder, sub)
+	}
+
+	response := batchResponse{ID: item.ID, Status: recorder.Code, Headers: make(map[string]string)}
+	for key := range recorder.Header() {
+		response.Headers[key] = recorder.Header().Get(key)
+	}
+	if body := bytes.TrimSpace(recorder.Body.Bytes()); len(body) > 0 {
+		response.Body = body
+	}
+	return response
+}
@@ -0,0 +1,981 @@
+// Package graphfake provides an in-memory fake of the Microsoft Graph To Do API and
+// of the Microsoft identity platform v2 token endpoint for tests and local development.
+// It supports task lists, tasks and checklist items with @odata.nextLink paging, task
+// delta queries, JSON batching, throttling and OAuth 2.0 refresh token grants.
+//
+//	fake := graphfake.New()
+//	server := httptest.NewServer(fake)
+//	client, err := microsoft.NewMSToDoWithBaseURL(http.DefaultClient, server.URL+graphfake.TodoPath)
+package graphfake
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// TodoPath is the path of the To Do API, the base URL of clients is the server URL followed by it
+	TodoPath = "/v1.0/me/todo/"
+	// BatchPath is the path of the JSON batching endpoint
+	BatchPath = "/v1.0/$batch"
+	// TokenPath is the path of the token endpoint of the common tenant, other tenants are served as well
+	TokenPath = "/common/oauth2/v2.0/token"
+
+	dateTimeLayout = "2006-01-02T15:04:05.0000000"
+	maxBatchSize   = 20
+
+	statusNotStarted = "notStarted"
+	statusCompleted  = "completed"
+)
+
+// DateTimeTimeZone is a point in time with its time zone
+type DateTimeTimeZone struct {
+	DateTime string `json:"dateTime"`
+	TimeZone string `json:"timeZone"`
+}
+
+// ItemBody is the content of a task
+type ItemBody struct {
+	Content     string `json:"content"`
+	ContentType string `json:"contentType"`
+}
+
+// TaskList is a To Do list
+type TaskList struct {
+	ID                string `json:"id"`
+	DisplayName       string `json:"displayName"`
+	IsOwner           bool   `json:"isOwner"`
+	IsShared          bool   `json:"isShared"`
+	WellknownListName string `json:"wellknownListName"`
+}
+
+// ChecklistItem is a step of a task
+type ChecklistItem struct {
+	ID              string     `json:"id"`
+	DisplayName     string     `json:"displayName"`
+	IsChecked       bool       `json:"isChecked"`
+	CreatedDateTime time.Time  `json:"createdDateTime"`
+	CheckedDateTime *time.Time `json:"checkedDateTime,omitempty"`
+}
+
+// Task is a To Do task. Checklist items are only returned with $expand=checklistItems.
+type Task struct {
+	ID                   string            `json:"id"`
+	Title                string            `json:"title"`
+	Body                 ItemBody          `json:"body"`
+	Status               string            `json:"status"`
+	Importance           string            `json:"importance"`
+	IsReminderOn         bool              `json:"isReminderOn"`
+	Categories           []string          `json:"categories"`
+	CreatedDateTime      time.Time         `json:"createdDateTime"`
+	LastModifiedDateTime time.Time         `json:"lastModifiedDateTime"`
+	CompletedDateTime    *DateTimeTimeZone `json:"completedDateTime,omitempty"`
+	DueDateTime          *DateTimeTimeZone `json:"dueDateTime,omitempty"`
+	ChecklistItems       []ChecklistItem   `json:"checklistItems,omitempty"`
+}
+
+type list struct {
+	TaskList
+	tasks   []*task
+	removed []tombstone
+}
+
+type task struct {
+	Task
+	version   int
+	checklist []*ChecklistItem
+}
+
+// tombstone records a deleted task for delta queries
+type tombstone struct {
+	id      string
+	version int
+}
+
+type fault struct {
+	method string
+	path   string
+	status int
+	count  int
+}
+
+// Server is the fake API, it implements http.Handler
+type Server struct {
+	mu       sync.Mutex
+	mux      *http.ServeMux
+	now      func() time.Time
+	nextID   int
+	version  int
+	lists    []*list
+	pageSize int
+	faults   []fault
+	latency  time.Duration
+	limit    int
+	window   time.Duration
+	hits     []time.Time
+	requests []string
+
+	auth          bool
+	clientID      string
+	clientSecret  string
+	tokenLifetime time.Duration
+	refreshTokens map[string]bool
+	accessTokens  map[string]time.Time // expiry per access token
+}
+
+// New creates a fake with the default list "Tasks". Authentication is disabled until EnableAuth is called.
+func New() *Server {
+	s := &Server{
+		mux:           http.NewServeMux(),
+		now:           func() time.Time { return time.Now().UTC() },
+		pageSize:      10,
+		tokenLifetime: time.Hour,
+		refreshTokens: make(map[string]bool),
+		accessTokens:  make(map[string]time.Time),
+	}
+	s.addList("Tasks").WellknownListName = "defaultList"
+
+	todo := strings.TrimSuffix(TodoPath, "/")
+	s.mux.HandleFunc("GET "+todo+"/lists", s.listLists)
+	s.mux.HandleFunc("POST "+todo+"/lists", s.createList)
+	s.mux.HandleFunc("GET "+todo+"/lists/{list}", s.withList(s.getList))
+	s.mux.HandleFunc("PATCH "+todo+"/lists/{list}", s.withList(s.updateList))
+	s.mux.HandleFunc("DELETE "+todo+"/lists/{list}", s.withList(s.deleteList))
+	s.mux.HandleFunc("GET "+todo+"/lists/{list}/tasks", s.withList(s.listTasks))
+	s.mux.HandleFunc("GET "+todo+"/lists/{list}/tasks/delta", s.withList(s.deltaTasks))
+	s.mux.HandleFunc("POST "+todo+"/lists/{list}/tasks", s.withList(s.createTask))
+	s.mux.HandleFunc("GET "+todo+"/lists/{list}/tasks/{task}", s.withTask(s.getTask))
+	s.mux.HandleFunc("PATCH "+todo+"/lists/{list}/tasks/{task}", s.withTask(s.updateTask))
+	s.mux.HandleFunc("DELETE "+todo+"/lists/{list}/tasks/{task}", s.withTask(s.deleteTask))
+	s.mux.HandleFunc("GET "+todo+"/lists/{list}/tasks/{task}/checklistItems", s.withTask(s.listChecklistItems))
+	s.mux.HandleFunc("POST "+todo+"/lists/{list}/tasks/{task}/checklistItems", s.withTask(s.createChecklistItem))
+	s.mux.HandleFunc("GET "+todo+"/lists/{list}/tasks/{task}/checklistItems/{item}", s.withChecklistItem(s.getChecklistItem))
+	s.mux.HandleFunc("PATCH "+todo+"/lists/{list}/tasks/{task}/checklistItems/{item}", s.withChecklistItem(s.updateChecklistItem))
+	s.mux.HandleFunc("DELETE "+todo+"/lists/{list}/tasks/{task}/checklistItems/{item}", s.withChecklistItem(s.deleteChecklistItem))
+	s.mux.HandleFunc("POST "+BatchPath, s.batch)
+	s.mux.HandleFunc("POST /{tenant}/oauth2/v2.0/token", s.token)
+	return s
+}
+
+// ServeHTTP handles a request like Microsoft Graph or the identity platform
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	s.requests = append(s.requests, r.Method+" "+r.URL.RequestURI())
+	latency := s.latency
+	s.mu.Unlock()
+
+	if latency > 0 {
+		select {
+		case <-time.After(latency):
+		case <-r.Context().Done():
+			return
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	// Graph accepts trailing slashes
+	if len(r.URL.Path) > 1 {
+		r.URL.Path = strings.TrimSuffix(r.URL.Path, "/")
+	}
+	if strings.HasSuffix(r.URL.Path, "/oauth2/v2.0/token") {
+		s.mux.ServeHTTP(w, r)
+		return
+	}
+	if !s.authorized(r) {
+		writeError(w, http.StatusUnauthorized, "InvalidAuthenticationToken", "Access token is empty or invalid.")
+		return
+	}
+	s.serveGraph(w, r)
+}
+
+// serveGraph applies throttling and faults before handling a Graph request.
+// The lock must be held.
+func (s *Server) serveGraph(w http.ResponseWriter, r *http.Request) {
+	if retryAfter, limited := s.throttled(); limited {
+		w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+		writeError(w, http.StatusTooManyRequests, "TooManyRequests", "Too many requests, retry after "+strconv.Itoa(retryAfter)+" seconds.")
+		return
+	}
+	if status, failed := s.takeFault(r); failed {
+		writeError(w, status, "ServiceError", http.StatusText(status))
+		return
+	}
+	s.mux.ServeHTTP(w, r)
+}
+
+// Fail makes the next count Graph requests with the method and a path starting with the
+// prefix fail with the status. An empty method matches all methods. Requests within
+// a batch are matched individually.
+func (s *Server) Fail(method, pathPrefix string, status, count int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.faults = append(s.faults, fault{method: method, path: pathPrefix, status: status, count: count})
+}
+
+// SetLatency delays every response by the duration
+func (s *Server) SetLatency(latency time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.latency = latency
+}
+
+// SetThrottle rejects Graph requests exceeding the limit within the window with status
+// 429 and a Retry-After header. A limit of 0 disables throttling.
+func (s *Server) SetThrottle(limit int, window time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.limit, s.window, s.hits = limit, window, nil
+}
+
+// SetPageSize sets the number of items per page if a request has no $top, the default is 10
+func (s *Server) SetPageSize(size int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.pageSize = max(size, 1)
+}
+
+// EnableAuth requires Graph requests to carry an access token issued by the token
+// endpoint to the client. The returned refresh token can be redeemed for one.
+func (s *Server) EnableAuth(clientID, clientSecret string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.auth, s.clientID, s.clientSecret = true, clientID, clientSecret
+	return s.issueRefreshToken()
+}
+
+// SetTokenLifetime sets the lifetime of issued access tokens, the default is one hour
+func (s *Server) SetTokenLifetime(lifetime time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tokenLifetime = lifetime
+}
+
+// ExpireTokens invalidates all issued access tokens, refresh tokens remain valid
+func (s *Server) ExpireTokens() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.accessTokens = make(map[string]time.Time)
+}
+
+// Requests returns method and URI of all received requests, batched requests are not listed
+func (s *Server) Requests() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string{}, s.requests...)
+}
+
+// AddList creates a list and returns its ID
+func (s *Server) AddList(name string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.addList(name).ID
+}
+
+// AddTask creates a task in the list and returns its ID
+func (s *Server) AddTask(listID, title string, categories ...string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	l := s.findList(listID)
+	if l == nil {
+		return ""
+	}
+	t := s.addTask(l, title)
+	t.Categories = append(t.Categories, categories...)
+	return t.ID
+}
+
+// AddChecklistItem adds a checklist item to the task and returns its ID
+func (s *Server) AddChecklistItem(listID, taskID, name string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	l := s.findList(listID)
+	if l == nil {
+		return ""
+	}
+	t := findTask(l, taskID)
+	if t == nil {
+		return ""
+	}
+	return s.addChecklistItem(t, name).ID
+}
+
+func (s *Server) id() string {
+	s.nextID++
+	return fmt.Sprintf("AAMkADIyAAAAABEg%08d", s.nextID)
+}
+
+// touch marks a task as changed for delta queries
+func (s *Server) touch(t *task) {
+	s.version++
+	t.version = s.version
+	t.LastModifiedDateTime = s.now()
+}
+
+func (s *Server) addList(name string) *list {
+	l := &list{TaskList: TaskList{ID: s.id(), DisplayName: name, IsOwner: true, WellknownListName: "none"}}
+	s.lists = append(s.lists, l)
+	return l
+}
+
+func (s *Server) addTask(l *list, title string) *task {
+	t := &task{Task: Task{
+		ID:              s.id(),
+		Title:           title,
+		Body:            ItemBody{ContentType: "text"},
+		Status:          statusNotStarted,
+		Importance:      "normal",
+		Categories:      make([]string, 0),
+		CreatedDateTime: s.now(),
+	}}
+	s.touch(t)
+	l.tasks = append(l.tasks, t)
+	return t
+}
+
+func (s *Server) addChecklistItem(t *task, name string) *ChecklistItem {
+	item := &ChecklistItem{ID: s.id(), DisplayName: name, CreatedDateTime: s.now()}
+	t.checklist = append(t.checklist, item)
+	s.touch(t)
+	return item
+}
+
+func (s *Server) findList(id string) *list {
+	for _, l := range s.lists {
+		if l.ID == id {
+			return l
+		}
+	}
+	return nil
+}
+
+func findTask(l *list, id string) *task {
+	for _, t := range l.tasks {
+		if t.ID == id {
+			return t
+		}
+	}
+	return nil
+}
+
+func (s *Server) throttled() (int, bool) {
+	if s.limit <= 0 {
+		return 0, false
+	}
+	now := s.now()
+	kept := s.hits[:0]
+	for _, hit := range s.hits {
+		if now.Sub(hit) < s.window {
+			kept = append(kept, hit)
+		}
+	}
+	s.hits = kept
+	if len(s.hits) >= s.limit {
+		wait := s.window - now.Sub(s.hits[0])
+		return max(int((wait+time.Second-1)/time.Second), 1), true
+	}
+	s.hits = append(s.hits, now)
+	return 0, false
+}
+
+func (s *Server) takeFault(r *http.Request) (int, bool) {
+	for i, f := range s.faults {
+		if (f.method == "" || f.method == r.Method) && strings.HasPrefix(r.URL.Path, f.path) {
+			s.faults[i].count--
+			if s.faults[i].count <= 0 {
+				s.faults = append(s.faults[:i], s.faults[i+1:]...)
+			}
+			return f.status, true
+		}
+	}
+	return 0, false
+}
+
+func (s *Server) withList(fn func(w http.ResponseWriter, r *http.Request, l *list)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		l := s.findList(r.PathValue("list"))
+		if l == nil {
+			writeError(w, http.StatusNotFound, "ErrorItemNotFound", "The specified object was not found in the store.")
+			return
+		}
+		fn(w, r, l)
+	}
+}
+
+func (s *Server) withTask(fn func(w http.ResponseWriter, r *http.Request, l *list, t *task)) http.HandlerFunc {
+	return s.withList(func(w http.ResponseWriter, r *http.Request, l *list) {
+		t := findTask(l, r.PathValue("task"))
+		if t == nil {
+			writeError(w, http.StatusNotFound, "ErrorItemNotFound", "The specified object was not found in the store.")
+			return
+		}
+		fn(w, r, l, t)
+	})
+}
+
+func (s *Server) withChecklistItem(fn func(w http.ResponseWriter, r *http.Request, t *task, item *ChecklistItem)) http.HandlerFunc {
+	return s.withTask(func(w http.ResponseWriter, r *http.Request, l *list, t *task) {
+		for _, item := range t.checklist {
+			if item.ID == r.PathValue("item") {
+				fn(w, r, t, item)
+				return
+			}
+		}
+		writeError(w, http.StatusNotFound, "ErrorItemNotFound", "The specified object was not found in the store.")
+	})
+}
+
+func (s *Server) listLists(w http.ResponseWriter, r *http.Request) {
+	lists := make([]interface{}, 0, len(s.lists))
+	for _, l := range s.lists {
+		lists = append(lists, l.TaskList)
+	}
+	s.writePage(w, r, lists)
+}
+
+func (s *Server) getList(w http.ResponseWriter, r *http.Request, l *list) {
+	writeJSON(w, http.StatusOK, l.TaskList)
+}
+
+func (s *Server) createList(w http.ResponseWriter, r *http.Request) {
+	var request struct {
+		DisplayName string `json:"displayName"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil || strings.TrimSpace(request.DisplayName) == "" {
+		writeError(w, http.StatusBadRequest, "invalidRequest", "The displayName property is required.")
+		return
+	}
+	writeJSON(w, http.StatusCreated, s.addList(request.DisplayName).TaskList)
+}
+
+func (s *Server) updateList(w http.ResponseWriter, r *http.Request, l *list) {
+	var request struct {
+		DisplayName *string `json:"displayName"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		writeError(w, http.StatusBadRequest, "invalidRequest", "Invalid JSON.")
+		return
+	}
+	if request.DisplayName != nil {
+		l.DisplayName = *request.DisplayName
+	}
+	writeJSON(w, http.StatusOK, l.TaskList)
+}
+
+func (s *Server) deleteList(w http.ResponseWriter, r *http.Request, l *list) {
+	if l.WellknownListName == "defaultList" {
+		writeError(w, http.StatusBadRequest, "invalidRequest", "The default list cannot be deleted.")
+		return
+	}
+	lists := s.lists[:0]
+	for _, other := range s.lists {
+		if other != l {
+			lists = append(lists, other)
+		}
+	}
+	s.lists = lists
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// listTasks supports $filter, $orderby, $top, $skip and $expand=checklistItems
+func (s *Server) listTasks(w http.ResponseWriter, r *http.Request, l *list) {
+	query := r.URL.Query()
+	match, err := parseFilter(query.Get("$filter"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "BadRequest", err.Error())
+		return
+	}
+	tasks := make([]*task, 0, len(l.tasks))
+	for _, t := range l.tasks {
+		if match(&t.Task) {
+			tasks = append(tasks, t)
+		}
+	}
+	if err := orderTasks(tasks, query.Get("$orderby")); err != nil {
+		writeError(w, http.StatusBadRequest, "BadRequest", err.Error())
+		return
+	}
+
+	expand := query.Get("$expand") == "checklistItems"
+	values := make([]interface{}, 0, len(tasks))
+	for _, t := range tasks {
+		values = append(values, t.view(expand))
+	}
+	s.writePage(w, r, values)
+}
+
+// deltaTasks returns changed and removed tasks since the $deltatoken. Without token,
+// all tasks are returned. The last page carries the @odata.deltaLink for the next query.
+func (s *Server) deltaTasks(w http.ResponseWriter, r *http.Request, l *list) {
+	query := r.URL.Query()
+	since := 0
+	if token := query.Get("$deltatoken"); token != "" {
+		var err error
+		if since, err = strconv.Atoi(token); err != nil || since < 0 || since > s.version {
+			writeError(w, http.StatusGone, "SyncStateNotFound", "The sync state is not valid, start a new delta query.")
+			return
+		}
+	}
+
+	type change struct {
+		version int
+		value   interface{}
+	}
+	changes := make([]change, 0)
+	for _, t := range l.tasks {
+		if t.version > since {
+			changes = append(changes, change{t.version, t.view(false)})
+		}
+	}
+	for _, removed := range l.removed {
+		if removed.version > since {
+			changes = append(changes, change{removed.version, map[string]interface{}{
+				"id":       removed.id,
+				"@removed": map[string]string{"reason": "deleted"},
+			}})
+		}
+	}
+	sort.SliceStable(changes, func(i, j int) bool { return changes[i].version < changes[j].version })
+
+	values := make([]interface{}, 0, len(changes))
+	for _, c := range changes {
+		values = append(values, c.value)
+	}
+
+	// the skip token keeps the state of the first page, so that changes during paging are not lost
+	offset, state := 0, s.version
+	if token := query.Get("$skiptoken"); token != "" {
+		parts := strings.SplitN(token, ".", 2)
+		if len(parts) != 2 {
+			writeError(w, http.StatusBadRequest, "BadRequest", "Invalid skip token.")
+			return
+		}
+		offset, _ = strconv.Atoi(parts[0])
+		state, _ = strconv.Atoi(parts[1])
+	}
+	end := min(offset+s.pageSize, len(values))
+	offset = min(offset, end)
+
+	response := map[string]interface{}{"value": values[offset:end]}
+	if end < len(values) {
+		response["@odata.nextLink"] = link(r, map[string]string{"$skiptoken": fmt.Sprintf("%d.%d", end, state)}, "$deltatoken")
+	} else {
+		response["@odata.deltaLink"] = link(r, map[string]string{"$deltatoken": strconv.Itoa(state)}, "$skiptoken")
+	}
+	writeJSON(w, http.StatusOK, response)
+}
+
+func (s *Server) getTask(w http.ResponseWriter, r *http.Request, l *list, t *task) {
+	writeJSON(w, http.StatusOK, t.view(r.URL.Query().Get("$expand") == "checklistItems"))
+}
+
+// taskRequest holds the writable fields of a task, nil fields are not changed.
+// A JSON null due date removes it.
+type taskRequest struct {
+	Title        *string         `json:"title"`
+	Body         *ItemBody       `json:"body"`
+	Status       *string         `json:"status"`
+	Importance   *string         `json:"importance"`
+	IsReminderOn *bool           `json:"isReminderOn"`
+	Categories   *[]string       `json:"categories"`
+	DueDateTime  json.RawMessage `json:"dueDateTime"`
+}
+
+func (s *Server) createTask(w http.ResponseWriter, r *http.Request, l *list) {
+	var request taskRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		writeError(w, http.StatusBadRequest, "invalidRequest", "Invalid JSON.")
+		return
+	}
+	if request.Title == nil || strings.TrimSpace(*request.Title) == "" {
+		writeError(w, http.StatusBadRequest, "invalidRequest", "The title property is required.")
+		return
+	}
+
+	updated := task{Task: Task{Body: ItemBody{ContentType: "text"}, Status: statusNotStarted, Importance: "normal", Categories: make([]string, 0)}}
+	if err := s.apply(&updated, request); err != nil {
+		writeError(w, http.StatusBadRequest, "invalidRequest", err.Error())
+		return
+	}
+	t := s.addTask(l, "")
+	updated.ID, updated.CreatedDateTime, updated.version, updated.LastModifiedDateTime = t.ID, t.CreatedDateTime, t.version, t.LastModifiedDateTime
+	*t = updated
+	writeJSON(w, http.StatusCreated, t.view(false))
+}
+
+func (s *Server) updateTask(w http.ResponseWriter, r *http.Request, l *list, t *task) {
+	var request taskRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		writeError(w, http.StatusBadRequest, "invalidRequest", "Invalid JSON.")
+		return
+	}
+	updated := *t
+	if err := s.apply(&updated, request); err != nil {
+		writeError(w, http.StatusBadRequest, "invalidRequest", err.Error())
+		return
+	}
+	*t = updated
+	s.touch(t)
+	writeJSON(w, http.StatusOK, t.view(false))
+}
+
+// apply sets the fields of the request on the task
+func (s *Server) apply(t *task, request taskRequest) error {
+	if request.Title != nil {
+		t.Title = *request.Title
+	}
+	if request.Body != nil {
+		t.Body = *request.Body
+		if t.Body.ContentType == "" {
+			t.Body.ContentType = "text"
+		}
+	}
+	if request.Importance != nil {
+		switch *request.Importance {
+		case "low", "normal", "high":
+			t.Importance = *request.Importance
+		default:
+			return fmt.Errorf("invalid importance '%s'", *request.Importance)
+		}
+	}
+	if request.IsReminderOn != nil {
+		t.IsReminderOn = *request.IsReminderOn
+	}
+	if request.Categories != nil {
+		t.Categories = append(make([]string, 0, len(*request.Categories)), *request.Categories...)
+	}
+	if request.Status != nil {
+		switch *request.Status {
+		case statusNotStarted, "inProgress", statusCompleted, "waitingOnOthers", "deferred":
+		default:
+			return fmt.Errorf("invalid status '%s'", *request.Status)
+		}
+		if *request.Status == statusCompleted && t.Status != statusCompleted {
+			t.CompletedDateTime = &DateTimeTimeZone{DateTime: s.now().Format(dateTimeLayout), TimeZone: "UTC"}
+		} else if *request.Status != statusCompleted {
+			t.CompletedDateTime = nil
+		}
+		t.Status = *request.Status
+	}
+
+	if len(request.DueDateTime) > 0 {
+		if string(request.DueDateTime) == "null" {
+			t.DueDateTime = nil
+			return nil
+		}
+		var due DateTimeTimeZone
+		if err := json.Unmarshal(request.DueDateTime, &due); err != nil {
+			return fmt.Errorf("invalid dueDateTime")
+		}
+		parsed, err := parseDateTime(due.DateTime)
+		if err != nil {
+			return fmt.Errorf("invalid dueDateTime '%s'", due.DateTime)
+		}
+		// To Do keeps the date of a due date only
+		t.DueDateTime = &DateTimeTimeZone{DateTime: parsed.Format("2006-01-02") + "T00:00:00.0000000", TimeZone: "UTC"}
+	}
+	return nil
+}
+
+func (s *Server) deleteTask(w http.ResponseWriter, r *http.Request, l *list, t *task) {
+	tasks := l.tasks[:0]
+	for _, other := range l.tasks {
+		if other != t {
+			tasks = append(tasks, other)
+		}
+	}
+	l.tasks = tasks
+	s.version++
+	l.removed = append(l.removed, tombstone{id: t.ID, version: s.version})
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) listChecklistItems(w http.ResponseWriter, r *http.Request, l *list, t *task) {
+	values := make([]interface{}, 0, len(t.checklist))
+	for _, item := range t.checklist {
+		values = append(values, *item)
+	}
+	s.writePage(w, r, values)
+}
+
+func (s *Server) createChecklistItem(w http.ResponseWriter, r *http.Request, l *list, t *task) {
+	var request struct {
+		DisplayName string `json:"displayName"`
+		IsChecked   bool   `json:"isChecked"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil || strings.TrimSpace(request.DisplayName) == "" {
+		writeError(w, http.StatusBadRequest, "invalidRequest", "The displayName property is required.")
+		return
+	}
+	item := s.addChecklistItem(t, request.DisplayName)
+	if request.IsChecked {
+		checked := s.now()
+		item.IsChecked, item.CheckedDateTime = true, &checked
+	}
+	writeJSON(w, http.StatusCreated, item)
+}
+
+func (s *Server) getChecklistItem(w http.ResponseWriter, r *http.Request, t *task, item *ChecklistItem) {
+	writeJSON(w, http.StatusOK, item)
+}
+
+func (s *Server) updateChecklistItem(w http.ResponseWriter, r *http.Request, t *task, item *ChecklistItem) {
+	var request struct {
+		DisplayName *string `json:"displayName"`
+		IsChecked   *bool   `json:"isChecked"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		writeError(w, http.StatusBadRequest, "invalidRequest", "Invalid JSON.")
+		return
+	}
+	if request.DisplayName != nil {
+		item.DisplayName = *request.DisplayName
+	}
+	if request.IsChecked != nil && *request.IsChecked != item.IsChecked {
+		item.IsChecked, item.CheckedDateTime = *request.IsChecked, nil
+		if item.IsChecked {
+			checked := s.now()
+			item.CheckedDateTime = &checked
+		}
+	}
+	s.touch(t)
+	writeJSON(w, http.StatusOK, item)
+}
+
+func (s *Server) deleteChecklistItem(w http.ResponseWriter, r *http.Request, t *task, item *ChecklistItem) {
+	checklist := t.checklist[:0]
+	for _, other := range t.checklist {
+		if other != item {
+			checklist = append(checklist, other)
+		}
+	}
+	t.checklist = checklist
+	s.touch(t)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// view returns the task as served by the API
+func (t *task) view(expand bool) Task {
+	result := t.Task
+	result.Categories = append(make([]string, 0, len(t.Categories)), t.Categories...)
+	result.ChecklistItems = nil
+	if expand {
+		result.ChecklistItems = make([]ChecklistItem, 0, len(t.checklist))
+		for _, item := range t.checklist {
+			result.ChecklistItems = append(result.ChecklistItems, *item)
+		}
+	}
+	return result
+}
+
+// writePage writes a page of the values selected by $top and $skip. Further pages
+// are linked by @odata.nextLink.
+func (s *Server) writePage(w http.ResponseWriter, r *http.Request, values []interface{}) {
+	query := r.URL.Query()
+	top := s.pageSize
+	if value := query.Get("$top"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 1 {
+			writeError(w, http.StatusBadRequest, "BadRequest", "Invalid $top value.")
+			return
+		}
+		top = parsed
+	}
+	skip, _ := strconv.Atoi(query.Get("$skip"))
+	start := min(max(skip, 0), len(values))
+	end := min(start+top, len(values))
+
+	response := map[string]interface{}{"value": values[start:end]}
+	if end < len(values) {
+		response["@odata.nextLink"] = link(r, map[string]string{"$top": strconv.Itoa(top), "$skip": strconv.Itoa(end)})
+	}
+	writeJSON(w, http.StatusOK, response)
+}
+
+// link returns the absolute URL of the request with parameters set or removed
+func link(r *http.Request, set map[string]string, remove ...string) string {
+	query := r.URL.Query()
+	for key, value := range set {
+		query.Set(key, value)
+	}
+	for _, key := range remove {
+		query.Del(key)
+	}
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	// Graph escapes spaces with %20
+	return scheme + "://" + r.Host + r.URL.Path + "?" + strings.ReplaceAll(query.Encode(), "+", "%20")
+}
+
+// parseFilter supports the subset of OData used by the microsoft package: comparisons of
+// status, title and dueDateTime/dateTime and categories/any(c:c eq '...') joined by "and"
+func parseFilter(filter string) (func(t *Task) bool, error) {
+	conditions := make([]func(t *Task) bool, 0)
+	if strings.TrimSpace(filter) == "" {
+		return func(t *Task) bool { return true }, nil
+	}
+	for _, clause := range splitAnd(filter) {
+		clause = strings.TrimSpace(clause)
+		if inner, ok := strings.CutPrefix(clause, "categories/any(c:c eq "); ok {
+			value, err := unquote(strings.TrimSuffix(inner, ")"))
+			if err != nil {
+				return nil, err
+			}
+			conditions = append(conditions, func(t *Task) bool {
+				for _, category := range t.Categories {
+					if category == value {
+						return true
+					}
+				}
+				return false
+			})
+			continue
+		}
+
+		fields := strings.SplitN(clause, " ", 3)
+		if len(fields) != 3 {
+			return nil, fmt.Errorf("invalid filter clause '%s'", clause)
+		}
+		value, err := unquote(fields[2])
+		if err != nil {
+			return nil, err
+		}
+		compare, err := comparison(fields[1])
+		if err != nil {
+			return nil, err
+		}
+		switch fields[0] {
+		case "status":
+			conditions = append(conditions, func(t *Task) bool { return compare(strings.Compare(t.Status, value)) })
+		case "title":
+			conditions = append(conditions, func(t *Task) bool { return compare(strings.Compare(t.Title, value)) })
+		case "dueDateTime/dateTime":
+			bound, err := parseDateTime(value)
+			if err != nil {
+				return nil, fmt.Errorf("invalid date time '%s'", value)
+			}
+			conditions = append(conditions, func(t *Task) bool {
+				if t.DueDateTime == nil {
+					return false
+				}
+				due, err := parseDateTime(t.DueDateTime.DateTime)
+				return err == nil && compare(due.Compare(bound))
+			})
+		default:
+			return nil, fmt.Errorf("unsupported filter property '%s'", fields[0])
+		}
+	}
+	return func(t *Task) bool {
+		for _, condition := range conditions {
+			if !condition(t) {
+				return false
+			}
+		}
+		return true
+	}, nil
+}
+
+// splitAnd splits a filter at "and" operators outside of string literals
+func splitAnd(filter string) []string {
+	parts := make([]string, 0)
+	quoted, start := false, 0
+	for i := 0; i < len(filter); i++ {
+		if filter[i] == '\'' {
+			quoted = !quoted
+			continue
+		}
+		if !quoted && strings.HasPrefix(filter[i:], " and ") {
+			parts = append(parts, filter[start:i])
+			start = i + len(" and ")
+			i = start - 1
+		}
+	}
+	return append(parts, filter[start:])
+}
+
+func unquote(value string) (string, error) {
+	value = strings.TrimSpace(value)
+	if len(value) < 2 || value[0] != '\'' || value[len(value)-1] != '\'' {
+		return "", fmt.Errorf("expected string literal but found '%s'", value)
+	}
+	return strings.ReplaceAll(value[1:len(value)-1], "''", "'"), nil
+}
+
+func comparison(operator string) (func(result int) bool, error) {
+	switch operator {
+	case "eq":
+		return func(result int) bool { return result == 0 }, nil
+	case "ne":
+		return func(result int) bool { return result != 0 }, nil
+	case "lt":
+		return func(result int) bool { return result < 0 }, nil
+	case "le":
+		return func(result int) bool { return result <= 0 }, nil
+	case "gt":
+		return func(result int) bool { return result > 0 }, nil
+	case "ge":
+		return func(result int) bool { return result >= 0 }, nil
+	default:
+		return nil, fmt.Errorf("unsupported operator '%s'", operator)
+	}
+}
+
+func orderTasks(tasks []*task, orderBy string) error {
+	if orderBy == "" {
+		return nil
+	}
+	property, direction, _ := strings.Cut(strings.TrimSpace(orderBy), " ")
+	var less func(a, b *task) bool
+	switch property {
+	case "title":
+		less = func(a, b *task) bool { return a.Title < b.Title }
+	case "createdDateTime":
+		less = func(a, b *task) bool { return a.CreatedDateTime.Before(b.CreatedDateTime) }
+	case "dueDateTime/dateTime":
+		less = func(a, b *task) bool {
+			if a.DueDateTime == nil || b.DueDateTime == nil {
+				return a.DueDateTime != nil
+			}
+			return a.DueDateTime.DateTime < b.DueDateTime.DateTime
+		}
+	default:
+		return fmt.Errorf("unsupported order by property '%s'", property)
+	}
+	if direction == "desc" {
+		ascending := less
+		less = func(a, b *task) bool { return ascending(b, a) }
+	}
+	sort.SliceStable(tasks, func(i, j int) bool { return less(tasks[i], tasks[j]) })
+	return nil
+}
+
+// parseDateTime parses the date time formats accepted by Graph
+func parseDateTime(value string) (time.Time, error) {
+	for _, layout := range []string{"2006-01-02T15:04:05.9999999", "2006-01-02T15:04:05Z07:00", "2006-01-02"} {
+		if parsed, err := time.Parse(layout, value); err == nil {
+			return parsed, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid date time '%s'", value)
+}
+
+func writeJSON(w http.ResponseWriter, status int, value interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(value)
+}
+
+// writeError writes an error in the format of Graph
+func writeError(w http.ResponseWriter, status int, code, message string) {
+	writeJSON(w, status, map[string]interface{}{
+		"error": map[string]string{"code": code, "message": message},
+	})
+}
@@ -0,0 +1,389 @@
+package graphfake
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestServer(t *testing.T) (*Server, *httptest.Server) {
+	t.Helper()
+	fake := New()
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+	return fake, server
+}
+
+func do(t *testing.T, server *httptest.Server, method, path, body string) *http.Response {
+	t.Helper()
+	target := path
+	if !strings.HasPrefix(path, "http") {
+		target = server.URL + path
+	}
+	req, err := http.NewRequest(method, target, strings.NewReader(body))
+	if err != nil {
+		t.Fatalf("error was not nil but '%v'", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := server.Client().Do(req)
+	if err != nil {
+		t.Fatalf("error was not nil but '%v'", err)
+	}
+	t.Cleanup(func() { _ = resp.Body.Close() })
+	return resp
+}
+
+func decode(t *testing.T, resp *http.Response, value interface{}) {
+	t.Helper()
+	if err := json.NewDecoder(resp.Body).Decode(value); err != nil {
+		t.Fatalf("error was not nil but '%v'", err)
+	}
+}
+
+type page struct {
+	Value     []json.RawMessage `json:"value"`
+	NextLink  string            `json:"@odata.nextLink"`
+	DeltaLink string            `json:"@odata.deltaLink"`
+}
+
+func TestServer_TaskLifecycle(t *testing.T) {
+	fake, server := newTestServer(t)
+	listID := fake.AddList("Work")
+	tasksPath := TodoPath + "lists/" + listID + "/tasks"
+
+	resp := do(t, server, http.MethodPost, tasksPath, `{"title":"task","dueDateTime":{"dateTime":"2030-01-02T15:04:05","timeZone":"Etc/GMT"}}`)
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("expected status %d but found %d", http.StatusCreated, resp.StatusCode)
+	}
+	var task Task
+	decode(t, resp, &task)
+	if task.DueDateTime == nil || task.DueDateTime.DateTime != "2030-01-02T00:00:00.0000000" {
+		t.Errorf("expected due date without time but found %+v", task.DueDateTime)
+	}
+
+	var updated Task
+	decode(t, do(t, server, http.MethodPatch, tasksPath+"/"+task.ID, `{"status":"completed","dueDateTime":null}`), &updated)
+	if updated.Status != statusCompleted || updated.CompletedDateTime == nil || updated.DueDateTime != nil {
+		t.Errorf("expected completed task without due date but found %+v", updated)
+	}
+
+	if resp := do(t, server, http.MethodPatch, tasksPath+"/"+task.ID, `{"status":"done"}`); resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("expected status %d but found %d", http.StatusBadRequest, resp.StatusCode)
+	}
+	if resp := do(t, server, http.MethodDelete, tasksPath+"/"+task.ID, ""); resp.StatusCode != http.StatusNoContent {
+		t.Errorf("expected status %d but found %d", http.StatusNoContent, resp.StatusCode)
+	}
+	resp = do(t, server, http.MethodGet, tasksPath+"/"+task.ID, "")
+	var graphError struct {
+		Error struct {
+			Code string `json:"code"`
+		} `json:"error"`
+	}
+	decode(t, resp, &graphError)
+	if resp.StatusCode != http.StatusNotFound || graphError.Error.Code != "ErrorItemNotFound" {
+		t.Errorf("expected not found error but found status %d and %+v", resp.StatusCode, graphError)
+	}
+}
+
+func TestServer_DefaultList(t *testing.T) {
+	_, server := newTestServer(t)
+
+	var lists struct {
+		Value []TaskList `json:"value"`
+	}
+	decode(t, do(t, server, http.MethodGet, TodoPath+"lists/", ""), &lists)
+	if len(lists.Value) != 1 || lists.Value[0].WellknownListName != "defaultList" {
+		t.Fatalf("expected default list but found %+v", lists.Value)
+	}
+	if resp := do(t, server, http.MethodDelete, TodoPath+"lists/"+lists.Value[0].ID, ""); resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("expected status %d but found %d", http.StatusBadRequest, resp.StatusCode)
+	}
+}
+
+func TestServer_Paging(t *testing.T) {
+	fake, server := newTestServer(t)
+	fake.SetPageSize(3)
+	listID := fake.AddList("Work")
+	for i := 0; i < 7; i++ {
+		fake.AddTask(listID, fmt.Sprintf("task %d", i))
+	}
+
+	pages, total := 0, 0
+	for link := TodoPath + "lists/" + listID + "/tasks"; link != ""; pages++ {
+		var current page
+		decode(t, do(t, server, http.MethodGet, link, ""), &current)
+		total += len(current.Value)
+		link = current.NextLink
+		if link != "" && !strings.HasPrefix(link, server.URL) {
+			t.Fatalf("expected absolute next link but found '%s'", link)
+		}
+	}
+	if pages != 3 || total != 7 {
+		t.Errorf("expected 7 tasks on 3 pages but found %d on %d", total, pages)
+	}
+}
+
+func TestServer_Filter(t *testing.T) {
+	fake, server := newTestServer(t)
+	listID := fake.AddList("Work")
+	fake.AddTask(listID, "labeled", "work")
+	fake.AddTask(listID, "it's quoted")
+	do(t, server, http.MethodPost, TodoPath+"lists/"+listID+"/tasks", `{"title":"due","status":"completed","dueDateTime":{"dateTime":"2030-01-02T00:00:00","timeZone":"UTC"}}`)
+
+	tests := []struct {
+		name     string
+		query    string
+		status   int
+		expected []string
+	}{
+		{name: "category", query: "$filter=categories/any(c:c eq 'work')", status: http.StatusOK, expected: []string{"labeled"}},
+		{name: "status", query: "$filter=status ne 'completed'&$orderby=title desc", status: http.StatusOK, expected: []string{"labeled", "it's quoted"}},
+		{name: "due date", query: "$filter=dueDateTime/dateTime ge '2030-01-01T00:00:00' and dueDateTime/dateTime lt '2030-01-03T00:00:00'", status: http.StatusOK, expected: []string{"due"}},
+		{name: "escaped quote", query: "$filter=title eq 'it''s quoted'", status: http.StatusOK, expected: []string{"it's quoted"}},
+		{name: "unsupported", query: "$filter=importance eq 'high'", status: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			values, _ := url.ParseQuery(tt.query)
+			resp := do(t, server, http.MethodGet, TodoPath+"lists/"+listID+"/tasks?"+values.Encode(), "")
+			if resp.StatusCode != tt.status {
+				t.Fatalf("expected status %d but found %d", tt.status, resp.StatusCode)
+			}
+			if tt.status != http.StatusOK {
+				return
+			}
+			var tasks struct {
+				Value []Task `json:"value"`
+			}
+			decode(t, resp, &tasks)
+			titles := make([]string, 0)
+			for _, task := range tasks.Value {
+				titles = append(titles, task.Title)
+			}
+			if strings.Join(titles, ",") != strings.Join(tt.expected, ",") {
+				t.Errorf("expected %v but found %v", tt.expected, titles)
+			}
+		})
+	}
+}
+
+func TestServer_ChecklistItems(t *testing.T) {
+	fake, server := newTestServer(t)
+	listID := fake.AddList("Work")
+	taskID := fake.AddTask(listID, "task")
+	fake.AddChecklistItem(listID, taskID, "first")
+	itemsPath := TodoPath + "lists/" + listID + "/tasks/" + taskID + "/checklistItems"
+
+	resp := do(t, server, http.MethodPost, itemsPath, `{"displayName":"second"}`)
+	var item ChecklistItem
+	decode(t, resp, &item)
+	if resp.StatusCode != http.StatusCreated || item.IsChecked {
+		t.Fatalf("expected unchecked item but found status %d and %+v", resp.StatusCode, item)
+	}
+	decode(t, do(t, server, http.MethodPatch, itemsPath+"/"+item.ID, `{"isChecked":true}`), &item)
+	if !item.IsChecked || item.CheckedDateTime == nil {
+		t.Errorf("expected checked item but found %+v", item)
+	}
+
+	var expanded, plain Task
+	decode(t, do(t, server, http.MethodGet, TodoPath+"lists/"+listID+"/tasks/"+taskID+"?$expand=checklistItems", ""), &expanded)
+	if len(expanded.ChecklistItems) != 2 {
+		t.Errorf("expected 2 expanded checklist items but found %+v", expanded.ChecklistItems)
+	}
+	decode(t, do(t, server, http.MethodGet, TodoPath+"lists/"+listID+"/tasks/"+taskID, ""), &plain)
+	if plain.ChecklistItems != nil {
+		t.Errorf("expected checklist items only if expanded but found %+v", plain.ChecklistItems)
+	}
+}
+
+func TestServer_Delta(t *testing.T) {
+	fake, server := newTestServer(t)
+	fake.SetPageSize(2)
+	listID := fake.AddList("Work")
+	first := fake.AddTask(listID, "first")
+	second := fake.AddTask(listID, "second")
+	fake.AddTask(listID, "third")
+	deltaPath := TodoPath + "lists/" + listID + "/tasks/delta"
+
+	collect := func(link string) ([]map[string]interface{}, string) {
+		items := make([]map[string]interface{}, 0)
+		for link != "" {
+			var current struct {
+				Value     []map[string]interface{} `json:"value"`
+				NextLink  string                   `json:"@odata.nextLink"`
+				DeltaLink string                   `json:"@odata.deltaLink"`
+			}
+			decode(t, do(t, server, http.MethodGet, link, ""), &current)
+			items = append(items, current.Value...)
+			if current.DeltaLink != "" {
+				return items, current.DeltaLink
+			}
+			link = current.NextLink
+		}
+		t.Fatal("expected delta link")
+		return nil, ""
+	}
+
+	items, deltaLink := collect(deltaPath)
+	if len(items) != 3 {
+		t.Errorf("expected initial delta to contain all 3 tasks but found %d", len(items))
+	}
+
+	do(t, server, http.MethodPatch, TodoPath+"lists/"+listID+"/tasks/"+first, `{"title":"changed"}`)
+	do(t, server, http.MethodDelete, TodoPath+"lists/"+listID+"/tasks/"+second, "")
+	items, _ = collect(deltaLink)
+	if len(items) != 2 || items[0]["title"] != "changed" || items[1]["@removed"] == nil || items[1]["id"] != second {
+		t.Errorf("expected changed and removed task but found %+v", items)
+	}
+
+	if resp := do(t, server, http.MethodGet, deltaPath+"?$deltatoken=invalid", ""); resp.StatusCode != http.StatusGone {
+		t.Errorf("expected status %d but found %d", http.StatusGone, resp.StatusCode)
+	}
+}
+
+func TestServer_Batch(t *testing.T) {
+	fake, server := newTestServer(t)
+	listID := fake.AddList("Work")
+	fake.Fail(http.MethodGet, TodoPath+"lists/"+listID+"/tasks", http.StatusServiceUnavailable, 1)
+
+	body := `{"requests":[
+		{"id":"1","method":"POST","url":"/me/todo/lists/` + listID + `/tasks","headers":{"Content-Type":"application/json"},"body":{"title":"batched"}},
+		{"id":"2","method":"GET","url":"/me/todo/lists/` + listID + `/tasks"},
+		{"id":"3","method":"GET","url":"/me/todo/lists/` + listID + `/tasks"},
+		{"id":"4","method":"GET","url":"/me/todo/lists/missing"}
+	]}`
+	resp := do(t, server, http.MethodPost, BatchPath, body)
+	var result struct {
+		Responses []batchResponse `json:"responses"`
+	}
+	decode(t, resp, &result)
+
+	expected := []int{http.StatusCreated, http.StatusServiceUnavailable, http.StatusOK, http.StatusNotFound}
+	if len(result.Responses) != len(expected) {
+		t.Fatalf("expected %d responses but found %d", len(expected), len(result.Responses))
+	}
+	for i, status := range expected {
+		if result.Responses[i].Status != status {
+			t.Errorf("response %s: expected status %d but found %d", result.Responses[i].ID, status, result.Responses[i].Status)
+		}
+	}
+	var tasks page
+	_ = json.Unmarshal(result.Responses[2].Body, &tasks)
+	if len(tasks.Value) != 1 {
+		t.Errorf("expected batched task to be listed but found %d tasks", len(tasks.Value))
+	}
+
+	requests := make([]string, 0, maxBatchSize+1)
+	for i := 0; i <= maxBatchSize; i++ {
+		requests = append(requests, fmt.Sprintf(`{"id":"%d","method":"GET","url":"/me/todo/lists"}`, i))
+	}
+	if resp := do(t, server, http.MethodPost, BatchPath, `{"requests":[`+strings.Join(requests, ",")+`]}`); resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("expected status %d for oversized batch but found %d", http.StatusBadRequest, resp.StatusCode)
+	}
+}
+
+func TestServer_Throttle(t *testing.T) {
+	fake, server := newTestServer(t)
+	fake.SetThrottle(2, time.Minute)
+
+	do(t, server, http.MethodGet, TodoPath+"lists", "")
+	do(t, server, http.MethodGet, TodoPath+"lists", "")
+	resp := do(t, server, http.MethodGet, TodoPath+"lists", "")
+	if resp.StatusCode != http.StatusTooManyRequests {
+		t.Errorf("expected status %d but found %d", http.StatusTooManyRequests, resp.StatusCode)
+	}
+	if resp.Header.Get("Retry-After") == "" {
+		t.Error("expected Retry-After header")
+	}
+}
+
+func TestServer_Token(t *testing.T) {
+	fake, server := newTestServer(t)
+	refreshToken := fake.EnableAuth("client", "secret")
+
+	if resp := do(t, server, http.MethodGet, TodoPath+"lists", ""); resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("expected status %d but found %d", http.StatusUnauthorized, resp.StatusCode)
+	}
+
+	redeem := func(form url.Values) *http.Response {
+		resp, err := server.Client().PostForm(server.URL+"/tenant-id/oauth2/v2.0/token", form)
+		if err != nil {
+			t.Fatalf("error was not nil but '%v'", err)
+		}
+		t.Cleanup(func() { _ = resp.Body.Close() })
+		return resp
+	}
+	valid := url.Values{
+		"grant_type":    {"refresh_token"},
+		"refresh_token": {refreshToken},
+		"client_id":     {"client"},
+		"client_secret": {"secret"},
+		"scope":         {"offline_access Tasks.ReadWrite"},
+	}
+
+	tests := []struct {
+		name   string
+		key    string
+		value  string
+		status int
+		code   string
+	}{
+		{name: "missing scope", key: "scope", value: "", status: http.StatusBadRequest, code: "invalid_request"},
+		{name: "wrong secret", key: "client_secret", value: "wrong", status: http.StatusUnauthorized, code: "invalid_client"},
+		{name: "unknown refresh token", key: "refresh_token", value: "unknown", status: http.StatusBadRequest, code: "invalid_grant"},
+		{name: "unsupported grant", key: "grant_type", value: "password", status: http.StatusBadRequest, code: "unsupported_grant_type"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{}
+			for key, values := range valid {
+				form[key] = values
+			}
+			form.Set(tt.key, tt.value)
+			resp := redeem(form)
+			var tokenError struct {
+				Error string `json:"error"`
+			}
+			decode(t, resp, &tokenError)
+			if resp.StatusCode != tt.status || tokenError.Error != tt.code {
+				t.Errorf("expected status %d and '%s' but found %d and '%s'", tt.status, tt.code, resp.StatusCode, tokenError.Error)
+			}
+		})
+	}
+
+	var token struct {
+		AccessToken  string `json:"access_token"`
+		RefreshToken string `json:"refresh_token"`
+		ExpiresIn    int    `json:"expires_in"`
+	}
+	decode(t, redeem(valid), &token)
+	if token.AccessToken == "" || token.RefreshToken == "" || token.ExpiresIn != 3600 {
+		t.Fatalf("expected access and refresh token but found %+v", token)
+	}
+
+	req, _ := http.NewRequest(http.MethodGet, server.URL+TodoPath+"lists", nil)
+	req.Header.Set("Authorization", "Bearer "+token.AccessToken)
+	resp, err := server.Client().Do(req)
+	if err != nil {
+		t.Fatalf("error was not nil but '%v'", err)
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("expected status %d but found %d", http.StatusOK, resp.StatusCode)
+	}
+
+	fake.ExpireTokens()
+	resp, err = server.Client().Do(req)
+	if err != nil {
+		t.Fatalf("error was not nil but '%v'", err)
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("expected expired token to be rejected but found status %d", resp.StatusCode)
+	}
+}
@@ -0,0 +1,83 @@
+package graphfake
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// token implements the refresh token and client credentials grants of the identity
+// platform v2 token endpoint. Refresh tokens stay valid after redemption.
+func (s *Server) token(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		writeTokenError(w, http.StatusBadRequest, "invalid_request", 900144, "The request body must contain a valid form.")
+		return
+	}
+	if s.auth && (r.PostForm.Get("client_id") != s.clientID || r.PostForm.Get("client_secret") != s.clientSecret) {
+		writeTokenError(w, http.StatusUnauthorized, "invalid_client", 7000215, "Invalid client secret provided.")
+		return
+	}
+	scope := r.PostForm.Get("scope")
+	if scope == "" {
+		writeTokenError(w, http.StatusBadRequest, "invalid_request", 90014, "The request body must contain the following parameter: 'scope'.")
+		return
+	}
+
+	response := map[string]interface{}{
+		"token_type":     "Bearer",
+		"scope":          scope,
+		"expires_in":     int(s.tokenLifetime.Seconds()),
+		"ext_expires_in": int(s.tokenLifetime.Seconds()),
+	}
+	switch r.PostForm.Get("grant_type") {
+	case "refresh_token":
+		refreshToken := r.PostForm.Get("refresh_token")
+		if refreshToken == "" || (s.auth && !s.refreshTokens[refreshToken]) {
+			writeTokenError(w, http.StatusBadRequest, "invalid_grant", 70000, "The provided value for the 'refresh_token' is not valid.")
+			return
+		}
+		response["refresh_token"] = s.issueRefreshToken()
+	case "client_credentials":
+	default:
+		writeTokenError(w, http.StatusBadRequest, "unsupported_grant_type", 70003, "The app requested an unsupported grant type.")
+		return
+	}
+	response["access_token"] = s.issueAccessToken()
+	writeJSON(w, http.StatusOK, response)
+}
+
+// authorized reports whether the request carries a valid access token. The lock must be held.
+func (s *Server) authorized(r *http.Request) bool {
+	if !s.auth {
+		return true
+	}
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok {
+		return false
+	}
+	expiry, ok := s.accessTokens[token]
+	return ok && s.now().Before(expiry)
+}
+
+func (s *Server) issueAccessToken() string {
+	s.nextID++
+	token := fmt.Sprintf("access-%d", s.nextID)
+	s.accessTokens[token] = s.now().Add(s.tokenLifetime)
+	return token
+}
+
+func (s *Server) issueRefreshToken() string {
+	s.nextID++
+	token := fmt.Sprintf("refresh-%d", s.nextID)
+	s.refreshTokens[token] = true
+	return token
+}
+
+// writeTokenError writes an error in the format of the identity platform
+func writeTokenError(w http.ResponseWriter, status int, code string, aadsts int, description string) {
+	writeJSON(w, status, map[string]interface{}{
+		"error":             code,
+		"error_description": fmt.Sprintf("AADSTS%d: %s", aadsts, description),
+		"error_codes":       []int{aadsts},
+	})
+}
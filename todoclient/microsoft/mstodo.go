@@ -7,8 +7,8 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
-	"log"
 	"net/http"
+	neturl "net/url"
 	"strings"
 	"time"
 
@@ -18,20 +18,24 @@ import (
 )
 
 const (
-	todoAPIURL = "https://graph.microsoft.com/v1.0/me/todo/"
-	listsURL   = todoAPIURL + "lists/"
-	listURL    = listsURL + "%s/"   // %s = list id
-	tasksURL   = listURL + "tasks/" // %s = list id
-	taskURL    = tasksURL + "%s"    // %s = list id; %s = task id
+	// DefaultBaseURL is the base URL of the Microsoft Graph To Do API
+	DefaultBaseURL = "https://graph.microsoft.com/v1.0/me/todo/"
+	listsURL       = "lists/"
+	listURL        = listsURL + "%s/"   // %s = list id
+	tasksURL       = listURL + "tasks/" // %s = list id
+	taskURL        = tasksURL + "%s"    // %s = list id; %s = task id
 
 	timeDueDateLayout = "2006-01-02T15:04:05.9999999" // this weird MS format is not used consistently in JSON object
 	defaultTimeZone   = "Etc/GMT"
+	statusCompleted   = "completed"
+	statusNotStarted  = "notStarted"
 )
 
 // Client uses REST MS API
 // https://learn.microsoft.com/en-us/graph/api/resources/todo-overview?view=graph-rest-1.0
 type MSToDo struct {
-	client *http.Client
+	client  *http.Client
+	baseURL string
 }
 
 type msTask struct {
@@ -40,7 +44,10 @@ type msTask struct {
 	BodyItem       bodyItem            `json:"bodyItem"`
 	DueDate        time.Time           `json:"dueDateTime"`
 	CreationDate   time.Time           `json:"createdDateTime"`
+	ModifiedDate   time.Time           `json:"lastModifiedDateTime"`
 	CheckListItems []msDisplayNameItem `json:"checklistItems"`
+	IsCompleted    bool                `json:"isCompleted"`
+	Categories     []string            `json:"categories"`
 	ListID         string
 }
 
@@ -71,6 +78,9 @@ type msOdataTask struct {
 	Title            string           `json:"title,omitempty"`
 	Body             *bodyItem        `json:"body,omitempty"`
 	CreationDateTime *time.Time       `json:"createdDateTime,omitempty"`
+	ModifiedDateTime *time.Time       `json:"lastModifiedDateTime,omitempty"`
+	Status           string           `json:"status,omitempty"`
+	Categories       []string         `json:"categories"` // always sent on writes, an empty list removes all categories
 }
 
 type msOdataDateTime struct {
@@ -80,37 +90,76 @@ type msOdataDateTime struct {
 
 func NewMSToDo(client *http.Client) *MSToDo {
 	return &MSToDo{
-		client: client,
+		client:  client,
+		baseURL: DefaultBaseURL,
 	}
 }
 
+// NewMSToDoWithBaseURL creates a client for a Graph compatible To Do API, e.g. the
+// fake in the graphfake package. An empty base URL selects DefaultBaseURL.
+func NewMSToDoWithBaseURL(client *http.Client, baseURL string) (*MSToDo, error) {
+	if baseURL == "" {
+		baseURL = DefaultBaseURL
+	}
+	if !strings.HasSuffix(baseURL, "/") {
+		baseURL += "/"
+	}
+	parsed, err := neturl.Parse(baseURL)
+	if err != nil || parsed.Scheme == "" || parsed.Host == "" {
+		return nil, errors.NewValidationError("base_url", "invalid base URL '"+baseURL+"'")
+	}
+	return &MSToDo{
+		client:  client,
+		baseURL: baseURL,
+	}, nil
+}
+
+// url resolves an API path relative to the base URL
+func (msToDo *MSToDo) url(path string, args ...interface{}) string {
+	return msToDo.baseURL + fmt.Sprintf(path, args...)
+}
+
 // GetAllTasks returns all tasks across all lists
-func (msToDo *MSToDo) GetAllTasks(ctx context.Context) ([]todoclient.ToDoTask, error) {
+func (msToDo *MSToDo) GetAllTasks(ctx context.Context, query *todoclient.TaskQuery) ([]todoclient.ToDoTask, error) {
 	taskLists, err := msToDo.getTaskLists(ctx)
 	if err != nil {
-		log.Printf("failed to get task lists: %v", err)
 		return nil, errors.NewAPIError("MS_GET_LISTS_FAILED", "failed to retrieve task lists", err)
 	}
 
-	result := make([]todoclient.ToDoTask, 0)
+	// the limit applies to all lists together, so it is applied once after merging
+	listQuery := query
+	if query != nil && query.Limit > 0 {
+		unlimited := *query
+		unlimited.Limit = 0
+		listQuery = &unlimited
+	}
 
+	result := make([]todoclient.ToDoTask, 0)
 	for _, taskList := range taskLists.Value {
-		tasksInList, err := msToDo.getChildrenMSTasks(ctx, taskList.ID)
+		tasksInList, err := msToDo.getChildrenMSTasks(ctx, taskList.ID, listQuery)
 		if err != nil {
-			log.Printf("failed to get tasks for list %s: %v", taskList.ID, err)
 			return nil, errors.NewAPIError("MS_GET_TASKS_FAILED", "failed to retrieve tasks for list", err)
 		}
 		msTasks := msToDo.processChildren(taskList.ID, tasksInList)
 		result = append(result, msTasks...)
 	}
 
-	return result, nil
+	return query.Apply(result), nil
 }
 
+// concertToMSToDoTask sets the status only for completed tasks, so that writes keep
+// other statuses like inProgress. New tasks are not started by default.
 func concertToMSToDoTask(input todoclient.ToDoTask) msOdataTask {
 	// create result
 	result := msOdataTask{
-		Title: input.Name,
+		Title:      input.Name,
+		Categories: input.Labels,
+	}
+	if result.Categories == nil {
+		result.Categories = make([]string, 0)
+	}
+	if input.IsCompleted {
+		result.Status = statusCompleted
 	}
 	if !input.DueDate.IsZero() {
 		result.DueDateTime = &msOdataDateTime{
@@ -133,30 +182,48 @@ func (msToDo *MSToDo) UpdateTask(ctx context.Context, parentID string, task todo
 		return err
 	}
 
-	payload := concertToMSToDoTask(task)
+	updated, err := msToDo.patchTask(ctx, parentID, task.ID, concertToMSToDoTask(task))
+	if err != nil {
+		return err
+	}
+	// the status is only sent if the task is reopened
+	if !task.IsCompleted && updated.Status == statusCompleted {
+		if _, err := msToDo.patchTask(ctx, parentID, task.ID, map[string]string{"status": statusNotStarted}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// patchTask updates the fields of the payload and returns the updated task
+func (msToDo *MSToDo) patchTask(ctx context.Context, parentID, taskID string, payload interface{}) (msOdataTask, error) {
+	var updated msOdataTask
 	jsonPayload, err := json.Marshal(payload)
 	if err != nil {
-		return errors.NewAPIError("MS_MARSHAL_FAILED", "failed to marshal task", err)
+		return updated, errors.NewAPIError("MS_MARSHAL_FAILED", "failed to marshal task", err)
 	}
 
-	req, err := http.NewRequestWithContext(ctx, http.MethodPatch, fmt.Sprintf(taskURL, parentID, task.ID), bytes.NewBuffer(jsonPayload))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPatch, msToDo.url(taskURL, parentID, taskID), bytes.NewBuffer(jsonPayload))
 	if err != nil {
-		return errors.NewAPIError("MS_REQUEST_FAILED", "failed to create request", err)
+		return updated, errors.NewAPIError("MS_REQUEST_FAILED", "failed to create request", err)
 	}
 	req.Header.Set("Content-Type", "application/json")
 
 	resp, err := msToDo.client.Do(req)
 	if err != nil {
-		return errors.NewAPIError("MS_HTTP_FAILED", "HTTP request failed", err)
+		return updated, errors.NewAPIError("MS_HTTP_FAILED", "HTTP request failed", err)
 	}
 	defer common.CloseBody(resp.Body)
 
 	if resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(resp.Body)
-		return errors.NewAPIError("MS_UPDATE_FAILED", fmt.Sprintf("update failed with status %d", resp.StatusCode), fmt.Errorf("%s", string(body)))
+		return updated, errors.NewAPIError("MS_UPDATE_FAILED", fmt.Sprintf("update failed with status %d", resp.StatusCode), fmt.Errorf("%s", string(body)))
 	}
 
-	return nil
+	// a response without the task leaves the status unknown
+	_ = json.NewDecoder(resp.Body).Decode(&updated)
+	return updated, nil
 }
 
 func (msToDo *MSToDo) CreateTask(ctx context.Context, parentID string, task todoclient.ToDoTask) (todoclient.ToDoTask, error) {
@@ -172,7 +239,7 @@ func (msToDo *MSToDo) CreateTask(ctx context.Context, parentID string, task todo
 		return result, errors.NewAPIError("MS_MARSHAL_FAILED", "failed to marshal task", err)
 	}
 
-	req, err := http.NewRequestWithContext(ctx, http.MethodPost, fmt.Sprintf(tasksURL, parentID), bytes.NewBuffer(jsonPayload))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, msToDo.url(tasksURL, parentID), bytes.NewBuffer(jsonPayload))
 	if err != nil {
 		return result, errors.NewAPIError("MS_REQUEST_FAILED", "failed to create request", err)
 	}
@@ -188,9 +255,30 @@ func (msToDo *MSToDo) CreateTask(ctx context.Context, parentID string, task todo
 		return result, errors.NewAPIError("MS_DECODE_FAILED", "failed to decode response", err)
 	}
 
-	result.Name = data.Title
-	result.ID = data.ID
-	result.Description = ""
+	return convertToToDoTask(data), nil
+}
+
+// GetTask returns a single task of a list
+func (msToDo *MSToDo) GetTask(ctx context.Context, parentID, taskID string) (todoclient.ToDoTask, error) {
+	var data msOdataTask
+	if err := msToDo.getData(ctx, msToDo.url(taskURL, parentID, taskID), &data); err != nil {
+		return todoclient.ToDoTask{}, errors.NewAPIError("MS_GET_TASK_FAILED", "failed to retrieve task", err)
+	}
+
+	return convertToToDoTask(data), nil
+}
+
+// convertToToDoTask converts a single OData task to a generic ToDoTask
+func convertToToDoTask(data msOdataTask) todoclient.ToDoTask {
+	result := todoclient.ToDoTask{
+		ID:          data.ID,
+		Name:        data.Title,
+		IsCompleted: data.Status == statusCompleted,
+		Labels:      data.Categories,
+	}
+	if result.Labels == nil {
+		result.Labels = make([]string, 0)
+	}
 	if data.Body != nil {
 		result.Description = data.Body.Content
 	}
@@ -198,6 +286,9 @@ func (msToDo *MSToDo) CreateTask(ctx context.Context, parentID string, task todo
 	if data.CreationDateTime != nil {
 		result.CreationTime = *data.CreationDateTime
 	}
+	if data.ModifiedDateTime != nil {
+		result.ModifiedTime = *data.ModifiedDateTime
+	}
 
 	if data.DueDateTime != nil {
 		if dueDate, err := time.Parse(timeDueDateLayout, data.DueDateTime.DateTime); err == nil {
@@ -205,11 +296,11 @@ func (msToDo *MSToDo) CreateTask(ctx context.Context, parentID string, task todo
 		}
 	}
 
-	return result, nil
+	return result
 }
 
 func (msToDo *MSToDo) DeleteTask(ctx context.Context, parentID, taskID string) error {
-	return msToDo.deleteObject(ctx, fmt.Sprintf(taskURL, parentID, taskID))
+	return msToDo.deleteObject(ctx, msToDo.url(taskURL, parentID, taskID))
 }
 
 func (msToDo *MSToDo) deleteObject(ctx context.Context, url string) error {
@@ -249,7 +340,7 @@ func (msToDo *MSToDo) CreateParent(ctx context.Context, parentName string) (todo
 		return result, errors.NewAPIError("MS_MARSHAL_FAILED", "failed to marshal parent", err)
 	}
 
-	req, err := http.NewRequestWithContext(ctx, http.MethodPost, listsURL, bytes.NewBuffer(jsonPayload))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, msToDo.url(listsURL), bytes.NewBuffer(jsonPayload))
 	if err != nil {
 		return result, errors.NewAPIError("MS_REQUEST_FAILED", "failed to create request", err)
 	}
@@ -272,7 +363,18 @@ func (msToDo *MSToDo) CreateParent(ctx context.Context, parentName string) (todo
 }
 
 func (msToDo *MSToDo) DeleteParent(ctx context.Context, parentID string) error {
-	return msToDo.deleteObject(ctx, fmt.Sprintf(listURL, parentID))
+	return msToDo.deleteObject(ctx, msToDo.url(listURL, parentID))
+}
+
+// Capabilities reports the features supported by the Microsoft To Do adapter.
+// Microsoft To Do only keeps the date of a due date.
+func (msToDo *MSToDo) Capabilities() todoclient.Capabilities {
+	return todoclient.Capabilities{
+		Labels:       true,
+		DueDates:     true,
+		CreateParent: true,
+		DeleteParent: true,
+	}
 }
 
 func (msToDo *MSToDo) GetAllParents(ctx context.Context) ([]todoclient.ToDoParent, error) {
@@ -280,7 +382,6 @@ func (msToDo *MSToDo) GetAllParents(ctx context.Context) ([]todoclient.ToDoParen
 
 	lists, err := msToDo.getTaskLists(ctx)
 	if err != nil {
-		log.Printf("failed to get task lists: %v", err)
 		return result, errors.NewAPIError("MS_GET_LISTS_FAILED", "failed to retrieve task lists", err)
 	}
 
@@ -294,13 +395,12 @@ func (msToDo *MSToDo) GetAllParents(ctx context.Context) ([]todoclient.ToDoParen
 	return result, nil
 }
 
-func (msToDo *MSToDo) GetChildrenTasks(ctx context.Context, parentID string) ([]todoclient.ToDoTask, error) {
-	childrenTasks, err := msToDo.getChildrenMSTasks(ctx, parentID)
+func (msToDo *MSToDo) GetChildrenTasks(ctx context.Context, parentID string, query *todoclient.TaskQuery) ([]todoclient.ToDoTask, error) {
+	childrenTasks, err := msToDo.getChildrenMSTasks(ctx, parentID, query)
 	if err != nil {
-		log.Printf("failed to get children tasks for parent %s: %v", parentID, err)
 		return nil, errors.NewAPIError("MS_GET_CHILDREN_FAILED", "failed to retrieve children tasks", err)
 	}
-	return msToDo.processChildren(parentID, childrenTasks), nil
+	return query.Apply(msToDo.processChildren(parentID, childrenTasks)), nil
 }
 
 // Converts items to OData items to generic ToDoTasks and updates the internal cache
@@ -316,15 +416,22 @@ func (msToDo *MSToDo) processChildren(listId string, tasksInList []msTask) (task
 			Description:  task.BodyItem.Content,
 			DueDate:      task.DueDate,
 			CreationTime: task.CreationDate,
+			ModifiedTime: task.ModifiedDate,
+			IsCompleted:  task.IsCompleted,
+			Labels:       task.Categories,
 		})
 	}
 
 	return result
 }
 
-func (msToDo *MSToDo) getChildrenMSTasks(ctx context.Context, parentID string) ([]msTask, error) {
+func (msToDo *MSToDo) getChildrenMSTasks(ctx context.Context, parentID string, query *todoclient.TaskQuery) ([]msTask, error) {
 	result := []msTask{}
-	url := fmt.Sprintf(tasksURL, parentID)
+	url := msToDo.url(tasksURL, parentID)
+	odataQuery, limit := buildODataQuery(query)
+	if odataQuery != "" {
+		url = url + "?" + odataQuery
+	}
 
 	for url != "" {
 		tasks := msOdataTasks{}
@@ -344,12 +451,20 @@ func (msToDo *MSToDo) getChildrenMSTasks(ctx context.Context, parentID string) (
 				ID:          task.ID,
 				DisplayName: task.Title,
 				DueDate:     dueDate,
+				IsCompleted: task.Status == statusCompleted,
+				Categories:  task.Categories,
 				ListID:      parentID,
 			}
+			if item.Categories == nil {
+				item.Categories = make([]string, 0)
+			}
 
 			if task.CreationDateTime != nil {
 				item.CreationDate = *task.CreationDateTime
 			}
+			if task.ModifiedDateTime != nil {
+				item.ModifiedDate = *task.ModifiedDateTime
+			}
 
 			if task.Body != nil && task.Body.Content != "" {
 				item.BodyItem.Content = task.Body.Content
@@ -359,13 +474,75 @@ func (msToDo *MSToDo) getChildrenMSTasks(ctx context.Context, parentID string) (
 			result = append(result, item)
 		}
 		url = tasks.OdataNextlink
+		if limit > 0 && len(result) >= limit {
+			break
+		}
 	}
 	return result, nil
 }
 
+// buildODataQuery translates a query into OData $filter, $orderby and $top parameters.
+// Text search cannot be expressed as the description is part of the body, hence
+// it is applied client-side. The returned limit is only set if the whole query
+// is evaluated server-side so that paging may stop early.
+func buildODataQuery(query *todoclient.TaskQuery) (string, int) {
+	if query == nil {
+		return "", 0
+	}
+
+	filters := make([]string, 0)
+	if query.Completed != nil {
+		if *query.Completed {
+			filters = append(filters, "status eq '"+statusCompleted+"'")
+		} else {
+			filters = append(filters, "status ne '"+statusCompleted+"'")
+		}
+	}
+	if !query.DueAfter.IsZero() {
+		filters = append(filters, "dueDateTime/dateTime ge '"+query.DueAfter.UTC().Format(timeDueDateLayout)+"'")
+	}
+	if !query.DueBefore.IsZero() {
+		filters = append(filters, "dueDateTime/dateTime lt '"+query.DueBefore.UTC().Format(timeDueDateLayout)+"'")
+	}
+	for _, label := range query.Labels {
+		filters = append(filters, "categories/any(c:c eq '"+escapeODataString(label)+"')")
+	}
+
+	params := make([]string, 0)
+	if len(filters) > 0 {
+		params = append(params, "$filter="+escapeODataParam(strings.Join(filters, " and ")))
+	}
+	switch query.OrderBy {
+	case todoclient.OrderByDueDate:
+		params = append(params, "$orderby="+escapeODataParam("dueDateTime/dateTime"))
+	case todoclient.OrderByCreationTime:
+		params = append(params, "$orderby=createdDateTime")
+	case todoclient.OrderByName:
+		params = append(params, "$orderby=title")
+	}
+
+	limit := 0
+	if query.Limit > 0 && query.Text == "" {
+		limit = query.Limit
+		params = append(params, fmt.Sprintf("$top=%d", limit))
+	}
+
+	return strings.Join(params, "&"), limit
+}
+
+// escapeODataString escapes a string literal for usage within single quotes
+func escapeODataString(value string) string {
+	return strings.ReplaceAll(value, "'", "''")
+}
+
+// escapeODataParam escapes a query parameter value, using %20 for spaces as expected by Graph
+func escapeODataParam(value string) string {
+	return strings.ReplaceAll(neturl.QueryEscape(value), "+", "%20")
+}
+
 func (msToDo *MSToDo) getTaskLists(ctx context.Context) (*msOdataLists, error) {
 	lists := msOdataLists{}
-	url := listsURL
+	url := msToDo.url(listsURL)
 	for url != "" {
 		tmpList := msOdataLists{}
 		if err := msToDo.getData(ctx, url, &tmpList); err != nil {
@@ -389,6 +566,9 @@ func (msToDo *MSToDo) getData(ctx context.Context, url string, data interface{})
 	}
 	defer common.CloseBody(resp.Body)
 
+	if resp.StatusCode == http.StatusNotFound {
+		return errors.NewAPIError("MS_NOT_FOUND", "resource not found", errors.ErrNotFound)
+	}
 	if resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(resp.Body)
 		return errors.NewAPIError("MS_GET_FAILED", fmt.Sprintf("GET failed with status %d", resp.StatusCode), fmt.Errorf("%s", string(body)))
@@ -0,0 +1,88 @@
+package microsoft
+
+import (
+	"context"
+	"net/http"
+	"path/filepath"
+	"regexp"
+	"testing"
+	"time"
+
+	customhttp "github.com/jo-hoe/todoapi/internal/http"
+	"github.com/jo-hoe/todoapi/todoclient"
+)
+
+// newCassetteClient replays the interactions in testdata/cassettes. With RECORD_CASSETTES=true
+// they are recorded against the live API using the credentials of the integration tests.
+func newCassetteClient(t *testing.T, name string) *MSToDo {
+	t.Helper()
+	mode := customhttp.CassetteModeFromEnv()
+	var transport http.RoundTripper
+	if mode == customhttp.ModeRecord {
+		transport = createClient(t).client.Transport
+	}
+
+	// the OData context contains the user principal name
+	scrubbers := append(customhttp.DefaultScrubbers(), customhttp.ScrubRegexp(regexp.MustCompile(`users\('[^']*'\)`), "users('REDACTED')"))
+	cassette, err := customhttp.NewCassetteTransport(filepath.Join("testdata", "cassettes", name+".json"), mode, transport, scrubbers...)
+	if err != nil {
+		t.Fatalf("error was not nil but '%v'", err)
+	}
+	t.Cleanup(func() {
+		if err := cassette.Save(); err != nil {
+			t.Errorf("error was not nil but '%v'", err)
+		}
+		if mode == customhttp.ModeReplay && cassette.Unused() > 0 {
+			t.Errorf("expected all interactions to be replayed but %d were not", cassette.Unused())
+		}
+	})
+	return NewMSToDo(&http.Client{Transport: cassette})
+}
+
+func TestMSToDo_Cassette_Converters(t *testing.T) {
+	client := newCassetteClient(t, "converters")
+	ctx := context.Background()
+
+	parent, err := client.CreateParent(ctx, "todoapi cassette")
+	if err != nil {
+		t.Fatalf("error was not nil but '%v'", err)
+	}
+	dueDate := time.Date(2030, 1, 2, 0, 0, 0, 0, time.UTC)
+	created, err := client.CreateTask(ctx, parent.ID, todoclient.ToDoTask{Name: "Buy milk", Description: "2 litres", DueDate: dueDate, Labels: []string{"shopping"}})
+	if err != nil {
+		t.Fatalf("error was not nil but '%v'", err)
+	}
+	completed, err := client.CreateTask(ctx, parent.ID, todoclient.ToDoTask{Name: "Pay rent", IsCompleted: true})
+	if err != nil {
+		t.Fatalf("error was not nil but '%v'", err)
+	}
+
+	tasks, err := client.GetChildrenTasks(ctx, parent.ID, nil)
+	if err != nil {
+		t.Fatalf("error was not nil but '%v'", err)
+	}
+	if len(tasks) != 2 {
+		t.Fatalf("expected 2 tasks but found %+v", tasks)
+	}
+	task, err := client.GetTask(ctx, parent.ID, created.ID)
+	if err != nil {
+		t.Fatalf("error was not nil but '%v'", err)
+	}
+
+	for _, converted := range []todoclient.ToDoTask{created, tasks[0], task} {
+		if converted.ID != created.ID || converted.Name != "Buy milk" || converted.Description != "2 litres" || !converted.DueDate.Equal(dueDate) ||
+			len(converted.Labels) != 1 || converted.Labels[0] != "shopping" || converted.IsCompleted ||
+			converted.CreationTime.IsZero() || converted.ModifiedTime.IsZero() {
+			t.Errorf("unexpected conversion %+v", converted)
+		}
+	}
+	for _, converted := range []todoclient.ToDoTask{completed, tasks[1]} {
+		if converted.Name != "Pay rent" || !converted.IsCompleted || !converted.DueDate.IsZero() || converted.Labels == nil {
+			t.Errorf("unexpected conversion %+v", converted)
+		}
+	}
+
+	if err := client.DeleteParent(ctx, parent.ID); err != nil {
+		t.Errorf("error was not nil but '%v'", err)
+	}
+}
@@ -2,17 +2,29 @@ package microsoft
 
 import (
 	"context"
-	"log"
+	"encoding/json"
 	"net/http"
+	"net/url"
+	"os"
+	"strings"
 	"time"
 
+	"github.com/jo-hoe/todoapi/pkg/errors"
 	"golang.org/x/oauth2"
 )
 
+// DefaultAuthority is the Microsoft identity platform of the global cloud
+const DefaultAuthority = "https://login.microsoftonline.com"
+
+// DefaultTenant accepts work, school and personal Microsoft accounts
+const DefaultTenant = "common"
+
 type MSClientConfig struct {
 	ClientCredentials MSClientCredentials
 	Token             MsOAuthToken
-	Scopes           []string
+	Scopes            []string
+	Tenant            string // tenant ID or domain, defaults to DefaultTenant
+	Authority         string // defaults to DefaultAuthority, e.g. for national clouds
 }
 
 type MSClientCredentials struct {
@@ -27,35 +39,81 @@ type MsOAuthToken struct {
 	ExtExpiresIn int    `json:"ext_expires_in"`
 	AccessToken  string `json:"access_token"`
 	RefreshToken string `json:"refresh_token"`
+	// ExpiresAt is the expiry of the access token as written by FileTokenSaver.
+	// If it is unknown, the token is refreshed on first use.
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// LoadToken reads the token of a file written by FileTokenSaver or the credential generation
+func LoadToken(path string) (MsOAuthToken, error) {
+	var token MsOAuthToken
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return token, errors.NewAPIError("MS_TOKEN_READ_FAILED", "failed to read token file", err)
+	}
+	if err := json.Unmarshal(b, &token); err != nil {
+		return token, errors.NewAPIError("MS_TOKEN_READ_FAILED", "failed to decode token file", err)
+	}
+	return token, nil
+}
+
+// Endpoint returns the OAuth2 v2 endpoint of the tenant at the authority.
+// Empty values default to DefaultAuthority and DefaultTenant.
+func Endpoint(authority, tenant string) oauth2.Endpoint {
+	if authority == "" {
+		authority = DefaultAuthority
+	}
+	if tenant == "" {
+		tenant = DefaultTenant
+	}
+	base := strings.TrimSuffix(authority, "/") + "/" + url.PathEscape(tenant) + "/oauth2/v2.0"
+	return oauth2.Endpoint{
+		AuthURL:  base + "/authorize",
+		TokenURL: base + "/token",
+	}
 }
 
+// NewHTTPClient creates an HTTP client authorizing requests with the token of
+// the configuration. No request is made here, the token is refreshed on first use
+// if it expired or its expiry is unknown. Every refreshed token is passed to
+// saveToken, which may be nil. Canceling ctx does not stop later refreshes.
+func NewHTTPClient(ctx context.Context, config MSClientConfig, saveToken func(token *oauth2.Token)) (*http.Client, error) {
+	if config.Token.RefreshToken == "" && config.Token.AccessToken == "" {
+		return nil, errors.NewValidationError("token", "access or refresh token required")
+	}
+	return oauth2.NewClient(ctx, newTokenSource(ctx, config, saveToken)), nil
+}
+
+// NewClient creates an HTTP client like NewHTTPClient using the background context.
+// Authorization errors are returned by the requests of the client.
+//
+// Deprecated: Use NewHTTPClient.
 func NewClient(config MSClientConfig, saveToken func(token *oauth2.Token)) *http.Client {
+	ctx := context.Background()
+	return oauth2.NewClient(ctx, newTokenSource(ctx, config, saveToken))
+}
+
+func newTokenSource(ctx context.Context, config MSClientConfig, saveToken func(token *oauth2.Token)) oauth2.TokenSource {
 	var oauthConfig = oauth2.Config{
 		ClientID:     config.ClientCredentials.ClientId,
 		ClientSecret: config.ClientCredentials.ClientSecret,
 		// Use provided scopes if any; normalization and defaulting will be handled by the AzureV2TokenSource
 		Scopes:      config.Scopes,
 		RedirectURL: "https://localhost/login/authorized",
-		Endpoint: oauth2.Endpoint{
-			AuthURL:  "https://login.microsoftonline.com/common/oauth2/v2.0/authorize",
-			TokenURL: "https://login.microsoftonline.com/common/oauth2/v2.0/token",
-		},
+		Endpoint:    Endpoint(config.Authority, config.Tenant),
 	}
 
 	var token = oauth2.Token{
 		AccessToken:  config.Token.AccessToken,
 		TokenType:    config.Token.TokenType,
 		RefreshToken: config.Token.RefreshToken,
-		// Force immediate refresh on startup to ensure we persist a fresh token and correct scopes
-		Expiry: time.Now().Add(-1 * time.Hour),
+		Expiry:       config.Token.ExpiresAt,
 	}
-	ctx := context.Background()
-
-	// Use custom Azure AD v2 TokenSource to ensure scope is included on refresh and tokens are persisted
-	ts := NewAzureV2TokenSource(ctx, &oauthConfig, &token, saveToken)
-	if _, err := ts.Token(); err != nil {
-		log.Fatalln(err)
+	// Without a known expiry the access token may be stale, refresh it on first use
+	if token.Expiry.IsZero() && token.RefreshToken != "" {
+		token.Expiry = time.Unix(1, 0)
 	}
 
-	return oauth2.NewClient(ctx, ts)
+	// Use custom Azure AD v2 TokenSource to ensure scope is included on refresh and tokens are persisted
+	return NewAzureV2TokenSource(ctx, &oauthConfig, &token, saveToken)
 }
@@ -0,0 +1,167 @@
+package microsoft
+
+import (
+	"context"
+	stderrors "errors"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/jo-hoe/todoapi/pkg/errors"
+	"github.com/jo-hoe/todoapi/todoclient/microsoft/graphfake"
+	"golang.org/x/oauth2"
+)
+
+func newFakeAuthConfig(t *testing.T) (MSClientConfig, *graphfake.Server, string) {
+	t.Helper()
+	fake := graphfake.New()
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+	refreshToken := fake.EnableAuth("client", "secret")
+
+	config := MSClientConfig{
+		ClientCredentials: MSClientCredentials{ClientId: "client", ClientSecret: "secret"},
+		Token:             MsOAuthToken{RefreshToken: refreshToken},
+		Tenant:            "contoso.onmicrosoft.com",
+		Authority:         server.URL,
+	}
+	return config, fake, server.URL + graphfake.TodoPath
+}
+
+func TestEndpoint(t *testing.T) {
+	endpoint := Endpoint("", "")
+	if endpoint.TokenURL != "https://login.microsoftonline.com/common/oauth2/v2.0/token" {
+		t.Errorf("expected default token URL but found '%s'", endpoint.TokenURL)
+	}
+	endpoint = Endpoint("https://login.microsoftonline.us/", "tenant-id")
+	if endpoint.AuthURL != "https://login.microsoftonline.us/tenant-id/oauth2/v2.0/authorize" {
+		t.Errorf("expected authorize URL of tenant but found '%s'", endpoint.AuthURL)
+	}
+}
+
+func TestNewHTTPClient_LazyRefresh(t *testing.T) {
+	config, fake, baseURL := newFakeAuthConfig(t)
+	ctx := context.Background()
+	saved := make([]*oauth2.Token, 0)
+
+	httpClient, err := NewHTTPClient(ctx, config, func(token *oauth2.Token) {
+		saved = append(saved, token)
+	})
+	if err != nil {
+		t.Fatalf("error was not nil but '%v'", err)
+	}
+	if len(fake.Requests()) != 0 {
+		t.Errorf("expected no request before first use but found %v", fake.Requests())
+	}
+
+	client, err := NewMSToDoWithBaseURL(httpClient, baseURL)
+	if err != nil {
+		t.Fatalf("error was not nil but '%v'", err)
+	}
+	if _, err := client.GetAllParents(ctx); err != nil {
+		t.Fatalf("error was not nil but '%v'", err)
+	}
+	requests := fake.Requests()
+	if len(requests) == 0 || requests[0] != "POST /contoso.onmicrosoft.com/oauth2/v2.0/token" {
+		t.Errorf("expected token of configured tenant to be refreshed first but found %v", requests)
+	}
+	if len(saved) != 1 {
+		t.Errorf("expected refreshed token to be saved once but found %d", len(saved))
+	}
+}
+
+func TestNewHTTPClient_UsesTokenExpiry(t *testing.T) {
+	config, fake, baseURL := newFakeAuthConfig(t)
+	ctx := context.Background()
+	// redeem an access token to start with
+	source := NewAzureV2TokenSource(ctx, &oauth2.Config{
+		ClientID:     "client",
+		ClientSecret: "secret",
+		Endpoint:     Endpoint(config.Authority, config.Tenant),
+	}, &oauth2.Token{RefreshToken: config.Token.RefreshToken, Expiry: time.Unix(1, 0)}, nil)
+	token, err := source.Token()
+	if err != nil {
+		t.Fatalf("error was not nil but '%v'", err)
+	}
+	config.Token.AccessToken = token.AccessToken
+	config.Token.ExpiresAt = token.Expiry
+
+	httpClient, err := NewHTTPClient(ctx, config, nil)
+	if err != nil {
+		t.Fatalf("error was not nil but '%v'", err)
+	}
+	client, err := NewMSToDoWithBaseURL(httpClient, baseURL)
+	if err != nil {
+		t.Fatalf("error was not nil but '%v'", err)
+	}
+	if _, err := client.GetAllParents(ctx); err != nil {
+		t.Fatalf("error was not nil but '%v'", err)
+	}
+	if requests := fake.Requests(); len(requests) != 2 {
+		t.Errorf("expected valid access token to be used without refresh but found %v", requests)
+	}
+}
+
+func TestNewHTTPClient_CanceledContext(t *testing.T) {
+	config, _, baseURL := newFakeAuthConfig(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	httpClient, err := NewHTTPClient(ctx, config, nil)
+	if err != nil {
+		t.Fatalf("error was not nil but '%v'", err)
+	}
+	// e.g. the context of the request the client was created in
+	cancel()
+
+	client, err := NewMSToDoWithBaseURL(httpClient, baseURL)
+	if err != nil {
+		t.Fatalf("error was not nil but '%v'", err)
+	}
+	if _, err := client.GetAllParents(context.Background()); err != nil {
+		t.Errorf("error was not nil but '%v'", err)
+	}
+}
+
+func TestNewHTTPClient_Errors(t *testing.T) {
+	config, _, baseURL := newFakeAuthConfig(t)
+	ctx := context.Background()
+
+	var validationErr *errors.ValidationError
+	if _, err := NewHTTPClient(ctx, MSClientConfig{}, nil); !stderrors.As(err, &validationErr) {
+		t.Errorf("expected validation error without token but found '%v'", err)
+	}
+
+	// errors of the first refresh are returned by the request instead of ending the process
+	config.Token.RefreshToken = "revoked"
+	httpClient, err := NewHTTPClient(ctx, config, nil)
+	if err != nil {
+		t.Fatalf("error was not nil but '%v'", err)
+	}
+	client, err := NewMSToDoWithBaseURL(httpClient, baseURL)
+	if err != nil {
+		t.Fatalf("error was not nil but '%v'", err)
+	}
+	if _, err := client.GetAllParents(ctx); !stderrors.Is(err, ErrInvalidGrant) {
+		t.Errorf("expected invalid grant error but found '%v'", err)
+	}
+}
+
+func TestLoadToken(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "oauth_credentials.json")
+	FileTokenSaver(path)(&oauth2.Token{
+		AccessToken:  "access",
+		RefreshToken: "refresh",
+		Expiry:       time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC),
+	})
+
+	token, err := LoadToken(path)
+	if err != nil {
+		t.Fatalf("error was not nil but '%v'", err)
+	}
+	if token.AccessToken != "access" || token.RefreshToken != "refresh" || !token.ExpiresAt.Equal(time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("unexpected token %+v", token)
+	}
+	if _, err := LoadToken(filepath.Join(t.TempDir(), "missing.json")); err == nil {
+		t.Error("expected error for missing file")
+	}
+}
@@ -1,6 +1,7 @@
 package microsoft
 
 import (
+	"context"
 	"encoding/json"
 	"os"
 	"testing"
@@ -20,6 +21,10 @@ func TestMSToDo_Integration_CRUD(t *testing.T) {
 	testutil.IntegrationTest_CRUD(t, createClient(t))
 }
 
+func TestMSToDo_Integration_Conformance(t *testing.T) {
+	testutil.IntegrationTest_Conformance(t, createClient(t))
+}
+
 func createClient(t *testing.T) *MSToDo {
 	clientCredentials := os.Getenv("MSCLIENTCREDENTIALS")
 	if clientCredentials == "" {
@@ -42,6 +47,9 @@ func createClient(t *testing.T) *MSToDo {
 		t.Errorf("error was not nil but '%v'", err)
 	}
 
-	httpClient := NewClient(msClientConfig, nil)
+	httpClient, err := NewHTTPClient(context.Background(), msClientConfig, nil)
+	if err != nil {
+		t.Fatalf("error was not nil but '%v'", err)
+	}
 	return NewMSToDo(httpClient)
 }
@@ -3,13 +3,20 @@ package microsoft
 import (
 	"bytes"
 	"context"
+	stderrors "errors"
 	"io"
 	"net/http"
+	"net/http/httptest"
+	neturl "net/url"
 	"strings"
 	"testing"
 	"time"
 
+	"github.com/jo-hoe/todoapi/pkg/errors"
 	"github.com/jo-hoe/todoapi/todoclient"
+	"github.com/jo-hoe/todoapi/todoclient/conformance"
+	"github.com/jo-hoe/todoapi/todoclient/microsoft/graphfake"
+	"golang.org/x/oauth2"
 )
 
 // RoundTripFunc .
@@ -38,7 +45,7 @@ func TestMSToDo_GetAllTask(t *testing.T) {
 
 	api := NewMSToDo(client)
 
-	tasks, err := api.GetAllTasks(ctx)
+	tasks, err := api.GetAllTasks(ctx, nil)
 	if len(tasks) != 6 {
 		t.Errorf("Expected 6 but found %d tasks", len(tasks))
 	}
@@ -53,7 +60,7 @@ func TestMSToDo_GetChildrenTasks(t *testing.T) {
 
 	api := NewMSToDo(client)
 
-	tasks, err := api.GetChildrenTasks(ctx, "xyz")
+	tasks, err := api.GetChildrenTasks(ctx, "xyz", nil)
 	if len(tasks) != 3 {
 		t.Errorf("Expected 3 but found %d tasks", len(tasks))
 	}
@@ -68,7 +75,7 @@ func TestMSToDo_GetAllTask_CheckSerialization(t *testing.T) {
 	testTime := time.Date(1990, time.Month(1), 1, 1, 1, 0, 0, time.UTC)
 	api := NewMSToDo(client)
 
-	tasks, err := api.GetAllTasks(ctx)
+	tasks, err := api.GetAllTasks(ctx, nil)
 
 	task := tasks[0]
 
@@ -89,6 +96,68 @@ func TestMSToDo_GetAllTask_CheckSerialization(t *testing.T) {
 	}
 }
 
+func TestMSToDo_GetChildrenTasks_WithQuery(t *testing.T) {
+	var requestedUrl string
+	client := NewMockClient(func(req *http.Request) *http.Response {
+		requestedUrl = req.URL.String()
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Body:       io.NopCloser(bytes.NewBufferString(demoTasks2)),
+			Header:     make(http.Header),
+		}
+	})
+	ctx := context.Background()
+	completed := true
+
+	api := NewMSToDo(client)
+
+	tasks, err := api.GetChildrenTasks(ctx, "xyz", &todoclient.TaskQuery{
+		Completed: &completed,
+		OrderBy:   todoclient.OrderByDueDate,
+		Limit:     5,
+	})
+	if err != nil {
+		t.Errorf("Found error: '%v'", err)
+	}
+	if len(tasks) != 1 {
+		t.Fatalf("Expected 1 but found %d tasks", len(tasks))
+	}
+	if !tasks[0].IsCompleted {
+		t.Error("Expected task to be completed")
+	}
+	for _, expected := range []string{"$filter=status%20eq%20%27completed%27", "$orderby=dueDateTime%2FdateTime", "$top=5"} {
+		if !strings.Contains(requestedUrl, expected) {
+			t.Errorf("Expected '%s' in url '%s'", expected, requestedUrl)
+		}
+	}
+}
+
+func TestBuildODataQuery(t *testing.T) {
+	open := false
+	query := &todoclient.TaskQuery{
+		Completed: &open,
+		DueAfter:  time.Date(2024, 1, 10, 0, 0, 0, 0, time.UTC),
+		Labels:    []string{"Tom's"},
+		Text:      "client-side",
+		Limit:     3,
+	}
+
+	params, limit := buildODataQuery(query)
+
+	filter, err := neturl.QueryUnescape(strings.TrimPrefix(params, "$filter="))
+	if err != nil {
+		t.Fatalf("could not unescape '%s': %v", params, err)
+	}
+	expected := "status ne 'completed' and dueDateTime/dateTime ge '2024-01-10T00:00:00' and categories/any(c:c eq 'Tom''s')"
+	if filter != expected {
+		t.Errorf("Expected '%s' but found '%s'", expected, filter)
+	}
+	// text search is client-side, so $top must not be used
+	if limit != 0 || strings.Contains(params, "$top") {
+		t.Errorf("Expected no server-side limit but found '%s'", params)
+	}
+}
+
 func TestMSToDo_UpdateTask(t *testing.T) {
 	client := createMockClient()
 	ctx := context.Background()
@@ -106,6 +175,51 @@ func TestMSToDo_UpdateTask(t *testing.T) {
 	}
 }
 
+func TestMSToDo_GetTask(t *testing.T) {
+	client := NewMockClient(func(req *http.Request) *http.Response {
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Body:       io.NopCloser(bytes.NewBufferString(demoTask)),
+			Header:     make(http.Header),
+		}
+	})
+	ctx := context.Background()
+
+	api := NewMSToDo(client)
+
+	task, err := api.GetTask(ctx, "demo", "atask")
+	if err != nil {
+		t.Errorf("Found error: '%v'", err)
+	}
+	if task.ID != "atask" {
+		t.Errorf("Expected ID 'atask' but found '%s'", task.ID)
+	}
+	if task.Name != "Banana" {
+		t.Errorf("Expected name 'Banana' but found '%s'", task.Name)
+	}
+	if task.DueDate.IsZero() {
+		t.Error("DueDate is zero")
+	}
+}
+
+func TestMSToDo_GetTask_NotFound(t *testing.T) {
+	client := NewMockClient(func(req *http.Request) *http.Response {
+		return &http.Response{
+			StatusCode: http.StatusNotFound,
+			Body:       io.NopCloser(bytes.NewBufferString(`{"error":{"code":"ErrorItemNotFound"}}`)),
+			Header:     make(http.Header),
+		}
+	})
+	ctx := context.Background()
+
+	api := NewMSToDo(client)
+
+	_, err := api.GetTask(ctx, "demo", "unknown")
+	if !stderrors.Is(err, errors.ErrNotFound) {
+		t.Errorf("Expected not found error but found '%v'", err)
+	}
+}
+
 var firstTaskCall = true
 
 func createMockClient() *http.Client {
@@ -156,6 +270,26 @@ const demoList = `{
 	]
 }`
 
+const demoTask = `{
+    "@odata.context": "https://graph.microsoft.com/v1.0/$metadata#users('someone.someone%40outlook.com')/todo/lists('demo')/tasks/$entity",
+    "@odata.etag": "demo",
+    "body": {
+        "content": "",
+        "contentType": "text"
+    },
+    "dueDateTime": {
+        "dateTime": "2021-04-04T22:00:00.0000000",
+        "timeZone": "UTC"
+    },
+    "createdDateTime": "2021-04-04T10:27:46.6543589Z",
+    "id": "atask",
+    "importance": "high",
+    "isReminderOn": false,
+    "lastModifiedDateTime": "2021-04-04T11:53:53.2660551Z",
+    "status": "notStarted",
+    "title": "Banana"
+}`
+
 const demoTasks1 = `{
     "@odata.context": "https://graph.microsoft.com/v1.0/$metadata#users('someone.someone%40outlook.com')/todo/lists('demo')/tasks",
     "@odata.nextLink": "https://graph.microsoft.com/v1.0/me/todo/lists/demo/tasks?$top=100skip=100",
@@ -227,3 +361,174 @@ const demoTasks2 = `{
         }
     ]
 }`
+
+func newFakeClient(t *testing.T) (*MSToDo, *graphfake.Server) {
+	t.Helper()
+	fake := graphfake.New()
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+
+	client, err := NewMSToDoWithBaseURL(server.Client(), server.URL+graphfake.TodoPath)
+	if err != nil {
+		t.Fatalf("error was not nil but '%v'", err)
+	}
+	return client, fake
+}
+
+func TestMSToDo_Conformance(t *testing.T) {
+	client, _ := newFakeClient(t)
+	conformance.Run(t, client, conformance.Options{})
+}
+
+func TestMSToDo_Fake_PagingAndQuery(t *testing.T) {
+	client, fake := newFakeClient(t)
+	fake.SetPageSize(2)
+	ctx := context.Background()
+	listID := fake.AddList("Work")
+	for i := 0; i < 5; i++ {
+		fake.AddTask(listID, "task", "work")
+	}
+	fake.AddTask(listID, "other")
+
+	tasks, err := client.GetChildrenTasks(ctx, listID, nil)
+	if err != nil {
+		t.Fatalf("error was not nil but '%v'", err)
+	}
+	if len(tasks) != 6 {
+		t.Errorf("expected 6 tasks across pages but found %d", len(tasks))
+	}
+
+	tasks, err = client.GetChildrenTasks(ctx, listID, &todoclient.TaskQuery{Labels: []string{"work"}, Limit: 3})
+	if err != nil {
+		t.Fatalf("error was not nil but '%v'", err)
+	}
+	if len(tasks) != 3 {
+		t.Errorf("expected 3 labeled tasks but found %d", len(tasks))
+	}
+}
+
+func TestMSToDo_Fake_GetAllTasksLimit(t *testing.T) {
+	client, fake := newFakeClient(t)
+	ctx := context.Background()
+	for _, name := range []string{"Work", "Home"} {
+		listID := fake.AddList(name)
+		for i := 0; i < 3; i++ {
+			fake.AddTask(listID, name+" task")
+		}
+	}
+
+	tasks, err := client.GetAllTasks(ctx, &todoclient.TaskQuery{OrderBy: todoclient.OrderByName, Limit: 2})
+	if err != nil {
+		t.Fatalf("error was not nil but '%v'", err)
+	}
+	if len(tasks) != 2 || tasks[0].Name != "Home task" {
+		t.Errorf("expected the first 2 tasks of all lists but found %+v", tasks)
+	}
+	for _, request := range fake.Requests() {
+		if strings.Contains(request, "top=") {
+			t.Errorf("expected no per-list limit but found '%s'", request)
+		}
+	}
+}
+
+func TestMSToDo_Fake_ClearCategories(t *testing.T) {
+	client, fake := newFakeClient(t)
+	ctx := context.Background()
+	listID := fake.AddList("Work")
+	taskID := fake.AddTask(listID, "task", "work")
+
+	task, err := client.GetTask(ctx, listID, taskID)
+	if err != nil {
+		t.Fatalf("error was not nil but '%v'", err)
+	}
+	task.Labels = nil
+	if err := client.UpdateTask(ctx, listID, task); err != nil {
+		t.Fatalf("error was not nil but '%v'", err)
+	}
+	if task, _ = client.GetTask(ctx, listID, taskID); len(task.Labels) != 0 {
+		t.Errorf("expected categories to be removed but found %v", task.Labels)
+	}
+}
+
+func TestMSToDo_Fake_KeepsStatus(t *testing.T) {
+	client, fake := newFakeClient(t)
+	ctx := context.Background()
+	listID := fake.AddList("Work")
+	taskID := fake.AddTask(listID, "task")
+	status := func() string {
+		var data msOdataTask
+		if err := client.getData(ctx, client.url(taskURL, listID, taskID), &data); err != nil {
+			t.Fatalf("error was not nil but '%v'", err)
+		}
+		return data.Status
+	}
+	if _, err := client.patchTask(ctx, listID, taskID, map[string]string{"status": "inProgress"}); err != nil {
+		t.Fatalf("error was not nil but '%v'", err)
+	}
+
+	task := todoclient.ToDoTask{ID: taskID, Name: "renamed"}
+	if err := client.UpdateTask(ctx, listID, task); err != nil {
+		t.Fatalf("error was not nil but '%v'", err)
+	}
+	if status() != "inProgress" {
+		t.Errorf("expected status to be kept but found '%s'", status())
+	}
+
+	task.IsCompleted = true
+	_ = client.UpdateTask(ctx, listID, task)
+	if status() != statusCompleted {
+		t.Errorf("expected completed task but found '%s'", status())
+	}
+	task.IsCompleted = false
+	_ = client.UpdateTask(ctx, listID, task)
+	if status() != statusNotStarted {
+		t.Errorf("expected reopened task but found '%s'", status())
+	}
+}
+
+func TestMSToDo_Fake_Throttled(t *testing.T) {
+	client, fake := newFakeClient(t)
+	fake.SetThrottle(1, time.Minute)
+	ctx := context.Background()
+
+	if _, err := client.GetAllParents(ctx); err != nil {
+		t.Fatalf("error was not nil but '%v'", err)
+	}
+	if _, err := client.GetAllParents(ctx); err == nil {
+		t.Error("expected throttled request to fail")
+	}
+}
+
+func TestMSToDo_Fake_TokenRefresh(t *testing.T) {
+	fake := graphfake.New()
+	server := httptest.NewServer(fake)
+	defer server.Close()
+	refreshToken := fake.EnableAuth("client", "secret")
+
+	saved := make([]*oauth2.Token, 0)
+	conf := &oauth2.Config{
+		ClientID:     "client",
+		ClientSecret: "secret",
+		Endpoint:     oauth2.Endpoint{TokenURL: server.URL + graphfake.TokenPath},
+	}
+	ctx := context.Background()
+	source := NewAzureV2TokenSource(ctx, conf, &oauth2.Token{RefreshToken: refreshToken}, func(token *oauth2.Token) {
+		saved = append(saved, token)
+	})
+	client, err := NewMSToDoWithBaseURL(oauth2.NewClient(ctx, source), server.URL+graphfake.TodoPath)
+	if err != nil {
+		t.Fatalf("error was not nil but '%v'", err)
+	}
+
+	if _, err := client.GetAllParents(ctx); err != nil {
+		t.Fatalf("error was not nil but '%v'", err)
+	}
+	if len(saved) != 1 || saved[0].RefreshToken == refreshToken {
+		t.Errorf("expected rotated refresh token to be saved but found %+v", saved)
+	}
+
+	invalid := NewAzureV2TokenSource(ctx, conf, &oauth2.Token{RefreshToken: "revoked"}, nil)
+	if _, err := invalid.Token(); err == nil || !strings.Contains(err.Error(), "invalid_grant") {
+		t.Errorf("expected invalid_grant error but found '%v'", err)
+	}
+}
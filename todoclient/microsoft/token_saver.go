@@ -18,7 +18,7 @@ import (
 // - Creates the file if it does not exist.
 //
 // Example usage:
-//   httpClient := microsoft.NewClient(msConfig, microsoft.FileTokenSaver("oauth_credentials.json"))
+//   httpClient, err := microsoft.NewHTTPClient(ctx, msConfig, microsoft.FileTokenSaver("oauth_credentials.json"))
 func FileTokenSaver(path string) func(tok *oauth2.Token) {
 	return func(tok *oauth2.Token) {
 		// Read existing file if present
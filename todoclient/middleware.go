@@ -0,0 +1,202 @@
+package todoclient
+
+import (
+	"context"
+	"fmt"
+)
+
+// Operation names passed to interceptors, equal to the ToDoClient method names
+const (
+	OpGetAllTasks      = "GetAllTasks"
+	OpGetChildrenTasks = "GetChildrenTasks"
+	OpGetTask          = "GetTask"
+	OpCreateTask       = "CreateTask"
+	OpUpdateTask       = "UpdateTask"
+	OpDeleteTask       = "DeleteTask"
+	OpGetAllParents    = "GetAllParents"
+	OpCreateParent     = "CreateParent"
+	OpDeleteParent     = "DeleteParent"
+)
+
+// Invocation describes a single call of a ToDoClient method
+type Invocation struct {
+	Operation string        // Name of the called method, one of the Op constants
+	Args      []interface{} // Arguments of the method in declaration order, without the context
+}
+
+// Handler executes an invocation. The result is the non-error return value of
+// the method, or nil for methods returning only an error.
+type Handler func(ctx context.Context, inv Invocation) (interface{}, error)
+
+// Interceptor runs around the execution of an invocation. It may inspect or
+// replace the invocation and the result, or return without calling next.
+type Interceptor func(ctx context.Context, inv Invocation, next Handler) (interface{}, error)
+
+// Middleware wraps a ToDoClient with additional behavior
+type Middleware func(next ToDoClient) ToDoClient
+
+// Chain wraps a client with the middlewares. The first middleware is the outermost,
+// i.e. it sees a call first and the result last.
+func Chain(client ToDoClient, middlewares ...Middleware) ToDoClient {
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		client = middlewares[i](client)
+	}
+	return client
+}
+
+// Intercept returns a middleware running the interceptors in order around every call
+func Intercept(interceptors ...Interceptor) Middleware {
+	return func(next ToDoClient) ToDoClient {
+		handler := dispatch(next)
+		for i := len(interceptors) - 1; i >= 0; i-- {
+			handler = wrapHandler(interceptors[i], handler)
+		}
+		return &interceptedClient{handler: handler, capabilities: CapabilitiesOf(next)}
+	}
+}
+
+func wrapHandler(interceptor Interceptor, next Handler) Handler {
+	return func(ctx context.Context, inv Invocation) (interface{}, error) {
+		return interceptor(ctx, inv, next)
+	}
+}
+
+// dispatch returns the innermost handler calling the wrapped client. Interceptors
+// may replace arguments, arguments of the wrong type are returned as error.
+func dispatch(client ToDoClient) Handler {
+	return func(ctx context.Context, inv Invocation) (interface{}, error) {
+		switch inv.Operation {
+		case OpGetAllTasks:
+			query, err := arg[*TaskQuery](inv, 0)
+			if err != nil {
+				return nil, err
+			}
+			return client.GetAllTasks(ctx, query)
+		case OpGetChildrenTasks:
+			parentID, err := arg[string](inv, 0)
+			if err != nil {
+				return nil, err
+			}
+			query, err := arg[*TaskQuery](inv, 1)
+			if err != nil {
+				return nil, err
+			}
+			return client.GetChildrenTasks(ctx, parentID, query)
+		case OpGetTask, OpDeleteTask:
+			parentID, err := arg[string](inv, 0)
+			if err != nil {
+				return nil, err
+			}
+			taskID, err := arg[string](inv, 1)
+			if err != nil {
+				return nil, err
+			}
+			if inv.Operation == OpDeleteTask {
+				return nil, client.DeleteTask(ctx, parentID, taskID)
+			}
+			return client.GetTask(ctx, parentID, taskID)
+		case OpCreateTask, OpUpdateTask:
+			parentID, err := arg[string](inv, 0)
+			if err != nil {
+				return nil, err
+			}
+			task, err := arg[ToDoTask](inv, 1)
+			if err != nil {
+				return nil, err
+			}
+			if inv.Operation == OpUpdateTask {
+				return nil, client.UpdateTask(ctx, parentID, task)
+			}
+			return client.CreateTask(ctx, parentID, task)
+		case OpGetAllParents:
+			return client.GetAllParents(ctx)
+		case OpCreateParent:
+			name, err := arg[string](inv, 0)
+			if err != nil {
+				return nil, err
+			}
+			return client.CreateParent(ctx, name)
+		case OpDeleteParent:
+			parentID, err := arg[string](inv, 0)
+			if err != nil {
+				return nil, err
+			}
+			return nil, client.DeleteParent(ctx, parentID)
+		default:
+			return nil, fmt.Errorf("unknown operation %s", inv.Operation)
+		}
+	}
+}
+
+// arg returns the argument at position i if it has the type T
+func arg[T any](inv Invocation, i int) (T, error) {
+	value, ok := argAt(inv, i).(T)
+	if !ok {
+		return value, fmt.Errorf("invalid arguments for %s: argument %d is %T instead of %T", inv.Operation, i, argAt(inv, i), value)
+	}
+	return value, nil
+}
+
+// interceptedClient turns method calls into invocations of a handler chain
+type interceptedClient struct {
+	handler      Handler
+	capabilities Capabilities
+}
+
+// Capabilities reports the capabilities of the wrapped client
+func (c *interceptedClient) Capabilities() Capabilities {
+	return c.capabilities
+}
+
+func (c *interceptedClient) GetAllTasks(ctx context.Context, query *TaskQuery) ([]ToDoTask, error) {
+	return invoke[[]ToDoTask](ctx, c.handler, OpGetAllTasks, query)
+}
+
+func (c *interceptedClient) GetChildrenTasks(ctx context.Context, parentID string, query *TaskQuery) ([]ToDoTask, error) {
+	return invoke[[]ToDoTask](ctx, c.handler, OpGetChildrenTasks, parentID, query)
+}
+
+func (c *interceptedClient) GetTask(ctx context.Context, parentID, taskID string) (ToDoTask, error) {
+	return invoke[ToDoTask](ctx, c.handler, OpGetTask, parentID, taskID)
+}
+
+func (c *interceptedClient) CreateTask(ctx context.Context, parentID string, task ToDoTask) (ToDoTask, error) {
+	return invoke[ToDoTask](ctx, c.handler, OpCreateTask, parentID, task)
+}
+
+func (c *interceptedClient) UpdateTask(ctx context.Context, parentID string, task ToDoTask) error {
+	_, err := c.handler(ctx, Invocation{Operation: OpUpdateTask, Args: []interface{}{parentID, task}})
+	return err
+}
+
+func (c *interceptedClient) DeleteTask(ctx context.Context, parentID, taskID string) error {
+	_, err := c.handler(ctx, Invocation{Operation: OpDeleteTask, Args: []interface{}{parentID, taskID}})
+	return err
+}
+
+func (c *interceptedClient) GetAllParents(ctx context.Context) ([]ToDoParent, error) {
+	return invoke[[]ToDoParent](ctx, c.handler, OpGetAllParents)
+}
+
+func (c *interceptedClient) CreateParent(ctx context.Context, parentName string) (ToDoParent, error) {
+	return invoke[ToDoParent](ctx, c.handler, OpCreateParent, parentName)
+}
+
+func (c *interceptedClient) DeleteParent(ctx context.Context, parentID string) error {
+	_, err := c.handler(ctx, Invocation{Operation: OpDeleteParent, Args: []interface{}{parentID}})
+	return err
+}
+
+// invoke runs the handler and converts the result to the return type of the method
+func invoke[T any](ctx context.Context, handler Handler, operation string, args ...interface{}) (T, error) {
+	var result T
+	value, err := handler(ctx, Invocation{Operation: operation, Args: args})
+	if value != nil {
+		typed, ok := value.(T)
+		if !ok {
+			return result, fmt.Errorf("invalid result type %T for %s", value, operation)
+		}
+		result = typed
+	}
+	return result, err
+}
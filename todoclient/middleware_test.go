@@ -0,0 +1,240 @@
+package todoclient
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// recordingClient records the operations reaching it
+type recordingClient struct {
+	operations []string
+}
+
+func (c *recordingClient) record(operation string) {
+	c.operations = append(c.operations, operation)
+}
+
+func (c *recordingClient) GetAllTasks(ctx context.Context, query *TaskQuery) ([]ToDoTask, error) {
+	c.record(OpGetAllTasks)
+	return query.Apply([]ToDoTask{{ID: "1", Name: "a"}, {ID: "2", Name: "b"}}), nil
+}
+
+func (c *recordingClient) GetChildrenTasks(ctx context.Context, parentID string, query *TaskQuery) ([]ToDoTask, error) {
+	c.record(OpGetChildrenTasks)
+	return []ToDoTask{}, nil
+}
+
+func (c *recordingClient) GetTask(ctx context.Context, parentID, taskID string) (ToDoTask, error) {
+	c.record(OpGetTask)
+	return ToDoTask{ID: taskID}, nil
+}
+
+func (c *recordingClient) CreateTask(ctx context.Context, parentID string, task ToDoTask) (ToDoTask, error) {
+	c.record(OpCreateTask)
+	task.ID = "new"
+	return task, nil
+}
+
+func (c *recordingClient) UpdateTask(ctx context.Context, parentID string, task ToDoTask) error {
+	c.record(OpUpdateTask)
+	return nil
+}
+
+func (c *recordingClient) DeleteTask(ctx context.Context, parentID, taskID string) error {
+	c.record(OpDeleteTask)
+	return errors.New("delete failed")
+}
+
+func (c *recordingClient) GetAllParents(ctx context.Context) ([]ToDoParent, error) {
+	c.record(OpGetAllParents)
+	return []ToDoParent{{ID: "p", Name: "parent"}}, nil
+}
+
+func (c *recordingClient) CreateParent(ctx context.Context, parentName string) (ToDoParent, error) {
+	c.record(OpCreateParent)
+	return ToDoParent{ID: "p", Name: parentName}, nil
+}
+
+func (c *recordingClient) DeleteParent(ctx context.Context, parentID string) error {
+	c.record(OpDeleteParent)
+	return nil
+}
+
+func TestIntercept_PassesOperationAndArguments(t *testing.T) {
+	next := &recordingClient{}
+	var seen []Invocation
+	client := Chain(next, Intercept(func(ctx context.Context, inv Invocation, handler Handler) (interface{}, error) {
+		seen = append(seen, inv)
+		return handler(ctx, inv)
+	}))
+	ctx := context.Background()
+	query := &TaskQuery{Limit: 1}
+
+	tasks, err := client.GetAllTasks(ctx, query)
+	if err != nil || len(tasks) != 1 {
+		t.Errorf("expected 1 task without error but found %d and '%v'", len(tasks), err)
+	}
+	created, err := client.CreateTask(ctx, "p", ToDoTask{Name: "task"})
+	if err != nil || created.ID != "new" {
+		t.Errorf("expected created task without error but found '%s' and '%v'", created.ID, err)
+	}
+	if err := client.DeleteTask(ctx, "p", "t"); err == nil {
+		t.Error("expected error of wrapped client")
+	}
+	if _, err := client.GetAllParents(ctx); err != nil {
+		t.Errorf("error was not nil but '%v'", err)
+	}
+
+	want := []Invocation{
+		{Operation: OpGetAllTasks, Args: []interface{}{query}},
+		{Operation: OpCreateTask, Args: []interface{}{"p", ToDoTask{Name: "task"}}},
+		{Operation: OpDeleteTask, Args: []interface{}{"p", "t"}},
+		{Operation: OpGetAllParents, Args: nil},
+	}
+	if !reflect.DeepEqual(seen, want) {
+		t.Errorf("expected %+v but found %+v", want, seen)
+	}
+	if len(next.operations) != 4 {
+		t.Errorf("expected 4 calls of wrapped client but found %d", len(next.operations))
+	}
+}
+
+func TestChain_Order(t *testing.T) {
+	order := make([]string, 0)
+	named := func(name string) Interceptor {
+		return func(ctx context.Context, inv Invocation, next Handler) (interface{}, error) {
+			order = append(order, name+" before")
+			result, err := next(ctx, inv)
+			order = append(order, name+" after")
+			return result, err
+		}
+	}
+	client := Chain(&recordingClient{}, Intercept(named("a"), named("b")), Intercept(named("c")))
+
+	if err := client.DeleteParent(context.Background(), "p"); err != nil {
+		t.Fatalf("error was not nil but '%v'", err)
+	}
+
+	want := []string{"a before", "b before", "c before", "c after", "b after", "a after"}
+	if !reflect.DeepEqual(order, want) {
+		t.Errorf("expected %v but found %v", want, order)
+	}
+}
+
+func TestIntercept_ShortCircuitAndReplace(t *testing.T) {
+	next := &recordingClient{}
+	client := Chain(next, Intercept(func(ctx context.Context, inv Invocation, handler Handler) (interface{}, error) {
+		switch inv.Operation {
+		case OpGetTask:
+			return ToDoTask{ID: "cached"}, nil
+		case OpCreateParent:
+			inv.Args = []interface{}{"replaced"}
+		case OpDeleteParent:
+			inv.Args = []interface{}{42}
+		}
+		return handler(ctx, inv)
+	}))
+	ctx := context.Background()
+
+	task, err := client.GetTask(ctx, "p", "t")
+	if err != nil || task.ID != "cached" {
+		t.Errorf("expected short-circuited task but found '%s' and '%v'", task.ID, err)
+	}
+	parent, err := client.CreateParent(ctx, "original")
+	if err != nil || parent.Name != "replaced" {
+		t.Errorf("expected replaced argument but found '%s' and '%v'", parent.Name, err)
+	}
+	if err := client.DeleteParent(ctx, "p"); err == nil {
+		t.Error("expected an error for an argument of wrong type")
+	}
+	if !reflect.DeepEqual(next.operations, []string{OpCreateParent}) {
+		t.Errorf("unexpected calls of wrapped client %v", next.operations)
+	}
+}
+
+// panickingClient fails with a panic like an adapter with a bug
+type panickingClient struct {
+	recordingClient
+}
+
+func (c *panickingClient) GetAllParents(ctx context.Context) ([]ToDoParent, error) {
+	panic("adapter bug")
+}
+
+func TestIntercept_PanicOfClient(t *testing.T) {
+	client := Chain(&panickingClient{}, Intercept())
+	defer func() {
+		if r := recover(); r != "adapter bug" {
+			t.Errorf("expected panic of wrapped client but found '%v'", r)
+		}
+	}()
+
+	_, err := client.GetAllParents(context.Background())
+	t.Errorf("expected panic to reach the caller but found error '%v'", err)
+}
+
+func TestValidationInterceptor(t *testing.T) {
+	next := &recordingClient{}
+	client := Chain(next, Intercept(ValidationInterceptor()))
+	ctx := context.Background()
+
+	if _, err := client.CreateTask(ctx, "p", ToDoTask{}); err == nil {
+		t.Error("expected validation error for task without name")
+	}
+	if err := client.UpdateTask(ctx, "p", ToDoTask{Name: "task"}); err == nil {
+		t.Error("expected validation error for task without id")
+	}
+	if _, err := client.CreateParent(ctx, "  "); err == nil {
+		t.Error("expected validation error for empty parent name")
+	}
+	if err := client.DeleteParent(ctx, ""); err == nil {
+		t.Error("expected validation error for empty parent id")
+	}
+	if _, err := client.CreateTask(ctx, "p", ToDoTask{Name: "valid"}); err != nil {
+		t.Errorf("error was not nil but '%v'", err)
+	}
+
+	if !reflect.DeepEqual(next.operations, []string{OpCreateTask}) {
+		t.Errorf("expected only the valid call to reach the client but found %v", next.operations)
+	}
+}
+
+func TestLoggingInterceptor(t *testing.T) {
+	var buffer bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&buffer, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	client := Chain(&recordingClient{}, Intercept(LoggingInterceptor(logger)))
+	ctx := context.Background()
+
+	_, _ = client.GetChildrenTasks(ctx, "parent-id", nil)
+	_ = client.DeleteTask(ctx, "p", "t")
+
+	output := buffer.String()
+	for _, expected := range []string{"operation=GetChildrenTasks", "parent-id", "level=ERROR", "delete failed"} {
+		if !strings.Contains(output, expected) {
+			t.Errorf("expected '%s' in log output '%s'", expected, output)
+		}
+	}
+}
+
+func TestLogger(t *testing.T) {
+	var buffer bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&buffer, nil))
+	var found *slog.Logger
+	client := Chain(&recordingClient{}, Intercept(LoggingInterceptor(logger), func(ctx context.Context, inv Invocation, next Handler) (interface{}, error) {
+		found = Logger(ctx)
+		return next(ctx, inv)
+	}))
+
+	_, _ = client.GetAllParents(context.Background())
+	if found != logger {
+		t.Error("expected logger of the interceptor in the context of the call")
+	}
+	if Logger(context.Background()) != slog.Default() {
+		t.Error("expected default logger outside of a logged call")
+	}
+}
@@ -0,0 +1,263 @@
+package mirror
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/jo-hoe/todoapi/pkg/errors"
+)
+
+const manifestFile = "manifest.json"
+
+// Entry describes an archived snapshot
+type Entry struct {
+	Version   int       `json:"version"`
+	CreatedAt time.Time `json:"created_at"`
+	File      string    `json:"file"`
+	Checksum  string    `json:"checksum"` // SHA-256 of the snapshot file
+	DiffFile  string    `json:"diff_file"`
+	Parents   int       `json:"parents"`
+	Tasks     int       `json:"tasks"`
+	Changes   int       `json:"changes"` // Number of changes against the previous snapshot
+}
+
+type manifest struct {
+	Entries []Entry `json:"entries"`
+}
+
+// Archive stores numbered snapshots in a directory. Every snapshot is accompanied
+// by a diff against its predecessor, the manifest records checksums of all snapshots.
+//
+//	manifest.json
+//	snapshot-000001.json
+//	diff-000001.json
+type Archive struct {
+	mu  sync.Mutex
+	dir string
+}
+
+// OpenArchive opens the archive in dir, creating the directory if needed
+func OpenArchive(dir string) (*Archive, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, errors.NewAPIError("MIRROR_ARCHIVE_FAILED", "failed to create archive directory", err)
+	}
+	return &Archive{dir: dir}, nil
+}
+
+// Entries returns all archived snapshots, oldest first
+func (a *Archive) Entries() ([]Entry, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	m, err := a.readManifest()
+	if err != nil {
+		return nil, err
+	}
+	return m.Entries, nil
+}
+
+// Save archives the snapshot as the next version, assigning snapshot.Version
+func (a *Archive) Save(snapshot *Snapshot) (Entry, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	m, err := a.readManifest()
+	if err != nil {
+		return Entry{}, err
+	}
+
+	var previous *Snapshot
+	version := 1
+	if len(m.Entries) > 0 {
+		last := m.Entries[len(m.Entries)-1]
+		if previous, err = a.load(last); err != nil {
+			return Entry{}, err
+		}
+		version = last.Version + 1
+	}
+	snapshot.Version = version
+
+	b, err := json.MarshalIndent(snapshot, "", "  ")
+	if err != nil {
+		return Entry{}, errors.NewAPIError("MIRROR_ENCODE_FAILED", "failed to encode snapshot", err)
+	}
+	diff := Compare(previous, snapshot)
+	diffBytes, err := json.MarshalIndent(diff, "", "  ")
+	if err != nil {
+		return Entry{}, errors.NewAPIError("MIRROR_ENCODE_FAILED", "failed to encode diff", err)
+	}
+
+	entry := Entry{
+		Version:   version,
+		CreatedAt: snapshot.CreatedAt,
+		File:      fmt.Sprintf("snapshot-%06d.json", version),
+		Checksum:  checksum(b),
+		DiffFile:  fmt.Sprintf("diff-%06d.json", version),
+		Parents:   len(snapshot.Parents),
+		Tasks:     snapshot.TaskCount(),
+		Changes:   diff.Count(),
+	}
+
+	// the manifest is written last, so an interrupted save leaves no entry behind
+	if err := a.writeFile(entry.File, b); err != nil {
+		return Entry{}, err
+	}
+	if err := a.writeFile(entry.DiffFile, diffBytes); err != nil {
+		return Entry{}, err
+	}
+	m.Entries = append(m.Entries, entry)
+	if err := a.writeManifest(m); err != nil {
+		return Entry{}, err
+	}
+	return entry, nil
+}
+
+// Load returns the snapshot with the given version after verifying its checksum
+func (a *Archive) Load(version int) (*Snapshot, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	entry, err := a.entry(version)
+	if err != nil {
+		return nil, err
+	}
+	return a.load(entry)
+}
+
+// Latest returns the most recent snapshot
+func (a *Archive) Latest() (*Snapshot, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	m, err := a.readManifest()
+	if err != nil {
+		return nil, err
+	}
+	if len(m.Entries) == 0 {
+		return nil, errors.NewAPIError("MIRROR_ARCHIVE_EMPTY", "archive contains no snapshots", errors.ErrNotFound)
+	}
+	return a.load(m.Entries[len(m.Entries)-1])
+}
+
+// Diff returns the archived changes of a snapshot against its predecessor
+func (a *Archive) Diff(version int) (Diff, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	var diff Diff
+	entry, err := a.entry(version)
+	if err != nil {
+		return diff, err
+	}
+	b, err := os.ReadFile(filepath.Join(a.dir, entry.DiffFile))
+	if err != nil {
+		return diff, errors.NewAPIError("MIRROR_READ_FAILED", "failed to read diff", err)
+	}
+	if err := json.Unmarshal(b, &diff); err != nil {
+		return diff, errors.NewAPIError("MIRROR_DECODE_FAILED", "failed to decode diff", err)
+	}
+	return diff, nil
+}
+
+// Verify checks the checksums of all archived snapshots
+func (a *Archive) Verify() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	m, err := a.readManifest()
+	if err != nil {
+		return err
+	}
+	for _, entry := range m.Entries {
+		if _, err := a.read(entry); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (a *Archive) entry(version int) (Entry, error) {
+	m, err := a.readManifest()
+	if err != nil {
+		return Entry{}, err
+	}
+	for _, entry := range m.Entries {
+		if entry.Version == version {
+			return entry, nil
+		}
+	}
+	return Entry{}, errors.NewAPIError("MIRROR_VERSION_NOT_FOUND", fmt.Sprintf("snapshot version %d not found", version), errors.ErrNotFound)
+}
+
+func (a *Archive) load(entry Entry) (*Snapshot, error) {
+	b, err := a.read(entry)
+	if err != nil {
+		return nil, err
+	}
+	snapshot := &Snapshot{}
+	if err := json.Unmarshal(b, snapshot); err != nil {
+		return nil, errors.NewAPIError("MIRROR_DECODE_FAILED", "failed to decode snapshot", err)
+	}
+	return snapshot, nil
+}
+
+// read returns the content of a snapshot file if it matches the recorded checksum
+func (a *Archive) read(entry Entry) ([]byte, error) {
+	b, err := os.ReadFile(filepath.Join(a.dir, entry.File))
+	if err != nil {
+		return nil, errors.NewAPIError("MIRROR_READ_FAILED", "failed to read snapshot", err)
+	}
+	if checksum(b) != entry.Checksum {
+		return nil, errors.NewAPIError("MIRROR_CHECKSUM_MISMATCH", fmt.Sprintf("snapshot version %d is corrupted", entry.Version), nil)
+	}
+	return b, nil
+}
+
+func (a *Archive) readManifest() (*manifest, error) {
+	m := &manifest{Entries: make([]Entry, 0)}
+	b, err := os.ReadFile(filepath.Join(a.dir, manifestFile))
+	if os.IsNotExist(err) {
+		return m, nil
+	}
+	if err != nil {
+		return nil, errors.NewAPIError("MIRROR_READ_FAILED", "failed to read manifest", err)
+	}
+	if err := json.Unmarshal(b, m); err != nil {
+		return nil, errors.NewAPIError("MIRROR_DECODE_FAILED", "failed to decode manifest", err)
+	}
+	return m, nil
+}
+
+func (a *Archive) writeManifest(m *manifest) error {
+	b, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return errors.NewAPIError("MIRROR_ENCODE_FAILED", "failed to encode manifest", err)
+	}
+	return a.writeFile(manifestFile, b)
+}
+
+// writeFile writes atomically via a temporary file
+func (a *Archive) writeFile(name string, b []byte) error {
+	path := filepath.Join(a.dir, name)
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, b, 0o600); err != nil {
+		_ = os.Remove(tmp)
+		return errors.NewAPIError("MIRROR_WRITE_FAILED", "failed to write "+name, err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		_ = os.Remove(tmp)
+		return errors.NewAPIError("MIRROR_WRITE_FAILED", "failed to write "+name, err)
+	}
+	return nil
+}
+
+func checksum(b []byte) string {
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,121 @@
+package mirror
+
+import (
+	"encoding/json"
+
+	"github.com/jo-hoe/todoapi/todoclient"
+)
+
+// Diff lists the changes between two snapshots
+type Diff struct {
+	From           int                     `json:"from"` // Version of the older snapshot, 0 for none
+	To             int                     `json:"to"`
+	ParentsAdded   []todoclient.ToDoParent `json:"parents_added"`
+	ParentsRemoved []todoclient.ToDoParent `json:"parents_removed"`
+	ParentsRenamed []todoclient.ToDoParent `json:"parents_renamed"` // Parents with their new name
+	TasksAdded     []TaskChange            `json:"tasks_added"`
+	TasksRemoved   []TaskChange            `json:"tasks_removed"`
+	TasksChanged   []TaskChange            `json:"tasks_changed"`
+}
+
+// TaskChange is a task in the older (Before) and newer (After) snapshot.
+// Before is nil for added and After is nil for removed tasks.
+type TaskChange struct {
+	ParentID string               `json:"parent_id"`
+	Before   *todoclient.ToDoTask `json:"before,omitempty"`
+	After    *todoclient.ToDoTask `json:"after,omitempty"`
+}
+
+// Count returns the number of changes
+func (d Diff) Count() int {
+	return len(d.ParentsAdded) + len(d.ParentsRemoved) + len(d.ParentsRenamed) +
+		len(d.TasksAdded) + len(d.TasksRemoved) + len(d.TasksChanged)
+}
+
+// IsEmpty reports whether both snapshots are equal
+func (d Diff) IsEmpty() bool {
+	return d.Count() == 0
+}
+
+// Compare returns the changes from older to newer. A nil older snapshot is
+// treated as empty, so that everything in newer is reported as added.
+// Tasks are identified by parent and task ID, as task IDs of some providers
+// are only unique within their parent. A task moved to another parent is
+// reported as removed and added.
+func Compare(older, newer *Snapshot) Diff {
+	if older == nil {
+		older = &Snapshot{}
+	}
+	diff := Diff{
+		From:           older.Version,
+		To:             newer.Version,
+		ParentsAdded:   make([]todoclient.ToDoParent, 0),
+		ParentsRemoved: make([]todoclient.ToDoParent, 0),
+		ParentsRenamed: make([]todoclient.ToDoParent, 0),
+		TasksAdded:     make([]TaskChange, 0),
+		TasksRemoved:   make([]TaskChange, 0),
+		TasksChanged:   make([]TaskChange, 0),
+	}
+
+	oldParents, oldTasks := index(older)
+	newParents, newTasks := index(newer)
+
+	for _, parent := range newer.Parents {
+		old, ok := oldParents[parent.Parent.ID]
+		switch {
+		case !ok:
+			diff.ParentsAdded = append(diff.ParentsAdded, parent.Parent)
+		case old.Name != parent.Parent.Name:
+			diff.ParentsRenamed = append(diff.ParentsRenamed, parent.Parent)
+		}
+		for _, task := range parent.Tasks {
+			task := task
+			old, ok := oldTasks[taskKey(parent.Parent.ID, task.ID)]
+			switch {
+			case !ok:
+				diff.TasksAdded = append(diff.TasksAdded, TaskChange{ParentID: parent.Parent.ID, After: &task})
+			case !equalTasks(old, task):
+				before := old
+				diff.TasksChanged = append(diff.TasksChanged, TaskChange{ParentID: parent.Parent.ID, Before: &before, After: &task})
+			}
+		}
+	}
+
+	for _, parent := range older.Parents {
+		if _, ok := newParents[parent.Parent.ID]; !ok {
+			diff.ParentsRemoved = append(diff.ParentsRemoved, parent.Parent)
+		}
+		for _, task := range parent.Tasks {
+			task := task
+			if _, ok := newTasks[taskKey(parent.Parent.ID, task.ID)]; !ok {
+				diff.TasksRemoved = append(diff.TasksRemoved, TaskChange{ParentID: parent.Parent.ID, Before: &task})
+			}
+		}
+	}
+	return diff
+}
+
+// index returns the parents by ID and the tasks by taskKey
+func index(snapshot *Snapshot) (map[string]todoclient.ToDoParent, map[string]todoclient.ToDoTask) {
+	parents := make(map[string]todoclient.ToDoParent, len(snapshot.Parents))
+	tasks := make(map[string]todoclient.ToDoTask)
+	for _, parent := range snapshot.Parents {
+		parents[parent.Parent.ID] = parent.Parent
+		for _, task := range parent.Tasks {
+			tasks[taskKey(parent.Parent.ID, task.ID)] = task
+		}
+	}
+	return parents, tasks
+}
+
+func taskKey(parentID, taskID string) string {
+	return parentID + "\x00" + taskID
+}
+
+// equalTasks compares the JSON representation, as archived tasks lose
+// details like the monotonic clock reading of their times
+func equalTasks(a, b todoclient.ToDoTask) bool {
+	x, _ := json.Marshal(a)
+	y, _ := json.Marshal(b)
+	return string(x) == string(y)
+}
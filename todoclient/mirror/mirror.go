@@ -0,0 +1,55 @@
+package mirror
+
+import (
+	"context"
+	"time"
+
+	"github.com/jo-hoe/todoapi/todoclient"
+)
+
+// Mirror periodically snapshots a provider into an archive
+type Mirror struct {
+	client  todoclient.ToDoClient
+	archive *Archive
+}
+
+// New creates a mirror of client writing to archive
+func New(client todoclient.ToDoClient, archive *Archive) *Mirror {
+	return &Mirror{
+		client:  client,
+		archive: archive,
+	}
+}
+
+// RunOnce takes a snapshot and archives it
+func (m *Mirror) RunOnce(ctx context.Context) (Entry, error) {
+	snapshot, err := Take(ctx, m.client)
+	if err != nil {
+		return Entry{}, err
+	}
+	return m.archive.Save(snapshot)
+}
+
+// Run takes a snapshot immediately and then every interval until the context
+// is canceled. The result of every run is passed to done, failed runs do not
+// stop the schedule.
+func (m *Mirror) Run(ctx context.Context, interval time.Duration, done func(Entry, error)) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		entry, err := m.RunOnce(ctx)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if done != nil {
+			done(entry, err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,233 @@
+package mirror
+
+import (
+	"context"
+	stderrors "errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/jo-hoe/todoapi/internal/testutil"
+	"github.com/jo-hoe/todoapi/pkg/errors"
+	"github.com/jo-hoe/todoapi/todoclient"
+)
+
+// flatClient is a provider without subtasks
+type flatClient struct {
+	*testutil.MockToDoClient
+}
+
+func (c flatClient) Capabilities() todoclient.Capabilities {
+	return todoclient.DefaultCapabilities()
+}
+
+func seed(t *testing.T) (*testutil.MockToDoClient, todoclient.ToDoParent, todoclient.ToDoTask) {
+	t.Helper()
+	ctx := context.Background()
+	client := testutil.NewMockToDoClient()
+	parent, _ := client.CreateParent(ctx, "Work")
+	task, _ := client.CreateTask(ctx, parent.ID, todoclient.ToDoTask{
+		Name:        "report",
+		Description: "quarterly",
+		DueDate:     time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC),
+		Labels:      []string{"office"},
+	})
+	_, _ = client.CreateTask(ctx, parent.ID, todoclient.ToDoTask{Name: "mail"})
+	return client, parent, task
+}
+
+func TestMirror_SnapshotsAndDiffs(t *testing.T) {
+	ctx := context.Background()
+	client, parent, task := seed(t)
+	archive, err := OpenArchive(t.TempDir())
+	if err != nil {
+		t.Fatalf("error was not nil but '%v'", err)
+	}
+	mirror := New(client, archive)
+
+	first, err := mirror.RunOnce(ctx)
+	if err != nil {
+		t.Fatalf("error was not nil but '%v'", err)
+	}
+	if first.Version != 1 || first.Parents != 1 || first.Tasks != 2 || first.Changes != 3 {
+		t.Errorf("unexpected first entry %+v", first)
+	}
+
+	task.Description = "yearly"
+	_ = client.UpdateTask(ctx, parent.ID, task)
+	_, _ = client.CreateTask(ctx, parent.ID, todoclient.ToDoTask{Name: "call"})
+	second, err := mirror.RunOnce(ctx)
+	if err != nil {
+		t.Fatalf("error was not nil but '%v'", err)
+	}
+
+	diff, err := archive.Diff(second.Version)
+	if err != nil {
+		t.Fatalf("error was not nil but '%v'", err)
+	}
+	if diff.From != 1 || diff.To != 2 || len(diff.TasksAdded) != 1 || len(diff.TasksChanged) != 1 || len(diff.TasksRemoved) != 0 {
+		t.Errorf("unexpected diff %+v", diff)
+	}
+	if diff.TasksChanged[0].Before.Description != "quarterly" || diff.TasksChanged[0].After.Description != "yearly" {
+		t.Errorf("unexpected change %+v", diff.TasksChanged[0])
+	}
+
+	third, _ := mirror.RunOnce(ctx)
+	if third.Changes != 0 {
+		t.Errorf("expected no changes for unchanged provider but found %d", third.Changes)
+	}
+	entries, err := archive.Entries()
+	if err != nil || len(entries) != 3 {
+		t.Errorf("expected 3 entries but found %d and '%v'", len(entries), err)
+	}
+}
+
+func TestCompare_SameTaskIDInDifferentParents(t *testing.T) {
+	// issue numbers like those of GitHub repositories repeat across parents
+	older := &Snapshot{Version: 1, Parents: []ParentSnapshot{
+		{Parent: todoclient.ToDoParent{ID: "alice/app", Name: "app"}, Tasks: []todoclient.ToDoTask{{ID: "1", Name: "crash"}}},
+		{Parent: todoclient.ToDoParent{ID: "alice/web", Name: "web"}, Tasks: []todoclient.ToDoTask{{ID: "1", Name: "layout"}, {ID: "2", Name: "fonts"}}},
+	}}
+	newer := &Snapshot{Version: 2, Parents: []ParentSnapshot{
+		{Parent: todoclient.ToDoParent{ID: "alice/app", Name: "app"}, Tasks: []todoclient.ToDoTask{{ID: "1", Name: "crash"}, {ID: "2", Name: "fonts"}}},
+		{Parent: todoclient.ToDoParent{ID: "alice/web", Name: "web"}, Tasks: []todoclient.ToDoTask{{ID: "1", Name: "new layout"}}},
+	}}
+
+	diff := Compare(older, newer)
+
+	if len(diff.TasksChanged) != 1 || diff.TasksChanged[0].ParentID != "alice/web" || diff.TasksChanged[0].Before.Name != "layout" {
+		t.Errorf("expected changed task of 'alice/web' but found %+v", diff.TasksChanged)
+	}
+	// a task moved to another parent is removed and added
+	if len(diff.TasksAdded) != 1 || diff.TasksAdded[0].ParentID != "alice/app" {
+		t.Errorf("expected added task of 'alice/app' but found %+v", diff.TasksAdded)
+	}
+	if len(diff.TasksRemoved) != 1 || diff.TasksRemoved[0].ParentID != "alice/web" {
+		t.Errorf("expected removed task of 'alice/web' but found %+v", diff.TasksRemoved)
+	}
+}
+
+func TestArchive_DetectsCorruption(t *testing.T) {
+	client, _, _ := seed(t)
+	dir := t.TempDir()
+	archive, _ := OpenArchive(dir)
+	entry, err := New(client, archive).RunOnce(context.Background())
+	if err != nil {
+		t.Fatalf("error was not nil but '%v'", err)
+	}
+	if err := archive.Verify(); err != nil {
+		t.Errorf("error was not nil but '%v'", err)
+	}
+
+	path := filepath.Join(dir, entry.File)
+	b, _ := os.ReadFile(path)
+	_ = os.WriteFile(path, append(b, ' '), 0o600)
+
+	if _, err := archive.Load(entry.Version); err == nil {
+		t.Error("expected checksum error for modified snapshot")
+	}
+	if err := archive.Verify(); err == nil {
+		t.Error("expected verification to fail")
+	}
+	if _, err := archive.Load(42); !stderrors.Is(err, errors.ErrNotFound) {
+		t.Errorf("expected not found error but found '%v'", err)
+	}
+}
+
+func TestRestore(t *testing.T) {
+	ctx := context.Background()
+	source, parent, task := seed(t)
+	snapshot, err := Take(ctx, source)
+	if err != nil {
+		t.Fatalf("error was not nil but '%v'", err)
+	}
+
+	// into a different provider
+	target := testutil.NewMockToDoClient()
+	report, err := Restore(ctx, snapshot, target)
+	if err != nil {
+		t.Fatalf("error was not nil but '%v'", err)
+	}
+	parents, _ := target.GetAllParents(ctx)
+	if report.ParentsCreated != 1 || report.TasksCreated != 2 || len(parents) != 1 {
+		t.Errorf("unexpected report %+v", report)
+	}
+	restored, _ := target.GetChildrenTasks(ctx, parents[0].ID, nil)
+	if len(restored) != 2 || restored[0].Description != "quarterly" || !restored[0].DueDate.Equal(task.DueDate) {
+		t.Errorf("unexpected restored tasks %+v", restored)
+	}
+
+	// into the same provider after changes
+	task.Description = "changed"
+	_ = source.UpdateTask(ctx, parent.ID, task)
+	tasks, _ := source.GetChildrenTasks(ctx, parent.ID, nil)
+	_ = source.DeleteTask(ctx, parent.ID, tasks[1].ID)
+	report, err = Restore(ctx, snapshot, source)
+	if err != nil {
+		t.Fatalf("error was not nil but '%v'", err)
+	}
+	if report.ParentsCreated != 0 || report.TasksUpdated != 1 || report.TasksCreated != 1 {
+		t.Errorf("unexpected report %+v", report)
+	}
+	if current, _ := source.GetTask(ctx, parent.ID, task.ID); current.Description != "quarterly" {
+		t.Errorf("expected restored description but found '%s'", current.Description)
+	}
+}
+
+func TestRestore_Subtasks(t *testing.T) {
+	ctx := context.Background()
+	// tasks are ordered by ID, the subtask comes before its parent task
+	snapshot := &Snapshot{Parents: []ParentSnapshot{{
+		Parent: todoclient.ToDoParent{ID: "p", Name: "Work"},
+		Tasks: []todoclient.ToDoTask{
+			{ID: "a", Name: "subtask", ParentTaskID: "b"},
+			{ID: "b", Name: "task"},
+		},
+	}}}
+
+	target := testutil.NewMockToDoClient()
+	if _, err := Restore(ctx, snapshot, target); err != nil {
+		t.Fatalf("error was not nil but '%v'", err)
+	}
+	tasks, _ := target.GetAllTasks(ctx, nil)
+	byName := make(map[string]todoclient.ToDoTask)
+	for _, task := range tasks {
+		byName[task.Name] = task
+	}
+	if len(tasks) != 2 || byName["subtask"].ParentTaskID != byName["task"].ID {
+		t.Errorf("expected subtask of restored task but found %+v", tasks)
+	}
+
+	// providers without subtasks get the tasks flattened
+	flat := flatClient{testutil.NewMockToDoClient()}
+	if _, err := Restore(ctx, snapshot, flat); err != nil {
+		t.Fatalf("error was not nil but '%v'", err)
+	}
+	tasks, _ = flat.GetAllTasks(ctx, nil)
+	for _, task := range tasks {
+		if task.ParentTaskID != "" {
+			t.Errorf("expected no parent task but found '%s'", task.ParentTaskID)
+		}
+	}
+}
+
+func TestMirror_Run(t *testing.T) {
+	client, _, _ := seed(t)
+	archive, _ := OpenArchive(t.TempDir())
+	ctx, cancel := context.WithCancel(context.Background())
+
+	runs := 0
+	err := New(client, archive).Run(ctx, time.Millisecond, func(entry Entry, err error) {
+		if err != nil {
+			t.Errorf("error was not nil but '%v'", err)
+		}
+		if runs++; runs == 2 {
+			cancel()
+		}
+	})
+
+	if !stderrors.Is(err, context.Canceled) || runs != 2 {
+		t.Errorf("expected 2 runs until cancellation but found %d and '%v'", runs, err)
+	}
+}
@@ -0,0 +1,137 @@
+package mirror
+
+import (
+	"context"
+	"strings"
+
+	"github.com/jo-hoe/todoapi/pkg/errors"
+	"github.com/jo-hoe/todoapi/todoclient"
+)
+
+// RestoreReport summarizes the changes of a restore
+type RestoreReport struct {
+	ParentsCreated int `json:"parents_created"`
+	TasksCreated   int `json:"tasks_created"`
+	TasksUpdated   int `json:"tasks_updated"`
+	TasksUnchanged int `json:"tasks_unchanged"`
+}
+
+// Restore recreates the parents and tasks of the snapshot in client, which may
+// be the provider the snapshot was taken from or a different one.
+//
+// Parents are matched by ID and then by name, tasks by ID and then by name
+// within their parent. Missing objects are created and differing tasks are
+// overwritten. Objects not in the snapshot are left untouched.
+func Restore(ctx context.Context, snapshot *Snapshot, client todoclient.ToDoClient) (RestoreReport, error) {
+	var report RestoreReport
+
+	existing, err := client.GetAllParents(ctx)
+	if err != nil {
+		return report, errors.NewAPIError("MIRROR_GET_PARENTS_FAILED", "failed to retrieve parents", err)
+	}
+	used := make(map[string]bool)
+
+	for _, archived := range snapshot.Parents {
+		parent, ok := matchParent(existing, used, archived.Parent)
+		if !ok {
+			parent, err = client.CreateParent(ctx, archived.Parent.Name)
+			if err != nil {
+				return report, errors.NewAPIError("MIRROR_CREATE_PARENT_FAILED", "failed to create parent", err)
+			}
+			report.ParentsCreated++
+		}
+		used[parent.ID] = true
+
+		if err := restoreTasks(ctx, client, parent.ID, archived.Tasks, !ok, &report); err != nil {
+			return report, err
+		}
+	}
+	return report, nil
+}
+
+// restoreTasks creates parent tasks before their subtasks and points subtasks to the
+// restored parent tasks. Targets without subtasks get the tasks flattened.
+func restoreTasks(ctx context.Context, client todoclient.ToDoClient, parentID string, archived []todoclient.ToDoTask, created bool, report *RestoreReport) error {
+	tasks := make([]todoclient.ToDoTask, 0)
+	if !created {
+		var err error
+		if tasks, err = client.GetChildrenTasks(ctx, parentID, nil); err != nil {
+			return errors.NewAPIError("MIRROR_GET_TASKS_FAILED", "failed to retrieve tasks", err)
+		}
+	}
+	used := make(map[string]bool)
+	subtasks := todoclient.CapabilitiesOf(client).Subtasks
+	// IDs of the snapshot mapped to the IDs of the restored tasks
+	restored := make(map[string]string, len(archived))
+
+	for _, task := range todoclient.SortByParentTask(archived) {
+		archivedID := task.ID
+		task.ParentTaskID = restored[task.ParentTaskID]
+		if !subtasks {
+			task.ParentTaskID = ""
+		}
+
+		current, ok := matchTask(tasks, used, task)
+		if !ok {
+			task.ID = ""
+			createdTask, err := client.CreateTask(ctx, parentID, task)
+			if err != nil {
+				return errors.NewAPIError("MIRROR_CREATE_TASK_FAILED", "failed to create task", err)
+			}
+			restored[archivedID] = createdTask.ID
+			report.TasksCreated++
+			continue
+		}
+		used[current.ID] = true
+		restored[archivedID] = current.ID
+
+		task.ID = current.ID
+		if sameContent(current, task) {
+			report.TasksUnchanged++
+			continue
+		}
+		if err := client.UpdateTask(ctx, parentID, task); err != nil {
+			return errors.NewAPIError("MIRROR_UPDATE_TASK_FAILED", "failed to update task", err)
+		}
+		report.TasksUpdated++
+	}
+	return nil
+}
+
+func matchParent(parents []todoclient.ToDoParent, used map[string]bool, archived todoclient.ToDoParent) (todoclient.ToDoParent, bool) {
+	for _, parent := range parents {
+		if !used[parent.ID] && parent.ID == archived.ID {
+			return parent, true
+		}
+	}
+	for _, parent := range parents {
+		if !used[parent.ID] && strings.EqualFold(parent.Name, archived.Name) {
+			return parent, true
+		}
+	}
+	return todoclient.ToDoParent{}, false
+}
+
+func matchTask(tasks []todoclient.ToDoTask, used map[string]bool, archived todoclient.ToDoTask) (todoclient.ToDoTask, bool) {
+	for _, task := range tasks {
+		if !used[task.ID] && task.ID == archived.ID {
+			return task, true
+		}
+	}
+	for _, task := range tasks {
+		if !used[task.ID] && task.Name == archived.Name {
+			return task, true
+		}
+	}
+	return todoclient.ToDoTask{}, false
+}
+
+// sameContent compares the fields written by a restore
+func sameContent(a, b todoclient.ToDoTask) bool {
+	return a.Name == b.Name &&
+		a.Description == b.Description &&
+		a.DueDate.Equal(b.DueDate) &&
+		a.IsCompleted == b.IsCompleted &&
+		a.ParentTaskID == b.ParentTaskID &&
+		strings.Join(a.Labels, "\x00") == strings.Join(b.Labels, "\x00")
+}
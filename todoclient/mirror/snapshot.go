@@ -0,0 +1,63 @@
+// Package mirror takes snapshots of providers, keeps them in a versioned archive
+// and restores archived snapshots into a provider
+package mirror
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/jo-hoe/todoapi/pkg/errors"
+	"github.com/jo-hoe/todoapi/todoclient"
+)
+
+// Snapshot is the state of all parents and tasks of a provider at a point in time
+type Snapshot struct {
+	Version   int              `json:"version"` // Assigned by the archive, 0 if not archived
+	CreatedAt time.Time        `json:"created_at"`
+	Parents   []ParentSnapshot `json:"parents"`
+}
+
+// ParentSnapshot is a parent with its tasks
+type ParentSnapshot struct {
+	Parent todoclient.ToDoParent `json:"parent"`
+	Tasks  []todoclient.ToDoTask `json:"tasks"`
+}
+
+// Take reads all parents and their tasks from the client. Parents and tasks
+// are ordered by ID, so that snapshots of an unchanged provider are equal.
+func Take(ctx context.Context, client todoclient.ToDoClient) (*Snapshot, error) {
+	parents, err := client.GetAllParents(ctx)
+	if err != nil {
+		return nil, errors.NewAPIError("MIRROR_GET_PARENTS_FAILED", "failed to retrieve parents", err)
+	}
+
+	snapshot := &Snapshot{
+		CreatedAt: time.Now().UTC(),
+		Parents:   make([]ParentSnapshot, 0, len(parents)),
+	}
+	for _, parent := range parents {
+		tasks, err := client.GetChildrenTasks(ctx, parent.ID, nil)
+		if err != nil {
+			return nil, errors.NewAPIError("MIRROR_GET_TASKS_FAILED", "failed to retrieve tasks", err)
+		}
+		tasks = append(make([]todoclient.ToDoTask, 0, len(tasks)), tasks...)
+		sort.Slice(tasks, func(i, j int) bool {
+			return tasks[i].ID < tasks[j].ID
+		})
+		snapshot.Parents = append(snapshot.Parents, ParentSnapshot{Parent: parent, Tasks: tasks})
+	}
+	sort.Slice(snapshot.Parents, func(i, j int) bool {
+		return snapshot.Parents[i].Parent.ID < snapshot.Parents[j].Parent.ID
+	})
+	return snapshot, nil
+}
+
+// TaskCount returns the number of tasks in the snapshot
+func (s *Snapshot) TaskCount() int {
+	count := 0
+	for _, parent := range s.Parents {
+		count += len(parent.Tasks)
+	}
+	return count
+}
@@ -0,0 +1,113 @@
+package todoclient
+
+import (
+	"sort"
+	"strings"
+	"time"
+)
+
+// TaskOrder defines the order in which a task listing is returned.
+type TaskOrder string
+
+const (
+	OrderNone           TaskOrder = ""              // Provider order
+	OrderByDueDate      TaskOrder = "due_date"      // Earliest due date first, tasks without due date last
+	OrderByCreationTime TaskOrder = "creation_time" // Oldest task first
+	OrderByName         TaskOrder = "name"          // Alphabetical by name
+)
+
+// TaskQuery narrows down the tasks returned by the listing methods of a ToDoClient.
+// Providers translate as much of the query as possible into native filtering and
+// apply the remainder client-side. A nil or zero TaskQuery matches every task.
+type TaskQuery struct {
+	DueAfter  time.Time `json:"due_after"`  // Only tasks due at or after this time
+	DueBefore time.Time `json:"due_before"` // Only tasks due before this time
+	Completed *bool     `json:"completed"`  // Only tasks with the given completion state
+	Labels    []string  `json:"labels"`     // Only tasks carrying all of these labels
+	Text      string    `json:"text"`       // Case-insensitive substring of name or description
+	OrderBy   TaskOrder `json:"order_by"`   // Sort order of the result
+	Limit     int       `json:"limit"`      // Maximum number of tasks, 0 for no limit
+}
+
+// IsZero reports whether the query neither filters, sorts nor limits.
+func (q *TaskQuery) IsZero() bool {
+	return q == nil || (q.DueAfter.IsZero() && q.DueBefore.IsZero() && q.Completed == nil &&
+		len(q.Labels) == 0 && q.Text == "" && q.OrderBy == OrderNone && q.Limit <= 0)
+}
+
+// Matches reports whether a task satisfies the filter criteria of the query.
+// Ordering and limit are not considered.
+func (q *TaskQuery) Matches(task ToDoTask) bool {
+	if q == nil {
+		return true
+	}
+	if !q.DueAfter.IsZero() && (task.DueDate.IsZero() || task.DueDate.Before(q.DueAfter)) {
+		return false
+	}
+	if !q.DueBefore.IsZero() && (task.DueDate.IsZero() || !task.DueDate.Before(q.DueBefore)) {
+		return false
+	}
+	if q.Completed != nil && task.IsCompleted != *q.Completed {
+		return false
+	}
+	for _, label := range q.Labels {
+		if !hasLabel(task.Labels, label) {
+			return false
+		}
+	}
+	if q.Text != "" {
+		text := strings.ToLower(q.Text)
+		if !strings.Contains(strings.ToLower(task.Name), text) &&
+			!strings.Contains(strings.ToLower(task.Description), text) {
+			return false
+		}
+	}
+	return true
+}
+
+// Apply filters, sorts and limits tasks according to the query.
+// The result is always non-nil; the input slice is not modified.
+func (q *TaskQuery) Apply(tasks []ToDoTask) []ToDoTask {
+	result := make([]ToDoTask, 0, len(tasks))
+	for _, task := range tasks {
+		if q.Matches(task) {
+			result = append(result, task)
+		}
+	}
+	if q == nil {
+		return result
+	}
+
+	switch q.OrderBy {
+	case OrderByDueDate:
+		sort.SliceStable(result, func(i, j int) bool {
+			a, b := result[i].DueDate, result[j].DueDate
+			if a.IsZero() || b.IsZero() {
+				return !a.IsZero() && b.IsZero()
+			}
+			return a.Before(b)
+		})
+	case OrderByCreationTime:
+		sort.SliceStable(result, func(i, j int) bool {
+			return result[i].CreationTime.Before(result[j].CreationTime)
+		})
+	case OrderByName:
+		sort.SliceStable(result, func(i, j int) bool {
+			return strings.ToLower(result[i].Name) < strings.ToLower(result[j].Name)
+		})
+	}
+
+	if q.Limit > 0 && len(result) > q.Limit {
+		result = result[:q.Limit]
+	}
+	return result
+}
+
+func hasLabel(labels []string, label string) bool {
+	for _, l := range labels {
+		if strings.EqualFold(l, label) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,98 @@
+package todoclient
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTaskQuery_Matches(t *testing.T) {
+	due := time.Date(2024, 3, 10, 0, 0, 0, 0, time.UTC)
+	completed := true
+	open := false
+	task := ToDoTask{
+		ID:          "1",
+		Name:        "Write Report",
+		Description: "quarterly numbers",
+		DueDate:     due,
+		Labels:      []string{"work", "urgent"},
+	}
+
+	tests := []struct {
+		name  string
+		query *TaskQuery
+		want  bool
+	}{
+		{name: "nil query", query: nil, want: true},
+		{name: "zero query", query: &TaskQuery{}, want: true},
+		{name: "due after inclusive", query: &TaskQuery{DueAfter: due}, want: true},
+		{name: "due after excludes", query: &TaskQuery{DueAfter: due.Add(time.Hour)}, want: false},
+		{name: "due before exclusive", query: &TaskQuery{DueBefore: due}, want: false},
+		{name: "due before includes", query: &TaskQuery{DueBefore: due.Add(time.Hour)}, want: true},
+		{name: "open tasks", query: &TaskQuery{Completed: &open}, want: true},
+		{name: "completed tasks", query: &TaskQuery{Completed: &completed}, want: false},
+		{name: "all labels present", query: &TaskQuery{Labels: []string{"Work", "urgent"}}, want: true},
+		{name: "label missing", query: &TaskQuery{Labels: []string{"work", "home"}}, want: false},
+		{name: "text in name", query: &TaskQuery{Text: "report"}, want: true},
+		{name: "text in description", query: &TaskQuery{Text: "NUMBERS"}, want: true},
+		{name: "text missing", query: &TaskQuery{Text: "invoice"}, want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.query.Matches(task); got != tt.want {
+				t.Errorf("TaskQuery.Matches() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTaskQuery_Matches_NoDueDate(t *testing.T) {
+	query := &TaskQuery{DueBefore: time.Now()}
+
+	if query.Matches(ToDoTask{Name: "no due date"}) {
+		t.Error("expected task without due date not to match a due filter")
+	}
+}
+
+func TestTaskQuery_Apply(t *testing.T) {
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	tasks := []ToDoTask{
+		{ID: "1", Name: "c", DueDate: base.AddDate(0, 0, 2), CreationTime: base},
+		{ID: "2", Name: "a", CreationTime: base.AddDate(0, 0, 2)},
+		{ID: "3", Name: "B", DueDate: base.AddDate(0, 0, 1), CreationTime: base.AddDate(0, 0, 1)},
+	}
+
+	tests := []struct {
+		name  string
+		query *TaskQuery
+		want  []string
+	}{
+		{name: "nil query keeps order", query: nil, want: []string{"1", "2", "3"}},
+		{name: "order by due date", query: &TaskQuery{OrderBy: OrderByDueDate}, want: []string{"3", "1", "2"}},
+		{name: "order by creation", query: &TaskQuery{OrderBy: OrderByCreationTime}, want: []string{"1", "3", "2"}},
+		{name: "order by name", query: &TaskQuery{OrderBy: OrderByName}, want: []string{"2", "3", "1"}},
+		{name: "limit", query: &TaskQuery{OrderBy: OrderByName, Limit: 2}, want: []string{"2", "3"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.query.Apply(tasks)
+			if len(got) != len(tt.want) {
+				t.Fatalf("expected %d tasks but found %d", len(tt.want), len(got))
+			}
+			for i := range tt.want {
+				if got[i].ID != tt.want[i] {
+					t.Errorf("expected task[%d]=%s, got %s", i, tt.want[i], got[i].ID)
+				}
+			}
+		})
+	}
+}
+
+func TestTaskQuery_Apply_NonNil(t *testing.T) {
+	var query *TaskQuery
+
+	if result := query.Apply(nil); result == nil {
+		t.Error("expected non-nil result")
+	}
+}
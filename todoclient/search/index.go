@@ -0,0 +1,249 @@
+package search
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jo-hoe/todoapi/todoclient"
+)
+
+// RefreshStats summarizes the changes applied by an index refresh
+type RefreshStats struct {
+	Added     int `json:"added"`
+	Updated   int `json:"updated"`
+	Removed   int `json:"removed"`
+	Unchanged int `json:"unchanged"`
+}
+
+// Index is an in-memory inverted index over tasks of multiple providers.
+// Refreshes are incremental: only tasks whose content changed are re-indexed
+// and tasks which disappeared from a provider are removed.
+type Index struct {
+	refreshMu sync.Mutex // serializes refreshes
+	mu        sync.RWMutex
+	documents map[string]*indexedDocument
+	postings  map[string]map[string]struct{} // token -> document keys
+	refreshed map[string]time.Time           // provider -> time of the last successful refresh
+}
+
+type indexedDocument struct {
+	*document
+	hash string
+}
+
+// NewIndex creates an empty index
+func NewIndex() *Index {
+	return &Index{
+		documents: make(map[string]*indexedDocument),
+		postings:  make(map[string]map[string]struct{}),
+		refreshed: make(map[string]time.Time),
+	}
+}
+
+// LastRefresh returns the time of the last successful refresh of the provider,
+// zero if it was never refreshed
+func (idx *Index) LastRefresh(provider string) time.Time {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+	return idx.refreshed[provider]
+}
+
+// Len returns the number of indexed tasks
+func (idx *Index) Len() int {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+	return len(idx.documents)
+}
+
+// Refresh fetches all tasks from the providers and applies the differences to the index.
+// If a provider fails, its previously indexed tasks are kept, the remaining providers
+// are still refreshed and the failures are returned as ProviderErrors.
+func (idx *Index) Refresh(ctx context.Context, clients map[string]todoclient.ToDoClient) (RefreshStats, error) {
+	return idx.RefreshStale(ctx, clients, 0)
+}
+
+// RefreshStale is like Refresh but only refreshes providers whose last successful
+// refresh is older than maxAge. A maxAge of 0 refreshes all providers.
+func (idx *Index) RefreshStale(ctx context.Context, clients map[string]todoclient.ToDoClient, maxAge time.Duration) (RefreshStats, error) {
+	idx.refreshMu.Lock()
+	defer idx.refreshMu.Unlock()
+
+	var stats RefreshStats
+	errs := make(ProviderErrors)
+
+	for _, provider := range sortedProviders(clients) {
+		if maxAge > 0 && time.Since(idx.LastRefresh(provider)) <= maxAge {
+			continue
+		}
+		docs, err := providerDocuments(ctx, provider, clients[provider])
+		if err != nil {
+			errs[provider] = err
+			continue
+		}
+		idx.applyProvider(provider, docs, &stats)
+	}
+
+	// remove providers which are no longer configured
+	idx.mu.Lock()
+	for key, doc := range idx.documents {
+		if _, ok := clients[doc.provider]; !ok {
+			idx.remove(key)
+			stats.Removed++
+		}
+	}
+	for provider := range idx.refreshed {
+		if _, ok := clients[provider]; !ok {
+			delete(idx.refreshed, provider)
+		}
+	}
+	idx.mu.Unlock()
+
+	if len(errs) > 0 {
+		return stats, errs
+	}
+	return stats, nil
+}
+
+func (idx *Index) applyProvider(provider string, docs []*document, stats *RefreshStats) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	idx.refreshed[provider] = time.Now()
+
+	seen := make(map[string]struct{}, len(docs))
+	for _, doc := range docs {
+		seen[doc.key] = struct{}{}
+		hash := documentHash(doc)
+
+		existing, ok := idx.documents[doc.key]
+		switch {
+		case !ok:
+			stats.Added++
+		case existing.hash != hash:
+			idx.remove(doc.key)
+			stats.Updated++
+		default:
+			stats.Unchanged++
+			continue
+		}
+		idx.add(&indexedDocument{document: doc, hash: hash})
+	}
+
+	for key, doc := range idx.documents {
+		if _, ok := seen[key]; !ok && doc.provider == provider {
+			idx.remove(key)
+			stats.Removed++
+		}
+	}
+}
+
+// Search returns all indexed tasks matching the query in no particular order
+func (idx *Index) Search(query Query) []Result {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	result := make([]Result, 0)
+	for key := range idx.candidates(query) {
+		doc := idx.documents[key]
+		if score := doc.score(query); score > 0 {
+			result = append(result, doc.result(score))
+		}
+	}
+	return result
+}
+
+// candidates returns the documents containing all words of all terms.
+// Phrase order and fields are verified while scoring.
+func (idx *Index) candidates(query Query) map[string]struct{} {
+	var result map[string]struct{}
+	for _, term := range query.Terms {
+		for i, word := range term.Words {
+			var keys map[string]struct{}
+			if term.Prefix && i == len(term.Words)-1 {
+				keys = idx.prefixPostings(word)
+			} else {
+				keys = idx.postings[word]
+			}
+			result = intersect(result, keys)
+			if len(result) == 0 {
+				return result
+			}
+		}
+	}
+	return result
+}
+
+func (idx *Index) prefixPostings(prefix string) map[string]struct{} {
+	result := make(map[string]struct{})
+	for token, keys := range idx.postings {
+		if strings.HasPrefix(token, prefix) {
+			for key := range keys {
+				result[key] = struct{}{}
+			}
+		}
+	}
+	return result
+}
+
+// intersect returns the intersection of both sets, where a nil set stands for all documents
+func intersect(a, b map[string]struct{}) map[string]struct{} {
+	if a == nil {
+		result := make(map[string]struct{}, len(b))
+		for key := range b {
+			result[key] = struct{}{}
+		}
+		return result
+	}
+	for key := range a {
+		if _, ok := b[key]; !ok {
+			delete(a, key)
+		}
+	}
+	return a
+}
+
+func (idx *Index) add(doc *indexedDocument) {
+	idx.documents[doc.key] = doc
+	for _, token := range documentTokens(doc.document) {
+		keys, ok := idx.postings[token]
+		if !ok {
+			keys = make(map[string]struct{})
+			idx.postings[token] = keys
+		}
+		keys[doc.key] = struct{}{}
+	}
+}
+
+func (idx *Index) remove(key string) {
+	doc, ok := idx.documents[key]
+	if !ok {
+		return
+	}
+	for _, token := range documentTokens(doc.document) {
+		if keys, ok := idx.postings[token]; ok {
+			delete(keys, key)
+			if len(keys) == 0 {
+				delete(idx.postings, token)
+			}
+		}
+	}
+	delete(idx.documents, key)
+}
+
+func documentTokens(doc *document) []string {
+	return append(append(make([]string, 0, len(doc.name)+len(doc.description)), doc.name...), doc.description...)
+}
+
+// documentHash identifies the content of a document including its parent context
+func documentHash(doc *document) string {
+	data, _ := json.Marshal(struct {
+		Parent todoclient.ToDoParent
+		Task   todoclient.ToDoTask
+	}{doc.parent, doc.task})
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,113 @@
+package search
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Field restricts a term to a part of a task
+type Field string
+
+const (
+	FieldAny         Field = ""            // Name or description
+	FieldName        Field = "name"        // Task name only
+	FieldDescription Field = "description" // Task description only
+)
+
+// Term is a single search criterion. All terms of a query have to match.
+type Term struct {
+	Field  Field    // Field the term is restricted to
+	Words  []string // Normalized words, more than one for phrases
+	Phrase bool     // Words have to appear consecutively
+	Prefix bool     // The last word only needs to be a prefix of a token
+}
+
+// Query is a parsed search query
+type Query struct {
+	Terms []Term
+}
+
+// IsEmpty reports whether the query does not contain any term
+func (q Query) IsEmpty() bool {
+	return len(q.Terms) == 0
+}
+
+// fieldAliases maps the supported field prefixes to fields
+var fieldAliases = map[string]Field{
+	"name":        FieldName,
+	"title":       FieldName,
+	"description": FieldDescription,
+	"desc":        FieldDescription,
+}
+
+// ParseQuery parses a search query. Supported syntax:
+//   - word: matches tokens equal to the word
+//   - word*: matches tokens starting with the word
+//   - "some phrase": matches the words in this order
+//   - name:word, description:"some phrase": restricts a term to a field
+//
+// Unknown field prefixes are treated as part of the word and an unterminated
+// quote extends the phrase to the end of the input.
+func ParseQuery(input string) Query {
+	query := Query{Terms: make([]Term, 0)}
+	runes := []rune(input)
+
+	for i := 0; i < len(runes); {
+		if unicode.IsSpace(runes[i]) {
+			i++
+			continue
+		}
+
+		// read the raw token up to the next whitespace outside of quotes
+		start := i
+		inQuote := false
+		for i < len(runes) && (inQuote || !unicode.IsSpace(runes[i])) {
+			if runes[i] == '"' {
+				inQuote = !inQuote
+			}
+			i++
+		}
+		if term, ok := parseTerm(string(runes[start:i])); ok {
+			query.Terms = append(query.Terms, term)
+		}
+	}
+
+	return query
+}
+
+func parseTerm(raw string) (Term, bool) {
+	term := Term{Field: FieldAny}
+
+	if idx := strings.Index(raw, ":"); idx > 0 && !strings.Contains(raw[:idx], `"`) {
+		if field, ok := fieldAliases[strings.ToLower(raw[:idx])]; ok {
+			term.Field = field
+			raw = raw[idx+1:]
+		}
+	}
+
+	if strings.HasPrefix(raw, `"`) {
+		term.Phrase = true
+		raw = strings.TrimSuffix(strings.TrimPrefix(raw, `"`), `"`)
+	}
+	if strings.HasSuffix(raw, "*") {
+		term.Prefix = true
+		raw = strings.TrimRight(raw, "*")
+	}
+
+	term.Words = tokenize(raw)
+	if len(term.Words) == 0 {
+		return term, false
+	}
+	if len(term.Words) > 1 {
+		// words joined by punctuation, such as "e-mail", are matched as a phrase
+		term.Phrase = true
+	}
+	return term, true
+}
+
+// tokenize splits text into lower-cased words of letters and digits
+func tokenize(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
@@ -0,0 +1,52 @@
+package search
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseQuery(t *testing.T) {
+	tests := []struct {
+		input string
+		want  []Term
+	}{
+		{
+			input: "Report",
+			want:  []Term{{Field: FieldAny, Words: []string{"report"}}},
+		},
+		{
+			input: "rep* name:bike",
+			want: []Term{
+				{Field: FieldAny, Words: []string{"rep"}, Prefix: true},
+				{Field: FieldName, Words: []string{"bike"}},
+			},
+		},
+		{
+			input: `desc:"pull request" "open ended`,
+			want: []Term{
+				{Field: FieldDescription, Words: []string{"pull", "request"}, Phrase: true},
+				{Field: FieldAny, Words: []string{"open", "ended"}, Phrase: true},
+			},
+		},
+		{
+			input: "e-mail unknown:field",
+			want: []Term{
+				{Field: FieldAny, Words: []string{"e", "mail"}, Phrase: true},
+				{Field: FieldAny, Words: []string{"unknown", "field"}, Phrase: true},
+			},
+		},
+		{
+			input: `  "" * `,
+			want:  []Term{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got := ParseQuery(tt.input)
+			if !reflect.DeepEqual(got.Terms, tt.want) {
+				t.Errorf("ParseQuery() = %+v, want %+v", got.Terms, tt.want)
+			}
+		})
+	}
+}
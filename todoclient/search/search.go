@@ -0,0 +1,274 @@
+// Package search provides a unified task search across multiple todo providers
+package search
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jo-hoe/todoapi/pkg/errors"
+	"github.com/jo-hoe/todoapi/todoclient"
+)
+
+const (
+	nameWeight        = 3.0
+	descriptionWeight = 1.0
+	prefixFactor      = 0.5
+)
+
+// Result is a single task found by a search, including its provider and parent context
+type Result struct {
+	Provider string                `json:"provider"` // Name of the provider the task belongs to
+	Parent   todoclient.ToDoParent `json:"parent"`   // Parent (project/list) of the task
+	Task     todoclient.ToDoTask   `json:"task"`     // The matching task
+	Score    float64               `json:"score"`    // Relevance, higher is better
+}
+
+// Searcher finds tasks by name and description across all configured providers.
+// Without an index every search performs a full scan of all providers.
+type Searcher struct {
+	clients map[string]todoclient.ToDoClient
+	index   *Index
+	maxAge  time.Duration
+}
+
+// NewSearcher creates a searcher which scans all providers on each search
+func NewSearcher(clients map[string]todoclient.ToDoClient) *Searcher {
+	return &Searcher{
+		clients: clients,
+	}
+}
+
+// NewIndexedSearcher creates a searcher backed by a local index. The index is
+// refreshed incrementally before a search once it is older than maxAge.
+func NewIndexedSearcher(clients map[string]todoclient.ToDoClient, maxAge time.Duration) *Searcher {
+	return &Searcher{
+		clients: clients,
+		index:   NewIndex(),
+		maxAge:  maxAge,
+	}
+}
+
+// ProviderErrors holds the errors of failed providers by provider name. It is
+// returned along with the results of the remaining providers.
+type ProviderErrors map[string]error
+
+func (e ProviderErrors) Error() string {
+	messages := make([]string, 0, len(e))
+	for _, provider := range e.Providers() {
+		messages = append(messages, provider+": "+e[provider].Error())
+	}
+	return strings.Join(messages, "; ")
+}
+
+// Unwrap returns the errors of all providers
+func (e ProviderErrors) Unwrap() []error {
+	result := make([]error, 0, len(e))
+	for _, provider := range e.Providers() {
+		result = append(result, e[provider])
+	}
+	return result
+}
+
+// Providers returns the names of the failed providers in sorted order
+func (e ProviderErrors) Providers() []string {
+	result := make([]string, 0, len(e))
+	for provider := range e {
+		result = append(result, provider)
+	}
+	sort.Strings(result)
+	return result
+}
+
+// Refresh updates the index of the searcher. It is a no-op for searchers without index.
+func (s *Searcher) Refresh(ctx context.Context) error {
+	if s.index == nil {
+		return nil
+	}
+	_, err := s.index.Refresh(ctx, s.clients)
+	return err
+}
+
+// Search returns the tasks matching the query ordered by relevance.
+// A limit of 0 returns all matches. The result is always non-nil.
+//
+// If providers fail, the result contains the tasks of the remaining providers and,
+// for indexed searchers, the previously indexed tasks of the failed providers.
+// The failures are returned as ProviderErrors.
+func (s *Searcher) Search(ctx context.Context, input string, limit int) ([]Result, error) {
+	result := make([]Result, 0)
+
+	query := ParseQuery(input)
+	if query.IsEmpty() {
+		return result, errors.NewValidationError("query", "search query cannot be empty")
+	}
+
+	var err error
+	if s.index != nil {
+		// only providers with outdated tasks are refreshed
+		_, err = s.index.RefreshStale(ctx, s.clients, s.maxAge)
+		result = s.index.Search(query)
+	} else {
+		err = collectDocuments(ctx, s.clients, func(doc *document) {
+			if score := doc.score(query); score > 0 {
+				result = append(result, doc.result(score))
+			}
+		})
+	}
+
+	sortResults(result)
+	if limit > 0 && len(result) > limit {
+		result = result[:limit]
+	}
+	return result, err
+}
+
+// collectDocuments fetches all tasks of all providers in a stable order. Failed
+// providers are skipped and returned as ProviderErrors.
+func collectDocuments(ctx context.Context, clients map[string]todoclient.ToDoClient, fn func(doc *document)) error {
+	errs := make(ProviderErrors)
+	for _, provider := range sortedProviders(clients) {
+		docs, err := providerDocuments(ctx, provider, clients[provider])
+		if err != nil {
+			errs[provider] = err
+			continue
+		}
+		for _, doc := range docs {
+			fn(doc)
+		}
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+func providerDocuments(ctx context.Context, provider string, client todoclient.ToDoClient) ([]*document, error) {
+	parents, err := client.GetAllParents(ctx)
+	if err != nil {
+		return nil, errors.NewAPIError("SEARCH_GET_PARENTS_FAILED", "failed to retrieve parents of "+provider, err)
+	}
+
+	result := make([]*document, 0)
+	for _, parent := range parents {
+		tasks, err := client.GetChildrenTasks(ctx, parent.ID, nil)
+		if err != nil {
+			return nil, errors.NewAPIError("SEARCH_GET_TASKS_FAILED", "failed to retrieve tasks of "+provider, err)
+		}
+		for _, task := range tasks {
+			result = append(result, newDocument(provider, parent, task))
+		}
+	}
+	return result, nil
+}
+
+func sortedProviders(clients map[string]todoclient.ToDoClient) []string {
+	result := make([]string, 0, len(clients))
+	for name := range clients {
+		result = append(result, name)
+	}
+	sort.Strings(result)
+	return result
+}
+
+func sortResults(results []Result) {
+	sort.SliceStable(results, func(i, j int) bool {
+		a, b := results[i], results[j]
+		if a.Score != b.Score {
+			return a.Score > b.Score
+		}
+		if a.Provider != b.Provider {
+			return a.Provider < b.Provider
+		}
+		if a.Parent.Name != b.Parent.Name {
+			return a.Parent.Name < b.Parent.Name
+		}
+		return strings.ToLower(a.Task.Name) < strings.ToLower(b.Task.Name)
+	})
+}
+
+// document is a task prepared for matching
+type document struct {
+	key         string
+	provider    string
+	parent      todoclient.ToDoParent
+	task        todoclient.ToDoTask
+	name        []string
+	description []string
+}
+
+func newDocument(provider string, parent todoclient.ToDoParent, task todoclient.ToDoTask) *document {
+	return &document{
+		key:         documentKey(provider, parent.ID, task.ID),
+		provider:    provider,
+		parent:      parent,
+		task:        task,
+		name:        tokenize(task.Name),
+		description: tokenize(task.Description),
+	}
+}
+
+func documentKey(provider, parentID, taskID string) string {
+	return provider + "\x00" + parentID + "\x00" + taskID
+}
+
+func (d *document) result(score float64) Result {
+	return Result{
+		Provider: d.provider,
+		Parent:   d.parent,
+		Task:     d.task,
+		Score:    score,
+	}
+}
+
+// score returns the relevance of the document for a query, 0 if it does not match
+func (d *document) score(query Query) float64 {
+	total := 0.0
+	for _, term := range query.Terms {
+		termScore := 0.0
+		if term.Field == FieldAny || term.Field == FieldName {
+			termScore += nameWeight * float64(countMatches(d.name, term))
+		}
+		if term.Field == FieldAny || term.Field == FieldDescription {
+			termScore += descriptionWeight * float64(countMatches(d.description, term))
+		}
+		if termScore == 0 {
+			return 0
+		}
+
+		// longer phrases are more specific, prefixes less
+		termScore *= float64(len(term.Words))
+		if term.Prefix {
+			termScore *= prefixFactor
+		}
+		total += termScore
+	}
+	return total
+}
+
+// countMatches counts the positions in tokens where the term matches
+func countMatches(tokens []string, term Term) int {
+	count := 0
+	for i := 0; i+len(term.Words) <= len(tokens); i++ {
+		if matchesAt(tokens, i, term) {
+			count++
+		}
+	}
+	return count
+}
+
+func matchesAt(tokens []string, position int, term Term) bool {
+	last := len(term.Words) - 1
+	for i, word := range term.Words {
+		token := tokens[position+i]
+		if i == last && term.Prefix {
+			if !strings.HasPrefix(token, word) {
+				return false
+			}
+		} else if token != word {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,304 @@
+package search
+
+import (
+	"context"
+	stderrors "errors"
+	"testing"
+
+	"github.com/jo-hoe/todoapi/todoclient"
+)
+
+// stubClient serves fixed parents and tasks per parent
+type stubClient struct {
+	parents []todoclient.ToDoParent
+	tasks   map[string][]todoclient.ToDoTask
+	calls   int
+	err     error // returned by GetAllParents if set
+}
+
+func (s *stubClient) GetAllTasks(ctx context.Context, query *todoclient.TaskQuery) ([]todoclient.ToDoTask, error) {
+	result := make([]todoclient.ToDoTask, 0)
+	for _, tasks := range s.tasks {
+		result = append(result, tasks...)
+	}
+	return query.Apply(result), nil
+}
+
+func (s *stubClient) GetChildrenTasks(ctx context.Context, parentID string, query *todoclient.TaskQuery) ([]todoclient.ToDoTask, error) {
+	s.calls++
+	return query.Apply(s.tasks[parentID]), nil
+}
+
+func (s *stubClient) GetTask(ctx context.Context, parentID, taskID string) (todoclient.ToDoTask, error) {
+	return todoclient.ToDoTask{}, nil
+}
+
+func (s *stubClient) CreateTask(ctx context.Context, parentID string, task todoclient.ToDoTask) (todoclient.ToDoTask, error) {
+	return task, nil
+}
+
+func (s *stubClient) UpdateTask(ctx context.Context, parentID string, task todoclient.ToDoTask) error {
+	return nil
+}
+
+func (s *stubClient) DeleteTask(ctx context.Context, parentID, taskID string) error {
+	return nil
+}
+
+func (s *stubClient) GetAllParents(ctx context.Context) ([]todoclient.ToDoParent, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+	return s.parents, nil
+}
+
+func (s *stubClient) CreateParent(ctx context.Context, parentName string) (todoclient.ToDoParent, error) {
+	return todoclient.ToDoParent{}, nil
+}
+
+func (s *stubClient) DeleteParent(ctx context.Context, parentID string) error {
+	return nil
+}
+
+func createClients() (map[string]todoclient.ToDoClient, *stubClient) {
+	work := &stubClient{
+		parents: []todoclient.ToDoParent{{ID: "p1", Name: "Work"}, {ID: "p2", Name: "Home"}},
+		tasks: map[string][]todoclient.ToDoTask{
+			"p1": {
+				{ID: "t1", Name: "Write quarterly report", Description: "numbers for the board"},
+				{ID: "t2", Name: "Review pull request", Description: "report back to the team"},
+			},
+			"p2": {
+				{ID: "t3", Name: "Repair bike", Description: "front wheel"},
+			},
+		},
+	}
+	personal := &stubClient{
+		parents: []todoclient.ToDoParent{{ID: "a", Name: "Errands"}},
+		tasks: map[string][]todoclient.ToDoTask{
+			"a": {{ID: "t4", Name: "Buy bike lights"}},
+		},
+	}
+	return map[string]todoclient.ToDoClient{"todoist": work, "microsoft": personal}, work
+}
+
+func TestSearcher_Search(t *testing.T) {
+	clients, _ := createClients()
+	searchers := map[string]*Searcher{
+		"scan":    NewSearcher(clients),
+		"indexed": NewIndexedSearcher(clients, 0),
+	}
+
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{query: "report", want: []string{"t1", "t2"}},
+		{query: "name:report", want: []string{"t1"}},
+		{query: "description:report", want: []string{"t2"}},
+		{query: "bike", want: []string{"t4", "t3"}},
+		{query: "rep*", want: []string{"t3", "t1", "t2"}},
+		{query: `"pull request"`, want: []string{"t2"}},
+		{query: `"request pull"`, want: []string{}},
+		{query: "bike front", want: []string{"t3"}},
+		{query: "unknown", want: []string{}},
+	}
+
+	for name, searcher := range searchers {
+		for _, tt := range tests {
+			t.Run(name+"/"+tt.query, func(t *testing.T) {
+				results, err := searcher.Search(context.Background(), tt.query, 0)
+				if err != nil {
+					t.Fatalf("error was not nil but '%v'", err)
+				}
+				if len(results) != len(tt.want) {
+					t.Fatalf("expected %d results but found %d: %v", len(tt.want), len(results), results)
+				}
+				for i, id := range tt.want {
+					if results[i].Task.ID != id {
+						t.Errorf("expected result[%d]=%s but found %s", i, id, results[i].Task.ID)
+					}
+				}
+			})
+		}
+	}
+}
+
+func TestSearcher_Search_Context(t *testing.T) {
+	clients, _ := createClients()
+	searcher := NewSearcher(clients)
+
+	results, err := searcher.Search(context.Background(), "lights", 0)
+
+	if err != nil {
+		t.Fatalf("error was not nil but '%v'", err)
+	}
+	if len(results) != 1 {
+		t.Fatalf("expected 1 result but found %d", len(results))
+	}
+	if results[0].Provider != "microsoft" || results[0].Parent.Name != "Errands" {
+		t.Errorf("unexpected context %s/%s", results[0].Provider, results[0].Parent.Name)
+	}
+}
+
+func TestSearcher_Search_Limit(t *testing.T) {
+	clients, _ := createClients()
+	searcher := NewSearcher(clients)
+
+	results, err := searcher.Search(context.Background(), "r*", 2)
+
+	if err != nil {
+		t.Fatalf("error was not nil but '%v'", err)
+	}
+	if len(results) != 2 {
+		t.Errorf("expected 2 results but found %d", len(results))
+	}
+}
+
+func TestSearcher_Search_EmptyQuery(t *testing.T) {
+	clients, _ := createClients()
+	searcher := NewSearcher(clients)
+
+	results, err := searcher.Search(context.Background(), "  \"\" ", 0)
+
+	if err == nil {
+		t.Error("expected an error for an empty query")
+	}
+	if results == nil {
+		t.Error("expected non-nil results")
+	}
+}
+
+func TestSearcher_IndexIsReusedWithinMaxAge(t *testing.T) {
+	clients, work := createClients()
+	searcher := NewIndexedSearcher(clients, 1<<62)
+	ctx := context.Background()
+
+	if _, err := searcher.Search(ctx, "bike", 0); err != nil {
+		t.Fatalf("error was not nil but '%v'", err)
+	}
+	calls := work.calls
+	if _, err := searcher.Search(ctx, "report", 0); err != nil {
+		t.Fatalf("error was not nil but '%v'", err)
+	}
+
+	if work.calls != calls {
+		t.Errorf("expected no provider calls but found %d", work.calls-calls)
+	}
+}
+
+func TestSearcher_Search_ProviderFailure(t *testing.T) {
+	clients, work := createClients()
+	work.err = stderrors.New("service unavailable")
+
+	for name, searcher := range map[string]*Searcher{
+		"scan":    NewSearcher(clients),
+		"indexed": NewIndexedSearcher(clients, 0),
+	} {
+		t.Run(name, func(t *testing.T) {
+			results, err := searcher.Search(context.Background(), "bike", 0)
+
+			var providerErrors ProviderErrors
+			if !stderrors.As(err, &providerErrors) {
+				t.Fatalf("expected provider errors but found '%v'", err)
+			}
+			if len(providerErrors) != 1 || !stderrors.Is(providerErrors["todoist"], work.err) {
+				t.Errorf("expected error of 'todoist' but found '%v'", providerErrors)
+			}
+			if len(results) != 1 || results[0].Task.ID != "t4" {
+				t.Errorf("expected results of the healthy provider but found %v", results)
+			}
+		})
+	}
+}
+
+func TestIndex_Refresh_ProviderFailure(t *testing.T) {
+	clients, work := createClients()
+	index := NewIndex()
+	ctx := context.Background()
+	if _, err := index.Refresh(ctx, clients); err != nil {
+		t.Fatalf("error was not nil but '%v'", err)
+	}
+	refreshed := index.LastRefresh("todoist")
+
+	work.err = stderrors.New("service unavailable")
+	stats, err := index.Refresh(ctx, clients)
+
+	var providerErrors ProviderErrors
+	if !stderrors.As(err, &providerErrors) || len(providerErrors) != 1 {
+		t.Errorf("expected error of 'todoist' but found '%v'", err)
+	}
+	if stats.Removed != 0 || stats.Unchanged != 1 {
+		t.Errorf("expected only the healthy provider to be refreshed but found %+v", stats)
+	}
+	if !index.LastRefresh("todoist").Equal(refreshed) {
+		t.Error("expected refresh time of the failed provider to be kept")
+	}
+	if !index.LastRefresh("microsoft").After(refreshed) {
+		t.Error("expected refresh time of the healthy provider to advance")
+	}
+	if results := index.Search(ParseQuery("report")); len(results) != 2 {
+		t.Errorf("expected tasks of the failed provider to be kept but found %d results", len(results))
+	}
+}
+
+func TestIndex_RefreshStale(t *testing.T) {
+	clients, work := createClients()
+	index := NewIndex()
+	ctx := context.Background()
+	work.err = stderrors.New("service unavailable")
+	_, _ = index.Refresh(ctx, clients)
+
+	// only the provider which was never refreshed successfully is stale
+	work.err = nil
+	personal := clients["microsoft"].(*stubClient)
+	calls := personal.calls
+	stats, err := index.RefreshStale(ctx, clients, 1<<62)
+
+	if err != nil {
+		t.Errorf("error was not nil but '%v'", err)
+	}
+	if stats.Added != 3 || stats.Unchanged != 0 {
+		t.Errorf("expected tasks of the stale provider to be added but found %+v", stats)
+	}
+	if personal.calls != calls {
+		t.Errorf("expected no calls to the fresh provider but found %d", personal.calls-calls)
+	}
+}
+
+func TestIndex_Refresh_Incremental(t *testing.T) {
+	clients, work := createClients()
+	index := NewIndex()
+	ctx := context.Background()
+
+	stats, err := index.Refresh(ctx, clients)
+	if err != nil {
+		t.Fatalf("error was not nil but '%v'", err)
+	}
+	if stats.Added != 4 {
+		t.Errorf("expected 4 added tasks but found %d", stats.Added)
+	}
+
+	work.tasks["p1"] = []todoclient.ToDoTask{
+		{ID: "t1", Name: "Write annual report", Description: "numbers for the board"},
+		{ID: "t5", Name: "Plan offsite"},
+	}
+	stats, err = index.Refresh(ctx, clients)
+	if err != nil {
+		t.Fatalf("error was not nil but '%v'", err)
+	}
+
+	want := RefreshStats{Added: 1, Updated: 1, Removed: 1, Unchanged: 2}
+	if stats != want {
+		t.Errorf("expected %+v but found %+v", want, stats)
+	}
+	if index.Len() != 4 {
+		t.Errorf("expected 4 indexed tasks but found %d", index.Len())
+	}
+	if results := index.Search(ParseQuery("quarterly")); len(results) != 0 {
+		t.Errorf("expected stale token to be removed but found %d results", len(results))
+	}
+	if results := index.Search(ParseQuery("annual")); len(results) != 1 {
+		t.Errorf("expected 1 result but found %d", len(results))
+	}
+}
@@ -0,0 +1,120 @@
+package syncer
+
+import (
+	"encoding/json"
+	"os"
+	"sync"
+)
+
+// State holds the mapping between the objects of both sides and the content
+// hashes recorded at the last synchronization. Index 0 refers to side A and
+// index 1 to side B.
+type State struct {
+	Parents []ParentMapping `json:"parents"`
+	Tasks   []TaskMapping   `json:"tasks"`
+}
+
+// ParentMapping links a parent of side A to a parent of side B
+type ParentMapping struct {
+	IDs [2]string `json:"ids"`
+}
+
+// TaskMapping links a task of side A to a task of side B
+type TaskMapping struct {
+	ParentIDs [2]string `json:"parent_ids"`
+	IDs       [2]string `json:"ids"`
+	Hashes    [2]string `json:"hashes"` // content hashes at the last synchronization
+}
+
+// MappingStore persists the synchronization state between runs
+type MappingStore interface {
+	Load() (*State, error)
+	Save(state *State) error
+}
+
+// MemoryMappingStore keeps the state in memory, mainly for tests and one-off runs
+type MemoryMappingStore struct {
+	mu    sync.Mutex
+	state []byte
+}
+
+// NewMemoryMappingStore creates an empty in-memory store
+func NewMemoryMappingStore() *MemoryMappingStore {
+	return &MemoryMappingStore{}
+}
+
+func (s *MemoryMappingStore) Load() (*State, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return decodeState(s.state)
+}
+
+func (s *MemoryMappingStore) Save(state *State) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	b, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	s.state = b
+	return nil
+}
+
+// FileMappingStore persists the state as JSON file
+type FileMappingStore struct {
+	path string
+}
+
+// NewFileMappingStore creates a store writing to path. The file is created on the first save.
+func NewFileMappingStore(path string) *FileMappingStore {
+	return &FileMappingStore{
+		path: path,
+	}
+}
+
+func (s *FileMappingStore) Load() (*State, error) {
+	b, err := os.ReadFile(s.path)
+	if os.IsNotExist(err) {
+		return decodeState(nil)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return decodeState(b)
+}
+
+func (s *FileMappingStore) Save(state *State) error {
+	b, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	// Write atomically
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, b, 0o600); err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+	if err := os.Rename(tmp, s.path); err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+	return nil
+}
+
+func decodeState(b []byte) (*State, error) {
+	state := &State{}
+	if len(b) > 0 {
+		if err := json.Unmarshal(b, state); err != nil {
+			return nil, err
+		}
+	}
+	if state.Parents == nil {
+		state.Parents = make([]ParentMapping, 0)
+	}
+	if state.Tasks == nil {
+		state.Tasks = make([]TaskMapping, 0)
+	}
+	return state, nil
+}
@@ -0,0 +1,522 @@
+// Package syncer provides two-way synchronization of parents and tasks between two todo providers
+package syncer
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	stderrors "errors"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jo-hoe/todoapi/pkg/errors"
+	"github.com/jo-hoe/todoapi/todoclient"
+)
+
+// Side identifies one of the two synchronized providers
+type Side int
+
+const (
+	SideA Side = 0
+	SideB Side = 1
+)
+
+func (s Side) other() Side {
+	return 1 - s
+}
+
+// ConflictPolicy decides which side wins if a task was changed on both sides
+type ConflictPolicy int
+
+const (
+	// LastWriterWins keeps the most recently modified task. If modification times
+	// are unknown or equal, the priority side wins.
+	LastWriterWins ConflictPolicy = iota
+	// SourcePriority always keeps the task of the priority side.
+	SourcePriority
+	// Manual leaves both tasks untouched and reports the conflict, see Engine.Resolve.
+	Manual
+)
+
+// Options configures the synchronization
+type Options struct {
+	Policy           ConflictPolicy
+	Priority         Side // Winning side for SourcePriority and undecided LastWriterWins conflicts
+	PropagateDeletes bool // Delete objects removed on one side on the other side as well
+}
+
+// DefaultOptions returns last-writer-wins synchronization with delete propagation
+func DefaultOptions() Options {
+	return Options{
+		Policy:           LastWriterWins,
+		Priority:         SideA,
+		PropagateDeletes: true,
+	}
+}
+
+// Report summarizes the changes of a synchronization run
+type Report struct {
+	ParentsCreated int        `json:"parents_created"`
+	ParentsDeleted int        `json:"parents_deleted"`
+	TasksCreated   int        `json:"tasks_created"`
+	TasksUpdated   int        `json:"tasks_updated"`
+	TasksDeleted   int        `json:"tasks_deleted"`
+	Conflicts      []Conflict `json:"conflicts"`
+}
+
+// Conflict is a task changed on both sides which was not resolved automatically
+type Conflict struct {
+	Mapping TaskMapping            `json:"mapping"`
+	Tasks   [2]todoclient.ToDoTask `json:"tasks"`
+}
+
+// Engine mirrors parents and tasks between two providers
+type Engine struct {
+	clients      [2]todoclient.ToDoClient
+	capabilities [2]todoclient.Capabilities
+	store        MappingStore
+	options      Options
+}
+
+// NewEngine creates a synchronization engine between side A and side B
+func NewEngine(a, b todoclient.ToDoClient, store MappingStore, options Options) *Engine {
+	return &Engine{
+		clients:      [2]todoclient.ToDoClient{a, b},
+		capabilities: [2]todoclient.Capabilities{todoclient.CapabilitiesOf(a), todoclient.CapabilitiesOf(b)},
+		store:        store,
+		options:      options,
+	}
+}
+
+// Sync runs a synchronization. The state is saved even if the run fails midway,
+// so that objects created before the failure are not duplicated by the next run.
+func (e *Engine) Sync(ctx context.Context) (report Report, err error) {
+	report.Conflicts = make([]Conflict, 0)
+
+	state, err := e.store.Load()
+	if err != nil {
+		return report, errors.NewAPIError("SYNC_LOAD_FAILED", "failed to load synchronization state", err)
+	}
+	defer func() {
+		if saveErr := e.store.Save(state); saveErr != nil && err == nil {
+			err = errors.NewAPIError("SYNC_SAVE_FAILED", "failed to save synchronization state", saveErr)
+		}
+	}()
+
+	if err = e.syncParents(ctx, state, &report); err != nil {
+		return report, err
+	}
+	for _, parent := range append([]ParentMapping{}, state.Parents...) {
+		if err = e.syncTasks(ctx, state, parent, &report); err != nil {
+			return report, err
+		}
+	}
+	return report, nil
+}
+
+// Resolve settles a conflict reported by a Manual synchronization by copying the
+// task of the winning side to the other side.
+func (e *Engine) Resolve(ctx context.Context, conflict Conflict, winner Side) error {
+	state, err := e.store.Load()
+	if err != nil {
+		return errors.NewAPIError("SYNC_LOAD_FAILED", "failed to load synchronization state", err)
+	}
+
+	for i, mapping := range state.Tasks {
+		if mapping.IDs != conflict.Mapping.IDs {
+			continue
+		}
+		mapping, err = e.copyTask(ctx, mapping, conflict.Tasks, winner)
+		if err != nil {
+			return err
+		}
+		state.Tasks[i] = mapping
+		if err := e.store.Save(state); err != nil {
+			return errors.NewAPIError("SYNC_SAVE_FAILED", "failed to save synchronization state", err)
+		}
+		return nil
+	}
+	return errors.NewAPIError("SYNC_MAPPING_NOT_FOUND", "conflicting task is no longer mapped", errors.ErrNotFound)
+}
+
+func (e *Engine) syncParents(ctx context.Context, state *State, report *Report) error {
+	var parents [2][]todoclient.ToDoParent
+	var exists [2]map[string]bool
+	mapped := [2]map[string]bool{make(map[string]bool), make(map[string]bool)}
+	for side, client := range e.clients {
+		list, err := client.GetAllParents(ctx)
+		if err != nil {
+			return errors.NewAPIError("SYNC_GET_PARENTS_FAILED", "failed to retrieve parents", err)
+		}
+		parents[side] = list
+		exists[side] = make(map[string]bool, len(list))
+		for _, parent := range list {
+			exists[side][parent.ID] = true
+		}
+	}
+
+	kept := make([]ParentMapping, 0, len(state.Parents))
+	for i, mapping := range state.Parents {
+		inA, inB := exists[SideA][mapping.IDs[SideA]], exists[SideB][mapping.IDs[SideB]]
+		if inA && inB {
+			kept = append(kept, mapping)
+			mapped[SideA][mapping.IDs[SideA]] = true
+			mapped[SideB][mapping.IDs[SideB]] = true
+			continue
+		}
+
+		side := SideA
+		if inB {
+			side = SideB
+		}
+		if (inA || inB) && e.options.PropagateDeletes && e.capabilities[side].DeleteParent {
+			if err := e.clients[side].DeleteParent(ctx, mapping.IDs[side]); err != nil {
+				// the unprocessed mappings are kept to retry the deletion in the next run
+				state.Parents = append(kept, state.Parents[i:]...)
+				return errors.NewAPIError("SYNC_DELETE_PARENT_FAILED", "failed to delete parent", err)
+			}
+			report.ParentsDeleted++
+			mapped[side][mapping.IDs[side]] = true
+		}
+		// otherwise the remaining parent is recreated below
+		removeTaskMappings(state, mapping)
+	}
+	state.Parents = kept
+
+	for _, side := range []Side{SideA, SideB} {
+		other := side.other()
+		for _, parent := range parents[side] {
+			if mapped[side][parent.ID] {
+				continue
+			}
+
+			var mapping ParentMapping
+			mapping.IDs[side] = parent.ID
+			if match, ok := findParentByName(parents[other], mapped[other], parent.Name); ok {
+				mapping.IDs[other] = match.ID
+			} else if !e.capabilities[other].CreateParent {
+				// parents the other side cannot create are not synchronized
+				continue
+			} else {
+				created, err := e.clients[other].CreateParent(ctx, parent.Name)
+				if err != nil {
+					return errors.NewAPIError("SYNC_CREATE_PARENT_FAILED", "failed to create parent", err)
+				}
+				mapping.IDs[other] = created.ID
+				report.ParentsCreated++
+			}
+			mapped[side][mapping.IDs[side]] = true
+			mapped[other][mapping.IDs[other]] = true
+			state.Parents = append(state.Parents, mapping)
+		}
+	}
+	return nil
+}
+
+func (e *Engine) syncTasks(ctx context.Context, state *State, parent ParentMapping, report *Report) error {
+	var tasks [2][]todoclient.ToDoTask
+	var byID [2]map[string]todoclient.ToDoTask
+	mapped := [2]map[string]bool{make(map[string]bool), make(map[string]bool)}
+	// task IDs of one side mapped to the IDs of the other side, to link subtasks
+	linked := [2]map[string]string{make(map[string]string), make(map[string]string)}
+	for side, client := range e.clients {
+		list, err := client.GetChildrenTasks(ctx, parent.IDs[side], nil)
+		if err != nil {
+			return errors.NewAPIError("SYNC_GET_TASKS_FAILED", "failed to retrieve tasks", err)
+		}
+		tasks[side] = list
+		byID[side] = make(map[string]todoclient.ToDoTask, len(list))
+		for _, task := range list {
+			byID[side][task.ID] = task
+		}
+	}
+
+	// mappings of other parents are kept as they are, the ones of this parent are rebuilt
+	result := make([]TaskMapping, 0, len(state.Tasks))
+	current := make([]TaskMapping, 0)
+	for _, mapping := range state.Tasks {
+		if mapping.ParentIDs == parent.IDs {
+			current = append(current, mapping)
+		} else {
+			result = append(result, mapping)
+		}
+	}
+	defer func() {
+		state.Tasks = result
+	}()
+
+	for i, mapping := range current {
+		var found [2]todoclient.ToDoTask
+		var present [2]bool
+		for side := range e.clients {
+			task, ok, err := e.lookupTask(ctx, byID[side], e.clients[side], parent.IDs[side], mapping.IDs[side])
+			if err != nil {
+				result = append(result, current[i:]...)
+				return err
+			}
+			found[side], present[side] = task, ok
+			mapped[side][mapping.IDs[side]] = true
+		}
+
+		var err error
+		keep := true
+		switch {
+		case present[SideA] && present[SideB]:
+			mapping, err = e.reconcile(ctx, mapping, found, report)
+		case present[SideA] || present[SideB]:
+			mapping, keep, err = e.handleDeletion(ctx, mapping, found, present, linked, report)
+		default:
+			keep = false
+		}
+		if keep {
+			result = append(result, mapping)
+			link(linked, mapping)
+		}
+		if err != nil {
+			result = append(result, current[i+1:]...)
+			return err
+		}
+	}
+
+	for _, side := range []Side{SideA, SideB} {
+		other := side.other()
+		for _, task := range todoclient.SortByParentTask(tasks[side]) {
+			if mapped[side][task.ID] {
+				continue
+			}
+
+			mapping := TaskMapping{ParentIDs: parent.IDs}
+			mapping.IDs[side] = task.ID
+			mapping.Hashes[side] = e.hash(task)
+			if match, ok := e.findTaskByHash(tasks[other], mapped[other], mapping.Hashes[side]); ok {
+				mapping.IDs[other] = match.ID
+				mapping.Hashes[other] = mapping.Hashes[side]
+			} else {
+				created, err := e.clients[other].CreateTask(ctx, parent.IDs[other], copyContent(task, "", e.parentTaskID(other, task, linked)))
+				if err != nil {
+					return errors.NewAPIError("SYNC_CREATE_TASK_FAILED", "failed to create task", err)
+				}
+				mapping.IDs[other] = created.ID
+				mapping.Hashes[other] = e.hash(created)
+				report.TasksCreated++
+			}
+			mapped[side][mapping.IDs[side]] = true
+			mapped[other][mapping.IDs[other]] = true
+			result = append(result, mapping)
+			link(linked, mapping)
+		}
+	}
+	return nil
+}
+
+// link records the IDs of a task mapping for parentTaskID
+func link(linked [2]map[string]string, mapping TaskMapping) {
+	linked[SideA][mapping.IDs[SideA]] = mapping.IDs[SideB]
+	linked[SideB][mapping.IDs[SideB]] = mapping.IDs[SideA]
+}
+
+// parentTaskID returns the ID the parent task of a task copied to side has there.
+// It is empty if the parent task is not synchronized yet or side has no subtasks.
+func (e *Engine) parentTaskID(side Side, task todoclient.ToDoTask, linked [2]map[string]string) string {
+	if task.ParentTaskID == "" || !e.capabilities[side].Subtasks {
+		return ""
+	}
+	return linked[side.other()][task.ParentTaskID]
+}
+
+// lookupTask finds a task in a listing. Listings may omit tasks, e.g. completed
+// ones, so a task is only considered deleted if the provider does not find it.
+func (e *Engine) lookupTask(ctx context.Context, listed map[string]todoclient.ToDoTask, client todoclient.ToDoClient, parentID, taskID string) (todoclient.ToDoTask, bool, error) {
+	if task, ok := listed[taskID]; ok {
+		return task, true, nil
+	}
+	task, err := client.GetTask(ctx, parentID, taskID)
+	if err == nil {
+		return task, true, nil
+	}
+	if stderrors.Is(err, errors.ErrNotFound) {
+		return task, false, nil
+	}
+	return task, false, errors.NewAPIError("SYNC_GET_TASK_FAILED", "failed to retrieve task", err)
+}
+
+// reconcile propagates changes of a task present on both sides
+func (e *Engine) reconcile(ctx context.Context, mapping TaskMapping, tasks [2]todoclient.ToDoTask, report *Report) (TaskMapping, error) {
+	hashes := [2]string{e.hash(tasks[SideA]), e.hash(tasks[SideB])}
+	if hashes[SideA] == hashes[SideB] {
+		mapping.Hashes = hashes
+		return mapping, nil
+	}
+
+	changedA := hashes[SideA] != mapping.Hashes[SideA]
+	changedB := hashes[SideB] != mapping.Hashes[SideB]
+	var winner Side
+	switch {
+	case changedA && !changedB:
+		winner = SideA
+	case changedB && !changedA:
+		winner = SideB
+	case !changedA && !changedB:
+		// both sides differ only by what the providers can store, e.g. due times
+		return mapping, nil
+	default:
+		var ok bool
+		if winner, ok = e.resolveConflict(tasks); !ok {
+			report.Conflicts = append(report.Conflicts, Conflict{Mapping: mapping, Tasks: tasks})
+			return mapping, nil
+		}
+	}
+
+	mapping, err := e.copyTask(ctx, mapping, tasks, winner)
+	if err == nil {
+		report.TasksUpdated++
+	}
+	return mapping, err
+}
+
+func (e *Engine) resolveConflict(tasks [2]todoclient.ToDoTask) (Side, bool) {
+	switch e.options.Policy {
+	case SourcePriority:
+		return e.options.Priority, true
+	case LastWriterWins:
+		a, b := tasks[SideA].ModifiedTime, tasks[SideB].ModifiedTime
+		if !a.IsZero() && !b.IsZero() && !a.Equal(b) {
+			if a.After(b) {
+				return SideA, true
+			}
+			return SideB, true
+		}
+		return e.options.Priority, true
+	default:
+		return SideA, false
+	}
+}
+
+// copyTask overwrites the task of the losing side with the content of the winner.
+// The losing task stays a subtask of its current parent task.
+func (e *Engine) copyTask(ctx context.Context, mapping TaskMapping, tasks [2]todoclient.ToDoTask, winner Side) (TaskMapping, error) {
+	loser := winner.other()
+	update := copyContent(tasks[winner], mapping.IDs[loser], tasks[loser].ParentTaskID)
+	if err := e.clients[loser].UpdateTask(ctx, mapping.ParentIDs[loser], update); err != nil {
+		return mapping, errors.NewAPIError("SYNC_UPDATE_TASK_FAILED", "failed to update task", err)
+	}
+	mapping.Hashes[winner] = e.hash(tasks[winner])
+	mapping.Hashes[loser] = e.hash(update)
+	return mapping, nil
+}
+
+// handleDeletion deals with a task missing on one side. An unchanged task is deleted
+// on the other side, a changed one is recreated as modifications win over deletions.
+func (e *Engine) handleDeletion(ctx context.Context, mapping TaskMapping, tasks [2]todoclient.ToDoTask, present [2]bool, linked [2]map[string]string, report *Report) (TaskMapping, bool, error) {
+	side := SideA
+	if present[SideB] {
+		side = SideB
+	}
+	other := side.other()
+	changed := e.hash(tasks[side]) != mapping.Hashes[side]
+
+	if e.options.PropagateDeletes && !changed {
+		if err := e.clients[side].DeleteTask(ctx, mapping.ParentIDs[side], mapping.IDs[side]); err != nil {
+			return mapping, true, errors.NewAPIError("SYNC_DELETE_TASK_FAILED", "failed to delete task", err)
+		}
+		report.TasksDeleted++
+		return mapping, false, nil
+	}
+
+	created, err := e.clients[other].CreateTask(ctx, mapping.ParentIDs[other], copyContent(tasks[side], "", e.parentTaskID(other, tasks[side], linked)))
+	if err != nil {
+		return mapping, true, errors.NewAPIError("SYNC_CREATE_TASK_FAILED", "failed to create task", err)
+	}
+	report.TasksCreated++
+	mapping.IDs[other] = created.ID
+	mapping.Hashes[side] = e.hash(tasks[side])
+	mapping.Hashes[other] = e.hash(created)
+	return mapping, true, nil
+}
+
+// copyContent returns the synchronized fields of a task with the given ID and parent task
+func copyContent(task todoclient.ToDoTask, id, parentTaskID string) todoclient.ToDoTask {
+	return todoclient.ToDoTask{
+		ID:           id,
+		Name:         task.Name,
+		Description:  task.Description,
+		DueDate:      task.DueDate,
+		IsCompleted:  task.IsCompleted,
+		Labels:       task.Labels,
+		ParentTaskID: parentTaskID,
+	}
+}
+
+// hash identifies the synchronized content of a task. Fields one of the sides
+// cannot store are ignored, so that they neither cause updates nor conflicts.
+func (e *Engine) hash(task todoclient.ToDoTask) string {
+	common := e.capabilities[SideA].Intersect(e.capabilities[SideB])
+	if !common.Labels {
+		task.Labels = nil
+	}
+	if !common.DueDates {
+		task.DueDate = time.Time{}
+	}
+	return taskHash(task)
+}
+
+// taskHash identifies the synchronized content of a task. Values are normalized
+// to what all providers can store, so that lossy conversions are not detected as changes.
+func taskHash(task todoclient.ToDoTask) string {
+	labels := make([]string, 0, len(task.Labels))
+	for _, label := range task.Labels {
+		labels = append(labels, strings.ToLower(strings.TrimSpace(label)))
+	}
+	sort.Strings(labels)
+
+	dueDate := ""
+	if !task.DueDate.IsZero() {
+		dueDate = task.DueDate.UTC().Format("2006-01-02")
+	}
+
+	b, _ := json.Marshal(struct {
+		Name        string
+		Description string
+		DueDate     string
+		IsCompleted bool
+		Labels      []string
+	}{
+		Name:        strings.TrimSpace(task.Name),
+		Description: strings.TrimSpace(strings.ReplaceAll(task.Description, "\r\n", "\n")),
+		DueDate:     dueDate,
+		IsCompleted: task.IsCompleted,
+		Labels:      labels,
+	})
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+func findParentByName(parents []todoclient.ToDoParent, mapped map[string]bool, name string) (todoclient.ToDoParent, bool) {
+	for _, parent := range parents {
+		if !mapped[parent.ID] && strings.EqualFold(strings.TrimSpace(parent.Name), strings.TrimSpace(name)) {
+			return parent, true
+		}
+	}
+	return todoclient.ToDoParent{}, false
+}
+
+func (e *Engine) findTaskByHash(tasks []todoclient.ToDoTask, mapped map[string]bool, hash string) (todoclient.ToDoTask, bool) {
+	for _, task := range tasks {
+		if !mapped[task.ID] && e.hash(task) == hash {
+			return task, true
+		}
+	}
+	return todoclient.ToDoTask{}, false
+}
+
+func removeTaskMappings(state *State, parent ParentMapping) {
+	kept := make([]TaskMapping, 0, len(state.Tasks))
+	for _, mapping := range state.Tasks {
+		if mapping.ParentIDs != parent.IDs {
+			kept = append(kept, mapping)
+		}
+	}
+	state.Tasks = kept
+}
@@ -0,0 +1,393 @@
+package syncer
+
+import (
+	"context"
+	stderrors "errors"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/jo-hoe/todoapi/internal/testutil"
+	"github.com/jo-hoe/todoapi/pkg/errors"
+	"github.com/jo-hoe/todoapi/todoclient"
+)
+
+// listClient changes the task listings of the mock, e.g. to omit completed tasks like the Todoist API
+type listClient struct {
+	*testutil.MockToDoClient
+	list func(tasks []todoclient.ToDoTask) []todoclient.ToDoTask
+}
+
+func (c listClient) GetChildrenTasks(ctx context.Context, parentID string, query *todoclient.TaskQuery) ([]todoclient.ToDoTask, error) {
+	tasks, err := c.MockToDoClient.GetChildrenTasks(ctx, parentID, nil)
+	if err != nil {
+		return nil, err
+	}
+	return query.Apply(c.list(tasks)), nil
+}
+
+func openTasks(tasks []todoclient.ToDoTask) []todoclient.ToDoTask {
+	result := make([]todoclient.ToDoTask, 0)
+	for _, task := range tasks {
+		if !task.IsCompleted {
+			result = append(result, task)
+		}
+	}
+	return result
+}
+
+// names returns the sorted task names of the parent with the given name
+func names(client todoclient.ToDoClient, parentName string) []string {
+	ctx := context.Background()
+	result := make([]string, 0)
+	parents, _ := client.GetAllParents(ctx)
+	for _, parent := range parents {
+		if parent.Name == parentName {
+			tasks, _ := client.GetChildrenTasks(ctx, parent.ID, nil)
+			for _, task := range tasks {
+				result = append(result, task.Name)
+			}
+		}
+	}
+	sort.Strings(result)
+	return result
+}
+
+// find returns the parent ID and the task with the given name, including completed tasks
+func find(client *testutil.MockToDoClient, name string) (string, todoclient.ToDoTask) {
+	ctx := context.Background()
+	parents, _ := client.GetAllParents(ctx)
+	for _, parent := range parents {
+		tasks, _ := client.GetChildrenTasks(ctx, parent.ID, nil)
+		for _, task := range tasks {
+			if task.Name == name {
+				return parent.ID, task
+			}
+		}
+	}
+	return "", todoclient.ToDoTask{}
+}
+
+func parentCount(client todoclient.ToDoClient) int {
+	parents, _ := client.GetAllParents(context.Background())
+	return len(parents)
+}
+
+// limitedClient is a provider without labels which cannot create parents
+type limitedClient struct {
+	*testutil.MockToDoClient
+}
+
+func (c limitedClient) Capabilities() todoclient.Capabilities {
+	return todoclient.Capabilities{DueDates: true, DeleteParent: true}
+}
+
+func (c limitedClient) CreateTask(ctx context.Context, parentID string, task todoclient.ToDoTask) (todoclient.ToDoTask, error) {
+	task.Labels = nil
+	return c.MockToDoClient.CreateTask(ctx, parentID, task)
+}
+
+func (c limitedClient) UpdateTask(ctx context.Context, parentID string, task todoclient.ToDoTask) error {
+	task.Labels = nil
+	return c.MockToDoClient.UpdateTask(ctx, parentID, task)
+}
+
+func (c limitedClient) CreateParent(ctx context.Context, parentName string) (todoclient.ToDoParent, error) {
+	return todoclient.ToDoParent{}, errors.NewAPIError("MOCK_NOT_SUPPORTED", "parents cannot be created", errors.ErrNotSupported)
+}
+
+func mustSync(t *testing.T, engine *Engine) Report {
+	t.Helper()
+	report, err := engine.Sync(context.Background())
+	if err != nil {
+		t.Fatalf("error was not nil but '%v'", err)
+	}
+	return report
+}
+
+func equalNames(a, b []string) bool {
+	return fmt.Sprint(a) == fmt.Sprint(b)
+}
+
+func TestSync_InitialCopy(t *testing.T) {
+	ctx := context.Background()
+	a, b := testutil.NewMockToDoClient(), testutil.NewMockToDoClient()
+	work, _ := a.CreateParent(ctx, "Work")
+	_, _ = a.CreateTask(ctx, work.ID, todoclient.ToDoTask{Name: "only a"})
+	_, _ = a.CreateTask(ctx, work.ID, todoclient.ToDoTask{Name: "both", Labels: []string{"x"}})
+	other, _ := b.CreateParent(ctx, "work")
+	_, _ = b.CreateTask(ctx, other.ID, todoclient.ToDoTask{Name: "both", Labels: []string{"X"}})
+	_, _ = b.CreateParent(ctx, "Home")
+
+	engine := NewEngine(a, b, NewMemoryMappingStore(), DefaultOptions())
+	report := mustSync(t, engine)
+
+	if report.ParentsCreated != 1 || report.TasksCreated != 1 {
+		t.Errorf("expected 1 created parent and task but found %+v", report)
+	}
+	if !equalNames(names(b, "work"), []string{"both", "only a"}) {
+		t.Errorf("unexpected tasks on side b %v", names(b, "work"))
+	}
+	if !equalNames(names(a, "Work"), []string{"both", "only a"}) || parentCount(a) != 2 {
+		t.Errorf("unexpected state on side a %d %v", parentCount(a), names(a, "Work"))
+	}
+
+	report = mustSync(t, engine)
+	if report.ParentsCreated+report.TasksCreated+report.TasksUpdated+report.TasksDeleted != 0 {
+		t.Errorf("expected second run without changes but found %+v", report)
+	}
+}
+
+func TestSync_UpdateAndCompletion(t *testing.T) {
+	ctx := context.Background()
+	a := listClient{testutil.NewMockToDoClient(), openTasks}
+	b := listClient{testutil.NewMockToDoClient(), openTasks}
+	parent, _ := a.CreateParent(ctx, "Work")
+	_, _ = a.CreateTask(ctx, parent.ID, todoclient.ToDoTask{Name: "task"})
+	engine := NewEngine(a, b, NewMemoryMappingStore(), DefaultOptions())
+	mustSync(t, engine)
+
+	parentID, task := find(b.MockToDoClient, "task")
+	task.Description = "changed on b"
+	task.IsCompleted = true
+	_ = b.UpdateTask(ctx, parentID, task)
+
+	report := mustSync(t, engine)
+	if report.TasksUpdated != 1 || report.TasksDeleted != 0 {
+		t.Errorf("expected 1 update but found %+v", report)
+	}
+	if _, updated := find(a.MockToDoClient, "task"); updated.Description != "changed on b" || !updated.IsCompleted {
+		t.Errorf("change was not propagated, found %+v", updated)
+	}
+}
+
+func TestSync_Delete(t *testing.T) {
+	ctx := context.Background()
+	for _, propagate := range []bool{true, false} {
+		a, b := testutil.NewMockToDoClient(), testutil.NewMockToDoClient()
+		parent, _ := a.CreateParent(ctx, "Work")
+		created, _ := a.CreateTask(ctx, parent.ID, todoclient.ToDoTask{Name: "task"})
+		options := DefaultOptions()
+		options.PropagateDeletes = propagate
+		engine := NewEngine(a, b, NewMemoryMappingStore(), options)
+		mustSync(t, engine)
+
+		_ = a.DeleteTask(ctx, parent.ID, created.ID)
+		mustSync(t, engine)
+
+		expected := []string{}
+		if !propagate {
+			expected = []string{"task"}
+		}
+		if !equalNames(names(b, "Work"), expected) || !equalNames(names(a, "Work"), expected) {
+			t.Errorf("propagate %v: expected %v but found %v and %v", propagate, expected, names(a, "Work"), names(b, "Work"))
+		}
+	}
+}
+
+func TestSync_ChangeWinsOverDelete(t *testing.T) {
+	ctx := context.Background()
+	a, b := testutil.NewMockToDoClient(), testutil.NewMockToDoClient()
+	parent, _ := a.CreateParent(ctx, "Work")
+	created, _ := a.CreateTask(ctx, parent.ID, todoclient.ToDoTask{Name: "task"})
+	engine := NewEngine(a, b, NewMemoryMappingStore(), DefaultOptions())
+	mustSync(t, engine)
+
+	_ = a.DeleteTask(ctx, parent.ID, created.ID)
+	parentID, task := find(b, "task")
+	task.Name = "renamed"
+	_ = b.UpdateTask(ctx, parentID, task)
+
+	report := mustSync(t, engine)
+	if report.TasksCreated != 1 || !equalNames(names(a, "Work"), []string{"renamed"}) {
+		t.Errorf("expected the changed task to be recreated but found %+v %v", report, names(a, "Work"))
+	}
+}
+
+func TestSync_ConflictPolicies(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	tests := []struct {
+		name     string
+		options  Options
+		expected string
+	}{
+		{"last writer", Options{Policy: LastWriterWins, Priority: SideA}, "b"},
+		{"priority", Options{Policy: SourcePriority, Priority: SideA}, "a"},
+		{"manual", Options{Policy: Manual}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, b := testutil.NewMockToDoClient(), testutil.NewMockToDoClient()
+			parent, _ := a.CreateParent(ctx, "Work")
+			created, _ := a.CreateTask(ctx, parent.ID, todoclient.ToDoTask{Name: "task"})
+			engine := NewEngine(a, b, NewMemoryMappingStore(), tt.options)
+			mustSync(t, engine)
+
+			created.Description = "a"
+			created.ModifiedTime = now
+			_ = a.UpdateTask(ctx, parent.ID, created)
+			parentID, task := find(b, "task")
+			task.Description = "b"
+			task.ModifiedTime = now.Add(time.Minute)
+			_ = b.UpdateTask(ctx, parentID, task)
+
+			report := mustSync(t, engine)
+			_, taskA := find(a, "task")
+			_, taskB := find(b, "task")
+			if tt.expected == "" {
+				if len(report.Conflicts) != 1 || taskA.Description != "a" || taskB.Description != "b" {
+					t.Fatalf("expected an unresolved conflict but found %+v", report)
+				}
+				if err := engine.Resolve(ctx, report.Conflicts[0], SideB); err != nil {
+					t.Fatalf("error was not nil but '%v'", err)
+				}
+				if _, taskA = find(a, "task"); taskA.Description != "b" {
+					t.Errorf("expected resolved description 'b' but found '%s'", taskA.Description)
+				}
+				if report = mustSync(t, engine); len(report.Conflicts) != 0 || report.TasksUpdated != 0 {
+					t.Errorf("expected no changes after resolving but found %+v", report)
+				}
+				return
+			}
+			if taskA.Description != tt.expected || taskB.Description != tt.expected {
+				t.Errorf("expected '%s' on both sides but found '%s' and '%s'", tt.expected, taskA.Description, taskB.Description)
+			}
+		})
+	}
+}
+
+func TestSync_ParentDeletion(t *testing.T) {
+	ctx := context.Background()
+	a, b := testutil.NewMockToDoClient(), testutil.NewMockToDoClient()
+	parent, _ := a.CreateParent(ctx, "Work")
+	_, _ = a.CreateTask(ctx, parent.ID, todoclient.ToDoTask{Name: "task"})
+	engine := NewEngine(a, b, NewMemoryMappingStore(), DefaultOptions())
+	mustSync(t, engine)
+
+	_ = a.DeleteParent(ctx, parent.ID)
+	report := mustSync(t, engine)
+	if report.ParentsDeleted != 1 || parentCount(b) != 0 {
+		t.Errorf("expected parent deleted on side b but found %+v %d", report, parentCount(b))
+	}
+}
+
+func TestSync_Subtasks(t *testing.T) {
+	ctx := context.Background()
+	// the subtask is listed before its parent task
+	a := listClient{testutil.NewMockToDoClient(), func(tasks []todoclient.ToDoTask) []todoclient.ToDoTask {
+		slices.Reverse(tasks)
+		return tasks
+	}}
+	b := testutil.NewMockToDoClient()
+	parent, _ := a.CreateParent(ctx, "Work")
+	main, _ := a.CreateTask(ctx, parent.ID, todoclient.ToDoTask{Name: "task"})
+	sub, _ := a.CreateTask(ctx, parent.ID, todoclient.ToDoTask{Name: "subtask", ParentTaskID: main.ID})
+	engine := NewEngine(a, b, NewMemoryMappingStore(), DefaultOptions())
+	mustSync(t, engine)
+
+	subtaskOfTask := func() bool {
+		tasks, _ := b.GetAllTasks(ctx, nil)
+		byName := make(map[string]todoclient.ToDoTask)
+		for _, task := range tasks {
+			byName[task.Name] = task
+		}
+		return len(tasks) == 2 && byName["subtask"].ParentTaskID == byName["task"].ID
+	}
+	if !subtaskOfTask() {
+		t.Error("expected subtask of the copied task on side b")
+	}
+
+	sub.Description = "changed"
+	_ = a.UpdateTask(ctx, parent.ID, sub)
+	if report := mustSync(t, engine); report.TasksUpdated != 1 {
+		t.Errorf("expected 1 updated task but found %+v", report)
+	}
+	if !subtaskOfTask() {
+		t.Error("expected updated subtask to keep its parent task on side b")
+	}
+}
+
+func TestSync_ParentDeletionFailure(t *testing.T) {
+	ctx := context.Background()
+	a, b := testutil.NewMockToDoClient(), testutil.NewMockToDoClient()
+	for _, name := range []string{"Home", "Work"} {
+		parent, _ := a.CreateParent(ctx, name)
+		_, _ = a.CreateTask(ctx, parent.ID, todoclient.ToDoTask{Name: name + " task"})
+	}
+	store := NewMemoryMappingStore()
+	engine := NewEngine(a, b, store, DefaultOptions())
+	mustSync(t, engine)
+
+	parents, _ := a.GetAllParents(ctx)
+	_ = a.DeleteParent(ctx, parents[0].ID)
+	b.FailNext(todoclient.OpDeleteParent, stderrors.New("unavailable"), 1)
+	if _, err := engine.Sync(ctx); err == nil {
+		t.Fatal("expected error of failed parent deletion")
+	}
+	state, _ := store.Load()
+	if len(state.Parents) != 2 || len(state.Tasks) != 2 {
+		t.Errorf("expected mappings to be kept after failure but found %+v", state)
+	}
+
+	report := mustSync(t, engine)
+	if report.ParentsDeleted != 1 || report.ParentsCreated != 0 || report.TasksCreated != 0 {
+		t.Errorf("expected parent deleted without duplicates on retry but found %+v", report)
+	}
+}
+
+func TestFileMappingStore(t *testing.T) {
+	store := NewFileMappingStore(filepath.Join(t.TempDir(), "state.json"))
+
+	state, err := store.Load()
+	if err != nil || len(state.Parents) != 0 || state.Tasks == nil {
+		t.Fatalf("expected empty state but found %+v and '%v'", state, err)
+	}
+
+	state.Tasks = append(state.Tasks, TaskMapping{IDs: [2]string{"a", "b"}, Hashes: [2]string{"x", "y"}})
+	if err := store.Save(state); err != nil {
+		t.Fatalf("error was not nil but '%v'", err)
+	}
+	loaded, err := store.Load()
+	if err != nil || len(loaded.Tasks) != 1 || loaded.Tasks[0] != state.Tasks[0] {
+		t.Errorf("expected saved state but found %+v and '%v'", loaded, err)
+	}
+}
+
+func TestTaskHash_Normalization(t *testing.T) {
+	a := todoclient.ToDoTask{Name: "task ", DueDate: time.Date(2024, 1, 2, 10, 0, 0, 0, time.UTC), Labels: []string{"B", "a"}}
+	b := todoclient.ToDoTask{ID: "other", Name: "task", DueDate: time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC), Labels: []string{"a", "b"}}
+	if taskHash(a) != taskHash(b) {
+		t.Error("expected equal hashes for equivalent tasks")
+	}
+	b.IsCompleted = true
+	if taskHash(a) == taskHash(b) {
+		t.Error("expected different hashes for different completion")
+	}
+}
+
+func TestSync_Capabilities(t *testing.T) {
+	ctx := context.Background()
+	a, b := testutil.NewMockToDoClient(), limitedClient{testutil.NewMockToDoClient()}
+	work, _ := a.CreateParent(ctx, "Work")
+	_, _ = a.CreateParent(ctx, "Home")
+	_, _ = b.MockToDoClient.CreateParent(ctx, "Work")
+	_, _ = a.CreateTask(ctx, work.ID, todoclient.ToDoTask{Name: "task", Labels: []string{"x"}})
+
+	engine := NewEngine(a, b, NewMemoryMappingStore(), DefaultOptions())
+	report := mustSync(t, engine)
+	if report.ParentsCreated != 0 || report.TasksCreated != 1 {
+		t.Errorf("expected only the task to be created but found %+v", report)
+	}
+
+	report = mustSync(t, engine)
+	if report.TasksCreated+report.TasksUpdated+len(report.Conflicts) != 0 {
+		t.Errorf("expected unsupported labels to be ignored but found %+v", report)
+	}
+	if _, task := find(a, "task"); len(task.Labels) != 1 {
+		t.Errorf("expected labels to be kept on side a but found %v", task.Labels)
+	}
+}
@@ -8,12 +8,15 @@ import (
 
 // ToDoTask represents a task in the to-do list, with a due date and creation time.
 type ToDoTask struct {
-	ID           string    `json:"id"`            // Unique identifier for the task
-	Name         string    `json:"name"`          // Short description of the task
-	Description  string    `json:"description"`   // Detailed description of the task
-	DueDate      time.Time `json:"due_date"`      // When the task is due
-	CreationTime time.Time `json:"creation_time"` // When the task was created
-	IsCompleted  bool      `json:"is_completed"`  // Whether the task is completed
+	ID           string    `json:"id"`                       // Unique identifier for the task
+	Name         string    `json:"name"`                     // Short description of the task
+	Description  string    `json:"description"`              // Detailed description of the task
+	DueDate      time.Time `json:"due_date"`                 // When the task is due
+	CreationTime time.Time `json:"creation_time"`            // When the task was created
+	ModifiedTime time.Time `json:"modified_time"`            // When the task was last modified, zero if unknown
+	IsCompleted  bool      `json:"is_completed"`             // Whether the task is completed
+	Labels       []string  `json:"labels"`                   // Labels (tags/categories) attached to the task
+	ParentTaskID string    `json:"parent_task_id,omitempty"` // Task this task is a subtask of, empty for top-level tasks
 }
 
 // ToDoParent represents a parent entity, which can contain multiple tasks.
@@ -28,11 +31,17 @@ type ToDoParent struct {
 // as well as retrieval of all tasks and parents. Each method should return an error if the
 // operation fails, and all returned slices should be non-nil (empty if no results).
 type ToDoClient interface {
-	// GetAllTasks retrieves all tasks across all parents (projects/lists).
-	GetAllTasks(ctx context.Context) ([]ToDoTask, error)
+	// GetAllTasks retrieves all tasks across all parents (projects/lists) matching the query.
+	// A nil query returns every task.
+	GetAllTasks(ctx context.Context, query *TaskQuery) ([]ToDoTask, error)
 
-	// GetChildrenTasks retrieves all tasks under a specific parent (project/list).
-	GetChildrenTasks(ctx context.Context, parentID string) ([]ToDoTask, error)
+	// GetChildrenTasks retrieves all tasks under a specific parent (project/list) matching the query.
+	// A nil query returns every task of the parent.
+	GetChildrenTasks(ctx context.Context, parentID string, query *TaskQuery) ([]ToDoTask, error)
+
+	// GetTask retrieves a single task by its ID under the specified parent (project/list).
+	// If the task does not exist, the returned error wraps ErrNotFound from pkg/errors.
+	GetTask(ctx context.Context, parentID, taskID string) (ToDoTask, error)
 
 	// CreateTask creates a new task under the specified parent (project/list).
 	CreateTask(ctx context.Context, parentID string, task ToDoTask) (ToDoTask, error)
@@ -53,6 +62,48 @@ type ToDoClient interface {
 	DeleteParent(ctx context.Context, parentID string) error
 }
 
+// SortByParentTask returns the tasks ordered so that every subtask follows the task
+// it is a subtask of, e.g. to create them in this order. Otherwise the order is kept.
+func SortByParentTask(tasks []ToDoTask) []ToDoTask {
+	children := make(map[string][]ToDoTask)
+	ids := make(map[string]bool, len(tasks))
+	for _, task := range tasks {
+		ids[task.ID] = true
+	}
+	roots := make([]ToDoTask, 0, len(tasks))
+	for _, task := range tasks {
+		if task.ParentTaskID != "" && task.ParentTaskID != task.ID && ids[task.ParentTaskID] {
+			children[task.ParentTaskID] = append(children[task.ParentTaskID], task)
+		} else {
+			roots = append(roots, task)
+		}
+	}
+
+	result := make([]ToDoTask, 0, len(tasks))
+	visited := make(map[string]bool, len(tasks))
+	var visit func(task ToDoTask)
+	visit = func(task ToDoTask) {
+		if visited[task.ID] {
+			return
+		}
+		visited[task.ID] = true
+		result = append(result, task)
+		for _, child := range children[task.ID] {
+			visit(child)
+		}
+	}
+	for _, task := range roots {
+		visit(task)
+	}
+	// tasks in a cycle have no root, they are appended as they are
+	for _, task := range tasks {
+		if !visited[task.ID] {
+			result = append(result, task)
+		}
+	}
+	return result
+}
+
 // Validate validates a ToDoTask
 func (t *ToDoTask) Validate() error {
 	if t.Name == "" {
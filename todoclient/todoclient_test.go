@@ -100,3 +100,21 @@ func TestToDoParent_Validate(t *testing.T) {
 		})
 	}
 }
+
+func TestSortByParentTask(t *testing.T) {
+	tasks := []ToDoTask{
+		{ID: "3", ParentTaskID: "2"},
+		{ID: "2", ParentTaskID: "1"},
+		{ID: "4", ParentTaskID: "missing"},
+		{ID: "1"},
+	}
+
+	sorted := SortByParentTask(tasks)
+	order := ""
+	for _, task := range sorted {
+		order += task.ID
+	}
+	if order != "4123" {
+		t.Errorf("expected order '4123' but found '%s'", order)
+	}
+}
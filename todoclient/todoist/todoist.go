@@ -6,8 +6,8 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"log"
 	"net/http"
+	"net/url"
 	"strings"
 	"time"
 
@@ -19,6 +19,7 @@ import (
 
 type TodoistClient struct {
 	httpClient *http.Client
+	baseURL    string
 }
 
 type TodoistTask struct {
@@ -28,7 +29,12 @@ type TodoistTask struct {
 	Description  string      `json:"description,omitempty"`
 	CommentCount uint        `json:"comment_count,omitempty"`
 	Created      time.Time   `json:"created,omitempty" examples:"2022-10-16T11:53:16.720180Z"`
+	CreatedAt    *time.Time  `json:"created_at,omitempty" examples:"2022-10-16T11:53:16.720180Z"`
 	Due          *TodoistDue `json:"due,omitempty"`
+	DueDate      string      `json:"due_date,omitempty"`   // sets the due date on writes
+	DueString    string      `json:"due_string,omitempty"` // "no date" removes the due date on writes
+	Labels       []string    `json:"labels"`               // always sent on writes, an empty list removes all labels
+	IsCompleted  bool        `json:"is_completed,omitempty"`
 }
 
 type TodoistComment struct {
@@ -45,13 +51,17 @@ type TodoistDue struct {
 }
 
 const (
-	todoistUrl         = "https://api.todoist.com/rest/v2/"
-	todoistTasksUrl    = todoistUrl + "tasks"
-	todoistTaskUrl     = todoistUrl + "tasks/%s"
-	todoistParentsUrl  = todoistUrl + "projects"
+	// DefaultBaseURL is the base URL of the Todoist REST API
+	DefaultBaseURL     = "https://api.todoist.com/rest/v2/"
+	todoistTasksUrl    = "tasks"
+	todoistTaskUrl     = "tasks/%s"
+	todoistCloseUrl    = todoistTaskUrl + "/close"
+	todoistReopenUrl   = todoistTaskUrl + "/reopen"
+	todoistParentsUrl  = "projects"
 	todoistParentUrl   = todoistParentsUrl + "/%s"
-	todoistCommentsUrl = todoistUrl + "comments?task_id=%s"
+	todoistCommentsUrl = "comments?task_id=%s"
 	timeDueDateLayout  = "2006-01-02"
+	noDueDate          = "no date"
 )
 
 // NewTodoistHTTPClient creates an HTTP client with injected REST API token for each request
@@ -62,9 +72,34 @@ func NewTodoistHTTPClient(token string) *http.Client {
 func NewTodoistClient(httpClient *http.Client) *TodoistClient {
 	return &TodoistClient{
 		httpClient: httpClient,
+		baseURL:    DefaultBaseURL,
 	}
 }
 
+// NewTodoistClientWithBaseURL creates a client for a Todoist compatible API, e.g. the
+// fake in the todoistfake package. An empty base URL selects DefaultBaseURL.
+func NewTodoistClientWithBaseURL(httpClient *http.Client, baseURL string) (*TodoistClient, error) {
+	if baseURL == "" {
+		baseURL = DefaultBaseURL
+	}
+	if !strings.HasSuffix(baseURL, "/") {
+		baseURL += "/"
+	}
+	parsed, err := url.Parse(baseURL)
+	if err != nil || parsed.Scheme == "" || parsed.Host == "" {
+		return nil, errors.NewValidationError("base_url", "invalid base URL '"+baseURL+"'")
+	}
+	return &TodoistClient{
+		httpClient: httpClient,
+		baseURL:    baseURL,
+	}, nil
+}
+
+// url resolves an API path relative to the base URL
+func (client *TodoistClient) url(path string, args ...interface{}) string {
+	return client.baseURL + fmt.Sprintf(path, args...)
+}
+
 func (client *TodoistClient) CreateTask(ctx context.Context, parentID string, task todoclient.ToDoTask) (todoclient.ToDoTask, error) {
 	var result todoclient.ToDoTask
 
@@ -83,7 +118,7 @@ func (client *TodoistClient) CreateTask(ctx context.Context, parentID string, ta
 		return result, errors.NewAPIError("TODOIST_MARSHAL_FAILED", "failed to marshal task", err)
 	}
 
-	req, err := http.NewRequestWithContext(ctx, http.MethodPost, todoistTasksUrl, bytes.NewBuffer(jsonPayload))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, client.url(todoistTasksUrl), bytes.NewBuffer(jsonPayload))
 	if err != nil {
 		return result, errors.NewAPIError("TODOIST_REQUEST_FAILED", "failed to create request", err)
 	}
@@ -105,6 +140,13 @@ func (client *TodoistClient) CreateTask(ctx context.Context, parentID string, ta
 		return result, errors.NewAPIError("TODOIST_DECODE_FAILED", "failed to decode response", err)
 	}
 
+	if task.IsCompleted {
+		if err := client.setCompletion(ctx, responseObject.ID, true); err != nil {
+			return result, err
+		}
+		responseObject.IsCompleted = true
+	}
+
 	convertedTask, err := client.convertToToDoTask(ctx, responseObject)
 	if err != nil {
 		return result, err
@@ -113,9 +155,34 @@ func (client *TodoistClient) CreateTask(ctx context.Context, parentID string, ta
 	return *convertedTask, nil
 }
 
+// setCompletion closes or reopens a task, as the completion state cannot be updated directly
+func (client *TodoistClient) setCompletion(ctx context.Context, taskID string, completed bool) error {
+	url := client.url(todoistReopenUrl, taskID)
+	if completed {
+		url = client.url(todoistCloseUrl, taskID)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, nil)
+	if err != nil {
+		return errors.NewAPIError("TODOIST_REQUEST_FAILED", "failed to create request", err)
+	}
+
+	resp, err := client.httpClient.Do(req)
+	if err != nil {
+		return errors.NewAPIError("TODOIST_HTTP_FAILED", "HTTP request failed", err)
+	}
+	defer common.CloseBody(resp.Body)
+
+	if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusOK {
+		return errors.NewAPIError("TODOIST_COMPLETION_FAILED", fmt.Sprintf("changing completion failed with status %d", resp.StatusCode), nil)
+	}
+
+	return nil
+}
+
 func (client *TodoistClient) getComments(ctx context.Context, taskID string) ([]string, error) {
 	var comments []TodoistComment
-	if err := client.getData(ctx, fmt.Sprintf(todoistCommentsUrl, taskID), &comments); err != nil {
+	if err := client.getData(ctx, client.url(todoistCommentsUrl, taskID), &comments); err != nil {
 		return nil, err
 	}
 
@@ -142,7 +209,7 @@ func (client *TodoistClient) UpdateTask(ctx context.Context, parentID string, ta
 		return errors.NewAPIError("TODOIST_MARSHAL_FAILED", "failed to marshal task", err)
 	}
 
-	req, err := http.NewRequestWithContext(ctx, http.MethodPost, fmt.Sprintf(todoistTaskUrl, task.ID), bytes.NewBuffer(jsonPayload))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, client.url(todoistTaskUrl, task.ID), bytes.NewBuffer(jsonPayload))
 	if err != nil {
 		return errors.NewAPIError("TODOIST_REQUEST_FAILED", "failed to create request", err)
 	}
@@ -158,11 +225,18 @@ func (client *TodoistClient) UpdateTask(ctx context.Context, parentID string, ta
 		return errors.NewAPIError("TODOIST_UPDATE_FAILED", fmt.Sprintf("update failed with status %d", resp.StatusCode), nil)
 	}
 
+	// the response reflects the current completion state, which is only changed if needed
+	var updated TodoistTask
+	_ = json.NewDecoder(resp.Body).Decode(&updated)
+	if updated.IsCompleted != task.IsCompleted {
+		return client.setCompletion(ctx, task.ID, task.IsCompleted)
+	}
+
 	return nil
 }
 
 func (client *TodoistClient) DeleteTask(ctx context.Context, parentID, taskID string) error {
-	return client.deleteObject(ctx, fmt.Sprintf(todoistTaskUrl, taskID))
+	return client.deleteObject(ctx, client.url(todoistTaskUrl, taskID))
 }
 
 func (client *TodoistClient) deleteObject(ctx context.Context, url string) error {
@@ -201,7 +275,7 @@ func (client *TodoistClient) CreateParent(ctx context.Context, parentName string
 		return result, errors.NewAPIError("TODOIST_MARSHAL_FAILED", "failed to marshal parent", err)
 	}
 
-	req, err := http.NewRequestWithContext(ctx, http.MethodPost, todoistParentsUrl, bytes.NewBuffer(jsonPayload))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, client.url(todoistParentsUrl), bytes.NewBuffer(jsonPayload))
 	if err != nil {
 		return result, errors.NewAPIError("TODOIST_REQUEST_FAILED", "failed to create request", err)
 	}
@@ -230,15 +304,25 @@ func (client *TodoistClient) CreateParent(ctx context.Context, parentName string
 }
 
 func (client *TodoistClient) DeleteParent(ctx context.Context, parentID string) error {
-	return client.deleteObject(ctx, fmt.Sprintf(todoistParentUrl, parentID))
+	return client.deleteObject(ctx, client.url(todoistParentUrl, parentID))
+}
+
+// Capabilities reports the features supported by the Todoist adapter. Due dates
+// are stored without time and subtasks are not mapped.
+func (client *TodoistClient) Capabilities() todoclient.Capabilities {
+	return todoclient.Capabilities{
+		Labels:       true,
+		DueDates:     true,
+		CreateParent: true,
+		DeleteParent: true,
+	}
 }
 
 func (client *TodoistClient) GetAllParents(ctx context.Context) ([]todoclient.ToDoParent, error) {
 	result := make([]todoclient.ToDoParent, 0)
 	var projects []TodoistProject
 
-	if err := client.getData(ctx, todoistParentsUrl, &projects); err != nil {
-		log.Printf("failed to get all parents: %v", err)
+	if err := client.getData(ctx, client.url(todoistParentsUrl), &projects); err != nil {
 		return result, errors.NewAPIError("TODOIST_GET_PARENTS_FAILED", "failed to retrieve parents", err)
 	}
 
@@ -253,29 +337,67 @@ func (client *TodoistClient) GetAllParents(ctx context.Context) ([]todoclient.To
 	return result, nil
 }
 
-func (client *TodoistClient) GetAllTasks(ctx context.Context) ([]todoclient.ToDoTask, error) {
-	return client.getTasks(ctx, nil)
+func (client *TodoistClient) GetAllTasks(ctx context.Context, query *todoclient.TaskQuery) ([]todoclient.ToDoTask, error) {
+	return client.getTasks(ctx, nil, query)
 }
 
-func (client *TodoistClient) GetChildrenTasks(ctx context.Context, parentID string) ([]todoclient.ToDoTask, error) {
-	return client.getTasks(ctx, &parentID)
+func (client *TodoistClient) GetChildrenTasks(ctx context.Context, parentID string, query *todoclient.TaskQuery) ([]todoclient.ToDoTask, error) {
+	return client.getTasks(ctx, &parentID, query)
 }
 
-func (client *TodoistClient) getTasks(ctx context.Context, parentID *string) ([]todoclient.ToDoTask, error) {
+func (client *TodoistClient) GetTask(ctx context.Context, parentID, taskID string) (todoclient.ToDoTask, error) {
+	var result todoclient.ToDoTask
+	var task TodoistTask
+
+	if err := client.getData(ctx, client.url(todoistTaskUrl, taskID), &task); err != nil {
+		return result, errors.NewAPIError("TODOIST_GET_TASK_FAILED", "failed to retrieve task", err)
+	}
+
+	// the task endpoint is not scoped by project, so a mismatch is treated like a missing task
+	if parentID != "" && task.ProjectID != parentID {
+		return result, errors.NewAPIError("TODOIST_GET_TASK_FAILED", fmt.Sprintf("task %s not found in project %s", taskID, parentID), errors.ErrNotFound)
+	}
+
+	convertedTask, err := client.convertToToDoTask(ctx, task)
+	if err != nil {
+		return result, err
+	}
+
+	return *convertedTask, nil
+}
+
+func (client *TodoistClient) getTasks(ctx context.Context, parentID *string, query *todoclient.TaskQuery) ([]todoclient.ToDoTask, error) {
+	// the REST API lists active tasks only, completed tasks would never be found
+	if query != nil && query.Completed != nil && *query.Completed {
+		return nil, errors.NewAPIError("TODOIST_NOT_SUPPORTED", "completed tasks cannot be listed", errors.ErrNotSupported)
+	}
+
 	var todoistTasks []TodoistTask
 
-	url := todoistTasksUrl
+	params := url.Values{}
 	if parentID != nil {
-		url = url + "?project_id=" + *parentID
+		params.Set("project_id", *parentID)
+	}
+	filter := buildTodoistFilter(query)
+	if filter != "" {
+		params.Set("filter", filter)
+	}
+
+	requestUrl := client.url(todoistTasksUrl)
+	if len(params) > 0 {
+		requestUrl = requestUrl + "?" + params.Encode()
 	}
 
-	if err := client.getData(ctx, url, &todoistTasks); err != nil {
-		log.Printf("failed to get tasks: %v", err)
+	if err := client.getData(ctx, requestUrl, &todoistTasks); err != nil {
 		return nil, errors.NewAPIError("TODOIST_GET_TASKS_FAILED", "failed to retrieve tasks", err)
 	}
 
 	tasks := make([]todoclient.ToDoTask, 0, len(todoistTasks))
 	for _, task := range todoistTasks {
+		// a filter takes precedence over project_id in the Todoist API
+		if filter != "" && parentID != nil && task.ProjectID != *parentID {
+			continue
+		}
 		convertedTask, err := client.convertToToDoTask(ctx, task)
 		if err != nil {
 			return nil, err
@@ -283,7 +405,43 @@ func (client *TodoistClient) getTasks(ctx context.Context, parentID *string) ([]
 		tasks = append(tasks, *convertedTask)
 	}
 
-	return tasks, nil
+	return query.Apply(tasks), nil
+}
+
+// buildTodoistFilter translates the parts of a query expressible in the Todoist
+// filter syntax. The resulting filter is a superset of the query, the remainder
+// is applied client-side.
+func buildTodoistFilter(query *todoclient.TaskQuery) string {
+	if query == nil {
+		return ""
+	}
+
+	parts := make([]string, 0)
+	// Todoist compares whole days exclusively, so the boundaries are widened by one day
+	if !query.DueAfter.IsZero() {
+		parts = append(parts, "due after: "+query.DueAfter.AddDate(0, 0, -1).Format(timeDueDateLayout))
+	}
+	if !query.DueBefore.IsZero() {
+		parts = append(parts, "due before: "+query.DueBefore.AddDate(0, 0, 1).Format(timeDueDateLayout))
+	}
+	for _, label := range query.Labels {
+		parts = append(parts, "@"+escapeFilterValue(label))
+	}
+
+	return strings.Join(parts, " & ")
+}
+
+// escapeFilterValue escapes characters with a meaning in the filter syntax, e.g.
+// operators and spaces in label names
+func escapeFilterValue(value string) string {
+	var b strings.Builder
+	for _, r := range value {
+		if strings.ContainsRune(`\&|!(),: @#*`, r) {
+			b.WriteRune('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
 }
 
 func (client *TodoistClient) getData(ctx context.Context, url string, data interface{}) error {
@@ -298,6 +456,9 @@ func (client *TodoistClient) getData(ctx context.Context, url string, data inter
 	}
 	defer common.CloseBody(resp.Body)
 
+	if resp.StatusCode == http.StatusNotFound {
+		return errors.NewAPIError("TODOIST_NOT_FOUND", "resource not found", errors.ErrNotFound)
+	}
 	if resp.StatusCode != http.StatusOK {
 		return errors.NewAPIError("TODOIST_GET_FAILED", fmt.Sprintf("GET failed with status %d", resp.StatusCode), nil)
 	}
@@ -324,13 +485,22 @@ func (client *TodoistClient) convertToToDoTask(ctx context.Context, task Todoist
 		Description:  task.Description,
 		DueDate:      dueDate,
 		CreationTime: task.Created,
+		IsCompleted:  task.IsCompleted,
+		Labels:       task.Labels,
+	}
+	if result.Labels == nil {
+		result.Labels = make([]string, 0)
+	}
+	// the current API version names the creation time created_at
+	if task.CreatedAt != nil {
+		result.CreationTime = *task.CreatedAt
 	}
 
 	if task.CommentCount > 0 {
 		comments, err := client.getComments(ctx, task.ID)
 		if err != nil {
-			// Log error but don't fail the conversion
-			log.Printf("failed to get comments for task %s: %v", task.ID, err)
+			// the task is kept without comments instead of failing the listing
+			todoclient.Logger(ctx).WarnContext(ctx, "failed to get comments", "task", task.ID, "error", err)
 			return &result, nil
 		}
 		for _, comment := range comments {
@@ -350,12 +520,16 @@ func convertTodoistTask(task todoclient.ToDoTask) (*TodoistTask, error) {
 		ID:          task.ID,
 		Content:     task.Name,
 		Description: task.Description,
+		Labels:      task.Labels,
+	}
+	if result.Labels == nil {
+		result.Labels = make([]string, 0)
 	}
 
-	if !task.DueDate.IsZero() {
-		result.Due = &TodoistDue{
-			Date: task.DueDate.Format(timeDueDateLayout),
-		}
+	if task.DueDate.IsZero() {
+		result.DueString = noDueDate
+	} else {
+		result.DueDate = task.DueDate.Format(timeDueDateLayout)
 	}
 
 	return &result, nil
@@ -0,0 +1,83 @@
+package todoist
+
+import (
+	"context"
+	"net/http"
+	"path/filepath"
+	"testing"
+	"time"
+
+	customhttp "github.com/jo-hoe/todoapi/internal/http"
+	"github.com/jo-hoe/todoapi/todoclient"
+)
+
+// newCassetteClient replays the interactions in testdata/cassettes. With RECORD_CASSETTES=true
+// they are recorded against the live API using the credentials of the integration tests.
+func newCassetteClient(t *testing.T, name string) *TodoistClient {
+	t.Helper()
+	mode := customhttp.CassetteModeFromEnv()
+	var transport http.RoundTripper
+	if mode == customhttp.ModeRecord {
+		transport = createClient(t).httpClient.Transport
+	}
+
+	cassette, err := customhttp.NewCassetteTransport(filepath.Join("testdata", "cassettes", name+".json"), mode, transport)
+	if err != nil {
+		t.Fatalf("error was not nil but '%v'", err)
+	}
+	t.Cleanup(func() {
+		if err := cassette.Save(); err != nil {
+			t.Errorf("error was not nil but '%v'", err)
+		}
+		if mode == customhttp.ModeReplay && cassette.Unused() > 0 {
+			t.Errorf("expected all interactions to be replayed but %d were not", cassette.Unused())
+		}
+	})
+	return NewTodoistClient(&http.Client{Transport: cassette})
+}
+
+func TestTodoistClient_Cassette_Converters(t *testing.T) {
+	client := newCassetteClient(t, "converters")
+	ctx := context.Background()
+
+	parent, err := client.CreateParent(ctx, "todoapi cassette")
+	if err != nil {
+		t.Fatalf("error was not nil but '%v'", err)
+	}
+	dueDate := time.Date(2030, 1, 2, 0, 0, 0, 0, time.UTC)
+	created, err := client.CreateTask(ctx, parent.ID, todoclient.ToDoTask{Name: "Buy milk", Description: "2 litres", DueDate: dueDate, Labels: []string{"shopping"}})
+	if err != nil {
+		t.Fatalf("error was not nil but '%v'", err)
+	}
+	completed, err := client.CreateTask(ctx, parent.ID, todoclient.ToDoTask{Name: "Pay rent", IsCompleted: true})
+	if err != nil {
+		t.Fatalf("error was not nil but '%v'", err)
+	}
+	if !completed.IsCompleted {
+		t.Errorf("expected completed task but found %+v", completed)
+	}
+
+	// completed tasks are not listed by the API
+	tasks, err := client.GetChildrenTasks(ctx, parent.ID, nil)
+	if err != nil {
+		t.Fatalf("error was not nil but '%v'", err)
+	}
+	if len(tasks) != 1 || tasks[0].ID != created.ID {
+		t.Fatalf("expected created task only but found %+v", tasks)
+	}
+	task, err := client.GetTask(ctx, parent.ID, created.ID)
+	if err != nil {
+		t.Fatalf("error was not nil but '%v'", err)
+	}
+
+	for _, converted := range []todoclient.ToDoTask{created, tasks[0], task} {
+		if converted.Name != "Buy milk" || converted.Description != "2 litres" || !converted.DueDate.Equal(dueDate) ||
+			len(converted.Labels) != 1 || converted.Labels[0] != "shopping" || converted.IsCompleted || converted.CreationTime.IsZero() {
+			t.Errorf("unexpected conversion %+v", converted)
+		}
+	}
+
+	if err := client.DeleteParent(ctx, parent.ID); err != nil {
+		t.Errorf("error was not nil but '%v'", err)
+	}
+}
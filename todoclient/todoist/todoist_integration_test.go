@@ -19,6 +19,10 @@ func TestTodoistClient_Integration_CRUD(t *testing.T) {
 	testutil.IntegrationTest_CRUD(t, createClient(t))
 }
 
+func TestTodoistClient_Integration_Conformance(t *testing.T) {
+	testutil.IntegrationTest_Conformance(t, createClient(t))
+}
+
 func createClient(t *testing.T) *TodoistClient {
 	token := os.Getenv("TODOIST_API_TOKEN")
 	if token == "" {
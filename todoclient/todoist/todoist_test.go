@@ -3,12 +3,19 @@ package todoist
 import (
 	"bytes"
 	"context"
+	stderrors "errors"
 	"io"
+	"log/slog"
 	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/jo-hoe/todoapi/pkg/errors"
 	"github.com/jo-hoe/todoapi/todoclient"
+	"github.com/jo-hoe/todoapi/todoclient/conformance"
+	"github.com/jo-hoe/todoapi/todoclient/todoist/todoistfake"
 )
 
 func TestTodoistClient_ImplementationTest(t *testing.T) {
@@ -20,7 +27,7 @@ func TestTodoistClient_GetAllTasks(t *testing.T) {
 	client := NewTodoistClient(createMockClient(demoList))
 	ctx := context.Background()
 
-	tasks, err := client.GetAllTasks(ctx)
+	tasks, err := client.GetAllTasks(ctx, nil)
 
 	if err != nil {
 		t.Errorf("error was not nil but '%v'", err)
@@ -35,7 +42,7 @@ func TestTodoistClient_GetChildrenTasks(t *testing.T) {
 	client := NewTodoistClient(createMockClient(demoListProject))
 	ctx := context.Background()
 
-	tasks, err := client.GetChildrenTasks(ctx, mockProjectId)
+	tasks, err := client.GetChildrenTasks(ctx, mockProjectId, nil)
 
 	if err != nil {
 		t.Errorf("error was not nil but '%v'", err)
@@ -45,6 +52,72 @@ func TestTodoistClient_GetChildrenTasks(t *testing.T) {
 	}
 }
 
+func TestTodoistClient_GetChildrenTasks_WithQuery(t *testing.T) {
+	var requestedUrl string
+	client := NewTodoistClient(NewMockClient(func(req *http.Request) *http.Response {
+		requestedUrl = req.URL.String()
+		return &http.Response{
+			StatusCode: 200,
+			Body:       io.NopCloser(bytes.NewBufferString(demoList)),
+			Header:     make(http.Header),
+		}
+	}))
+	ctx := context.Background()
+	query := &todoclient.TaskQuery{
+		DueAfter: time.Date(2021, 11, 1, 0, 0, 0, 0, time.UTC),
+		Labels:   []string{"work"},
+	}
+
+	tasks, err := client.GetChildrenTasks(ctx, "2180393145", query)
+
+	if err != nil {
+		t.Errorf("error was not nil but '%v'", err)
+	}
+	// the mock ignores the filter, so the label is checked client-side as well
+	if len(tasks) != 0 {
+		t.Errorf("expected %d tasks but found %d", 0, len(tasks))
+	}
+	if !strings.Contains(requestedUrl, "filter=due+after%3A+2021-10-31+%26+%40work") {
+		t.Errorf("expected filter in url but found '%s'", requestedUrl)
+	}
+}
+
+func TestTodoistClient_GetAllTasks_ClientSideFallback(t *testing.T) {
+	client := NewTodoistClient(createMockClient(demoList))
+	ctx := context.Background()
+
+	tasks, err := client.GetAllTasks(ctx, &todoclient.TaskQuery{Text: "STUFF"})
+
+	if err != nil {
+		t.Errorf("error was not nil but '%v'", err)
+	}
+	if len(tasks) != 1 {
+		t.Errorf("expected %d tasks but found %d", 1, len(tasks))
+	}
+}
+
+func TestBuildTodoistFilter(t *testing.T) {
+	query := &todoclient.TaskQuery{
+		DueAfter:  time.Date(2024, 1, 10, 0, 0, 0, 0, time.UTC),
+		DueBefore: time.Date(2024, 1, 20, 0, 0, 0, 0, time.UTC),
+		Labels:    []string{"home"},
+		Text:      "not expressible",
+	}
+
+	filter := buildTodoistFilter(query)
+
+	expected := "due after: 2024-01-09 & due before: 2024-01-21 & @home"
+	if filter != expected {
+		t.Errorf("expected '%s' but found '%s'", expected, filter)
+	}
+	if buildTodoistFilter(nil) != "" {
+		t.Error("expected empty filter for nil query")
+	}
+	if filter := buildTodoistFilter(&todoclient.TaskQuery{Labels: []string{"home & garden"}}); filter != `@home\ \&\ garden` {
+		t.Errorf("expected escaped label but found '%s'", filter)
+	}
+}
+
 func TestTodoistClient_UpdateTask(t *testing.T) {
 	client := NewTodoistClient(createMockClient(demoListProject))
 	ctx := context.Background()
@@ -79,6 +152,31 @@ func TestTodoistClient_UpdateTask_Without_CreationTime(t *testing.T) {
 	}
 }
 
+func TestTodoistClient_UpdateTask_Completed(t *testing.T) {
+	requests := make([]string, 0)
+	client := NewTodoistClient(NewMockClient(func(req *http.Request) *http.Response {
+		requests = append(requests, req.URL.Path)
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Body:       io.NopCloser(bytes.NewBufferString(`{"id":"5196276900","is_completed":false}`)),
+		}
+	}))
+	task := todoclient.ToDoTask{
+		ID:          "5196276900",
+		Name:        "mockTitle",
+		IsCompleted: true,
+	}
+
+	err := client.UpdateTask(context.Background(), "2180393145", task)
+
+	if err != nil {
+		t.Errorf("error was not nil but '%v'", err)
+	}
+	if len(requests) != 2 || requests[1] != "/rest/v2/tasks/5196276900/close" {
+		t.Errorf("expected update followed by close but found %v", requests)
+	}
+}
+
 func TestTodoistClient_Create(t *testing.T) {
 	client := NewTodoistClient(createMockClient(demoTask))
 	ctx := context.Background()
@@ -96,6 +194,51 @@ func TestTodoistClient_Create(t *testing.T) {
 	}
 }
 
+func TestTodoistClient_GetTask(t *testing.T) {
+	client := NewTodoistClient(createMockClient(demoTask))
+	ctx := context.Background()
+
+	task, err := client.GetTask(ctx, "2180393145", "5207162814")
+
+	if err != nil {
+		t.Errorf("error was not nil but '%v'", err)
+	}
+	if task.ID != "5207162814" {
+		t.Errorf("expected task id %s but found %s", "5207162814", task.ID)
+	}
+	if task.Name != "stuff" {
+		t.Errorf("expected task name %s but found %s", "stuff", task.Name)
+	}
+}
+
+func TestTodoistClient_GetTask_WrongProject(t *testing.T) {
+	client := NewTodoistClient(createMockClient(demoTask))
+	ctx := context.Background()
+
+	_, err := client.GetTask(ctx, "other", "5207162814")
+
+	if !stderrors.Is(err, errors.ErrNotFound) {
+		t.Errorf("expected not found error but found '%v'", err)
+	}
+}
+
+func TestTodoistClient_GetTask_NotFound(t *testing.T) {
+	client := NewTodoistClient(NewMockClient(func(_ *http.Request) *http.Response {
+		return &http.Response{
+			StatusCode: http.StatusNotFound,
+			Body:       io.NopCloser(bytes.NewBufferString("Task not found")),
+			Header:     make(http.Header),
+		}
+	}))
+	ctx := context.Background()
+
+	_, err := client.GetTask(ctx, "2180393145", "unknown")
+
+	if !stderrors.Is(err, errors.ErrNotFound) {
+		t.Errorf("expected not found error but found '%v'", err)
+	}
+}
+
 func TestTodoistClient_GetAllParents(t *testing.T) {
 	client := NewTodoistClient(createMockClient(demoListProject))
 	ctx := context.Background()
@@ -256,3 +399,141 @@ func Test_NewTodoistHTTPClient(t *testing.T) {
 		t.Error("client.Transport was nil")
 	}
 }
+
+func newFakeClient(t *testing.T) (*TodoistClient, *todoistfake.Server) {
+	t.Helper()
+	fake := todoistfake.New("token")
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+
+	client, err := NewTodoistClientWithBaseURL(NewTodoistHTTPClient("token"), server.URL)
+	if err != nil {
+		t.Fatalf("error was not nil but '%v'", err)
+	}
+	return client, fake
+}
+
+func TestTodoistClient_Conformance(t *testing.T) {
+	client, _ := newFakeClient(t)
+	conformance.Run(t, client, conformance.Options{})
+}
+
+func TestTodoistClient_Fake_CommentsAndFilter(t *testing.T) {
+	client, fake := newFakeClient(t)
+	ctx := context.Background()
+	projectID := fake.AddProject("Work")
+	taskID := fake.AddTask(projectID, "report", "work")
+	fake.AddTask(projectID, "other")
+	fake.AddComment(taskID, "first comment")
+
+	task, err := client.GetTask(ctx, projectID, taskID)
+	if err != nil {
+		t.Fatalf("error was not nil but '%v'", err)
+	}
+	if task.Description != "first comment" || task.CreationTime.IsZero() {
+		t.Errorf("expected comment in description and creation time but found %+v", task)
+	}
+
+	tasks, err := client.GetChildrenTasks(ctx, projectID, &todoclient.TaskQuery{Labels: []string{"work"}})
+	if err != nil {
+		t.Fatalf("error was not nil but '%v'", err)
+	}
+	if len(tasks) != 1 || tasks[0].ID != taskID {
+		t.Errorf("expected labeled task but found %+v", tasks)
+	}
+}
+
+func TestTodoistClient_Fake_CommentsFailure(t *testing.T) {
+	client, fake := newFakeClient(t)
+	ctx := context.Background()
+	projectID := fake.AddProject("Work")
+	taskID := fake.AddTask(projectID, "report")
+	fake.AddComment(taskID, "first comment")
+	var buffer bytes.Buffer
+	logged := todoclient.Chain(client, todoclient.Intercept(todoclient.LoggingInterceptor(slog.New(slog.NewTextHandler(&buffer, nil)))))
+
+	// the task is listed without comments and the failure is logged by the interceptor
+	fake.Fail(http.MethodGet, "/comments", http.StatusForbidden, 1)
+	tasks, err := logged.GetChildrenTasks(ctx, projectID, nil)
+	if err != nil {
+		t.Fatalf("error was not nil but '%v'", err)
+	}
+	if len(tasks) != 1 || tasks[0].Description != "" {
+		t.Errorf("expected task without comments but found %+v", tasks)
+	}
+	if !strings.Contains(buffer.String(), "failed to get comments") || !strings.Contains(buffer.String(), taskID) {
+		t.Errorf("expected logged comment failure but found '%s'", buffer.String())
+	}
+}
+
+func TestTodoistClient_Fake_CompletedQuery(t *testing.T) {
+	client, fake := newFakeClient(t)
+	ctx := context.Background()
+	projectID := fake.AddProject("Work")
+	fake.AddTask(projectID, "report")
+
+	completed, active := true, false
+	if _, err := client.GetChildrenTasks(ctx, projectID, &todoclient.TaskQuery{Completed: &completed}); !stderrors.Is(err, errors.ErrNotSupported) {
+		t.Errorf("expected not supported error for completed tasks but found '%v'", err)
+	}
+	if _, err := client.GetAllTasks(ctx, &todoclient.TaskQuery{Completed: &completed}); !stderrors.Is(err, errors.ErrNotSupported) {
+		t.Errorf("expected not supported error for completed tasks but found '%v'", err)
+	}
+	tasks, err := client.GetChildrenTasks(ctx, projectID, &todoclient.TaskQuery{Completed: &active})
+	if err != nil || len(tasks) != 1 {
+		t.Errorf("expected active task but found %v and '%v'", tasks, err)
+	}
+}
+
+func TestTodoistClient_Fake_ClearDueDate(t *testing.T) {
+	client, fake := newFakeClient(t)
+	ctx := context.Background()
+	projectID := fake.AddProject("Work")
+
+	created, err := client.CreateTask(ctx, projectID, todoclient.ToDoTask{Name: "task", DueDate: time.Date(2030, 1, 2, 0, 0, 0, 0, time.UTC)})
+	if err != nil {
+		t.Fatalf("error was not nil but '%v'", err)
+	}
+	created.DueDate = time.Time{}
+	if err := client.UpdateTask(ctx, projectID, created); err != nil {
+		t.Fatalf("error was not nil but '%v'", err)
+	}
+	task, err := client.GetTask(ctx, projectID, created.ID)
+	if err != nil {
+		t.Fatalf("error was not nil but '%v'", err)
+	}
+	if !task.DueDate.IsZero() {
+		t.Errorf("expected due date to be removed but found %v", task.DueDate)
+	}
+}
+
+func TestTodoistClient_Fake_Labels(t *testing.T) {
+	client, fake := newFakeClient(t)
+	ctx := context.Background()
+	projectID := fake.AddProject("Work")
+	fake.AddTask(projectID, "other", "home")
+
+	created, err := client.CreateTask(ctx, projectID, todoclient.ToDoTask{Name: "task", Labels: []string{"home & garden"}})
+	if err != nil {
+		t.Fatalf("error was not nil but '%v'", err)
+	}
+	tasks, err := client.GetChildrenTasks(ctx, projectID, &todoclient.TaskQuery{Labels: []string{"home & garden"}})
+	if err != nil {
+		t.Fatalf("error was not nil but '%v'", err)
+	}
+	if len(tasks) != 1 || tasks[0].ID != created.ID {
+		t.Errorf("expected task with special characters in label but found %+v", tasks)
+	}
+
+	created.Labels = nil
+	if err := client.UpdateTask(ctx, projectID, created); err != nil {
+		t.Fatalf("error was not nil but '%v'", err)
+	}
+	task, err := client.GetTask(ctx, projectID, created.ID)
+	if err != nil {
+		t.Fatalf("error was not nil but '%v'", err)
+	}
+	if len(task.Labels) != 0 {
+		t.Errorf("expected labels to be removed but found %v", task.Labels)
+	}
+}
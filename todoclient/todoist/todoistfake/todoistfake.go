@@ -0,0 +1,764 @@
+// Package todoistfake provides an in-memory fake of the Todoist REST API (v2) for tests
+// and local development without network access. Besides projects, tasks and comments
+// it simulates failures, rate limiting and latency.
+//
+//	fake := todoistfake.New("token")
+//	server := httptest.NewServer(fake)
+//	client, err := todoist.NewTodoistClientWithBaseURL(todoist.NewTodoistHTTPClient("token"), server.URL)
+package todoistfake
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const dateLayout = "2006-01-02"
+
+// Project is a Todoist project
+type Project struct {
+	ID             string `json:"id"`
+	Name           string `json:"name"`
+	CommentCount   int    `json:"comment_count"`
+	Order          int    `json:"order"`
+	IsInboxProject bool   `json:"is_inbox_project"`
+	URL            string `json:"url"`
+}
+
+// Due is the due date of a task
+type Due struct {
+	Date        string `json:"date"`
+	String      string `json:"string"`
+	Lang        string `json:"lang"`
+	IsRecurring bool   `json:"is_recurring"`
+	Datetime    string `json:"datetime,omitempty"`
+}
+
+// Task is a Todoist task
+type Task struct {
+	ID           string    `json:"id"`
+	ProjectID    string    `json:"project_id"`
+	ParentID     *string   `json:"parent_id"`
+	Content      string    `json:"content"`
+	Description  string    `json:"description"`
+	IsCompleted  bool      `json:"is_completed"`
+	Labels       []string  `json:"labels"`
+	Order        int       `json:"order"`
+	Priority     int       `json:"priority"`
+	Due          *Due      `json:"due"`
+	CommentCount int       `json:"comment_count"`
+	CreatedAt    time.Time `json:"created_at"`
+	URL          string    `json:"url"`
+}
+
+// Comment is a comment on a task or project
+type Comment struct {
+	ID        string    `json:"id"`
+	TaskID    *string   `json:"task_id"`
+	ProjectID *string   `json:"project_id"`
+	Content   string    `json:"content"`
+	PostedAt  time.Time `json:"posted_at"`
+}
+
+// fault makes matching requests fail
+type fault struct {
+	method string
+	path   string
+	status int
+	count  int
+}
+
+// Server is the fake API. It implements http.Handler with the API paths relative
+// to the root, so the URL of the server is the base URL for clients.
+type Server struct {
+	mu       sync.Mutex
+	mux      *http.ServeMux
+	token    string
+	now      func() time.Time
+	nextID   int
+	projects []*Project
+	tasks    []*Task
+	comments []*Comment
+	faults   []fault
+	latency  time.Duration
+	limit    int
+	window   time.Duration
+	hits     []time.Time
+	requests []string
+}
+
+// New creates a fake with an empty inbox project. Requests must carry the token as
+// bearer token, an empty token accepts all requests.
+func New(token string) *Server {
+	s := &Server{
+		mux:   http.NewServeMux(),
+		token: token,
+		now:   func() time.Time { return time.Now().UTC() },
+	}
+	inbox := s.addProject("Inbox")
+	inbox.IsInboxProject = true
+
+	s.mux.HandleFunc("GET /projects", s.listProjects)
+	s.mux.HandleFunc("POST /projects", s.createProject)
+	s.mux.HandleFunc("GET /projects/{id}", s.withProject(func(w http.ResponseWriter, r *http.Request, project *Project) {
+		writeJSON(w, http.StatusOK, project)
+	}))
+	s.mux.HandleFunc("POST /projects/{id}", s.withProject(s.updateProject))
+	s.mux.HandleFunc("DELETE /projects/{id}", s.withProject(s.deleteProject))
+	s.mux.HandleFunc("GET /tasks", s.listTasks)
+	s.mux.HandleFunc("POST /tasks", s.createTask)
+	s.mux.HandleFunc("GET /tasks/{id}", s.withTask(func(w http.ResponseWriter, r *http.Request, task *Task) {
+		writeJSON(w, http.StatusOK, task)
+	}))
+	s.mux.HandleFunc("POST /tasks/{id}", s.withTask(s.updateTask))
+	s.mux.HandleFunc("POST /tasks/{id}/close", s.withTask(s.setCompletion(true)))
+	s.mux.HandleFunc("POST /tasks/{id}/reopen", s.withTask(s.setCompletion(false)))
+	s.mux.HandleFunc("DELETE /tasks/{id}", s.withTask(s.deleteTask))
+	s.mux.HandleFunc("GET /comments", s.listComments)
+	s.mux.HandleFunc("POST /comments", s.createComment)
+	s.mux.HandleFunc("DELETE /comments/{id}", s.deleteComment)
+	return s
+}
+
+// ServeHTTP handles a request like the Todoist REST API
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	s.requests = append(s.requests, r.Method+" "+r.URL.RequestURI())
+	latency := s.latency
+	s.mu.Unlock()
+
+	if latency > 0 {
+		select {
+		case <-time.After(latency):
+		case <-r.Context().Done():
+			return
+		}
+	}
+
+	if s.token != "" && r.Header.Get("Authorization") != "Bearer "+s.token {
+		writeError(w, http.StatusUnauthorized, "Unauthorized")
+		return
+	}
+	if retryAfter, limited := s.rateLimited(); limited {
+		w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+		writeError(w, http.StatusTooManyRequests, "Too many requests")
+		return
+	}
+	if status, failed := s.takeFault(r); failed {
+		writeError(w, status, http.StatusText(status))
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.mux.ServeHTTP(w, r)
+}
+
+// Fail makes the next count requests with the method and a path starting with
+// the prefix fail with the status. An empty method matches all methods.
+func (s *Server) Fail(method, pathPrefix string, status, count int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.faults = append(s.faults, fault{method: method, path: pathPrefix, status: status, count: count})
+}
+
+// SetLatency delays every response by the duration
+func (s *Server) SetLatency(latency time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.latency = latency
+}
+
+// SetRateLimit rejects requests exceeding the limit within the window with status 429
+// and a Retry-After header. A limit of 0 disables rate limiting.
+func (s *Server) SetRateLimit(limit int, window time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.limit, s.window, s.hits = limit, window, nil
+}
+
+// Requests returns method and URI of all received requests
+func (s *Server) Requests() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string{}, s.requests...)
+}
+
+// AddProject creates a project and returns its ID
+func (s *Server) AddProject(name string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.addProject(name).ID
+}
+
+// AddTask creates a task in the project and returns its ID
+func (s *Server) AddTask(projectID, content string, labels ...string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	task := s.addTask(projectID, content)
+	task.Labels = append(task.Labels, labels...)
+	return task.ID
+}
+
+// AddComment adds a comment to the task and returns its ID
+func (s *Server) AddComment(taskID, content string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	comment := &Comment{ID: s.id(), TaskID: &taskID, Content: content, PostedAt: s.now()}
+	s.comments = append(s.comments, comment)
+	if task := s.findTask(taskID); task != nil {
+		task.CommentCount++
+	}
+	return comment.ID
+}
+
+// Tasks returns copies of all tasks including completed ones
+func (s *Server) Tasks() []Task {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	result := make([]Task, 0, len(s.tasks))
+	for _, task := range s.tasks {
+		result = append(result, *task)
+	}
+	return result
+}
+
+func (s *Server) rateLimited() (int, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.limit <= 0 {
+		return 0, false
+	}
+	now := s.now()
+	kept := s.hits[:0]
+	for _, hit := range s.hits {
+		if now.Sub(hit) < s.window {
+			kept = append(kept, hit)
+		}
+	}
+	s.hits = kept
+	if len(s.hits) >= s.limit {
+		wait := s.window - now.Sub(s.hits[0])
+		return max(int((wait+time.Second-1)/time.Second), 1), true
+	}
+	s.hits = append(s.hits, now)
+	return 0, false
+}
+
+func (s *Server) takeFault(r *http.Request) (int, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, f := range s.faults {
+		if (f.method == "" || f.method == r.Method) && strings.HasPrefix(r.URL.Path, f.path) {
+			s.faults[i].count--
+			if s.faults[i].count <= 0 {
+				s.faults = append(s.faults[:i], s.faults[i+1:]...)
+			}
+			return f.status, true
+		}
+	}
+	return 0, false
+}
+
+func (s *Server) id() string {
+	s.nextID++
+	return strconv.Itoa(2200000000 + s.nextID)
+}
+
+func (s *Server) addProject(name string) *Project {
+	project := &Project{ID: s.id(), Name: name, Order: len(s.projects)}
+	project.URL = "https://todoist.com/showProject?id=" + project.ID
+	s.projects = append(s.projects, project)
+	return project
+}
+
+func (s *Server) addTask(projectID, content string) *Task {
+	task := &Task{
+		ID:        s.id(),
+		ProjectID: projectID,
+		Content:   content,
+		Labels:    make([]string, 0),
+		Priority:  1,
+		Order:     len(s.tasks),
+		CreatedAt: s.now(),
+	}
+	task.URL = "https://todoist.com/showTask?id=" + task.ID
+	s.tasks = append(s.tasks, task)
+	return task
+}
+
+func (s *Server) findProject(id string) *Project {
+	for _, project := range s.projects {
+		if project.ID == id {
+			return project
+		}
+	}
+	return nil
+}
+
+func (s *Server) findTask(id string) *Task {
+	for _, task := range s.tasks {
+		if task.ID == id {
+			return task
+		}
+	}
+	return nil
+}
+
+func (s *Server) withProject(fn func(w http.ResponseWriter, r *http.Request, project *Project)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		project := s.findProject(r.PathValue("id"))
+		if project == nil {
+			writeError(w, http.StatusNotFound, "Project not found")
+			return
+		}
+		fn(w, r, project)
+	}
+}
+
+func (s *Server) withTask(fn func(w http.ResponseWriter, r *http.Request, task *Task)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		task := s.findTask(r.PathValue("id"))
+		if task == nil {
+			writeError(w, http.StatusNotFound, "Task not found")
+			return
+		}
+		fn(w, r, task)
+	}
+}
+
+func (s *Server) listProjects(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, s.projects)
+}
+
+func (s *Server) createProject(w http.ResponseWriter, r *http.Request) {
+	var request struct {
+		Name string `json:"name"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil || strings.TrimSpace(request.Name) == "" {
+		writeError(w, http.StatusBadRequest, "Required argument is missing: name")
+		return
+	}
+	writeJSON(w, http.StatusOK, s.addProject(request.Name))
+}
+
+func (s *Server) updateProject(w http.ResponseWriter, r *http.Request, project *Project) {
+	var request struct {
+		Name *string `json:"name"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid JSON")
+		return
+	}
+	if request.Name != nil {
+		project.Name = *request.Name
+	}
+	writeJSON(w, http.StatusOK, project)
+}
+
+func (s *Server) deleteProject(w http.ResponseWriter, r *http.Request, project *Project) {
+	if project.IsInboxProject {
+		writeError(w, http.StatusBadRequest, "Inbox project cannot be deleted")
+		return
+	}
+	projects := s.projects[:0]
+	for _, p := range s.projects {
+		if p != project {
+			projects = append(projects, p)
+		}
+	}
+	s.projects = projects
+
+	tasks := s.tasks[:0]
+	for _, task := range s.tasks {
+		if task.ProjectID == project.ID {
+			s.removeComments(task.ID)
+		} else {
+			tasks = append(tasks, task)
+		}
+	}
+	s.tasks = tasks
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// listTasks returns active tasks. Like in the Todoist API, a filter takes
+// precedence over project_id.
+func (s *Server) listTasks(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	var match func(task *Task) bool
+	switch {
+	case query.Get("filter") != "":
+		var err error
+		if match, err = parseFilter(query.Get("filter")); err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+	case query.Get("project_id") != "":
+		projectID := query.Get("project_id")
+		if s.findProject(projectID) == nil {
+			writeError(w, http.StatusNotFound, "Project not found")
+			return
+		}
+		match = func(task *Task) bool { return task.ProjectID == projectID }
+	default:
+		match = func(task *Task) bool { return true }
+	}
+
+	result := make([]*Task, 0)
+	for _, task := range s.tasks {
+		if !task.IsCompleted && match(task) {
+			result = append(result, task)
+		}
+	}
+	writeJSON(w, http.StatusOK, result)
+}
+
+// taskRequest holds the writable fields of a task, nil fields are not changed
+type taskRequest struct {
+	Content     *string   `json:"content"`
+	Description *string   `json:"description"`
+	ProjectID   *string   `json:"project_id"`
+	ParentID    *string   `json:"parent_id"`
+	Labels      *[]string `json:"labels"`
+	Priority    *int      `json:"priority"`
+	DueString   *string   `json:"due_string"`
+	DueDate     *string   `json:"due_date"`
+	DueDatetime *string   `json:"due_datetime"`
+}
+
+func (s *Server) createTask(w http.ResponseWriter, r *http.Request) {
+	var request taskRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid JSON")
+		return
+	}
+	if request.Content == nil || strings.TrimSpace(*request.Content) == "" {
+		writeError(w, http.StatusBadRequest, "Required argument is missing: content")
+		return
+	}
+
+	projectID := s.projects[0].ID
+	if request.ProjectID != nil && *request.ProjectID != "" {
+		projectID = *request.ProjectID
+	}
+	if s.findProject(projectID) == nil {
+		writeError(w, http.StatusBadRequest, "Project not found")
+		return
+	}
+	request.ProjectID = nil
+
+	task := s.addTask(projectID, "")
+	if err := s.apply(task, request); err != nil {
+		s.tasks = s.tasks[:len(s.tasks)-1]
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, task)
+}
+
+func (s *Server) updateTask(w http.ResponseWriter, r *http.Request, task *Task) {
+	var request taskRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid JSON")
+		return
+	}
+	// tasks are moved with the sync API only
+	request.ProjectID, request.ParentID = nil, nil
+
+	updated := *task
+	if err := s.apply(&updated, request); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	*task = updated
+	writeJSON(w, http.StatusOK, task)
+}
+
+// apply sets the fields of the request on the task
+func (s *Server) apply(task *Task, request taskRequest) error {
+	if request.Content != nil {
+		if strings.TrimSpace(*request.Content) == "" {
+			return fmt.Errorf("content cannot be empty")
+		}
+		task.Content = *request.Content
+	}
+	if request.Description != nil {
+		task.Description = *request.Description
+	}
+	if request.ParentID != nil && *request.ParentID != "" {
+		parent := s.findTask(*request.ParentID)
+		if parent == nil || parent.ProjectID != task.ProjectID {
+			return fmt.Errorf("parent task not found")
+		}
+		parentID := *request.ParentID
+		task.ParentID = &parentID
+	}
+	if request.Labels != nil {
+		task.Labels = append(make([]string, 0, len(*request.Labels)), *request.Labels...)
+	}
+	if request.Priority != nil {
+		if *request.Priority < 1 || *request.Priority > 4 {
+			return fmt.Errorf("priority must be between 1 and 4")
+		}
+		task.Priority = *request.Priority
+	}
+
+	switch {
+	case request.DueDatetime != nil:
+		due, err := time.Parse(time.RFC3339, *request.DueDatetime)
+		if err != nil {
+			return fmt.Errorf("invalid due_datetime")
+		}
+		task.Due = &Due{Date: due.UTC().Format(dateLayout), String: *request.DueDatetime, Lang: "en", Datetime: due.UTC().Format(time.RFC3339)}
+	case request.DueDate != nil:
+		if _, err := time.Parse(dateLayout, *request.DueDate); err != nil {
+			return fmt.Errorf("invalid due_date")
+		}
+		task.Due = &Due{Date: *request.DueDate, String: *request.DueDate, Lang: "en"}
+	case request.DueString != nil:
+		due, err := s.parseDueString(*request.DueString)
+		if err != nil {
+			return err
+		}
+		task.Due = due
+	}
+	return nil
+}
+
+// parseDueString understands "no date", "today", "tomorrow" and dates, other
+// natural language is not supported by the fake
+func (s *Server) parseDueString(value string) (*Due, error) {
+	var date time.Time
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "", "no date", "no due date":
+		return nil, nil
+	case "today":
+		date = s.now()
+	case "tomorrow":
+		date = s.now().AddDate(0, 0, 1)
+	default:
+		parsed, err := time.Parse(dateLayout, value)
+		if err != nil {
+			return nil, fmt.Errorf("unsupported due_string '%s'", value)
+		}
+		date = parsed
+	}
+	return &Due{Date: date.Format(dateLayout), String: value, Lang: "en"}, nil
+}
+
+func (s *Server) setCompletion(completed bool) func(w http.ResponseWriter, r *http.Request, task *Task) {
+	return func(w http.ResponseWriter, r *http.Request, task *Task) {
+		task.IsCompleted = completed
+		// subtasks are closed with their parent
+		if completed {
+			for _, subtask := range s.tasks {
+				if subtask.ParentID != nil && *subtask.ParentID == task.ID {
+					subtask.IsCompleted = true
+				}
+			}
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func (s *Server) deleteTask(w http.ResponseWriter, r *http.Request, task *Task) {
+	deleted := map[string]bool{task.ID: true}
+	// subtasks are deleted with their parent, they follow it in creation order
+	for _, t := range s.tasks {
+		if t.ParentID != nil && deleted[*t.ParentID] {
+			deleted[t.ID] = true
+		}
+	}
+	tasks := s.tasks[:0]
+	for _, t := range s.tasks {
+		if deleted[t.ID] {
+			s.removeComments(t.ID)
+		} else {
+			tasks = append(tasks, t)
+		}
+	}
+	s.tasks = tasks
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) removeComments(taskID string) {
+	comments := s.comments[:0]
+	for _, comment := range s.comments {
+		if comment.TaskID == nil || *comment.TaskID != taskID {
+			comments = append(comments, comment)
+		}
+	}
+	s.comments = comments
+}
+
+func (s *Server) listComments(w http.ResponseWriter, r *http.Request) {
+	taskID, projectID := r.URL.Query().Get("task_id"), r.URL.Query().Get("project_id")
+	if taskID == "" && projectID == "" {
+		writeError(w, http.StatusBadRequest, "Required argument is missing: task_id or project_id")
+		return
+	}
+	result := make([]*Comment, 0)
+	for _, comment := range s.comments {
+		if (taskID != "" && comment.TaskID != nil && *comment.TaskID == taskID) ||
+			(projectID != "" && comment.ProjectID != nil && *comment.ProjectID == projectID) {
+			result = append(result, comment)
+		}
+	}
+	writeJSON(w, http.StatusOK, result)
+}
+
+func (s *Server) createComment(w http.ResponseWriter, r *http.Request) {
+	var request struct {
+		TaskID    string `json:"task_id"`
+		ProjectID string `json:"project_id"`
+		Content   string `json:"content"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil || request.Content == "" {
+		writeError(w, http.StatusBadRequest, "Required argument is missing: content")
+		return
+	}
+
+	comment := &Comment{ID: s.id(), Content: request.Content, PostedAt: s.now()}
+	switch {
+	case request.TaskID != "":
+		task := s.findTask(request.TaskID)
+		if task == nil {
+			writeError(w, http.StatusBadRequest, "Task not found")
+			return
+		}
+		task.CommentCount++
+		comment.TaskID = &request.TaskID
+	case request.ProjectID != "":
+		project := s.findProject(request.ProjectID)
+		if project == nil {
+			writeError(w, http.StatusBadRequest, "Project not found")
+			return
+		}
+		project.CommentCount++
+		comment.ProjectID = &request.ProjectID
+	default:
+		writeError(w, http.StatusBadRequest, "Required argument is missing: task_id or project_id")
+		return
+	}
+	s.comments = append(s.comments, comment)
+	writeJSON(w, http.StatusOK, comment)
+}
+
+func (s *Server) deleteComment(w http.ResponseWriter, r *http.Request) {
+	for i, comment := range s.comments {
+		if comment.ID != r.PathValue("id") {
+			continue
+		}
+		if comment.TaskID != nil {
+			if task := s.findTask(*comment.TaskID); task != nil {
+				task.CommentCount--
+			}
+		}
+		if comment.ProjectID != nil {
+			if project := s.findProject(*comment.ProjectID); project != nil {
+				project.CommentCount--
+			}
+		}
+		s.comments = append(s.comments[:i], s.comments[i+1:]...)
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	writeError(w, http.StatusNotFound, "Comment not found")
+}
+
+// splitFilter splits the filter at "&" operators, escaped characters are kept
+func splitFilter(filter string) []string {
+	parts := make([]string, 0)
+	start := 0
+	for i := 0; i < len(filter); i++ {
+		switch filter[i] {
+		case '\\':
+			i++
+		case '&':
+			parts = append(parts, filter[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, filter[start:])
+}
+
+// unescapeFilter removes the backslashes escaping special characters
+func unescapeFilter(value string) string {
+	var b strings.Builder
+	for i := 0; i < len(value); i++ {
+		if value[i] == '\\' && i+1 < len(value) {
+			i++
+		}
+		b.WriteByte(value[i])
+	}
+	return b.String()
+}
+
+// parseFilter supports the subset of the filter syntax used by the todoist
+// package: "due after: <date>", "due before: <date>" and "@label" joined by "&".
+// Special characters of labels are escaped with a backslash.
+func parseFilter(filter string) (func(task *Task) bool, error) {
+	conditions := make([]func(task *Task) bool, 0)
+	for _, part := range splitFilter(filter) {
+		part = strings.TrimSpace(part)
+		lower := strings.ToLower(part)
+		switch {
+		case strings.HasPrefix(part, "@"):
+			label := unescapeFilter(part[1:])
+			conditions = append(conditions, func(task *Task) bool {
+				for _, l := range task.Labels {
+					if strings.EqualFold(l, label) {
+						return true
+					}
+				}
+				return false
+			})
+		case strings.HasPrefix(lower, "due after:"), strings.HasPrefix(lower, "due before:"):
+			after := strings.HasPrefix(lower, "due after:")
+			date, err := time.Parse(dateLayout, strings.TrimSpace(part[strings.Index(part, ":")+1:]))
+			if err != nil {
+				return nil, fmt.Errorf("invalid filter '%s'", part)
+			}
+			conditions = append(conditions, func(task *Task) bool {
+				if task.Due == nil {
+					return false
+				}
+				due, err := time.Parse(dateLayout, task.Due.Date)
+				if err != nil {
+					return false
+				}
+				if after {
+					return due.After(date)
+				}
+				return due.Before(date)
+			})
+		default:
+			return nil, fmt.Errorf("unsupported filter '%s'", part)
+		}
+	}
+	return func(task *Task) bool {
+		for _, condition := range conditions {
+			if !condition(task) {
+				return false
+			}
+		}
+		return true
+	}, nil
+}
+
+func writeJSON(w http.ResponseWriter, status int, value interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(value)
+}
+
+// writeError writes a plain text error like the Todoist API
+func writeError(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.WriteHeader(status)
+	_, _ = fmt.Fprint(w, message)
+}
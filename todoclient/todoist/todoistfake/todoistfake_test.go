@@ -0,0 +1,154 @@
+package todoistfake
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestServer(t *testing.T) (*Server, *httptest.Server) {
+	t.Helper()
+	fake := New("token")
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+	return fake, server
+}
+
+func do(t *testing.T, server *httptest.Server, method, path, body string) *http.Response {
+	t.Helper()
+	req, err := http.NewRequest(method, server.URL+path, strings.NewReader(body))
+	if err != nil {
+		t.Fatalf("error was not nil but '%v'", err)
+	}
+	req.Header.Set("Authorization", "Bearer token")
+	resp, err := server.Client().Do(req)
+	if err != nil {
+		t.Fatalf("error was not nil but '%v'", err)
+	}
+	t.Cleanup(func() { _ = resp.Body.Close() })
+	return resp
+}
+
+func TestServer_Unauthorized(t *testing.T) {
+	_, server := newTestServer(t)
+
+	resp, err := server.Client().Get(server.URL + "/projects")
+	if err != nil {
+		t.Fatalf("error was not nil but '%v'", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("expected status %d but found %d", http.StatusUnauthorized, resp.StatusCode)
+	}
+}
+
+func TestServer_TaskLifecycle(t *testing.T) {
+	fake, server := newTestServer(t)
+	projectID := fake.AddProject("Work")
+
+	resp := do(t, server, http.MethodPost, "/tasks", `{"content":"task","project_id":"`+projectID+`","due_date":"2030-01-02"}`)
+	var task Task
+	if err := json.NewDecoder(resp.Body).Decode(&task); err != nil || resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected created task but found status %d and '%v'", resp.StatusCode, err)
+	}
+	if task.Due == nil || task.Due.Date != "2030-01-02" {
+		t.Errorf("expected due date but found %+v", task.Due)
+	}
+
+	if resp := do(t, server, http.MethodPost, "/tasks/"+task.ID+"/close", ""); resp.StatusCode != http.StatusNoContent {
+		t.Errorf("expected status %d but found %d", http.StatusNoContent, resp.StatusCode)
+	}
+	var active []Task
+	_ = json.NewDecoder(do(t, server, http.MethodGet, "/tasks?project_id="+projectID, "").Body).Decode(&active)
+	if len(active) != 0 {
+		t.Errorf("expected completed task not to be listed but found %+v", active)
+	}
+
+	if resp := do(t, server, http.MethodDelete, "/projects/"+projectID, ""); resp.StatusCode != http.StatusNoContent {
+		t.Errorf("expected status %d but found %d", http.StatusNoContent, resp.StatusCode)
+	}
+	if resp := do(t, server, http.MethodGet, "/tasks/"+task.ID, ""); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("expected tasks to be deleted with their project but found status %d", resp.StatusCode)
+	}
+}
+
+func TestServer_Filter(t *testing.T) {
+	fake, server := newTestServer(t)
+	projectID := fake.AddProject("Work")
+	fake.AddTask(projectID, "labeled", "work")
+	fake.AddTask(projectID, "other")
+
+	tests := []struct {
+		name     string
+		filter   string
+		status   int
+		expected int
+	}{
+		{name: "label", filter: "@work", status: http.StatusOK, expected: 1},
+		{name: "due date", filter: "due before: 2030-01-01", status: http.StatusOK, expected: 0},
+		{name: "unsupported", filter: "p1 | today", status: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := do(t, server, http.MethodGet, "/tasks?filter="+strings.NewReplacer(" ", "+", "@", "%40", "|", "%7C").Replace(tt.filter), "")
+			if resp.StatusCode != tt.status {
+				t.Fatalf("expected status %d but found %d", tt.status, resp.StatusCode)
+			}
+			if tt.status != http.StatusOK {
+				return
+			}
+			var tasks []Task
+			_ = json.NewDecoder(resp.Body).Decode(&tasks)
+			if len(tasks) != tt.expected {
+				t.Errorf("expected %d tasks but found %d", tt.expected, len(tasks))
+			}
+		})
+	}
+}
+
+func TestServer_Fail(t *testing.T) {
+	fake, server := newTestServer(t)
+	fake.Fail(http.MethodGet, "/projects", http.StatusServiceUnavailable, 2)
+
+	for i, expected := range []int{http.StatusServiceUnavailable, http.StatusServiceUnavailable, http.StatusOK} {
+		if resp := do(t, server, http.MethodGet, "/projects", ""); resp.StatusCode != expected {
+			t.Errorf("request %d: expected status %d but found %d", i, expected, resp.StatusCode)
+		}
+	}
+	if resp := do(t, server, http.MethodGet, "/tasks", ""); resp.StatusCode != http.StatusOK {
+		t.Errorf("expected other paths to succeed but found status %d", resp.StatusCode)
+	}
+}
+
+func TestServer_RateLimit(t *testing.T) {
+	fake, server := newTestServer(t)
+	fake.SetRateLimit(2, time.Minute)
+
+	do(t, server, http.MethodGet, "/projects", "")
+	do(t, server, http.MethodGet, "/projects", "")
+	resp := do(t, server, http.MethodGet, "/projects", "")
+	if resp.StatusCode != http.StatusTooManyRequests {
+		t.Errorf("expected status %d but found %d", http.StatusTooManyRequests, resp.StatusCode)
+	}
+	if resp.Header.Get("Retry-After") == "" {
+		t.Error("expected Retry-After header")
+	}
+}
+
+func TestServer_Latency(t *testing.T) {
+	fake, server := newTestServer(t)
+	fake.SetLatency(time.Second)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, server.URL+"/projects", nil)
+	req.Header.Set("Authorization", "Bearer token")
+	if resp, err := server.Client().Do(req); err == nil {
+		_ = resp.Body.Close()
+		t.Error("expected request to time out")
+	}
+}
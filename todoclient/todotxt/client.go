@@ -0,0 +1,209 @@
+package todotxt
+
+import (
+	"context"
+	stderrors "errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/jo-hoe/todoapi/pkg/errors"
+	"github.com/jo-hoe/todoapi/todoclient"
+)
+
+// PriorityLabelPrefix marks labels holding the todo.txt priority, e.g. "priority:A",
+// as ToDoTask has no priority of its own
+const PriorityLabelPrefix = "priority:"
+
+// Export writes the tasks of all parents of the client. Every line carries the
+// parent as +project and the task ID as id: pair, which lets Import update the
+// tasks after the file was edited. Descriptions are not exported.
+func Export(ctx context.Context, client todoclient.ToDoClient, w io.Writer) error {
+	parents, err := client.GetAllParents(ctx)
+	if err != nil {
+		return errors.NewAPIError("TODOTXT_GET_PARENTS_FAILED", "failed to retrieve parents", err)
+	}
+
+	items := make([]Item, 0)
+	for _, parent := range parents {
+		tasks, err := client.GetChildrenTasks(ctx, parent.ID, nil)
+		if err != nil {
+			return errors.NewAPIError("TODOTXT_GET_TASKS_FAILED", "failed to retrieve tasks", err)
+		}
+		for _, task := range tasks {
+			items = append(items, FromTask(task, parent.Name))
+		}
+	}
+	return Encode(w, items)
+}
+
+// FromTask converts a task of the given parent to an item
+func FromTask(task todoclient.ToDoTask, parentName string) Item {
+	item := Item{
+		Task:     task,
+		Projects: make([]string, 0, 1),
+		Extra:    make(map[string]string),
+	}
+	if parentName != "" {
+		item.Projects = append(item.Projects, parentName)
+	}
+
+	item.Task.Labels = make([]string, 0, len(task.Labels))
+	for _, label := range task.Labels {
+		if priority, ok := strings.CutPrefix(label, PriorityLabelPrefix); ok && priorityPattern.MatchString("("+priority+")") {
+			item.Priority = priority
+			continue
+		}
+		item.Task.Labels = append(item.Task.Labels, label)
+	}
+	if task.IsCompleted && !task.ModifiedTime.IsZero() {
+		item.CompletionDate = task.ModifiedTime
+	}
+	return item
+}
+
+// ToTask converts an item to a task, keeping the priority as label
+func (item Item) ToTask() todoclient.ToDoTask {
+	task := item.Task
+	task.Labels = append(make([]string, 0, len(item.Task.Labels)+1), item.Task.Labels...)
+	if item.Priority != "" {
+		task.Labels = append(task.Labels, PriorityLabelPrefix+item.Priority)
+	}
+	return task
+}
+
+// ImportOptions configures an import
+type ImportOptions struct {
+	DefaultParentID string // Parent of items without +project, items are rejected if empty
+	DryRun          bool   // Count the changes without applying them
+}
+
+// ImportReport summarizes an import
+type ImportReport struct {
+	ParentsCreated int      `json:"parents_created"`
+	Created        int      `json:"created"`
+	Updated        int      `json:"updated"`
+	Unchanged      int      `json:"unchanged"`
+	Failed         int      `json:"failed"`
+	Errors         []string `json:"errors"`
+}
+
+// Import applies a todo.txt file to the client. The first +project of an item
+// selects the parent by name, missing parents are created. Items with an id:
+// pair update the existing task, all others are created. Tasks which are not
+// part of the file are left untouched.
+func Import(ctx context.Context, client todoclient.ToDoClient, r io.Reader, options ImportOptions) (ImportReport, error) {
+	report := ImportReport{Errors: make([]string, 0)}
+
+	items, err := Decode(r)
+	if err != nil {
+		return report, err
+	}
+	parents, err := client.GetAllParents(ctx)
+	if err != nil {
+		return report, errors.NewAPIError("TODOTXT_GET_PARENTS_FAILED", "failed to retrieve parents", err)
+	}
+	parentIDs := make(map[string]string, len(parents))
+	for _, parent := range parents {
+		parentIDs[strings.ToLower(Tag(parent.Name))] = parent.ID
+	}
+
+	for i, item := range items {
+		if err := importItem(ctx, client, item, parentIDs, options, &report); err != nil {
+			report.Failed++
+			report.Errors = append(report.Errors, fmt.Sprintf("item %d '%s': %v", i+1, item.Task.Name, err))
+		}
+	}
+	return report, nil
+}
+
+func importItem(ctx context.Context, client todoclient.ToDoClient, item Item, parentIDs map[string]string, options ImportOptions, report *ImportReport) error {
+	task := item.ToTask()
+	if err := task.Validate(); err != nil {
+		return err
+	}
+
+	parentID := options.DefaultParentID
+	if len(item.Projects) > 0 {
+		key := strings.ToLower(Tag(item.Projects[0]))
+		id, ok := parentIDs[key]
+		if !ok && !options.DryRun {
+			parent, err := client.CreateParent(ctx, item.Projects[0])
+			if err != nil {
+				return err
+			}
+			id = parent.ID
+		}
+		if !ok {
+			parentIDs[key] = id
+			report.ParentsCreated++
+		}
+		parentID = id
+	}
+	if parentID == "" && !(options.DryRun && len(item.Projects) > 0) {
+		return errors.NewValidationError("project", "item has no +project and no default parent is set")
+	}
+
+	if task.ID != "" && parentID != "" {
+		existing, err := client.GetTask(ctx, parentID, task.ID)
+		switch {
+		case err == nil:
+			return updateTask(ctx, client, parentID, existing, task, options, report)
+		case !stderrors.Is(err, errors.ErrNotFound):
+			return err
+		}
+	}
+
+	report.Created++
+	if options.DryRun {
+		return nil
+	}
+	task.ID = ""
+	if _, err := client.CreateTask(ctx, parentID, task); err != nil {
+		report.Created--
+		return err
+	}
+	return nil
+}
+
+// updateTask applies the fields of the todo.txt format to an existing task
+func updateTask(ctx context.Context, client todoclient.ToDoClient, parentID string, existing, task todoclient.ToDoTask, options ImportOptions, report *ImportReport) error {
+	updated := existing
+	updated.Name = task.Name
+	updated.IsCompleted = task.IsCompleted
+	updated.Labels = matchLabels(task.Labels, existing.Labels)
+	// todo.txt only knows dates, times of existing due dates are kept
+	if task.DueDate.IsZero() || existing.DueDate.IsZero() || existing.DueDate.Format(dateFormat) != task.DueDate.Format(dateFormat) {
+		updated.DueDate = task.DueDate
+	}
+
+	if updated.Name == existing.Name && updated.IsCompleted == existing.IsCompleted &&
+		updated.DueDate.Equal(existing.DueDate) && strings.Join(updated.Labels, "\x00") == strings.Join(existing.Labels, "\x00") {
+		report.Unchanged++
+		return nil
+	}
+	if !options.DryRun {
+		if err := client.UpdateTask(ctx, parentID, updated); err != nil {
+			return err
+		}
+	}
+	report.Updated++
+	return nil
+}
+
+// matchLabels replaces tags by the existing labels they were written for, as
+// labels with whitespace are written as tags like @at_home for "at home"
+func matchLabels(tags, existing []string) []string {
+	result := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		label := tag
+		for _, candidate := range existing {
+			if Tag(candidate) == tag {
+				label = candidate
+				break
+			}
+		}
+		result = append(result, label)
+	}
+	return result
+}
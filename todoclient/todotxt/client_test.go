@@ -0,0 +1,136 @@
+package todotxt
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jo-hoe/todoapi/internal/testutil"
+	"github.com/jo-hoe/todoapi/todoclient"
+)
+
+func taskCount(client todoclient.ToDoClient) int {
+	tasks, _ := client.GetAllTasks(context.Background(), nil)
+	return len(tasks)
+}
+
+func parentCount(client todoclient.ToDoClient) int {
+	parents, _ := client.GetAllParents(context.Background())
+	return len(parents)
+}
+
+func TestExportImport_RoundTrip(t *testing.T) {
+	ctx := context.Background()
+	client := testutil.NewMockToDoClient()
+	work, _ := client.CreateParent(ctx, "Work Stuff")
+	due := time.Date(2024, 3, 5, 15, 0, 0, 0, time.UTC)
+	report, _ := client.CreateTask(ctx, work.ID, todoclient.ToDoTask{
+		Name:        "Write report",
+		Description: "kept on import",
+		DueDate:     due,
+		Labels:      []string{"office", PriorityLabelPrefix + "A"},
+	})
+	call, _ := client.CreateTask(ctx, work.ID, todoclient.ToDoTask{Name: "Call", IsCompleted: true})
+
+	var buffer bytes.Buffer
+	if err := Export(ctx, client, &buffer); err != nil {
+		t.Fatalf("error was not nil but '%v'", err)
+	}
+	// the mock sets the creation and modification time to now
+	today := report.CreationTime.Format(dateFormat)
+	expected := fmt.Sprintf("(A) %[1]s Write report +Work_Stuff @office due:2024-03-05 id:%[2]s\nx %[1]s %[1]s Call +Work_Stuff id:%[3]s\n", today, report.ID, call.ID)
+	if buffer.String() != expected {
+		t.Errorf("expected '%s' but found '%s'", expected, buffer.String())
+	}
+
+	edited := strings.Replace(buffer.String(), "(A) "+today+" Write report", "(B) "+today+" Write final report", 1) +
+		"New task +work_stuff @home\nPrivate +Home\n"
+	result, err := Import(ctx, client, strings.NewReader(edited), ImportOptions{})
+	if err != nil {
+		t.Fatalf("error was not nil but '%v'", err)
+	}
+	if result.Updated != 1 || result.Unchanged != 1 || result.Created != 2 || result.ParentsCreated != 1 || result.Failed != 0 {
+		t.Errorf("unexpected report %+v", result)
+	}
+
+	updated, _ := client.GetTask(ctx, work.ID, report.ID)
+	if updated.Name != "Write final report" || updated.Description != "kept on import" || !updated.DueDate.Equal(due) {
+		t.Errorf("unexpected updated task %+v", updated)
+	}
+	if fmt.Sprint(updated.Labels) != "[office priority:B]" {
+		t.Errorf("unexpected labels %v", updated.Labels)
+	}
+	if tasks, _ := client.GetChildrenTasks(ctx, work.ID, nil); len(tasks) != 3 || parentCount(client) != 2 {
+		t.Errorf("unexpected state with %d parents and tasks %+v", parentCount(client), tasks)
+	}
+}
+
+func TestImport_DryRunAndDefaultParent(t *testing.T) {
+	ctx := context.Background()
+	client := testutil.NewMockToDoClient()
+	inbox, _ := client.CreateParent(ctx, "Inbox")
+	input := "no project\nwith project +New\nanother +New\nbad due:x\n"
+
+	result, err := Import(ctx, client, strings.NewReader("no project\n"), ImportOptions{})
+	if err != nil {
+		t.Fatalf("error was not nil but '%v'", err)
+	}
+	if result.Failed != 1 || len(result.Errors) != 1 {
+		t.Errorf("expected a failure without default parent but found %+v", result)
+	}
+
+	if _, err := Import(ctx, client, strings.NewReader(input), ImportOptions{}); err == nil {
+		t.Error("expected error for invalid line")
+	}
+
+	input = "no project\nwith project +New\nanother +New\n"
+	result, err = Import(ctx, client, strings.NewReader(input), ImportOptions{DefaultParentID: inbox.ID, DryRun: true})
+	if err != nil {
+		t.Fatalf("error was not nil but '%v'", err)
+	}
+	if result.Created != 3 || result.ParentsCreated != 1 || parentCount(client) != 1 || taskCount(client) != 0 {
+		t.Errorf("unexpected dry-run result %+v", result)
+	}
+}
+
+func TestExportImport_RoundTripMetadataInNames(t *testing.T) {
+	ctx := context.Background()
+	client := testutil.NewMockToDoClient()
+	work, _ := client.CreateParent(ctx, "Work")
+	tasks := []todoclient.ToDoTask{
+		{Name: "Vote +1 on PR +Work"},
+		{Name: "Meet 10:30", Labels: []string{"at home", "office"}},
+		{Name: "x marks the spot", IsCompleted: true},
+		{Name: "email@example.com is @ceo"},
+	}
+	for _, task := range tasks {
+		if _, err := client.CreateTask(ctx, work.ID, task); err != nil {
+			t.Fatalf("error was not nil but '%v'", err)
+		}
+	}
+
+	var buffer bytes.Buffer
+	if err := Export(ctx, client, &buffer); err != nil {
+		t.Fatalf("error was not nil but '%v'", err)
+	}
+	result, err := Import(ctx, client, strings.NewReader(buffer.String()), ImportOptions{})
+	if err != nil {
+		t.Fatalf("error was not nil but '%v'", err)
+	}
+
+	if result.Unchanged != len(tasks) || result.Created+result.Updated+result.ParentsCreated+result.Failed != 0 {
+		t.Errorf("expected unchanged tasks but found %+v in\n%s", result, buffer.String())
+	}
+	imported, _ := client.GetChildrenTasks(ctx, work.ID, nil)
+	if len(imported) != len(tasks) {
+		t.Fatalf("expected %d tasks but found %d", len(tasks), len(imported))
+	}
+	for i, task := range imported {
+		if task.Name != tasks[i].Name || fmt.Sprint(task.Labels) != fmt.Sprint(tasks[i].Labels) {
+			t.Errorf("expected %+v but found %+v", tasks[i], task)
+		}
+	}
+}
@@ -0,0 +1,242 @@
+// Package todotxt reads and writes tasks in the todo.txt format, see
+// https://github.com/todotxt/todo.txt
+package todotxt
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jo-hoe/todoapi/pkg/errors"
+	"github.com/jo-hoe/todoapi/todoclient"
+)
+
+const dateFormat = "2006-01-02"
+
+// Keys of key:value pairs with a meaning for the codec
+const (
+	dueKey      = "due"
+	idKey       = "id"
+	priorityKey = "pri" // priority of completed tasks, which lose their (A) prefix
+)
+
+var priorityPattern = regexp.MustCompile(`^\(([A-Z])\)$`)
+
+// Item is a single line of a todo.txt file.
+//
+// Words of the name which would be read as metadata, like +1, @home, 10:30 or a
+// leading x, are written with a backslash, e.g. \+1. A backslash at the start
+// of a word is removed when reading.
+type Item struct {
+	Task           todoclient.ToDoTask // Name, ID (id:), DueDate (due:), CreationTime, IsCompleted and Labels (@context)
+	Priority       string              // A to Z, empty without priority
+	Projects       []string            // +project tags in order of appearance
+	CompletionDate time.Time
+	Extra          map[string]string // key:value pairs without a meaning for the codec
+}
+
+// ParseLine reads a single todo.txt line
+func ParseLine(line string) (Item, error) {
+	item := Item{
+		Projects: make([]string, 0),
+		Extra:    make(map[string]string),
+	}
+	item.Task.Labels = make([]string, 0)
+
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		return item, errors.NewValidationError("line", "line is empty")
+	}
+
+	if fields[0] == "x" {
+		item.Task.IsCompleted = true
+		fields = fields[1:]
+		if date, ok := parseDate(fields); ok {
+			item.CompletionDate = date
+			fields = fields[1:]
+		}
+	} else if match := priorityPattern.FindStringSubmatch(fields[0]); match != nil {
+		item.Priority = match[1]
+		fields = fields[1:]
+	}
+	if date, ok := parseDate(fields); ok {
+		item.Task.CreationTime = date
+		fields = fields[1:]
+	}
+
+	words := make([]string, 0, len(fields))
+	for _, field := range fields {
+		switch {
+		case strings.HasPrefix(field, `\`):
+			words = append(words, field[1:])
+		case len(field) > 1 && field[0] == '+':
+			item.Projects = append(item.Projects, field[1:])
+		case len(field) > 1 && field[0] == '@':
+			item.Task.Labels = append(item.Task.Labels, field[1:])
+		default:
+			key, value, ok := splitKeyValue(field)
+			if !ok {
+				words = append(words, field)
+				continue
+			}
+			if err := item.setValue(key, value); err != nil {
+				return item, err
+			}
+		}
+	}
+	item.Task.Name = strings.Join(words, " ")
+	return item, nil
+}
+
+func (item *Item) setValue(key, value string) error {
+	switch key {
+	case dueKey:
+		due, err := time.Parse(dateFormat, value)
+		if err != nil {
+			return errors.NewValidationError(dueKey, fmt.Sprintf("invalid due date '%s'", value))
+		}
+		item.Task.DueDate = due
+	case idKey:
+		item.Task.ID = value
+	case priorityKey:
+		if !priorityPattern.MatchString("(" + value + ")") {
+			return errors.NewValidationError(priorityKey, fmt.Sprintf("invalid priority '%s'", value))
+		}
+		item.Priority = value
+	default:
+		item.Extra[key] = value
+	}
+	return nil
+}
+
+// String returns the todo.txt line of the item
+func (item Item) String() string {
+	parts := make([]string, 0)
+	if item.Task.IsCompleted {
+		parts = append(parts, "x")
+		// the creation date may only follow a completion date
+		if !item.CompletionDate.IsZero() {
+			parts = append(parts, item.CompletionDate.Format(dateFormat))
+			if !item.Task.CreationTime.IsZero() {
+				parts = append(parts, item.Task.CreationTime.Format(dateFormat))
+			}
+		}
+	} else {
+		if item.Priority != "" {
+			parts = append(parts, "("+item.Priority+")")
+		}
+		if !item.Task.CreationTime.IsZero() {
+			parts = append(parts, item.Task.CreationTime.Format(dateFormat))
+		}
+	}
+
+	for i, word := range strings.Fields(item.Task.Name) {
+		if isMetadata(word, i == 0) {
+			word = `\` + word
+		}
+		parts = append(parts, word)
+	}
+	for _, project := range item.Projects {
+		parts = append(parts, "+"+Tag(project))
+	}
+	for _, label := range item.Task.Labels {
+		parts = append(parts, "@"+Tag(label))
+	}
+	if !item.Task.DueDate.IsZero() {
+		parts = append(parts, dueKey+":"+item.Task.DueDate.Format(dateFormat))
+	}
+	if item.Task.IsCompleted && item.Priority != "" {
+		parts = append(parts, priorityKey+":"+item.Priority)
+	}
+
+	keys := make([]string, 0, len(item.Extra))
+	for key := range item.Extra {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		parts = append(parts, key+":"+item.Extra[key])
+	}
+	if item.Task.ID != "" {
+		parts = append(parts, idKey+":"+item.Task.ID)
+	}
+	return strings.Join(parts, " ")
+}
+
+// Decode reads all items of a todo.txt file, skipping empty lines
+func Decode(r io.Reader) ([]Item, error) {
+	items := make([]Item, 0)
+	scanner := bufio.NewScanner(r)
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		if strings.TrimSpace(scanner.Text()) == "" {
+			continue
+		}
+		item, err := ParseLine(scanner.Text())
+		if err != nil {
+			return nil, errors.NewAPIError("TODOTXT_PARSE_FAILED", fmt.Sprintf("invalid line %d", lineNumber), err)
+		}
+		items = append(items, item)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, errors.NewAPIError("TODOTXT_READ_FAILED", "failed to read file", err)
+	}
+	return items, nil
+}
+
+// Encode writes the items as todo.txt lines
+func Encode(w io.Writer, items []Item) error {
+	bw := bufio.NewWriter(w)
+	for _, item := range items {
+		if _, err := bw.WriteString(item.String() + "\n"); err != nil {
+			return errors.NewAPIError("TODOTXT_WRITE_FAILED", "failed to write file", err)
+		}
+	}
+	if err := bw.Flush(); err != nil {
+		return errors.NewAPIError("TODOTXT_WRITE_FAILED", "failed to write file", err)
+	}
+	return nil
+}
+
+// Tag turns a name into a +project or @context tag, which cannot contain whitespace
+func Tag(name string) string {
+	return strings.Join(strings.Fields(name), "_")
+}
+
+// isMetadata reports whether a word of a name would not be read as text. The
+// first word may also be taken for the completion mark, a priority or a date.
+func isMetadata(word string, first bool) bool {
+	if strings.HasPrefix(word, `\`) || (len(word) > 1 && (word[0] == '+' || word[0] == '@')) {
+		return true
+	}
+	if _, _, ok := splitKeyValue(word); ok {
+		return true
+	}
+	if _, ok := parseDate([]string{word}); first && ok {
+		return true
+	}
+	return first && (word == "x" || priorityPattern.MatchString(word))
+}
+
+func parseDate(fields []string) (time.Time, bool) {
+	if len(fields) == 0 {
+		return time.Time{}, false
+	}
+	date, err := time.Parse(dateFormat, fields[0])
+	return date, err == nil
+}
+
+// splitKeyValue detects key:value pairs. Neither side may be empty or contain
+// a colon, which keeps URLs like https://example.com part of the text.
+func splitKeyValue(field string) (string, string, bool) {
+	key, value, ok := strings.Cut(field, ":")
+	if !ok || key == "" || value == "" || strings.Contains(value, ":") || strings.HasPrefix(value, "//") {
+		return "", "", false
+	}
+	return key, value, true
+}
@@ -0,0 +1,155 @@
+package todotxt
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func date(year int, month time.Month, day int) time.Time {
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+}
+
+func TestParseLine(t *testing.T) {
+	tests := []struct {
+		line string
+		want func(item *Item)
+	}{
+		{
+			line: "(A) 2024-03-01 Call Mom +Family +Phone @phone due:2024-03-05 see https://example.com",
+			want: func(item *Item) {
+				item.Priority = "A"
+				item.Task.CreationTime = date(2024, 3, 1)
+				item.Task.Name = "Call Mom see https://example.com"
+				item.Projects = []string{"Family", "Phone"}
+				item.Task.Labels = []string{"phone"}
+				item.Task.DueDate = date(2024, 3, 5)
+			},
+		},
+		{
+			line: "x 2024-03-02 2024-03-01 Pay bills pri:B id:42 rec:1w",
+			want: func(item *Item) {
+				item.Task.IsCompleted = true
+				item.CompletionDate = date(2024, 3, 2)
+				item.Task.CreationTime = date(2024, 3, 1)
+				item.Task.Name = "Pay bills"
+				item.Priority = "B"
+				item.Task.ID = "42"
+				item.Extra["rec"] = "1w"
+			},
+		},
+		{
+			line: "(a) xylophone lesson +",
+			want: func(item *Item) {
+				item.Task.Name = "(a) xylophone lesson +"
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.line, func(t *testing.T) {
+			want := Item{Projects: []string{}, Extra: map[string]string{}}
+			want.Task.Labels = []string{}
+			tt.want(&want)
+
+			got, err := ParseLine(tt.line)
+			if err != nil {
+				t.Fatalf("error was not nil but '%v'", err)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("expected %+v but found %+v", want, got)
+			}
+		})
+	}
+}
+
+func TestParseLine_Invalid(t *testing.T) {
+	for _, line := range []string{"", "  ", "task due:tomorrow", "x task pri:AB"} {
+		if _, err := ParseLine(line); err == nil {
+			t.Errorf("expected error for '%s'", line)
+		}
+	}
+}
+
+func TestItem_String_RoundTrip(t *testing.T) {
+	for _, line := range []string{
+		"(A) 2024-03-01 Call Mom +Family @phone due:2024-03-05",
+		"x 2024-03-02 2024-03-01 Pay bills +Home due:2024-03-05 pri:B rec:1w id:42",
+		"x Done without dates",
+		"plain task",
+	} {
+		item, err := ParseLine(line)
+		if err != nil {
+			t.Fatalf("error was not nil but '%v'", err)
+		}
+		if item.String() != line {
+			t.Errorf("expected '%s' but found '%s'", line, item.String())
+		}
+	}
+}
+
+func TestItem_String_EscapedName(t *testing.T) {
+	tests := map[string]string{
+		"Vote +1 on PR +Work":      `Vote \+1 on PR \+Work`,
+		"Meet 10:30 @desk":         `Meet \10:30 \@desk`,
+		"x marks the spot":         `\x marks the spot`,
+		"(A) is not a priority":    `\(A) is not a priority`,
+		"2024-03-01 review":        `\2024-03-01 review`,
+		`\\server\share`:           `\\\server\share`,
+		"see https://example.com":  "see https://example.com",
+		"plain x and 2024-03-01 +": "plain x and 2024-03-01 +",
+	}
+	for name, expected := range tests {
+		for _, completed := range []bool{false, true} {
+			item := Item{}
+			item.Task.Name = name
+			item.Task.IsCompleted = completed
+			line := item.String()
+			if strings.TrimPrefix(line, "x ") != expected {
+				t.Errorf("expected '%s' but found '%s'", expected, line)
+			}
+
+			parsed, err := ParseLine(line)
+			if err != nil {
+				t.Fatalf("error was not nil but '%v'", err)
+			}
+			if parsed.Task.Name != name || parsed.Task.IsCompleted != completed || len(parsed.Projects)+len(parsed.Task.Labels)+len(parsed.Extra) != 0 {
+				t.Errorf("expected name '%s' without metadata but found %+v", name, parsed)
+			}
+		}
+	}
+}
+
+func TestItem_String_Tags(t *testing.T) {
+	item := Item{Projects: []string{"Work Stuff"}}
+	item.Task.Name = "multi\nline  name"
+	item.Task.Labels = []string{"at home"}
+
+	if got := item.String(); got != "multi line name +Work_Stuff @at_home" {
+		t.Errorf("unexpected line '%s'", got)
+	}
+}
+
+func TestDecodeEncode(t *testing.T) {
+	input := "(B) first +p\n\n   \nx second\n"
+	items, err := Decode(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("error was not nil but '%v'", err)
+	}
+	if len(items) != 2 {
+		t.Fatalf("expected 2 items but found %d", len(items))
+	}
+
+	var buffer bytes.Buffer
+	if err := Encode(&buffer, items); err != nil {
+		t.Fatalf("error was not nil but '%v'", err)
+	}
+	if buffer.String() != "(B) first +p\nx second\n" {
+		t.Errorf("unexpected output '%s'", buffer.String())
+	}
+
+	if _, err := Decode(strings.NewReader("ok\ndue:x\n")); err == nil || !strings.Contains(err.Error(), "line 2") {
+		t.Errorf("expected error for line 2 but found '%v'", err)
+	}
+}